@@ -0,0 +1,142 @@
+package accessutil
+
+import (
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"strings"
+)
+
+// File names of the vanilla user lists, relative to the server directory.
+const (
+	WhitelistFile     = "whitelist.json"
+	OpsFile           = "ops.json"
+	BannedPlayersFile = "banned-players.json"
+	BannedIPsFile     = "banned-ips.json"
+)
+
+// MatchIP returns the ban covering addr, either for the exact address or
+// for a CIDR range containing it.
+func MatchIP(bans *UserList[IPBan], addr netip.Addr) (IPBan, bool) {
+	addr = addr.Unmap()
+	return bans.find(func(ban IPBan) bool {
+		if strings.Contains(ban.IP, "/") {
+			prefix, err := netip.ParsePrefix(ban.IP)
+			return err == nil && unmapPrefix(prefix).Contains(addr)
+		}
+		banned, err := netip.ParseAddr(ban.IP)
+		return err == nil && banned.Unmap() == addr
+	})
+}
+
+// Lists holds the four vanilla user lists of a server.
+type Lists struct {
+	Whitelist     *UserList[WhitelistEntry]
+	Ops           *UserList[OpEntry]
+	BannedPlayers *UserList[PlayerBan]
+	BannedIPs     *UserList[IPBan]
+	// WhitelistEnabled is whether players must be whitelisted, or opped, to
+	// join, as set by white-list in server.properties.
+	WhitelistEnabled bool
+}
+
+// CreateLists is a factory function for creating a new Lists with every list
+// empty.
+func CreateLists() *Lists {
+	l := new(Lists)
+	l.Whitelist = CreateUserList[WhitelistEntry]()
+	l.Ops = CreateUserList[OpEntry]()
+	l.BannedPlayers = CreateUserList[PlayerBan]()
+	l.BannedIPs = CreateUserList[IPBan]()
+	return l
+}
+
+// LoadLists loads the user lists from a server directory. Lists whose file
+// doesn't exist start out empty.
+func LoadLists(dir string) (*Lists, error) {
+	l := CreateLists()
+	if err := l.Whitelist.LoadFile(filepath.Join(dir, WhitelistFile)); err != nil {
+		return nil, fmt.Errorf("loading %s: %w", WhitelistFile, err)
+	}
+	if err := l.Ops.LoadFile(filepath.Join(dir, OpsFile)); err != nil {
+		return nil, fmt.Errorf("loading %s: %w", OpsFile, err)
+	}
+	if err := l.BannedPlayers.LoadFile(filepath.Join(dir, BannedPlayersFile)); err != nil {
+		return nil, fmt.Errorf("loading %s: %w", BannedPlayersFile, err)
+	}
+	if err := l.BannedIPs.LoadFile(filepath.Join(dir, BannedIPsFile)); err != nil {
+		return nil, fmt.Errorf("loading %s: %w", BannedIPsFile, err)
+	}
+	return l, nil
+}
+
+// Save writes every list to the server directory.
+func (l *Lists) Save(dir string) error {
+	if err := l.Whitelist.SaveFile(filepath.Join(dir, WhitelistFile)); err != nil {
+		return err
+	}
+	if err := l.Ops.SaveFile(filepath.Join(dir, OpsFile)); err != nil {
+		return err
+	}
+	if err := l.BannedPlayers.SaveFile(filepath.Join(dir, BannedPlayersFile)); err != nil {
+		return err
+	}
+	return l.BannedIPs.SaveFile(filepath.Join(dir, BannedIPsFile))
+}
+
+// LoginDeniedError is returned by CheckLogin when a player may not join.
+// Message is the vanilla disconnect message to show them.
+type LoginDeniedError struct {
+	Message string
+	// Ban is the ban that denied the login, or nil if the player wasn't
+	// whitelisted.
+	Ban *BanInfo
+}
+
+func (lde *LoginDeniedError) Error() string {
+	return lde.Message
+}
+
+func banMessage(prefix string, ban BanInfo) string {
+	message := prefix
+	if ban.Reason != "" {
+		message += "\nReason: " + ban.Reason
+	}
+	if !ban.Expires.IsZero() {
+		message += "\nYour ban will be removed on " + ban.Expires.Format(timeLayout)
+	}
+	return message
+}
+
+// CheckLogin decides whether a player may join, checking their bans, the
+// whitelist and then IP bans in the same order vanilla does. It returns a
+// *LoginDeniedError if not. It should be called once the player's UUID is
+// known, after authentication in online mode.
+func (l *Lists) CheckLogin(uuid, name string, addr netip.Addr) error {
+	if ban, ok := l.BannedPlayers.Get(uuid); ok {
+		return &LoginDeniedError{banMessage("You are banned from this server.", ban.BanInfo), &ban.BanInfo}
+	}
+
+	if l.WhitelistEnabled {
+		_, whitelisted := l.Whitelist.Get(uuid)
+		_, opped := l.Ops.Get(uuid)
+		if !whitelisted && !opped {
+			return &LoginDeniedError{"You are not white-listed on this server!", nil}
+		}
+	}
+
+	if ban, ok := MatchIP(l.BannedIPs, addr); ok {
+		return &LoginDeniedError{banMessage("Your IP address is banned from this server.", ban.BanInfo), &ban.BanInfo}
+	}
+	return nil
+}
+
+// OpLevel returns the permission level of a player, or 0 if they aren't an
+// operator.
+func (l *Lists) OpLevel(uuid string) int {
+	op, ok := l.Ops.Get(uuid)
+	if !ok {
+		return 0
+	}
+	return op.Level
+}
@@ -0,0 +1,109 @@
+package accessutil
+
+import (
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AddressFilter decides whether an address may connect at all, before the
+// client has said who it is, which is what connutil.Listener's Verdict asks.
+// Blocks are kept as IP bans, so blocking an address bans it in
+// banned-ips.json and banning one with /ban-ip blocks it. The allowlist,
+// when it isn't empty, admits only the addresses it covers; it is for
+// private servers and is not saved.
+type AddressFilter struct {
+	Bans *UserList[IPBan]
+
+	lock  sync.RWMutex
+	allow []netip.Prefix
+}
+
+// CreateAddressFilter is a factory function for creating a new AddressFilter
+// that blocks with the given bans, such as Lists.BannedIPs.
+func CreateAddressFilter(bans *UserList[IPBan]) *AddressFilter {
+	af := new(AddressFilter)
+	af.Bans = bans
+	return af
+}
+
+// unmapPrefix turns an IPv4-mapped IPv6 range, such as ::ffff:10.0.0.0/104,
+// into the IPv4 range it stands for, masked. Addresses are unmapped before
+// they are matched, so a mapped range would otherwise never cover one.
+func unmapPrefix(prefix netip.Prefix) netip.Prefix {
+	addr := prefix.Addr()
+	if addr.Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(addr.Unmap(), prefix.Bits()-96)
+	}
+	return prefix.Masked()
+}
+
+// banKey writes a prefix as banned-ips.json does: single addresses bare and
+// ranges in CIDR form.
+func banKey(prefix netip.Prefix) string {
+	prefix = unmapPrefix(prefix)
+	if prefix.IsSingleIP() {
+		return prefix.Addr().String()
+	}
+	return prefix.String()
+}
+
+// Block blocks an address range until expires, or for good if it is zero.
+// A single address is a prefix of its full length.
+func (af *AddressFilter) Block(prefix netip.Prefix, source, reason string, expires time.Time) {
+	af.Bans.Add(IPBan{IP: banKey(prefix), BanInfo: CreateBanInfo(source, reason, expires)})
+}
+
+// Unblock lifts the block on exactly this range, reporting whether there was
+// one. Blocks on ranges containing it are kept.
+func (af *AddressFilter) Unblock(prefix netip.Prefix) bool {
+	return af.Bans.Remove(banKey(prefix))
+}
+
+// Allow adds a range to the allowlist.
+func (af *AddressFilter) Allow(prefix netip.Prefix) {
+	af.lock.Lock()
+	defer af.lock.Unlock()
+
+	prefix = unmapPrefix(prefix)
+	if !slices.Contains(af.allow, prefix) {
+		af.allow = append(af.allow, prefix)
+	}
+}
+
+// Disallow removes a range from the allowlist, reporting whether it was on
+// it. Removing the last range admits everybody again.
+func (af *AddressFilter) Disallow(prefix netip.Prefix) bool {
+	af.lock.Lock()
+	defer af.lock.Unlock()
+
+	i := slices.Index(af.allow, unmapPrefix(prefix))
+	if i < 0 {
+		return false
+	}
+	af.allow = slices.Delete(af.allow, i, i+1)
+	return true
+}
+
+// Check reports whether an address may connect: it must be allowlisted if
+// there is an allowlist, and no live block may cover it.
+func (af *AddressFilter) Check(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	af.lock.RLock()
+	allowed := len(af.allow) == 0
+	for _, prefix := range af.allow {
+		if prefix.Contains(addr) {
+			allowed = true
+			break
+		}
+	}
+	af.lock.RUnlock()
+	if !allowed {
+		return false
+	}
+
+	_, blocked := MatchIP(af.Bans, addr)
+	return !blocked
+}
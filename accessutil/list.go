@@ -0,0 +1,286 @@
+package accessutil
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeLayout is the date format vanilla uses for ban times.
+const timeLayout = "2006-01-02 15:04:05 -0700"
+
+// BanTime is a ban timestamp. The zero time is written as "forever", which
+// vanilla uses for bans that never expire.
+type BanTime struct {
+	time.Time
+}
+
+func (bt BanTime) MarshalJSON() ([]byte, error) {
+	if bt.IsZero() {
+		return json.Marshal("forever")
+	}
+	return json.Marshal(bt.Format(timeLayout))
+}
+
+func (bt *BanTime) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" || strings.EqualFold(s, "forever") {
+		bt.Time = time.Time{}
+		return nil
+	}
+	t, err := time.Parse(timeLayout, s)
+	if err != nil {
+		return err
+	}
+	bt.Time = t
+	return nil
+}
+
+// BanInfo holds the fields shared by player and IP bans.
+type BanInfo struct {
+	Created BanTime `json:"created"`
+	Source  string  `json:"source"`
+	Expires BanTime `json:"expires"`
+	Reason  string  `json:"reason"`
+}
+
+// CreateBanInfo is a factory function for creating a new BanInfo made now. A
+// zero expires means the ban is permanent.
+func CreateBanInfo(source, reason string, expires time.Time) BanInfo {
+	return BanInfo{BanTime{time.Now().Truncate(time.Second)}, source, BanTime{expires}, reason}
+}
+
+func (bi BanInfo) expired(now time.Time) bool {
+	return !bi.Expires.IsZero() && now.After(bi.Expires.Time)
+}
+
+// WhitelistEntry is an entry of whitelist.json.
+type WhitelistEntry struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+func (we WhitelistEntry) key() string            { return normaliseUUID(we.UUID) }
+func (we WhitelistEntry) name() string           { return we.Name }
+func (we WhitelistEntry) expired(time.Time) bool { return false }
+
+// OpEntry is an entry of ops.json.
+type OpEntry struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+	// Level is the permission level, from 1 to 4.
+	Level               int  `json:"level"`
+	BypassesPlayerLimit bool `json:"bypassesPlayerLimit"`
+}
+
+func (oe OpEntry) key() string            { return normaliseUUID(oe.UUID) }
+func (oe OpEntry) name() string           { return oe.Name }
+func (oe OpEntry) expired(time.Time) bool { return false }
+
+// PlayerBan is an entry of banned-players.json.
+type PlayerBan struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+	BanInfo
+}
+
+func (pb PlayerBan) key() string  { return normaliseUUID(pb.UUID) }
+func (pb PlayerBan) name() string { return pb.Name }
+
+// IPBan is an entry of banned-ips.json. Besides the single addresses vanilla
+// writes, IP may be a CIDR prefix such as 203.0.113.0/24, which bans the
+// whole range.
+type IPBan struct {
+	IP string `json:"ip"`
+	BanInfo
+}
+
+func (ib IPBan) key() string  { return strings.ToLower(ib.IP) }
+func (ib IPBan) name() string { return "" }
+
+// Entry is implemented by the entry types of the vanilla user lists.
+type Entry interface {
+	key() string
+	name() string
+	expired(now time.Time) bool
+}
+
+// normaliseUUID lower-cases a UUID so lookups don't depend on how it was
+// written.
+func normaliseUUID(uuid string) string {
+	return strings.ToLower(uuid)
+}
+
+// UserList is one of vanilla's JSON user lists, such as whitelist.json.
+// Entries are keyed by UUID, or by address for IP bans. Expired bans are
+// dropped as they're found, as vanilla does. It is safe for concurrent use.
+type UserList[E Entry] struct {
+	lock    sync.RWMutex
+	entries []E
+}
+
+// CreateUserList is a factory function for creating a new, empty UserList.
+func CreateUserList[E Entry]() *UserList[E] {
+	return new(UserList[E])
+}
+
+// Load replaces the list's entries with those read from r.
+func (ul *UserList[E]) Load(r io.Reader) error {
+	var entries []E
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+
+	ul.lock.Lock()
+	defer ul.lock.Unlock()
+	ul.entries = entries
+	return nil
+}
+
+// LoadFile replaces the list's entries with those in the file at path. A
+// missing file leaves the list empty, as it does for a fresh server.
+func (ul *UserList[E]) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		ul.lock.Lock()
+		ul.entries = nil
+		ul.lock.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return ul.Load(file)
+}
+
+// Save writes the list in vanilla's format, skipping expired entries.
+func (ul *UserList[E]) Save(w io.Writer) error {
+	entries := ul.Entries()
+	if entries == nil {
+		entries = []E{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// SaveFile writes the list to the file at path.
+func (ul *UserList[E]) SaveFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := ul.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Entries returns a copy of the list's current entries.
+func (ul *UserList[E]) Entries() []E {
+	ul.lock.Lock()
+	defer ul.lock.Unlock()
+	ul.removeExpired()
+	return append([]E(nil), ul.entries...)
+}
+
+func (ul *UserList[E]) removeExpired() {
+	now := time.Now()
+	live := ul.entries[:0]
+	for _, entry := range ul.entries {
+		if !entry.expired(now) {
+			live = append(live, entry)
+		}
+	}
+	clear(ul.entries[len(live):])
+	ul.entries = live
+}
+
+// Add adds an entry, replacing any with the same key.
+func (ul *UserList[E]) Add(entry E) {
+	ul.lock.Lock()
+	defer ul.lock.Unlock()
+
+	for i, existing := range ul.entries {
+		if existing.key() == entry.key() {
+			ul.entries[i] = entry
+			return
+		}
+	}
+	ul.entries = append(ul.entries, entry)
+}
+
+// Remove removes the entry with the given UUID, or address for IP bans, and
+// reports whether there was one.
+func (ul *UserList[E]) Remove(key string) bool {
+	return ul.removeFunc(func(entry E) bool {
+		return entry.key() == strings.ToLower(key)
+	})
+}
+
+// RemoveName removes the entry for the named player, ignoring case.
+func (ul *UserList[E]) RemoveName(name string) bool {
+	return ul.removeFunc(func(entry E) bool {
+		return entry.name() != "" && strings.EqualFold(entry.name(), name)
+	})
+}
+
+func (ul *UserList[E]) removeFunc(match func(entry E) bool) bool {
+	ul.lock.Lock()
+	defer ul.lock.Unlock()
+
+	for i, entry := range ul.entries {
+		if match(entry) {
+			ul.entries = append(ul.entries[:i], ul.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the entry with the given UUID, or address for IP bans.
+func (ul *UserList[E]) Get(key string) (E, bool) {
+	return ul.find(func(entry E) bool {
+		return entry.key() == strings.ToLower(key)
+	})
+}
+
+// GetName returns the entry for the named player, ignoring case.
+func (ul *UserList[E]) GetName(name string) (E, bool) {
+	return ul.find(func(entry E) bool {
+		return entry.name() != "" && strings.EqualFold(entry.name(), name)
+	})
+}
+
+func (ul *UserList[E]) find(match func(entry E) bool) (E, bool) {
+	ul.lock.Lock()
+	defer ul.lock.Unlock()
+	ul.removeExpired()
+
+	for _, entry := range ul.entries {
+		if match(entry) {
+			return entry, true
+		}
+	}
+	var zero E
+	return zero, false
+}
+
+// Len returns the number of entries, including any that have expired but
+// not yet been dropped.
+func (ul *UserList[E]) Len() int {
+	ul.lock.RLock()
+	defer ul.lock.RUnlock()
+	return len(ul.entries)
+}
@@ -0,0 +1,235 @@
+package accessutil
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UserCacheFile is the file name of the user cache, relative to the server
+// directory.
+const UserCacheFile = "usercache.json"
+
+// Defaults matching vanilla: entries last a month from when they were added,
+// and only the thousand most recently used are saved.
+const (
+	DefaultUserCacheExpiry = 30 * 24 * time.Hour
+	DefaultUserCacheSize   = 1000
+)
+
+type userCacheEntry struct {
+	name      string
+	uuid      string
+	expiresOn time.Time
+	// lastAccess orders entries by when they were last used for saving.
+	lastAccess int64
+}
+
+type userCacheJSON struct {
+	Name      string `json:"name"`
+	UUID      string `json:"uuid"`
+	ExpiresOn string `json:"expiresOn"`
+}
+
+// UserCache maps player names to UUIDs and back, persisted as vanilla's
+// usercache.json. It saves repeated profile lookups for players who have
+// joined before. It is safe for concurrent use.
+type UserCache struct {
+	// Expiry is how long an entry stays valid after it is added.
+	Expiry time.Duration
+	// MaxSaved is how many entries Save writes, keeping the most recently
+	// used.
+	MaxSaved int
+
+	lock      sync.Mutex
+	byName    map[string]*userCacheEntry
+	byUUID    map[string]*userCacheEntry
+	operation int64
+}
+
+// CreateUserCache is a factory function for creating a new, empty UserCache
+// with vanilla's limits.
+func CreateUserCache() *UserCache {
+	uc := new(UserCache)
+	uc.Expiry = DefaultUserCacheExpiry
+	uc.MaxSaved = DefaultUserCacheSize
+	uc.byName = make(map[string]*userCacheEntry)
+	uc.byUUID = make(map[string]*userCacheEntry)
+	return uc
+}
+
+// OfflineUUID returns the UUID vanilla gives a player in offline mode, which
+// is derived from their name alone.
+func OfflineUUID(name string) string {
+	sum := md5.Sum([]byte("OfflinePlayer:" + name))
+	sum[6] = sum[6]&0x0F | 0x30
+	sum[8] = sum[8]&0x3F | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func (uc *UserCache) put(entry *userCacheEntry) {
+	if old, ok := uc.byUUID[entry.uuid]; ok {
+		delete(uc.byName, strings.ToLower(old.name))
+	}
+	if old, ok := uc.byName[strings.ToLower(entry.name)]; ok {
+		delete(uc.byUUID, old.uuid)
+	}
+	uc.operation++
+	entry.lastAccess = uc.operation
+	uc.byName[strings.ToLower(entry.name)] = entry
+	uc.byUUID[entry.uuid] = entry
+}
+
+func (uc *UserCache) remove(entry *userCacheEntry) {
+	delete(uc.byName, strings.ToLower(entry.name))
+	delete(uc.byUUID, entry.uuid)
+}
+
+// get returns a live entry, dropping it if it has expired and marking it
+// used otherwise.
+func (uc *UserCache) get(entry *userCacheEntry, ok bool) (string, string, bool) {
+	if !ok {
+		return "", "", false
+	}
+	if !time.Now().Before(entry.expiresOn) {
+		uc.remove(entry)
+		return "", "", false
+	}
+	uc.operation++
+	entry.lastAccess = uc.operation
+	return entry.name, entry.uuid, true
+}
+
+// Add records a player's name and UUID, replacing any entry for either.
+func (uc *UserCache) Add(name, uuid string) {
+	uc.lock.Lock()
+	defer uc.lock.Unlock()
+	uc.put(&userCacheEntry{name: name, uuid: normaliseUUID(uuid), expiresOn: time.Now().Add(uc.Expiry)})
+}
+
+// GetByName returns the UUID of the named player, ignoring case.
+func (uc *UserCache) GetByName(name string) (string, bool) {
+	uc.lock.Lock()
+	defer uc.lock.Unlock()
+	entry, ok := uc.byName[strings.ToLower(name)]
+	_, uuid, ok := uc.get(entry, ok)
+	return uuid, ok
+}
+
+// GetByUUID returns the name of the player with the given UUID.
+func (uc *UserCache) GetByUUID(uuid string) (string, bool) {
+	uc.lock.Lock()
+	defer uc.lock.Unlock()
+	entry, ok := uc.byUUID[normaliseUUID(uuid)]
+	name, _, ok := uc.get(entry, ok)
+	return name, ok
+}
+
+// Lookup returns the UUID of the named player from the cache, or otherwise
+// from fetch, which would typically ask the Mojang API, caching the result.
+// fetch returns the player's correctly cased name and UUID, or false if no
+// such player exists.
+func (uc *UserCache) Lookup(name string, fetch func(name string) (string, string, bool, error)) (string, bool, error) {
+	if uuid, ok := uc.GetByName(name); ok {
+		return uuid, true, nil
+	}
+
+	properName, uuid, ok, err := fetch(name)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	uc.Add(properName, uuid)
+	return normaliseUUID(uuid), true, nil
+}
+
+// Load replaces the cache's entries with those read from r, skipping any
+// that have expired.
+func (uc *UserCache) Load(r io.Reader) error {
+	var entries []userCacheJSON
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+
+	uc.lock.Lock()
+	defer uc.lock.Unlock()
+	uc.byName = make(map[string]*userCacheEntry)
+	uc.byUUID = make(map[string]*userCacheEntry)
+
+	now := time.Now()
+	// The file lists the most recently used first, so add entries in
+	// reverse to keep that order.
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		expiresOn, err := time.Parse(timeLayout, entry.ExpiresOn)
+		if err != nil {
+			return fmt.Errorf("user cache entry for %s: %w", entry.Name, err)
+		}
+		if entry.Name == "" || entry.UUID == "" || !now.Before(expiresOn) {
+			continue
+		}
+		uc.put(&userCacheEntry{name: entry.Name, uuid: normaliseUUID(entry.UUID), expiresOn: expiresOn})
+	}
+	return nil
+}
+
+// LoadFile replaces the cache's entries with those in the file at path. A
+// missing file leaves the cache empty.
+func (uc *UserCache) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		uc.lock.Lock()
+		uc.byName = make(map[string]*userCacheEntry)
+		uc.byUUID = make(map[string]*userCacheEntry)
+		uc.lock.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return uc.Load(file)
+}
+
+// Save writes the MaxSaved most recently used entries in vanilla's format.
+func (uc *UserCache) Save(w io.Writer) error {
+	uc.lock.Lock()
+	entries := make([]userCacheEntry, 0, len(uc.byUUID))
+	for _, entry := range uc.byUUID {
+		entries = append(entries, *entry)
+	}
+	uc.lock.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastAccess > entries[j].lastAccess
+	})
+	if uc.MaxSaved > 0 && len(entries) > uc.MaxSaved {
+		entries = entries[:uc.MaxSaved]
+	}
+
+	out := make([]userCacheJSON, len(entries))
+	for i, entry := range entries {
+		out[i] = userCacheJSON{entry.name, entry.uuid, entry.expiresOn.Format(timeLayout)}
+	}
+	return json.NewEncoder(w).Encode(out)
+}
+
+// SaveFile writes the cache to the file at path.
+func (uc *UserCache) SaveFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := uc.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
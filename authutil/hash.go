@@ -0,0 +1,47 @@
+package authutil
+
+import (
+	"crypto/sha1"
+	"math/big"
+	"strings"
+)
+
+// ServerHash computes the server ID hash sent to the session server's join
+// and hasJoined endpoints, from the server ID string of Encryption Request
+// (empty since 1.7), the shared secret and the server's encoded public key.
+//
+// The hash is Java's BigInteger.toString(16) of the SHA-1 digest, so it is a
+// signed two's complement number: digests with the top bit set come out
+// negative, and leading zeros are dropped.
+func ServerHash(serverID string, sharedSecret, publicKey []byte) string {
+	h := sha1.New()
+	h.Write([]byte(serverID))
+	h.Write(sharedSecret)
+	h.Write(publicKey)
+	digest := h.Sum(nil)
+
+	negative := digest[0]&0x80 != 0
+	if negative {
+		// Two's complement: invert and add one.
+		carry := true
+		for i := len(digest) - 1; i >= 0; i-- {
+			digest[i] = ^digest[i]
+			if carry {
+				digest[i]++
+				carry = digest[i] == 0
+			}
+		}
+	}
+
+	hash := new(big.Int).SetBytes(digest).Text(16)
+	if negative {
+		return "-" + hash
+	}
+	return hash
+}
+
+// undashed returns a UUID without hyphens and in lower case, the form the
+// session server uses.
+func undashed(uuid string) string {
+	return strings.ToLower(strings.ReplaceAll(uuid, "-", ""))
+}
@@ -0,0 +1,236 @@
+package authutil
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Paths of the session server endpoints, relative to
+// https://sessionserver.mojang.com.
+const (
+	JoinPath      = "/session/minecraft/join"
+	HasJoinedPath = "/session/minecraft/hasJoined"
+	ProfilePath   = "/session/minecraft/profile/"
+)
+
+// DefaultJoinExpiry is how long a join stays valid for hasJoined. The real
+// session server doesn't document its window; servers normally check within
+// a second of the client joining.
+const DefaultJoinExpiry = 30 * time.Second
+
+// Property is a profile property, such as the player's textures.
+type Property struct {
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Profile is a game profile as returned by the session server, with an
+// undashed UUID.
+type Profile struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Properties []Property `json:"properties"`
+}
+
+type sessionError struct {
+	Error        string `json:"error"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+type account struct {
+	profile     Profile
+	accessToken string
+}
+
+type join struct {
+	uuid string
+	ip   string
+	at   time.Time
+}
+
+// SessionServer emulates the Mojang session server for local tests of the
+// online-mode login flow. It serves join for the client side and hasJoined
+// and profile for the server side, for the accounts added to it. Mount it
+// with httptest.NewServer and point both sides at its URL.
+type SessionServer struct {
+	// JoinExpiry is how long after joining hasJoined still succeeds.
+	JoinExpiry time.Duration
+	// SigningKey, when set, signs profile properties the way Mojang's key
+	// does, so servers that verify textures can be tested. PublicKey gives
+	// the key to verify against.
+	SigningKey *rsa.PrivateKey
+	// Fault is called before every request. A non-zero status is sent back
+	// instead of handling the request, to test error paths and outages.
+	Fault func(r *http.Request) int
+
+	lock     sync.Mutex
+	accounts map[string]*account
+	joins    map[string]join
+}
+
+// CreateSessionServer is a factory function for creating a new
+// SessionServer with no accounts.
+func CreateSessionServer() *SessionServer {
+	ss := new(SessionServer)
+	ss.JoinExpiry = DefaultJoinExpiry
+	ss.accounts = make(map[string]*account)
+	ss.joins = make(map[string]join)
+	return ss
+}
+
+// GenerateSigningKey sets a fresh RSA key for signing properties.
+func (ss *SessionServer) GenerateSigningKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	ss.SigningKey = key
+	return nil
+}
+
+// PublicKey returns the public half of SigningKey, or nil if there is none.
+func (ss *SessionServer) PublicKey() *rsa.PublicKey {
+	if ss.SigningKey == nil {
+		return nil
+	}
+	return &ss.SigningKey.PublicKey
+}
+
+// AddAccount adds an account the client side can join with, replacing any
+// with the same UUID.
+func (ss *SessionServer) AddAccount(name, uuid, accessToken string, properties ...Property) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	ss.accounts[undashed(uuid)] = &account{Profile{undashed(uuid), name, properties}, accessToken}
+}
+
+func joinKey(name, serverHash string) string {
+	return strings.ToLower(name) + "\x00" + serverHash
+}
+
+// Join records a join as the join endpoint does, checking the access token.
+// ip is the client's address, which hasJoined may be asked to match.
+func (ss *SessionServer) Join(accessToken, uuid, serverHash, ip string) error {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	acc, ok := ss.accounts[undashed(uuid)]
+	if !ok || acc.accessToken != accessToken {
+		return fmt.Errorf("invalid token")
+	}
+	ss.joins[joinKey(acc.profile.Name, serverHash)] = join{acc.profile.ID, ip, time.Now()}
+	return nil
+}
+
+// HasJoined returns the profile of a player who joined with the given server
+// hash, as the hasJoined endpoint does. An empty ip skips the address check.
+func (ss *SessionServer) HasJoined(name, serverHash, ip string) (Profile, bool) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	key := joinKey(name, serverHash)
+	j, ok := ss.joins[key]
+	if !ok || time.Since(j.at) > ss.JoinExpiry || (ip != "" && ip != j.ip) {
+		return Profile{}, false
+	}
+	// A join can only be checked once.
+	delete(ss.joins, key)
+
+	acc, ok := ss.accounts[j.uuid]
+	if !ok {
+		return Profile{}, false
+	}
+	return ss.signed(acc.profile), true
+}
+
+// signed returns a copy of the profile with its properties signed, if there
+// is a signing key.
+func (ss *SessionServer) signed(profile Profile) Profile {
+	properties := make([]Property, len(profile.Properties))
+	copy(properties, profile.Properties)
+	profile.Properties = properties
+	if ss.SigningKey == nil {
+		return profile
+	}
+
+	for i := range properties {
+		digest := sha1.Sum([]byte(properties[i].Value))
+		sig, err := rsa.SignPKCS1v15(nil, ss.SigningKey, crypto.SHA1, digest[:])
+		if err == nil {
+			properties[i].Signature = base64.StdEncoding.EncodeToString(sig)
+		}
+	}
+	return profile
+}
+
+func writeJSON(w http.ResponseWriter, status int, val any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(val)
+}
+
+func (ss *SessionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if ss.Fault != nil {
+		if status := ss.Fault(r); status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+	}
+
+	switch {
+	case r.URL.Path == JoinPath && r.Method == http.MethodPost:
+		var body struct {
+			AccessToken     string `json:"accessToken"`
+			SelectedProfile string `json:"selectedProfile"`
+			ServerID        string `json:"serverId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, sessionError{"IllegalArgumentException", err.Error()})
+			return
+		}
+		ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+		if err := ss.Join(body.AccessToken, body.SelectedProfile, body.ServerID, ip); err != nil {
+			writeJSON(w, http.StatusForbidden, sessionError{"ForbiddenOperationException", "Invalid token"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.URL.Path == HasJoinedPath && r.Method == http.MethodGet:
+		query := r.URL.Query()
+		profile, ok := ss.HasJoined(query.Get("username"), query.Get("serverId"), query.Get("ip"))
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, http.StatusOK, profile)
+
+	case strings.HasPrefix(r.URL.Path, ProfilePath) && r.Method == http.MethodGet:
+		uuid := undashed(strings.TrimPrefix(r.URL.Path, ProfilePath))
+		ss.lock.Lock()
+		acc, ok := ss.accounts[uuid]
+		ss.lock.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		profile := acc.profile
+		if r.URL.Query().Get("unsigned") == "false" {
+			profile = ss.signed(profile)
+		}
+		writeJSON(w, http.StatusOK, profile)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
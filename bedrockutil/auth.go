@@ -0,0 +1,238 @@
+package bedrockutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// MojangRootKey is the public key, base64 encoded DER, that signs the
+// certificate chain of players authenticated with Xbox Live.
+const MojangRootKey = "MHYwEAYHKoZIzj0CAQYFK4EEACIDYgAECRXueJeTDqNRRgJi/vlRufByu/2G0i2Ebt6YMar5QX/R0DIIyrJMcUpruK4QveTfJSTp3Shlq4Gk34cD/4GUWwkv0DVuzeuB+tXija7HBxii03NHDbPAD0AKnLr2wdAp"
+
+// clockSkew is how far a token's validity window is stretched, to allow
+// for clocks that disagree.
+const clockSkew = time.Minute
+
+// Identity is who a player is, according to their certificate chain.
+type Identity struct {
+	XUID        string
+	DisplayName string
+	UUID        string
+	TitleID     string
+	// PublicKey is the player's own key, which signs their client data and
+	// the key exchange for encryption.
+	PublicKey *ecdsa.PublicKey
+	// Authenticated is set when Mojang's root key signed the chain. A
+	// player who isn't logged in to Xbox Live sends a chain only they
+	// signed, whose identity can't be trusted.
+	Authenticated bool
+}
+
+// ClientData is what the client says about itself, signed with the key at
+// the end of its chain.
+type ClientData struct {
+	GameVersion     string `json:"GameVersion"`
+	DeviceOS        int    `json:"DeviceOS"`
+	DeviceModel     string `json:"DeviceModel"`
+	LanguageCode    string `json:"LanguageCode"`
+	ServerAddress   string `json:"ServerAddress"`
+	ThirdPartyName  string `json:"ThirdPartyName"`
+	SkinID          string `json:"SkinId"`
+	SkinData        []byte `json:"SkinData"`
+	SkinImageWidth  int    `json:"SkinImageWidth"`
+	SkinImageHeight int    `json:"SkinImageHeight"`
+	// Raw holds every claim, for the many this doesn't name.
+	Raw map[string]any `json:"-"`
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	X5U string `json:"x5u"`
+}
+
+type chainClaims struct {
+	IdentityPublicKey string `json:"identityPublicKey"`
+	NotBefore         int64  `json:"nbf"`
+	Expires           int64  `json:"exp"`
+	ExtraData         *struct {
+		XUID        string `json:"XUID"`
+		DisplayName string `json:"displayName"`
+		Identity    string `json:"identity"`
+		TitleID     string `json:"titleId"`
+	} `json:"extraData"`
+}
+
+func parseKey(encoded string) (*ecdsa.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("key is not an ECDSA key")
+	}
+	return ecKey, nil
+}
+
+// verifyToken checks a JWT's ES384 signature with key, or with the key in
+// its own header if key is nil, returning the key and the decoded claims.
+func verifyToken(token string, key *ecdsa.PublicKey) (*ecdsa.PublicKey, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("token has %d parts rather than 3", len(parts))
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not decode token header: %w", err)
+	}
+	var header tokenHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return nil, nil, fmt.Errorf("could not decode token header: %w", err)
+	}
+	if header.Alg != "ES384" {
+		return nil, nil, fmt.Errorf("token is signed with %q rather than ES384", header.Alg)
+	}
+	if key == nil {
+		if key, err = parseKey(header.X5U); err != nil {
+			return nil, nil, fmt.Errorf("could not parse token key: %w", err)
+		}
+	}
+
+	// JWTs carry the two halves of the signature side by side, rather than
+	// in ASN.1 like crypto/ecdsa expects.
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 96 {
+		return nil, nil, fmt.Errorf("token signature is malformed")
+	}
+	r := new(big.Int).SetBytes(signature[:48])
+	s := new(big.Int).SetBytes(signature[48:])
+	digest := sha512.Sum384([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return nil, nil, fmt.Errorf("token signature doesn't match")
+	}
+
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not decode token claims: %w", err)
+	}
+	return key, claims, nil
+}
+
+// VerifyChain checks the certificate chain of a Login packet and returns the
+// identity at its end. Each token is signed by the key the one before it
+// names, and the first by the key in its own header.
+func VerifyChain(chain []byte, now time.Time) (Identity, error) {
+	var wrapper struct {
+		Chain []string `json:"chain"`
+	}
+	if err := json.Unmarshal(chain, &wrapper); err != nil {
+		return Identity{}, fmt.Errorf("could not decode certificate chain: %w", err)
+	}
+	if len(wrapper.Chain) == 0 || len(wrapper.Chain) > 3 {
+		return Identity{}, fmt.Errorf("certificate chain has %d tokens", len(wrapper.Chain))
+	}
+
+	// Once the root key has signed a token, every later one is vouched for
+	// by it. Identities claimed before then, in tokens the player signed
+	// themselves, only count if the root key never appears.
+	var id Identity
+	var key *ecdsa.PublicKey
+	var claimed, vouched *chainClaims
+	for i, token := range wrapper.Chain {
+		signer, rawClaims, err := verifyToken(token, key)
+		if err != nil {
+			return Identity{}, fmt.Errorf("token %d of chain: %w", i, err)
+		}
+		if signer.Equal(mojangRootKey) {
+			id.Authenticated = true
+		}
+
+		claims := new(chainClaims)
+		if err := json.Unmarshal(rawClaims, claims); err != nil {
+			return Identity{}, fmt.Errorf("token %d of chain: could not decode claims: %w", i, err)
+		}
+		if claims.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+			return Identity{}, fmt.Errorf("token %d of chain isn't valid yet", i)
+		}
+		if claims.Expires != 0 && now.Add(-clockSkew).After(time.Unix(claims.Expires, 0)) {
+			return Identity{}, fmt.Errorf("token %d of chain has expired", i)
+		}
+		if key, err = parseKey(claims.IdentityPublicKey); err != nil {
+			return Identity{}, fmt.Errorf("token %d of chain: could not parse identity key: %w", i, err)
+		}
+		if claims.ExtraData != nil {
+			claimed = claims
+			if id.Authenticated {
+				vouched = claims
+			}
+		}
+	}
+
+	if id.Authenticated {
+		claimed = vouched
+	}
+	if claimed == nil || claimed.ExtraData.DisplayName == "" {
+		return Identity{}, fmt.Errorf("certificate chain names no player")
+	}
+	id.DisplayName = claimed.ExtraData.DisplayName
+	id.UUID = claimed.ExtraData.Identity
+	id.TitleID = claimed.ExtraData.TitleID
+	// Only the root key's signature vouches for the XUID.
+	if id.Authenticated {
+		id.XUID = claimed.ExtraData.XUID
+	}
+	id.PublicKey = key
+	return id, nil
+}
+
+var mojangRootKey = func() *ecdsa.PublicKey {
+	key, err := parseKey(MojangRootKey)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// VerifyClientData checks the client data token of a Login packet against
+// the player's key and decodes it.
+func VerifyClientData(token []byte, key *ecdsa.PublicKey) (ClientData, error) {
+	_, claims, err := verifyToken(string(token), key)
+	if err != nil {
+		return ClientData{}, fmt.Errorf("client data: %w", err)
+	}
+
+	var data ClientData
+	if err := json.Unmarshal(claims, &data); err != nil {
+		return ClientData{}, fmt.Errorf("could not decode client data: %w", err)
+	}
+	if err := json.Unmarshal(claims, &data.Raw); err != nil {
+		return ClientData{}, fmt.Errorf("could not decode client data: %w", err)
+	}
+	return data, nil
+}
+
+// VerifyLogin checks both halves of a Login packet. Players who aren't
+// logged in to Xbox Live pass with Authenticated unset; servers in online
+// mode should turn them away.
+func VerifyLogin(p *Login, now time.Time) (Identity, ClientData, error) {
+	id, err := VerifyChain(p.Chain, now)
+	if err != nil {
+		return Identity{}, ClientData{}, err
+	}
+	data, err := VerifyClientData(p.ClientData, id.PublicKey)
+	if err != nil {
+		return Identity{}, ClientData{}, err
+	}
+	return id, data, nil
+}
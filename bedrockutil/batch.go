@@ -0,0 +1,156 @@
+package bedrockutil
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/PurpurProject/elytra/connutil"
+)
+
+// BatchHeader starts every batch, which is how game packets are carried by
+// the transport.
+const BatchHeader = 0xFE
+
+// maxBatchSize caps how large a batch may decompress to.
+const maxBatchSize = 1 << 24
+
+// Compression is an algorithm batches are compressed with.
+type Compression uint16
+
+const (
+	CompressionFlate  Compression = 0
+	CompressionSnappy Compression = 1
+	// CompressionNone only appears in batch headers, for batches below the
+	// threshold.
+	CompressionNone Compression = 0xFF
+)
+
+// BatchSettings are what Network Settings negotiated. The zero value is what
+// applies before then: batches carry no compression byte.
+type BatchSettings struct {
+	Compressed bool
+	Algorithm  Compression
+	// Threshold is the smallest batch that is compressed.
+	Threshold uint16
+}
+
+// Header prefixes every packet in a batch. Split screen players share a
+// connection, so it names which one sent a packet and which it is for.
+type Header struct {
+	PacketID    uint32
+	SenderSubID uint8
+	TargetSubID uint8
+}
+
+func (h Header) encode() uint32 {
+	return h.PacketID&0x3FF | uint32(h.SenderSubID&3)<<10 | uint32(h.TargetSubID&3)<<12
+}
+
+// EncodePacket returns a packet with its header, ready to be batched.
+func EncodePacket(p Packet) []byte {
+	w := CreateWriter()
+	w.WriteVarUint32(Header{PacketID: p.ID()}.encode())
+	p.Marshal(w)
+	return w.Bytes()
+}
+
+// DecodePacket splits a packet from a batch into its header and a Reader
+// over its body.
+func DecodePacket(data []byte) (Header, *Reader, error) {
+	r := CreateReader(data)
+	raw, err := r.ReadVarUint32()
+	if err != nil {
+		return Header{}, nil, fmt.Errorf("could not read packet header: %w", err)
+	}
+	h := Header{
+		PacketID:    raw & 0x3FF,
+		SenderSubID: uint8(raw >> 10 & 3),
+		TargetSubID: uint8(raw >> 12 & 3),
+	}
+	return h, r, nil
+}
+
+// EncodeBatch wraps packets, as returned by EncodePacket, in a batch.
+func EncodeBatch(bs BatchSettings, packets ...[]byte) ([]byte, error) {
+	w := CreateWriter()
+	for _, p := range packets {
+		w.WriteByteSlice(p)
+	}
+	payload := w.Bytes()
+
+	batch := []byte{BatchHeader}
+	if !bs.Compressed {
+		return append(batch, payload...), nil
+	}
+	if len(payload) < int(bs.Threshold) {
+		return append(append(batch, byte(CompressionNone)), payload...), nil
+	}
+
+	batch = append(batch, byte(bs.Algorithm))
+	switch bs.Algorithm {
+	case CompressionFlate:
+		buf := bytes.NewBuffer(batch)
+		deflater, _ := flate.NewWriter(buf, flate.DefaultCompression)
+		deflater.Write(payload)
+		if err := deflater.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionSnappy:
+		return connutil.Snappy.Compress(batch, payload), nil
+	}
+	return nil, fmt.Errorf("unknown compression algorithm %d", bs.Algorithm)
+}
+
+// DecodeBatch unwraps a batch into its packets, each still with its header.
+func DecodeBatch(bs BatchSettings, batch []byte) ([][]byte, error) {
+	if len(batch) == 0 || batch[0] != BatchHeader {
+		return nil, fmt.Errorf("batch doesn't start with 0x%02X", BatchHeader)
+	}
+	payload := batch[1:]
+
+	if bs.Compressed {
+		if len(payload) == 0 {
+			return nil, fmt.Errorf("batch is missing its compression algorithm")
+		}
+		algorithm := Compression(payload[0])
+		payload = payload[1:]
+
+		var err error
+		switch algorithm {
+		case CompressionNone:
+		case CompressionFlate:
+			inflater := flate.NewReader(bytes.NewReader(payload))
+			payload, err = io.ReadAll(io.LimitReader(inflater, maxBatchSize+1))
+			inflater.Close()
+		case CompressionSnappy:
+			size, n := binary.Uvarint(payload)
+			if n <= 0 || size > maxBatchSize {
+				return nil, fmt.Errorf("snappy batch length is out of range")
+			}
+			payload, err = connutil.Snappy.Decompress(payload, int(size))
+		default:
+			return nil, fmt.Errorf("unknown compression algorithm %d", algorithm)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress batch: %w", err)
+		}
+		if len(payload) > maxBatchSize {
+			return nil, fmt.Errorf("batch decompresses to over %d bytes", maxBatchSize)
+		}
+	}
+
+	var packets [][]byte
+	r := CreateReader(payload)
+	for r.Len() > 0 {
+		p, err := r.ReadByteSlice(maxBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("could not read packet %d of batch: %w", len(packets), err)
+		}
+		packets = append(packets, p)
+	}
+	return packets, nil
+}
@@ -0,0 +1,246 @@
+// Package bedrockutil encodes the game packet layer of Bedrock Edition: the
+// batch wrapper that carries packets over a transport such as RakNet, the
+// encoding Bedrock uses for primitive types, and codecs for the packets
+// needed to log in, negotiate resource packs and move.
+//
+// Bedrock differs from Java Edition in its primitives: integers are mostly
+// little endian, strings and lengths are prefixed with unsigned VarInts, and
+// signed VarInts are zigzag encoded.
+package bedrockutil
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// maxStringLength caps strings read from a packet.
+const maxStringLength = 1 << 20
+
+// Writer builds the body of a Bedrock packet.
+type Writer struct {
+	data []byte
+}
+
+// CreateWriter is a factory function for creating a new, empty Writer.
+func CreateWriter() *Writer {
+	return new(Writer)
+}
+
+// Bytes returns what has been written.
+func (w *Writer) Bytes() []byte {
+	return w.data
+}
+
+func (w *Writer) WriteBool(val bool) {
+	if val {
+		w.data = append(w.data, 1)
+	} else {
+		w.data = append(w.data, 0)
+	}
+}
+
+func (w *Writer) WriteUint8(val uint8) {
+	w.data = append(w.data, val)
+}
+
+func (w *Writer) WriteUint16(val uint16) {
+	w.data = binary.LittleEndian.AppendUint16(w.data, val)
+}
+
+func (w *Writer) WriteInt32(val int32) {
+	w.data = binary.LittleEndian.AppendUint32(w.data, uint32(val))
+}
+
+// WriteBEInt32 writes a big endian int32, which a few packets still use.
+func (w *Writer) WriteBEInt32(val int32) {
+	w.data = binary.BigEndian.AppendUint32(w.data, uint32(val))
+}
+
+func (w *Writer) WriteInt64(val int64) {
+	w.data = binary.LittleEndian.AppendUint64(w.data, uint64(val))
+}
+
+func (w *Writer) WriteFloat32(val float32) {
+	w.data = binary.LittleEndian.AppendUint32(w.data, math.Float32bits(val))
+}
+
+func (w *Writer) WriteVarUint32(val uint32) {
+	w.data = binary.AppendUvarint(w.data, uint64(val))
+}
+
+func (w *Writer) WriteVarUint64(val uint64) {
+	w.data = binary.AppendUvarint(w.data, val)
+}
+
+// WriteVarInt32 writes a zigzag encoded VarInt.
+func (w *Writer) WriteVarInt32(val int32) {
+	w.data = binary.AppendVarint(w.data, int64(val))
+}
+
+// WriteVarInt64 writes a zigzag encoded VarLong.
+func (w *Writer) WriteVarInt64(val int64) {
+	w.data = binary.AppendVarint(w.data, val)
+}
+
+func (w *Writer) WriteString(val string) {
+	w.WriteVarUint32(uint32(len(val)))
+	w.data = append(w.data, val...)
+}
+
+// WriteByteSlice writes bytes prefixed with their length.
+func (w *Writer) WriteByteSlice(val []byte) {
+	w.WriteVarUint32(uint32(len(val)))
+	w.data = append(w.data, val...)
+}
+
+// WriteBytes writes bytes as they are.
+func (w *Writer) WriteBytes(val []byte) {
+	w.data = append(w.data, val...)
+}
+
+// Reader reads the body of a Bedrock packet.
+type Reader struct {
+	data   []byte
+	offset int
+}
+
+// CreateReader is a factory function for creating a new Reader over data.
+func CreateReader(data []byte) *Reader {
+	r := new(Reader)
+	r.data = data
+	return r
+}
+
+// Len returns how many bytes are left.
+func (r *Reader) Len() int {
+	return len(r.data) - r.offset
+}
+
+func (r *Reader) take(n int) ([]byte, error) {
+	if n < 0 || n > r.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.data[r.offset : r.offset+n]
+	r.offset += n
+	return b, nil
+}
+
+func (r *Reader) ReadBool() (bool, error) {
+	b, err := r.ReadUint8()
+	return b != 0, err
+}
+
+func (r *Reader) ReadUint8() (uint8, error) {
+	b, err := r.take(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *Reader) ReadUint16() (uint16, error) {
+	b, err := r.take(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(b), nil
+}
+
+func (r *Reader) ReadInt32() (int32, error) {
+	b, err := r.take(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.LittleEndian.Uint32(b)), nil
+}
+
+// ReadBEInt32 reads a big endian int32.
+func (r *Reader) ReadBEInt32() (int32, error) {
+	b, err := r.take(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+func (r *Reader) ReadInt64() (int64, error) {
+	b, err := r.take(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(b)), nil
+}
+
+func (r *Reader) ReadFloat32() (float32, error) {
+	b, err := r.take(4)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+}
+
+func (r *Reader) ReadVarUint32() (uint32, error) {
+	val, n := binary.Uvarint(r.data[r.offset:])
+	if n <= 0 || n > 5 || val > math.MaxUint32 {
+		return 0, fmt.Errorf("varint was malformed or over five bytes")
+	}
+	r.offset += n
+	return uint32(val), nil
+}
+
+func (r *Reader) ReadVarUint64() (uint64, error) {
+	val, n := binary.Uvarint(r.data[r.offset:])
+	if n <= 0 {
+		return 0, fmt.Errorf("varlong was malformed or over ten bytes")
+	}
+	r.offset += n
+	return val, nil
+}
+
+// ReadVarInt32 reads a zigzag encoded VarInt.
+func (r *Reader) ReadVarInt32() (int32, error) {
+	val, n := binary.Varint(r.data[r.offset:])
+	if n <= 0 || n > 5 || val < math.MinInt32 || val > math.MaxInt32 {
+		return 0, fmt.Errorf("varint was malformed or over five bytes")
+	}
+	r.offset += n
+	return int32(val), nil
+}
+
+// ReadVarInt64 reads a zigzag encoded VarLong.
+func (r *Reader) ReadVarInt64() (int64, error) {
+	val, n := binary.Varint(r.data[r.offset:])
+	if n <= 0 {
+		return 0, fmt.Errorf("varlong was malformed or over ten bytes")
+	}
+	r.offset += n
+	return val, nil
+}
+
+func (r *Reader) ReadString() (string, error) {
+	b, err := r.readPrefixed(maxStringLength)
+	return string(b), err
+}
+
+// ReadByteSlice reads bytes prefixed with their length, up to limit bytes.
+func (r *Reader) ReadByteSlice(limit int) ([]byte, error) {
+	return r.readPrefixed(limit)
+}
+
+func (r *Reader) readPrefixed(limit int) ([]byte, error) {
+	n, err := r.ReadVarUint32()
+	if err != nil {
+		return nil, err
+	}
+	if int64(n) > int64(limit) {
+		return nil, fmt.Errorf("length of %d is over the limit of %d", n, limit)
+	}
+	return r.take(int(n))
+}
+
+// ReadBytes reads n bytes as they are.
+func (r *Reader) ReadBytes(n int) ([]byte, error) {
+	return r.take(n)
+}
@@ -0,0 +1,434 @@
+package bedrockutil
+
+import (
+	"fmt"
+)
+
+// Protocol is the Bedrock protocol version the codecs follow, 1.21.50.
+// Packet layouts change between releases, so other versions may differ.
+const Protocol = 766
+
+// Packet IDs.
+const (
+	IDLogin                      = 0x01
+	IDPlayStatus                 = 0x02
+	IDResourcePackStack          = 0x07
+	IDResourcePackClientResponse = 0x08
+	IDMovePlayer                 = 0x13
+	IDNetworkSettings            = 0x8F
+	IDRequestNetworkSettings     = 0xC1
+)
+
+// maxLoginSize caps the login payload, which holds a certificate chain and
+// the client's skin.
+const maxLoginSize = 1 << 22
+
+// Packet is a game packet that can be encoded and decoded.
+type Packet interface {
+	ID() uint32
+	Marshal(w *Writer)
+	Unmarshal(r *Reader) error
+}
+
+// DecodeBody reads the body of a packet whose header has been read.
+func DecodeBody(h Header, r *Reader) (Packet, error) {
+	var p Packet
+	switch h.PacketID {
+	case IDLogin:
+		p = new(Login)
+	case IDPlayStatus:
+		p = new(PlayStatus)
+	case IDResourcePackStack:
+		p = new(ResourcePackStack)
+	case IDResourcePackClientResponse:
+		p = new(ResourcePackClientResponse)
+	case IDMovePlayer:
+		p = new(MovePlayer)
+	case IDNetworkSettings:
+		p = new(NetworkSettings)
+	case IDRequestNetworkSettings:
+		p = new(RequestNetworkSettings)
+	default:
+		return nil, fmt.Errorf("no codec for packet 0x%02X", h.PacketID)
+	}
+	if err := p.Unmarshal(r); err != nil {
+		return nil, fmt.Errorf("could not read packet 0x%02X: %w", h.PacketID, err)
+	}
+	return p, nil
+}
+
+// RequestNetworkSettings is the first packet a client sends, before any
+// compression.
+type RequestNetworkSettings struct {
+	ClientProtocol int32
+}
+
+func (*RequestNetworkSettings) ID() uint32 { return IDRequestNetworkSettings }
+
+func (p *RequestNetworkSettings) Marshal(w *Writer) {
+	w.WriteBEInt32(p.ClientProtocol)
+}
+
+func (p *RequestNetworkSettings) Unmarshal(r *Reader) (err error) {
+	p.ClientProtocol, err = r.ReadBEInt32()
+	return err
+}
+
+// NetworkSettings answers RequestNetworkSettings. Batches after it are
+// compressed as it says.
+type NetworkSettings struct {
+	CompressionThreshold    uint16
+	CompressionAlgorithm    Compression
+	ClientThrottle          bool
+	ClientThrottleThreshold uint8
+	ClientThrottleScalar    float32
+}
+
+func (*NetworkSettings) ID() uint32 { return IDNetworkSettings }
+
+func (p *NetworkSettings) Marshal(w *Writer) {
+	w.WriteUint16(p.CompressionThreshold)
+	w.WriteUint16(uint16(p.CompressionAlgorithm))
+	w.WriteBool(p.ClientThrottle)
+	w.WriteUint8(p.ClientThrottleThreshold)
+	w.WriteFloat32(p.ClientThrottleScalar)
+}
+
+func (p *NetworkSettings) Unmarshal(r *Reader) error {
+	var err error
+	if p.CompressionThreshold, err = r.ReadUint16(); err != nil {
+		return err
+	}
+	algorithm, err := r.ReadUint16()
+	if err != nil {
+		return err
+	}
+	p.CompressionAlgorithm = Compression(algorithm)
+	if p.ClientThrottle, err = r.ReadBool(); err != nil {
+		return err
+	}
+	if p.ClientThrottleThreshold, err = r.ReadUint8(); err != nil {
+		return err
+	}
+	p.ClientThrottleScalar, err = r.ReadFloat32()
+	return err
+}
+
+// Settings returns the batch settings the packet puts in place.
+func (p *NetworkSettings) Settings() BatchSettings {
+	return BatchSettings{Compressed: true, Algorithm: p.CompressionAlgorithm, Threshold: p.CompressionThreshold}
+}
+
+// Login carries the player's identity: a chain of JWTs that end in the
+// player's key, and a JWT of client data such as the skin, signed by it.
+type Login struct {
+	ClientProtocol int32
+	// Chain is the JSON object holding the certificate chain.
+	Chain []byte
+	// ClientData is the client data JWT.
+	ClientData []byte
+}
+
+func (*Login) ID() uint32 { return IDLogin }
+
+func (p *Login) Marshal(w *Writer) {
+	w.WriteBEInt32(p.ClientProtocol)
+	request := CreateWriter()
+	request.WriteInt32(int32(len(p.Chain)))
+	request.WriteBytes(p.Chain)
+	request.WriteInt32(int32(len(p.ClientData)))
+	request.WriteBytes(p.ClientData)
+	w.WriteByteSlice(request.Bytes())
+}
+
+func (p *Login) Unmarshal(r *Reader) error {
+	var err error
+	if p.ClientProtocol, err = r.ReadBEInt32(); err != nil {
+		return err
+	}
+	data, err := r.ReadByteSlice(maxLoginSize)
+	if err != nil {
+		return err
+	}
+	request := CreateReader(data)
+	if p.Chain, err = readInt32Prefixed(request); err != nil {
+		return fmt.Errorf("could not read certificate chain: %w", err)
+	}
+	if p.ClientData, err = readInt32Prefixed(request); err != nil {
+		return fmt.Errorf("could not read client data: %w", err)
+	}
+	return nil
+}
+
+func readInt32Prefixed(r *Reader) ([]byte, error) {
+	n, err := r.ReadInt32()
+	if err != nil {
+		return nil, err
+	}
+	return r.ReadBytes(int(n))
+}
+
+// PlayStatus statuses.
+const (
+	LoginSuccess int32 = iota
+	LoginFailedClient
+	LoginFailedServer
+	PlayerSpawn
+	LoginFailedInvalidTenant
+	LoginFailedVanillaEdu
+	LoginFailedEduVanilla
+	LoginFailedServerFull
+	LoginFailedEditorVanilla
+	LoginFailedVanillaEditor
+)
+
+// PlayStatus tells the client how login went, or that it may spawn.
+type PlayStatus struct {
+	Status int32
+}
+
+func (*PlayStatus) ID() uint32 { return IDPlayStatus }
+
+func (p *PlayStatus) Marshal(w *Writer) {
+	w.WriteBEInt32(p.Status)
+}
+
+func (p *PlayStatus) Unmarshal(r *Reader) (err error) {
+	p.Status, err = r.ReadBEInt32()
+	return err
+}
+
+// StackPack is a pack in a ResourcePackStack.
+type StackPack struct {
+	UUID        string
+	Version     string
+	SubPackName string
+}
+
+// Experiment is an experimental toggle a world needs.
+type Experiment struct {
+	Name    string
+	Enabled bool
+}
+
+// ResourcePackStack tells the client which packs to apply, in order, once
+// it has them all.
+type ResourcePackStack struct {
+	TexturePackRequired          bool
+	BehaviourPacks               []StackPack
+	TexturePacks                 []StackPack
+	BaseGameVersion              string
+	Experiments                  []Experiment
+	ExperimentsPreviouslyToggled bool
+	IncludeEditorPacks           bool
+}
+
+func (*ResourcePackStack) ID() uint32 { return IDResourcePackStack }
+
+func writeStackPacks(w *Writer, packs []StackPack) {
+	w.WriteVarUint32(uint32(len(packs)))
+	for _, pack := range packs {
+		w.WriteString(pack.UUID)
+		w.WriteString(pack.Version)
+		w.WriteString(pack.SubPackName)
+	}
+}
+
+func (p *ResourcePackStack) Marshal(w *Writer) {
+	w.WriteBool(p.TexturePackRequired)
+	writeStackPacks(w, p.BehaviourPacks)
+	writeStackPacks(w, p.TexturePacks)
+	w.WriteString(p.BaseGameVersion)
+	w.WriteInt32(int32(len(p.Experiments)))
+	for _, experiment := range p.Experiments {
+		w.WriteString(experiment.Name)
+		w.WriteBool(experiment.Enabled)
+	}
+	w.WriteBool(p.ExperimentsPreviouslyToggled)
+	w.WriteBool(p.IncludeEditorPacks)
+}
+
+func readStackPacks(r *Reader) ([]StackPack, error) {
+	count, err := r.ReadVarUint32()
+	if err != nil {
+		return nil, err
+	}
+	// Each pack takes at least three bytes, which bounds the allocation.
+	if int(count) > r.Len()/3 {
+		return nil, fmt.Errorf("pack count of %d is out of range", count)
+	}
+	packs := make([]StackPack, count)
+	for i := range packs {
+		if packs[i].UUID, err = r.ReadString(); err != nil {
+			return nil, err
+		}
+		if packs[i].Version, err = r.ReadString(); err != nil {
+			return nil, err
+		}
+		if packs[i].SubPackName, err = r.ReadString(); err != nil {
+			return nil, err
+		}
+	}
+	return packs, nil
+}
+
+func (p *ResourcePackStack) Unmarshal(r *Reader) error {
+	var err error
+	if p.TexturePackRequired, err = r.ReadBool(); err != nil {
+		return err
+	}
+	if p.BehaviourPacks, err = readStackPacks(r); err != nil {
+		return err
+	}
+	if p.TexturePacks, err = readStackPacks(r); err != nil {
+		return err
+	}
+	if p.BaseGameVersion, err = r.ReadString(); err != nil {
+		return err
+	}
+	count, err := r.ReadInt32()
+	if err != nil {
+		return err
+	}
+	if count < 0 || int(count) > r.Len()/2 {
+		return fmt.Errorf("experiment count of %d is out of range", count)
+	}
+	p.Experiments = make([]Experiment, count)
+	for i := range p.Experiments {
+		if p.Experiments[i].Name, err = r.ReadString(); err != nil {
+			return err
+		}
+		if p.Experiments[i].Enabled, err = r.ReadBool(); err != nil {
+			return err
+		}
+	}
+	if p.ExperimentsPreviouslyToggled, err = r.ReadBool(); err != nil {
+		return err
+	}
+	p.IncludeEditorPacks, err = r.ReadBool()
+	return err
+}
+
+// ResourcePackClientResponse statuses.
+const (
+	PackRefused      uint8 = 1
+	PackSendPacks    uint8 = 2
+	PackHaveAllPacks uint8 = 3
+	PackCompleted    uint8 = 4
+)
+
+// ResourcePackClientResponse moves the pack negotiation along: the client
+// refuses the packs, asks for the ones it lacks, says it has them all, or
+// that it has applied the stack.
+type ResourcePackClientResponse struct {
+	Status uint8
+	// PackIDs are the packs asked for, as uuid_version.
+	PackIDs []string
+}
+
+func (*ResourcePackClientResponse) ID() uint32 { return IDResourcePackClientResponse }
+
+func (p *ResourcePackClientResponse) Marshal(w *Writer) {
+	w.WriteUint8(p.Status)
+	w.WriteUint16(uint16(len(p.PackIDs)))
+	for _, id := range p.PackIDs {
+		w.WriteString(id)
+	}
+}
+
+func (p *ResourcePackClientResponse) Unmarshal(r *Reader) error {
+	var err error
+	if p.Status, err = r.ReadUint8(); err != nil {
+		return err
+	}
+	count, err := r.ReadUint16()
+	if err != nil {
+		return err
+	}
+	if int(count) > r.Len() {
+		return fmt.Errorf("pack count of %d is out of range", count)
+	}
+	p.PackIDs = make([]string, count)
+	for i := range p.PackIDs {
+		if p.PackIDs[i], err = r.ReadString(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MovePlayer modes.
+const (
+	MoveNormal uint8 = iota
+	MoveReset
+	MoveTeleport
+	MoveRotation
+)
+
+// MovePlayer moves a player, sent by the server to teleport or correct
+// them. Clients using server authoritative movement send Player Auth Input
+// instead.
+type MovePlayer struct {
+	EntityRuntimeID       uint64
+	X, Y, Z               float32
+	Pitch, Yaw, HeadYaw   float32
+	Mode                  uint8
+	OnGround              bool
+	RiddenEntityRuntimeID uint64
+	// TeleportCause and TeleportSourceEntityType are only sent with
+	// MoveTeleport.
+	TeleportCause            int32
+	TeleportSourceEntityType int32
+	Tick                     uint64
+}
+
+func (*MovePlayer) ID() uint32 { return IDMovePlayer }
+
+func (p *MovePlayer) Marshal(w *Writer) {
+	w.WriteVarUint64(p.EntityRuntimeID)
+	w.WriteFloat32(p.X)
+	w.WriteFloat32(p.Y)
+	w.WriteFloat32(p.Z)
+	w.WriteFloat32(p.Pitch)
+	w.WriteFloat32(p.Yaw)
+	w.WriteFloat32(p.HeadYaw)
+	w.WriteUint8(p.Mode)
+	w.WriteBool(p.OnGround)
+	w.WriteVarUint64(p.RiddenEntityRuntimeID)
+	if p.Mode == MoveTeleport {
+		w.WriteInt32(p.TeleportCause)
+		w.WriteInt32(p.TeleportSourceEntityType)
+	}
+	w.WriteVarUint64(p.Tick)
+}
+
+func (p *MovePlayer) Unmarshal(r *Reader) error {
+	var err error
+	if p.EntityRuntimeID, err = r.ReadVarUint64(); err != nil {
+		return err
+	}
+	for _, f := range []*float32{&p.X, &p.Y, &p.Z, &p.Pitch, &p.Yaw, &p.HeadYaw} {
+		if *f, err = r.ReadFloat32(); err != nil {
+			return err
+		}
+	}
+	if p.Mode, err = r.ReadUint8(); err != nil {
+		return err
+	}
+	if p.OnGround, err = r.ReadBool(); err != nil {
+		return err
+	}
+	if p.RiddenEntityRuntimeID, err = r.ReadVarUint64(); err != nil {
+		return err
+	}
+	if p.Mode == MoveTeleport {
+		if p.TeleportCause, err = r.ReadInt32(); err != nil {
+			return err
+		}
+		if p.TeleportSourceEntityType, err = r.ReadInt32(); err != nil {
+			return err
+		}
+	}
+	p.Tick, err = r.ReadVarUint64()
+	return err
+}
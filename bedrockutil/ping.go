@@ -0,0 +1,121 @@
+package bedrockutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RakNet offline message IDs used to ping a server before connecting.
+const (
+	IDUnconnectedPing = 0x01
+	IDUnconnectedPong = 0x1C
+)
+
+// offlineMagic marks RakNet offline messages.
+var offlineMagic = []byte{0x00, 0xFF, 0xFF, 0x00, 0xFE, 0xFE, 0xFE, 0xFE, 0xFD, 0xFD, 0xFD, 0xFD, 0x12, 0x34, 0x56, 0x78}
+
+// UnconnectedPing builds the datagram that asks a server for its status.
+// RakNet is big endian, unlike the game packets it carries.
+func UnconnectedPing(timestamp, clientGUID int64) []byte {
+	ping := []byte{IDUnconnectedPing}
+	ping = binary.BigEndian.AppendUint64(ping, uint64(timestamp))
+	ping = append(ping, offlineMagic...)
+	return binary.BigEndian.AppendUint64(ping, uint64(clientGUID))
+}
+
+// Status is what a server advertises in its pong, as shown in the server
+// list.
+type Status struct {
+	Edition         string
+	MOTD            string
+	Protocol        int32
+	Version         string
+	Players         int32
+	MaxPlayers      int32
+	ServerID        string
+	SubMOTD         string
+	GameMode        string
+	GameModeNumeric int32
+	PortV4          uint16
+	PortV6          uint16
+}
+
+// String encodes the status as a pong carries it.
+func (s Status) String() string {
+	fields := []string{
+		s.Edition, s.MOTD, strconv.Itoa(int(s.Protocol)), s.Version,
+		strconv.Itoa(int(s.Players)), strconv.Itoa(int(s.MaxPlayers)),
+		s.ServerID, s.SubMOTD, s.GameMode, strconv.Itoa(int(s.GameModeNumeric)),
+		strconv.Itoa(int(s.PortV4)), strconv.Itoa(int(s.PortV6)),
+	}
+	return strings.Join(fields, ";") + ";"
+}
+
+// ParseStatus parses the semicolon separated status of a pong. Only the
+// first six fields are required; older servers send no more.
+func ParseStatus(status string) (Status, error) {
+	fields := strings.Split(status, ";")
+	if len(fields) < 6 {
+		return Status{}, fmt.Errorf("status has %d fields rather than at least 6", len(fields))
+	}
+	field := func(i int) string {
+		if i < len(fields) {
+			return fields[i]
+		}
+		return ""
+	}
+	number := func(i int) int64 {
+		n, _ := strconv.ParseInt(field(i), 10, 32)
+		return n
+	}
+
+	s := Status{
+		Edition:         field(0),
+		MOTD:            field(1),
+		Protocol:        int32(number(2)),
+		Version:         field(3),
+		Players:         int32(number(4)),
+		MaxPlayers:      int32(number(5)),
+		ServerID:        field(6),
+		SubMOTD:         field(7),
+		GameMode:        field(8),
+		GameModeNumeric: int32(number(9)),
+		PortV4:          uint16(number(10)),
+		PortV6:          uint16(number(11)),
+	}
+	return s, nil
+}
+
+// UnconnectedPong builds a server's answer to a ping.
+func UnconnectedPong(timestamp, serverGUID int64, status Status) []byte {
+	encoded := status.String()
+	pong := []byte{IDUnconnectedPong}
+	pong = binary.BigEndian.AppendUint64(pong, uint64(timestamp))
+	pong = binary.BigEndian.AppendUint64(pong, uint64(serverGUID))
+	pong = append(pong, offlineMagic...)
+	pong = binary.BigEndian.AppendUint16(pong, uint16(len(encoded)))
+	return append(pong, encoded...)
+}
+
+// ParseUnconnectedPong reads a server's pong, returning the timestamp of the
+// ping it answers, the server's GUID and its status.
+func ParseUnconnectedPong(pong []byte) (int64, int64, Status, error) {
+	const header = 1 + 8 + 8 + 16 + 2
+	if len(pong) < header || pong[0] != IDUnconnectedPong {
+		return 0, 0, Status{}, fmt.Errorf("datagram is not an unconnected pong")
+	}
+	if !bytes.Equal(pong[17:33], offlineMagic) {
+		return 0, 0, Status{}, fmt.Errorf("pong is missing the offline message magic")
+	}
+	timestamp := int64(binary.BigEndian.Uint64(pong[1:]))
+	guid := int64(binary.BigEndian.Uint64(pong[9:]))
+	length := int(binary.BigEndian.Uint16(pong[33:]))
+	if len(pong) < header+length {
+		return 0, 0, Status{}, fmt.Errorf("pong status is truncated")
+	}
+	status, err := ParseStatus(string(pong[header : header+length]))
+	return timestamp, guid, status, err
+}
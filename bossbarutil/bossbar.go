@@ -0,0 +1,387 @@
+package bossbarutil
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/PurpurProject/elytra/jsonutil"
+	"github.com/PurpurProject/elytra/nbtutil"
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// protocol1_20_3 is the protocol version from which titles are sent as NBT.
+const protocol1_20_3 = 765
+
+// Color is a boss bar color.
+type Color int32
+
+const (
+	Pink Color = iota
+	Blue
+	Red
+	Green
+	Yellow
+	Purple
+	White
+)
+
+// Division is how many notches a boss bar is split into.
+type Division int32
+
+const (
+	NoDivision Division = iota
+	SixNotches
+	TenNotches
+	TwelveNotches
+	TwentyNotches
+)
+
+// Flags change the world around a player who sees the bar.
+type Flags uint8
+
+const (
+	DarkenSky Flags = 1 << iota
+	// DragonBar plays the end music.
+	DragonBar
+	CreateFog
+)
+
+// Boss Bar actions.
+const (
+	actionAdd int32 = iota
+	actionRemove
+	actionHealth
+	actionTitle
+	actionStyle
+	actionFlags
+)
+
+// Outgoing is a packet and the players it must be sent to.
+type Outgoing struct {
+	Packet  []byte
+	Players []string
+}
+
+// Manager creates boss bars and gives each a unique UUID. Players are
+// identified by whatever string the server uses, such as their UUID.
+type Manager struct {
+	packetID int32
+	protocol int32
+
+	lock sync.Mutex
+	bars map[[16]byte]*BossBar
+}
+
+// CreateManager is a factory function for creating a new Manager. The Boss
+// Bar packet ID depends on the protocol version, so it is passed in along
+// with the version, which decides whether titles are sent as NBT or JSON.
+func CreateManager(packetID, protocol int32) *Manager {
+	m := new(Manager)
+	m.packetID = packetID
+	m.protocol = protocol
+	m.bars = make(map[[16]byte]*BossBar)
+	return m
+}
+
+// encodeTitle encodes a title as the Boss Bar packet sends it: NBT from
+// 1.20.3, JSON before.
+func (m *Manager) encodeTitle(title jsonutil.ChatObject) ([]byte, error) {
+	if m.protocol >= protocol1_20_3 {
+		nw := nbtutil.CreateWriter()
+		if err := nw.WriteNetwork(title.Compound()); err != nil {
+			return nil, fmt.Errorf("encoding title: %w", err)
+		}
+		return nw.Bytes(), nil
+	}
+	encoded, err := json.Marshal(title)
+	if err != nil {
+		return nil, fmt.Errorf("encoding title: %w", err)
+	}
+	return encoded, nil
+}
+
+// Create makes a new boss bar with full health and no viewers, or returns
+// an error if the title can't be encoded.
+func (m *Manager) Create(title jsonutil.ChatObject, color Color, division Division) (*BossBar, error) {
+	encoded, err := m.encodeTitle(title)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	bb := new(BossBar)
+	bb.manager = m
+	bb.title = title
+	bb.encodedTitle = encoded
+	bb.health = 1
+	bb.color = color
+	bb.division = division
+	bb.visible = true
+	bb.viewers = make(map[string]bool)
+	for {
+		rand.Read(bb.id[:])
+		bb.id[6] = bb.id[6]&0x0F | 0x40
+		bb.id[8] = bb.id[8]&0x3F | 0x80
+		if m.bars[bb.id] == nil {
+			break
+		}
+	}
+	m.bars[bb.id] = bb
+	return bb, nil
+}
+
+// Bar returns the boss bar with a UUID.
+func (m *Manager) Bar(id [16]byte) (*BossBar, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	bb, ok := m.bars[id]
+	return bb, ok
+}
+
+// Remove deletes a boss bar, returning the packet that takes it off its
+// viewers' screens. It returns false if the bar was already removed.
+func (m *Manager) Remove(bb *BossBar) (Outgoing, bool) {
+	m.lock.Lock()
+	if m.bars[bb.id] != bb {
+		m.lock.Unlock()
+		return Outgoing{}, false
+	}
+	delete(m.bars, bb.id)
+	m.lock.Unlock()
+
+	bb.lock.Lock()
+	defer bb.lock.Unlock()
+	out := bb.broadcast(bb.packet(actionRemove))
+	clear(bb.viewers)
+	return out, true
+}
+
+// PlayerLeft forgets a player who disconnected from every boss bar. The
+// client drops its bars itself, so nothing needs sending.
+func (m *Manager) PlayerLeft(player string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, bb := range m.bars {
+		bb.lock.Lock()
+		delete(bb.viewers, player)
+		bb.lock.Unlock()
+	}
+}
+
+// PacketsFor returns the packets that show a player every bar they view,
+// for when their client has lost them, such as after reconfiguration.
+func (m *Manager) PacketsFor(player string) [][]byte {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var packets [][]byte
+	for _, bb := range m.bars {
+		bb.lock.Lock()
+		if bb.visible && bb.viewers[player] {
+			packets = append(packets, bb.packet(actionAdd))
+		}
+		bb.lock.Unlock()
+	}
+	return packets
+}
+
+// BossBar is a boss bar shown to a set of players. Each change returns the
+// packet to send and who to send it to; a change that alters nothing
+// returns false.
+type BossBar struct {
+	manager *Manager
+	id      [16]byte
+
+	lock  sync.Mutex
+	title jsonutil.ChatObject
+	// encodedTitle is title as writeTitle sends it, encoded when the title
+	// is set so that building a packet can't fail.
+	encodedTitle []byte
+	health       float32
+	color        Color
+	division     Division
+	flags        Flags
+	visible      bool
+	viewers      map[string]bool
+}
+
+// UUID returns the bar's UUID.
+func (bb *BossBar) UUID() [16]byte {
+	return bb.id
+}
+
+func (bb *BossBar) packet(action int32) []byte {
+	m := bb.manager
+	pw := packetutil.CreatePacketWriter(m.packetID)
+	pw.WriteBytes(bb.id[:])
+	pw.WriteVarInt(action)
+
+	switch action {
+	case actionAdd:
+		bb.writeTitle(pw)
+		pw.WriteFloat(bb.health)
+		pw.WriteVarInt(int32(bb.color))
+		pw.WriteVarInt(int32(bb.division))
+		pw.WriteUnsignedByte(byte(bb.flags))
+	case actionHealth:
+		pw.WriteFloat(bb.health)
+	case actionTitle:
+		bb.writeTitle(pw)
+	case actionStyle:
+		pw.WriteVarInt(int32(bb.color))
+		pw.WriteVarInt(int32(bb.division))
+	case actionFlags:
+		pw.WriteUnsignedByte(byte(bb.flags))
+	}
+	return pw.GetPacket()
+}
+
+func (bb *BossBar) writeTitle(pw *packetutil.PacketWriter) {
+	if bb.manager.protocol >= protocol1_20_3 {
+		pw.WriteBytes(bb.encodedTitle)
+		return
+	}
+	pw.WriteString(string(bb.encodedTitle))
+}
+
+// broadcast addresses a packet to every viewer, or to nobody while the bar
+// is hidden.
+func (bb *BossBar) broadcast(packet []byte) Outgoing {
+	out := Outgoing{Packet: packet}
+	if !bb.visible {
+		return out
+	}
+	for player := range bb.viewers {
+		out.Players = append(out.Players, player)
+	}
+	sort.Strings(out.Players)
+	return out
+}
+
+// Viewers returns the players the bar is shown to.
+func (bb *BossBar) Viewers() []string {
+	bb.lock.Lock()
+	defer bb.lock.Unlock()
+
+	viewers := make([]string, 0, len(bb.viewers))
+	for player := range bb.viewers {
+		viewers = append(viewers, player)
+	}
+	sort.Strings(viewers)
+	return viewers
+}
+
+// AddViewer shows the bar to a player, returning the packet to send them.
+// While the bar is hidden the player is recorded but sent nothing.
+func (bb *BossBar) AddViewer(player string) ([]byte, bool) {
+	bb.lock.Lock()
+	defer bb.lock.Unlock()
+
+	if bb.viewers[player] {
+		return nil, false
+	}
+	bb.viewers[player] = true
+	if !bb.visible {
+		return nil, false
+	}
+	return bb.packet(actionAdd), true
+}
+
+// RemoveViewer hides the bar from a player, returning the packet to send
+// them.
+func (bb *BossBar) RemoveViewer(player string) ([]byte, bool) {
+	bb.lock.Lock()
+	defer bb.lock.Unlock()
+
+	if !bb.viewers[player] {
+		return nil, false
+	}
+	delete(bb.viewers, player)
+	if !bb.visible {
+		return nil, false
+	}
+	return bb.packet(actionRemove), true
+}
+
+// SetVisible shows or hides the bar for every viewer at once, keeping the
+// viewers.
+func (bb *BossBar) SetVisible(visible bool) (Outgoing, bool) {
+	bb.lock.Lock()
+	defer bb.lock.Unlock()
+
+	if bb.visible == visible {
+		return Outgoing{}, false
+	}
+	action := actionRemove
+	if visible {
+		action = actionAdd
+	}
+	// Address the packet while the bar is visible, before hiding it or
+	// after showing it.
+	bb.visible = true
+	out := bb.broadcast(bb.packet(action))
+	bb.visible = visible
+	return out, true
+}
+
+// SetTitle changes the bar's title, or returns an error if the title can't
+// be encoded.
+func (bb *BossBar) SetTitle(title jsonutil.ChatObject) (Outgoing, bool, error) {
+	bb.lock.Lock()
+	defer bb.lock.Unlock()
+
+	if reflect.DeepEqual(bb.title, title) {
+		return Outgoing{}, false, nil
+	}
+	encoded, err := bb.manager.encodeTitle(title)
+	if err != nil {
+		return Outgoing{}, false, err
+	}
+	bb.title, bb.encodedTitle = title, encoded
+	return bb.broadcast(bb.packet(actionTitle)), true, nil
+}
+
+// SetHealth changes how full the bar is, from 0 to 1.
+func (bb *BossBar) SetHealth(health float32) (Outgoing, bool) {
+	bb.lock.Lock()
+	defer bb.lock.Unlock()
+
+	health = min(max(health, 0), 1)
+	if bb.health == health {
+		return Outgoing{}, false
+	}
+	bb.health = health
+	return bb.broadcast(bb.packet(actionHealth)), true
+}
+
+// SetStyle changes the bar's color and division.
+func (bb *BossBar) SetStyle(color Color, division Division) (Outgoing, bool) {
+	bb.lock.Lock()
+	defer bb.lock.Unlock()
+
+	if bb.color == color && bb.division == division {
+		return Outgoing{}, false
+	}
+	bb.color, bb.division = color, division
+	return bb.broadcast(bb.packet(actionStyle)), true
+}
+
+// SetFlags changes the bar's flags.
+func (bb *BossBar) SetFlags(flags Flags) (Outgoing, bool) {
+	bb.lock.Lock()
+	defer bb.lock.Unlock()
+
+	if bb.flags == flags {
+		return Outgoing{}, false
+	}
+	bb.flags = flags
+	return bb.broadcast(bb.packet(actionFlags)), true
+}
@@ -0,0 +1,122 @@
+package chatutil
+
+import (
+	"crypto/rsa"
+	"time"
+)
+
+// ChainValidator follows one player's signed message chain. Each message of
+// a chat session takes the next index and may not be older than the one
+// before; once a message fails, the chain is broken and every later message
+// is rejected until the player starts a new session, as in vanilla.
+type ChainValidator struct {
+	sender    [16]byte
+	sessionID [16]byte
+	key       *rsa.PublicKey
+	nextIndex int32
+	last      time.Time
+	broken    bool
+}
+
+// CreateChainValidator is a factory function for creating a new
+// ChainValidator for a chat session, from the session ID and public key the
+// client sent in Player Session.
+func CreateChainValidator(sender, sessionID [16]byte, key *rsa.PublicKey) *ChainValidator {
+	cv := new(ChainValidator)
+	cv.sender = sender
+	cv.sessionID = sessionID
+	cv.key = key
+	return cv
+}
+
+// Broken reports whether the chain has broken.
+func (cv *ChainValidator) Broken() bool {
+	return cv.broken
+}
+
+// Next checks a message and advances the chain, returning the message's link.
+func (cv *ChainValidator) Next(body Body, sig *MessageSignature) (Link, error) {
+	if cv.broken {
+		return Link{}, validationErrorf("chain is broken")
+	}
+
+	link := Link{cv.sender, cv.sessionID, cv.nextIndex}
+	if body.Timestamp.Before(cv.last) {
+		cv.broken = true
+		return link, validationErrorf("out-of-order chat message")
+	}
+	if err := Verify(cv.key, link, body, sig); err != nil {
+		cv.broken = true
+		return link, &ValidationError{err.Error()}
+	}
+
+	cv.nextIndex++
+	cv.last = body.Timestamp
+	return link, nil
+}
+
+// SignatureCacheSize is the number of signatures the client remembers for
+// packed references in Player Chat packets.
+const SignatureCacheSize = 128
+
+// SignatureCache mirrors the client's cache of recent signatures, so a Player
+// Chat packet can refer to a previously sent signature by index instead of
+// repeating all 256 bytes. The server and client must push the same messages
+// in the same order for the indices to agree.
+type SignatureCache struct {
+	entries [SignatureCacheSize]*MessageSignature
+}
+
+// CreateSignatureCache is a factory function for creating a new
+// SignatureCache.
+func CreateSignatureCache() *SignatureCache {
+	return new(SignatureCache)
+}
+
+// Push records a message sent to the client: its last seen list and then its
+// own signature, which may be nil for unsigned messages.
+func (sc *SignatureCache) Push(lastSeen []MessageSignature, sig *MessageSignature) {
+	queue := make([]*MessageSignature, 0, len(lastSeen)+1)
+	for i := range lastSeen {
+		queue = append(queue, &lastSeen[i])
+	}
+	if sig != nil {
+		queue = append(queue, sig)
+	}
+
+	pushed := make(map[MessageSignature]bool, len(queue))
+	for _, entry := range queue {
+		pushed[*entry] = true
+	}
+
+	// Newest first; displaced entries that weren't just pushed move down.
+	for i := 0; len(queue) > 0 && i < SignatureCacheSize; i++ {
+		displaced := sc.entries[i]
+		newest := *queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		sc.entries[i] = &newest
+		if displaced != nil && !pushed[*displaced] {
+			queue = append([]*MessageSignature{displaced}, queue...)
+		}
+	}
+}
+
+// Pack returns the index of a cached signature, or -1 if it isn't cached. On
+// the wire the index is sent as a VarInt plus one, with zero meaning the full
+// signature follows.
+func (sc *SignatureCache) Pack(sig *MessageSignature) int32 {
+	for i, entry := range sc.entries {
+		if entry != nil && *entry == *sig {
+			return int32(i)
+		}
+	}
+	return -1
+}
+
+// Unpack returns the signature at an index, or nil if there is none.
+func (sc *SignatureCache) Unpack(index int32) *MessageSignature {
+	if index < 0 || index >= SignatureCacheSize {
+		return nil
+	}
+	return sc.entries[index]
+}
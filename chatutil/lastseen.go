@@ -0,0 +1,215 @@
+package chatutil
+
+import "fmt"
+
+// LastSeenWindow is how many recent messages the client acknowledges in each
+// chat packet.
+const LastSeenWindow = 20
+
+// Update is the last seen update a client sends with each chat message and
+// command.
+type Update struct {
+	// Offset is how many new messages the client has seen since its
+	// previous update.
+	Offset int32
+	// Acknowledged is a bit per slot of the window, oldest first, set for
+	// the messages the client acknowledges. On the wire it is a fixed
+	// BitSet of 20 bits, so 3 bytes.
+	Acknowledged [3]byte
+	// Checksum was added in 1.21.5; zero means the client didn't send one.
+	Checksum byte
+}
+
+func (u *Update) acknowledged(i int) bool {
+	return u.Acknowledged[i/8]&(1<<(i%8)) != 0
+}
+
+func (u *Update) setAcknowledged(i int) {
+	u.Acknowledged[i/8] |= 1 << (i % 8)
+}
+
+// Checksum returns the checksum of a last seen list, as sent in updates since
+// 1.21.5. It is never zero, which is reserved for "no checksum".
+func Checksum(lastSeen []MessageSignature) byte {
+	h := int32(1)
+	for i := range lastSeen {
+		h = 31*h + lastSeen[i].checksum()
+	}
+	if byte(h) == 0 {
+		return 1
+	}
+	return byte(h)
+}
+
+type trackedEntry struct {
+	signature MessageSignature
+	pending   bool
+}
+
+// LastSeenTracker keeps a client's side of the last seen window: which recent
+// messages it has seen and not yet acknowledged. A proxy that injects its own
+// chat towards the server uses one to build the updates the server expects.
+type LastSeenTracker struct {
+	entries  [LastSeenWindow]*trackedEntry
+	tail     int
+	offset   int32
+	previous *MessageSignature
+}
+
+// CreateLastSeenTracker is a factory function for creating a new
+// LastSeenTracker.
+func CreateLastSeenTracker() *LastSeenTracker {
+	return new(LastSeenTracker)
+}
+
+// Add records a signed message the client received. Messages the client
+// chose not to show still advance the window but are never acknowledged. It
+// returns false for a repeat of the last message, which vanilla ignores.
+func (lst *LastSeenTracker) Add(sig *MessageSignature, shown bool) bool {
+	if lst.previous != nil && *lst.previous == *sig {
+		return false
+	}
+	copied := *sig
+	lst.previous = &copied
+
+	var entry *trackedEntry
+	if shown {
+		entry = &trackedEntry{copied, true}
+	}
+	lst.entries[lst.tail] = entry
+	lst.tail = (lst.tail + 1) % LastSeenWindow
+	lst.offset++
+	return true
+}
+
+// Ignore stops a message that hasn't been acknowledged yet from being
+// acknowledged, such as when the player hides its sender.
+func (lst *LastSeenTracker) Ignore(sig *MessageSignature) {
+	for i, entry := range lst.entries {
+		if entry != nil && entry.pending && entry.signature == *sig {
+			lst.entries[i] = nil
+			return
+		}
+	}
+}
+
+// Offset returns the number of messages added since the last update, which
+// is sent alone in a Message Acknowledgment packet.
+func (lst *LastSeenTracker) Offset() int32 {
+	offset := lst.offset
+	lst.offset = 0
+	return offset
+}
+
+// Update builds the update to send with the next message, returning it along
+// with the last seen list to sign into that message.
+func (lst *LastSeenTracker) Update() (Update, []MessageSignature) {
+	var update Update
+	update.Offset = lst.Offset()
+
+	var lastSeen []MessageSignature
+	for i := 0; i < LastSeenWindow; i++ {
+		slot := (lst.tail + i) % LastSeenWindow
+		entry := lst.entries[slot]
+		if entry == nil {
+			continue
+		}
+		update.setAcknowledged(i)
+		lastSeen = append(lastSeen, entry.signature)
+		lst.entries[slot] = &trackedEntry{entry.signature, false}
+	}
+	update.Checksum = Checksum(lastSeen)
+	return update, lastSeen
+}
+
+// ValidationError reports a last seen update or chain that doesn't match what
+// the server sent, which vanilla kicks the player for.
+type ValidationError struct {
+	Message string
+}
+
+func (ve *ValidationError) Error() string {
+	return ve.Message
+}
+
+func validationErrorf(format string, args ...any) error {
+	return &ValidationError{fmt.Sprintf(format, args...)}
+}
+
+// LastSeenValidator keeps a server's side of a player's last seen window,
+// checking the updates they send against the messages they were sent. A
+// proxy relaying signed chat needs one per player to know which signatures a
+// message covers.
+type LastSeenValidator struct {
+	tracked     []*trackedEntry
+	lastPending *MessageSignature
+}
+
+// CreateLastSeenValidator is a factory function for creating a new
+// LastSeenValidator.
+func CreateLastSeenValidator() *LastSeenValidator {
+	lsv := new(LastSeenValidator)
+	lsv.tracked = make([]*trackedEntry, LastSeenWindow)
+	return lsv
+}
+
+// AddPending records a signed message sent to the player.
+func (lsv *LastSeenValidator) AddPending(sig *MessageSignature) {
+	if lsv.lastPending != nil && *lsv.lastPending == *sig {
+		return
+	}
+	copied := *sig
+	lsv.lastPending = &copied
+	lsv.tracked = append(lsv.tracked, &trackedEntry{copied, true})
+}
+
+// Tracked returns how many messages are being tracked, including the window.
+// Vanilla disconnects players once this passes 4096, as they have stopped
+// acknowledging messages.
+func (lsv *LastSeenValidator) Tracked() int {
+	return len(lsv.tracked)
+}
+
+// ApplyOffset advances the window, as a Message Acknowledgment packet does.
+func (lsv *LastSeenValidator) ApplyOffset(offset int32) error {
+	limit := len(lsv.tracked) - LastSeenWindow
+	if offset < 0 || int(offset) > limit {
+		return validationErrorf("advanced last seen window by %d messages, but expected at most %d", offset, limit)
+	}
+	lsv.tracked = append(lsv.tracked[:0], lsv.tracked[offset:]...)
+	return nil
+}
+
+// ApplyUpdate applies the update sent with a chat message, returning the last
+// seen list the message was signed with.
+func (lsv *LastSeenValidator) ApplyUpdate(update Update) ([]MessageSignature, error) {
+	if err := lsv.ApplyOffset(update.Offset); err != nil {
+		return nil, err
+	}
+	// The bits past the window should never be set.
+	if update.Acknowledged[2]&0xF0 != 0 {
+		return nil, validationErrorf("last seen update acknowledged more than %d messages", LastSeenWindow)
+	}
+
+	var lastSeen []MessageSignature
+	for i := 0; i < LastSeenWindow; i++ {
+		entry := lsv.tracked[i]
+		if update.acknowledged(i) {
+			if entry == nil {
+				return nil, validationErrorf("last seen update acknowledged unknown or previously ignored message at index %d", i)
+			}
+			lsv.tracked[i] = &trackedEntry{entry.signature, false}
+			lastSeen = append(lastSeen, entry.signature)
+		} else {
+			if entry != nil && !entry.pending {
+				return nil, validationErrorf("last seen update ignored previously acknowledged message at index %d", i)
+			}
+			lsv.tracked[i] = nil
+		}
+	}
+
+	if update.Checksum != 0 && update.Checksum != Checksum(lastSeen) {
+		return nil, validationErrorf("checksum mismatch on last seen update: the client and server must have desynced")
+	}
+	return lastSeen, nil
+}
@@ -0,0 +1,229 @@
+package chatutil
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/PurpurProject/elytra/jsonutil"
+	"github.com/PurpurProject/elytra/nbtutil"
+	"github.com/PurpurProject/elytra/packetutil"
+	"github.com/PurpurProject/elytra/registryutil"
+)
+
+// Protocol versions at which the chat packets built here changed shape.
+const (
+	protocol1_20_3 = 765
+	protocol1_20_5 = 766
+	protocol1_21_5 = 770
+)
+
+// maxMessageLength is the longest chat message vanilla accepts or sends, in
+// the UTF-16 characters Java counts.
+const maxMessageLength = 256
+
+// The chat types vanilla registers, which decide how a message is
+// decorated: "<sender> message" for ChatTypeChat, "[sender] message" for
+// ChatTypeSayCommand, and so on.
+const (
+	ChatTypeChat                   = "minecraft:chat"
+	ChatTypeSayCommand             = "minecraft:say_command"
+	ChatTypeMsgCommandIncoming     = "minecraft:msg_command_incoming"
+	ChatTypeMsgCommandOutgoing     = "minecraft:msg_command_outgoing"
+	ChatTypeTeamMsgCommandIncoming = "minecraft:team_msg_command_incoming"
+	ChatTypeTeamMsgCommandOutgoing = "minecraft:team_msg_command_outgoing"
+	ChatTypeEmoteCommand           = "minecraft:emote_command"
+)
+
+// Bound is a chat type together with the names it decorates a message
+// with. Target is the team or player a message was sent to, for the
+// outgoing and team chat types, or nil.
+type Bound struct {
+	ChatType string
+	Sender   jsonutil.ChatObject
+	Target   *jsonutil.ChatObject
+}
+
+// PlayerMessage is a message a player sent, to pass on as Player Chat.
+type PlayerMessage struct {
+	// Link is the message's place in its sender's chain. Only the sender
+	// and index are sent.
+	Link Link
+	Body Body
+	// Signature is nil for messages from players without a chat session.
+	Signature *MessageSignature
+	// Unsigned, if set, is shown in place of the signed content, as for a
+	// message the server has decorated.
+	Unsigned *jsonutil.ChatObject
+	Bound    Bound
+}
+
+// ChatIDs are the clientbound packet IDs a Messenger sends, which depend on
+// the protocol version.
+type ChatIDs struct {
+	PlayerChat    int32
+	DisguisedChat int32
+	SystemChat    int32
+}
+
+// Messenger builds the chat packets sent to one player, picking the packet
+// each kind of message needs: Player Chat for messages in a player's signed
+// chain, Disguised Chat for decorated messages with no chain behind them,
+// such as /say from the console, and System Chat for everything else,
+// including the action bar. Chat types are sent as their IDs in the
+// minecraft:chat_type registry the player was sent. It mirrors the player's
+// signature cache, so every Player Chat they are sent must go through it.
+// It is safe for concurrent use.
+type Messenger struct {
+	ids       ChatIDs
+	protocol  int32
+	chatTypes *registryutil.Registry
+
+	lock        sync.Mutex
+	cache       *SignatureCache
+	globalIndex int32
+}
+
+// CreateMessenger is a factory function for creating a new Messenger that
+// builds packets for the given protocol version, numbering chat types as
+// chatTypes does.
+func CreateMessenger(ids ChatIDs, protocol int32, chatTypes *registryutil.Registry) *Messenger {
+	m := new(Messenger)
+	m.ids = ids
+	m.protocol = protocol
+	m.chatTypes = chatTypes
+	m.cache = CreateSignatureCache()
+	return m
+}
+
+func (m *Messenger) writeComponent(pw *packetutil.PacketWriter, c jsonutil.ChatObject) error {
+	if m.protocol >= protocol1_20_3 {
+		nw := nbtutil.CreateWriter()
+		if err := nw.WriteNetwork(c.Compound()); err != nil {
+			return fmt.Errorf("encoding chat component: %w", err)
+		}
+		pw.WriteBytes(nw.Bytes())
+	} else {
+		pw.WriteChat(c)
+	}
+	return nil
+}
+
+// writeBound writes a chat type and its names, as Player Chat and Disguised
+// Chat end with.
+func (m *Messenger) writeBound(pw *packetutil.PacketWriter, b Bound, id int32) error {
+	if m.protocol >= protocol1_20_5 {
+		// A Holder, where 0 would be an inline chat type.
+		pw.WriteVarInt(id + 1)
+	} else {
+		pw.WriteVarInt(id)
+	}
+	if err := m.writeComponent(pw, b.Sender); err != nil {
+		return err
+	}
+	pw.WriteBoolean(b.Target != nil)
+	if b.Target != nil {
+		return m.writeComponent(pw, *b.Target)
+	}
+	return nil
+}
+
+func (m *Messenger) chatTypeID(b Bound) (int32, error) {
+	id, ok := m.chatTypes.ID(b.ChatType)
+	if !ok {
+		return 0, fmt.Errorf("chat type %s isn't registered", b.ChatType)
+	}
+	return id, nil
+}
+
+// PlayerChat returns the Player Chat packet for a message. Signatures the
+// player was sent recently are referred to by their place in the cache
+// rather than sent again.
+func (m *Messenger) PlayerChat(msg PlayerMessage) ([]byte, error) {
+	if length := packetutil.UTF16Length(msg.Body.Content); length > maxMessageLength {
+		return nil, fmt.Errorf("message is %d characters, longer than %d", length, maxMessageLength)
+	}
+	if len(msg.Body.LastSeen) > LastSeenWindow {
+		return nil, fmt.Errorf("message has %d last seen signatures, more than %d", len(msg.Body.LastSeen), LastSeenWindow)
+	}
+	chatType, err := m.chatTypeID(msg.Bound)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	pw := packetutil.CreatePacketWriter(m.ids.PlayerChat)
+	if m.protocol >= protocol1_21_5 {
+		pw.WriteVarInt(m.globalIndex)
+		m.globalIndex++
+	}
+	pw.WriteUUID(msg.Link.Sender)
+	pw.WriteVarInt(msg.Link.Index)
+	pw.WriteBoolean(msg.Signature != nil)
+	if msg.Signature != nil {
+		pw.WriteBytes(msg.Signature[:])
+	}
+	pw.WriteString(msg.Body.Content)
+	pw.WriteLong(msg.Body.Timestamp.UnixMilli())
+	pw.WriteLong(msg.Body.Salt)
+	pw.WriteVarInt(int32(len(msg.Body.LastSeen)))
+	for i := range msg.Body.LastSeen {
+		packed := m.cache.Pack(&msg.Body.LastSeen[i])
+		pw.WriteVarInt(packed + 1)
+		if packed < 0 {
+			pw.WriteBytes(msg.Body.LastSeen[i][:])
+		}
+	}
+	pw.WriteBoolean(msg.Unsigned != nil)
+	if msg.Unsigned != nil {
+		if err := m.writeComponent(pw, *msg.Unsigned); err != nil {
+			return nil, err
+		}
+	}
+	// Filter type: not filtered.
+	pw.WriteVarInt(0)
+	if err := m.writeBound(pw, msg.Bound, chatType); err != nil {
+		return nil, err
+	}
+
+	m.cache.Push(msg.Body.LastSeen, msg.Signature)
+	return pw.GetPacket(), nil
+}
+
+// DisguisedChat returns the Disguised Chat packet, which decorates content
+// with a chat type as if a player had sent it, without a signature.
+func (m *Messenger) DisguisedChat(content jsonutil.ChatObject, b Bound) ([]byte, error) {
+	chatType, err := m.chatTypeID(b)
+	if err != nil {
+		return nil, err
+	}
+	pw := packetutil.CreatePacketWriter(m.ids.DisguisedChat)
+	if err := m.writeComponent(pw, content); err != nil {
+		return nil, err
+	}
+	if err := m.writeBound(pw, b, chatType); err != nil {
+		return nil, err
+	}
+	return pw.GetPacket(), nil
+}
+
+// SystemChat returns the System Chat packet that shows content in the chat.
+func (m *Messenger) SystemChat(content jsonutil.ChatObject) ([]byte, error) {
+	return m.systemChat(content, false)
+}
+
+// ActionBar returns the System Chat packet that shows content above the
+// hotbar.
+func (m *Messenger) ActionBar(content jsonutil.ChatObject) ([]byte, error) {
+	return m.systemChat(content, true)
+}
+
+func (m *Messenger) systemChat(content jsonutil.ChatObject, overlay bool) ([]byte, error) {
+	pw := packetutil.CreatePacketWriter(m.ids.SystemChat)
+	if err := m.writeComponent(pw, content); err != nil {
+		return nil, err
+	}
+	pw.WriteBoolean(overlay)
+	return pw.GetPacket(), nil
+}
@@ -0,0 +1,93 @@
+package chatutil
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// SignatureSize is the length of a chat message signature, an RSA-2048
+// signature made with the player's chat session key.
+const SignatureSize = 256
+
+// MessageSignature is the signature of a chat message. Signatures double as
+// message identifiers in last seen updates.
+type MessageSignature [SignatureSize]byte
+
+// checksum is Java's Arrays.hashCode over the signature bytes, which feeds
+// the last seen checksum.
+func (ms *MessageSignature) checksum() int32 {
+	h := int32(1)
+	for _, b := range ms {
+		h = 31*h + int32(int8(b))
+	}
+	return h
+}
+
+// Link places a message in its sender's chain: every message of a chat
+// session carries the next index.
+type Link struct {
+	Sender    [16]byte
+	SessionID [16]byte
+	Index     int32
+}
+
+// Body is the signed content of a chat message.
+type Body struct {
+	Content   string
+	Timestamp time.Time
+	Salt      int64
+	// LastSeen is the signatures of the messages the sender acknowledged
+	// when sending this one.
+	LastSeen []MessageSignature
+}
+
+// SignedData returns the bytes a message signature covers, in the layout used
+// since 1.19.3.
+func SignedData(link Link, body Body) []byte {
+	content := []byte(body.Content)
+
+	data := make([]byte, 0, 4+16+16+4+8+8+4+len(content)+4+len(body.LastSeen)*SignatureSize)
+	data = binary.BigEndian.AppendUint32(data, 1)
+	data = append(data, link.Sender[:]...)
+	data = append(data, link.SessionID[:]...)
+	data = binary.BigEndian.AppendUint32(data, uint32(link.Index))
+	data = binary.BigEndian.AppendUint64(data, uint64(body.Salt))
+	data = binary.BigEndian.AppendUint64(data, uint64(body.Timestamp.Unix()))
+	data = binary.BigEndian.AppendUint32(data, uint32(len(content)))
+	data = append(data, content...)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(body.LastSeen)))
+	for i := range body.LastSeen {
+		data = append(data, body.LastSeen[i][:]...)
+	}
+	return data
+}
+
+// Verify checks a message signature against the sender's chat session key.
+func Verify(key *rsa.PublicKey, link Link, body Body, sig *MessageSignature) error {
+	digest := sha256.Sum256(SignedData(link, body))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig[:]); err != nil {
+		return fmt.Errorf("invalid chat message signature: %w", err)
+	}
+	return nil
+}
+
+// Sign signs a message with a chat session's private key, for tools and
+// tests that need to act as a client.
+func Sign(key *rsa.PrivateKey, link Link, body Body) (*MessageSignature, error) {
+	digest := sha256.Sum256(SignedData(link, body))
+	signed, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	if len(signed) != SignatureSize {
+		return nil, fmt.Errorf("signature is %d bytes, expected %d", len(signed), SignatureSize)
+	}
+
+	sig := new(MessageSignature)
+	copy(sig[:], signed)
+	return sig, nil
+}
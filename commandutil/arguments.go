@@ -0,0 +1,272 @@
+package commandutil
+
+import (
+	"math"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// ArgumentType parses the value of an argument node, and describes it to the
+// client in the Commands packet.
+type ArgumentType interface {
+	// Parse consumes the argument from the reader and returns its value.
+	Parse(sr *StringReader) (any, error)
+	// Parser returns the identifier of the argument type, such as
+	// brigadier:integer.
+	Parser() string
+	// WriteProperties writes the parser-specific properties that follow the
+	// parser ID in the Commands packet.
+	WriteProperties(pw *packetutil.PacketWriter)
+}
+
+// Range flags used by the numeric Brigadier argument types.
+const (
+	rangeHasMin = 0x01
+	rangeHasMax = 0x02
+)
+
+func rangeFlags(hasMin, hasMax bool) byte {
+	var flags byte
+	if hasMin {
+		flags |= rangeHasMin
+	}
+	if hasMax {
+		flags |= rangeHasMax
+	}
+	return flags
+}
+
+type boolArgument struct{}
+
+// Bool returns the brigadier:bool argument type.
+func Bool() ArgumentType {
+	return boolArgument{}
+}
+
+func (boolArgument) Parse(sr *StringReader) (any, error) {
+	return sr.ReadBoolean()
+}
+
+func (boolArgument) Parser() string {
+	return "brigadier:bool"
+}
+
+func (boolArgument) WriteProperties(pw *packetutil.PacketWriter) {}
+
+type intArgument struct {
+	min, max int32
+}
+
+// Integer returns the brigadier:integer argument type, accepting values
+// between min and max inclusive.
+func Integer(min, max int32) ArgumentType {
+	return intArgument{min, max}
+}
+
+func (a intArgument) Parse(sr *StringReader) (any, error) {
+	start := sr.Cursor()
+	val, err := sr.ReadInt()
+	if err != nil {
+		return nil, err
+	}
+	if val < a.min || val > a.max {
+		sr.SetCursor(start)
+		return nil, sr.Errorf("Integer must be between %d and %d, found %d", a.min, a.max, val)
+	}
+	return val, nil
+}
+
+func (intArgument) Parser() string {
+	return "brigadier:integer"
+}
+
+func (a intArgument) WriteProperties(pw *packetutil.PacketWriter) {
+	hasMin, hasMax := a.min != math.MinInt32, a.max != math.MaxInt32
+	pw.WriteUnsignedByte(rangeFlags(hasMin, hasMax))
+	if hasMin {
+		pw.WriteInt(a.min)
+	}
+	if hasMax {
+		pw.WriteInt(a.max)
+	}
+}
+
+type longArgument struct {
+	min, max int64
+}
+
+// Long returns the brigadier:long argument type, accepting values between min
+// and max inclusive.
+func Long(min, max int64) ArgumentType {
+	return longArgument{min, max}
+}
+
+func (a longArgument) Parse(sr *StringReader) (any, error) {
+	start := sr.Cursor()
+	val, err := sr.ReadLong()
+	if err != nil {
+		return nil, err
+	}
+	if val < a.min || val > a.max {
+		sr.SetCursor(start)
+		return nil, sr.Errorf("Long must be between %d and %d, found %d", a.min, a.max, val)
+	}
+	return val, nil
+}
+
+func (longArgument) Parser() string {
+	return "brigadier:long"
+}
+
+func (a longArgument) WriteProperties(pw *packetutil.PacketWriter) {
+	hasMin, hasMax := a.min != math.MinInt64, a.max != math.MaxInt64
+	pw.WriteUnsignedByte(rangeFlags(hasMin, hasMax))
+	if hasMin {
+		pw.WriteLong(a.min)
+	}
+	if hasMax {
+		pw.WriteLong(a.max)
+	}
+}
+
+type floatArgument struct {
+	min, max float32
+}
+
+// Float returns the brigadier:float argument type, accepting values between
+// min and max inclusive. Pass infinities for an unbounded range.
+func Float(min, max float32) ArgumentType {
+	return floatArgument{min, max}
+}
+
+func (a floatArgument) Parse(sr *StringReader) (any, error) {
+	start := sr.Cursor()
+	val, err := sr.ReadFloat()
+	if err != nil {
+		return nil, err
+	}
+	if val < a.min || val > a.max {
+		sr.SetCursor(start)
+		return nil, sr.Errorf("Float must be between %g and %g, found %g", a.min, a.max, val)
+	}
+	return val, nil
+}
+
+func (floatArgument) Parser() string {
+	return "brigadier:float"
+}
+
+func (a floatArgument) WriteProperties(pw *packetutil.PacketWriter) {
+	hasMin, hasMax := !math.IsInf(float64(a.min), -1), !math.IsInf(float64(a.max), 1)
+	pw.WriteUnsignedByte(rangeFlags(hasMin, hasMax))
+	if hasMin {
+		pw.WriteFloat(a.min)
+	}
+	if hasMax {
+		pw.WriteFloat(a.max)
+	}
+}
+
+type doubleArgument struct {
+	min, max float64
+}
+
+// Double returns the brigadier:double argument type, accepting values between
+// min and max inclusive. Pass infinities for an unbounded range.
+func Double(min, max float64) ArgumentType {
+	return doubleArgument{min, max}
+}
+
+func (a doubleArgument) Parse(sr *StringReader) (any, error) {
+	start := sr.Cursor()
+	val, err := sr.ReadDouble()
+	if err != nil {
+		return nil, err
+	}
+	if val < a.min || val > a.max {
+		sr.SetCursor(start)
+		return nil, sr.Errorf("Double must be between %g and %g, found %g", a.min, a.max, val)
+	}
+	return val, nil
+}
+
+func (doubleArgument) Parser() string {
+	return "brigadier:double"
+}
+
+func (a doubleArgument) WriteProperties(pw *packetutil.PacketWriter) {
+	hasMin, hasMax := !math.IsInf(a.min, -1), !math.IsInf(a.max, 1)
+	pw.WriteUnsignedByte(rangeFlags(hasMin, hasMax))
+	if hasMin {
+		pw.WriteDouble(a.min)
+	}
+	if hasMax {
+		pw.WriteDouble(a.max)
+	}
+}
+
+// StringMode selects how much input a brigadier:string argument consumes.
+type StringMode int32
+
+const (
+	// SingleWord reads a single unquoted word.
+	SingleWord StringMode = iota
+	// QuotablePhrase reads a single word, or a quoted phrase.
+	QuotablePhrase
+	// GreedyPhrase reads the rest of the input.
+	GreedyPhrase
+)
+
+type stringArgument struct {
+	mode StringMode
+}
+
+// String returns the brigadier:string argument type.
+func String(mode StringMode) ArgumentType {
+	return stringArgument{mode}
+}
+
+func (a stringArgument) Parse(sr *StringReader) (any, error) {
+	switch a.mode {
+	case GreedyPhrase:
+		val := sr.Remaining()
+		sr.SetCursor(len(sr.Input()))
+		return val, nil
+	case SingleWord:
+		return sr.ReadUnquotedString(), nil
+	}
+	return sr.ReadString()
+}
+
+func (stringArgument) Parser() string {
+	return "brigadier:string"
+}
+
+func (a stringArgument) WriteProperties(pw *packetutil.PacketWriter) {
+	pw.WriteVarInt(int32(a.mode))
+}
+
+// CustomArgument is an ArgumentType for the many minecraft: parsers, whose
+// parsing and properties depend on game data elytra doesn't model. The client
+// uses the parser identifier for highlighting and its own validation.
+type CustomArgument struct {
+	Identifier string
+	ParseFunc  func(sr *StringReader) (any, error)
+	// WriteFunc writes the parser properties, and may be nil if the parser
+	// has none.
+	WriteFunc func(pw *packetutil.PacketWriter)
+}
+
+func (a *CustomArgument) Parse(sr *StringReader) (any, error) {
+	return a.ParseFunc(sr)
+}
+
+func (a *CustomArgument) Parser() string {
+	return a.Identifier
+}
+
+func (a *CustomArgument) WriteProperties(pw *packetutil.PacketWriter) {
+	if a.WriteFunc != nil {
+		a.WriteFunc(pw)
+	}
+}
@@ -0,0 +1,319 @@
+package commandutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParsedArgument is the value of an argument node, along with where it was
+// found in the input.
+type ParsedArgument struct {
+	Value      any
+	Start, End int
+}
+
+// Context is handed to commands and suggestion providers. It holds the
+// source that issued the command and the arguments parsed so far.
+type Context struct {
+	Source    any
+	Input     string
+	arguments map[string]ParsedArgument
+	command   Command
+}
+
+func (ctx *Context) copy() *Context {
+	arguments := make(map[string]ParsedArgument, len(ctx.arguments))
+	for name, arg := range ctx.arguments {
+		arguments[name] = arg
+	}
+	return &Context{ctx.Source, ctx.Input, arguments, ctx.command}
+}
+
+// Argument returns the parsed argument with the given name.
+func (ctx *Context) Argument(name string) (ParsedArgument, bool) {
+	arg, ok := ctx.arguments[name]
+	return arg, ok
+}
+
+// Value returns the value of the named argument, or nil if it wasn't parsed.
+func (ctx *Context) Value(name string) any {
+	return ctx.arguments[name].Value
+}
+
+// Bool returns the value of a brigadier:bool argument.
+func (ctx *Context) Bool(name string) bool {
+	val, _ := ctx.Value(name).(bool)
+	return val
+}
+
+// Int returns the value of a brigadier:integer argument.
+func (ctx *Context) Int(name string) int32 {
+	val, _ := ctx.Value(name).(int32)
+	return val
+}
+
+// Long returns the value of a brigadier:long argument.
+func (ctx *Context) Long(name string) int64 {
+	val, _ := ctx.Value(name).(int64)
+	return val
+}
+
+// Float returns the value of a brigadier:float argument.
+func (ctx *Context) Float(name string) float32 {
+	val, _ := ctx.Value(name).(float32)
+	return val
+}
+
+// Double returns the value of a brigadier:double argument.
+func (ctx *Context) Double(name string) float64 {
+	val, _ := ctx.Value(name).(float64)
+	return val
+}
+
+// String returns the value of a brigadier:string argument.
+func (ctx *Context) String(name string) string {
+	val, _ := ctx.Value(name).(string)
+	return val
+}
+
+// Dispatcher holds a command tree, and parses, executes, completes and
+// encodes the commands in it.
+type Dispatcher struct {
+	root *Node
+}
+
+// CreateDispatcher is a factory function for creating a new, empty
+// Dispatcher.
+func CreateDispatcher() *Dispatcher {
+	d := new(Dispatcher)
+	d.root = &Node{kind: rootNode}
+	return d
+}
+
+// Root returns the root of the command tree, for use as a redirect target.
+func (d *Dispatcher) Root() *Node {
+	return d.root
+}
+
+// Register adds top-level commands to the tree. Each node is usually a
+// Literal naming the command.
+func (d *Dispatcher) Register(nodes ...*Node) {
+	d.root.Then(nodes...)
+}
+
+// Execute parses input, without a leading slash, and runs the command it
+// ends at.
+func (d *Dispatcher) Execute(source any, input string) (int, error) {
+	ctx, err := d.Parse(source, input)
+	if err != nil {
+		return 0, err
+	}
+	return ctx.command(ctx)
+}
+
+// Parse parses input, without a leading slash, and returns the context the
+// command would be run with.
+func (d *Dispatcher) Parse(source any, input string) (*Context, error) {
+	sr := CreateStringReader(input)
+	ctx := &Context{Source: source, Input: input, arguments: make(map[string]ParsedArgument)}
+	return parseNodes(d.root, sr, ctx)
+}
+
+func parseNodes(node *Node, sr *StringReader, ctx *Context) (*Context, error) {
+	start := sr.Cursor()
+	var bestErr *SyntaxError
+	keep := func(err error) {
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			syntaxErr = &SyntaxError{err.Error(), sr.Input(), sr.Cursor()}
+		}
+		if bestErr == nil || syntaxErr.Cursor > bestErr.Cursor {
+			bestErr = syntaxErr
+		}
+	}
+
+	for _, child := range node.relevantChildren(sr) {
+		if !child.canUse(ctx.Source) {
+			continue
+		}
+
+		sr.SetCursor(start)
+		childCtx := ctx.copy()
+		if err := child.parse(sr, childCtx); err != nil {
+			keep(err)
+			continue
+		}
+		if sr.CanRead() && sr.Peek() != ' ' {
+			keep(sr.Errorf("Expected whitespace to end one argument, but found trailing data"))
+			continue
+		}
+
+		if !sr.CanRead() {
+			if child.command == nil {
+				keep(sr.Errorf("Unknown or incomplete command, see below for error"))
+				continue
+			}
+			childCtx.command = child.command
+			return childCtx, nil
+		}
+
+		sr.Skip()
+		target := child
+		if child.redirect != nil {
+			target = child.redirect
+		}
+		result, err := parseNodes(target, sr, childCtx)
+		if err == nil {
+			return result, nil
+		}
+		keep(err)
+	}
+
+	if bestErr == nil {
+		sr.SetCursor(start)
+		return nil, sr.Errorf("Unknown or incomplete command, see below for error")
+	}
+	return nil, bestErr
+}
+
+// Suggestions holds the completions for a command line. They all replace the
+// part of the input from Start to the end.
+type Suggestions struct {
+	Start   int
+	Length  int
+	Matches []Suggestion
+}
+
+// Suggest returns tab completions for the last word of input, which has no
+// leading slash. Literal nodes complete by prefix; argument nodes complete
+// through their SuggestionProvider, if they have one.
+func (d *Dispatcher) Suggest(source any, input string) *Suggestions {
+	sr := CreateStringReader(input)
+	ctx := &Context{Source: source, Input: input, arguments: make(map[string]ParsedArgument)}
+
+	start, matches := suggestNodes(d.root, sr, ctx)
+	matches = dedupeSuggestions(matches)
+	return &Suggestions{start, len(input) - start, matches}
+}
+
+func suggestNodes(node *Node, sr *StringReader, ctx *Context) (int, []Suggestion) {
+	start := sr.Cursor()
+	bestStart := start
+	var matches []Suggestion
+
+	merge := func(at int, found []Suggestion) {
+		if len(found) == 0 {
+			return
+		}
+		if at > bestStart || len(matches) == 0 {
+			bestStart, matches = at, found
+		} else if at == bestStart {
+			matches = append(matches, found...)
+		}
+	}
+
+	partial := sr.Remaining()
+	for _, child := range node.children {
+		if !child.canUse(ctx.Source) {
+			continue
+		}
+
+		sr.SetCursor(start)
+		childCtx := ctx.copy()
+		err := child.parse(sr, childCtx)
+		if err == nil && sr.CanRead() && sr.Peek() == ' ' {
+			sr.Skip()
+			target := child
+			if child.redirect != nil {
+				target = child.redirect
+			}
+			merge(suggestNodes(target, sr, childCtx))
+			continue
+		}
+		if strings.Contains(partial, " ") && child.kind == literalNode {
+			continue
+		}
+
+		switch child.kind {
+		case literalNode:
+			if strings.HasPrefix(strings.ToLower(child.name), strings.ToLower(partial)) {
+				merge(start, []Suggestion{{Text: child.name}})
+			}
+		case argumentNode:
+			if child.suggestions != nil {
+				merge(start, child.suggestions(childCtx, partial))
+			}
+		}
+	}
+
+	sr.SetCursor(start)
+	return bestStart, matches
+}
+
+func dedupeSuggestions(matches []Suggestion) []Suggestion {
+	sort.SliceStable(matches, func(i, j int) bool {
+		return strings.ToLower(matches[i].Text) < strings.ToLower(matches[j].Text)
+	})
+	result := matches[:0]
+	for i, match := range matches {
+		if i > 0 && match.Text == matches[i-1].Text {
+			continue
+		}
+		result = append(result, match)
+	}
+	return result
+}
+
+// Usage returns the smart usage strings vanilla shows for a node, one per
+// child, such as "<player> [<reason>]".
+func (d *Dispatcher) Usage(source any, node *Node) []string {
+	var usage []string
+	for _, child := range node.children {
+		if child.canUse(source) {
+			usage = append(usage, usageOf(source, child))
+		}
+	}
+	return usage
+}
+
+func usageOf(source any, node *Node) string {
+	self := node.name
+	if node.kind == argumentNode {
+		self = fmt.Sprintf("<%s>", node.name)
+	}
+	if node.redirect != nil {
+		return self + " ..."
+	}
+
+	var usable []*Node
+	for _, child := range node.children {
+		if child.canUse(source) {
+			usable = append(usable, child)
+		}
+	}
+
+	switch {
+	case len(usable) == 0:
+		return self
+	case len(usable) == 1:
+		rest := usageOf(source, usable[0])
+		if node.command != nil {
+			return fmt.Sprintf("%s [%s]", self, rest)
+		}
+		return fmt.Sprintf("%s %s", self, rest)
+	}
+
+	names := make([]string, len(usable))
+	for i, child := range usable {
+		names[i] = child.name
+		if child.kind == argumentNode {
+			names[i] = fmt.Sprintf("<%s>", child.name)
+		}
+	}
+	joined := strings.Join(names, "|")
+	if node.command != nil {
+		return fmt.Sprintf("%s [%s]", self, joined)
+	}
+	return fmt.Sprintf("%s (%s)", self, joined)
+}
@@ -0,0 +1,165 @@
+package commandutil
+
+import "fmt"
+
+type nodeType byte
+
+// The node types, as encoded in the low bits of the Commands packet flags.
+const (
+	rootNode nodeType = iota
+	literalNode
+	argumentNode
+)
+
+// Command is run when a command line ends at an executable node. The result
+// is passed back to the caller of Execute, the same as Brigadier's command
+// result.
+type Command func(ctx *Context) (int, error)
+
+// Suggestion is a single tab-completion entry.
+type Suggestion struct {
+	Text    string
+	Tooltip string
+}
+
+// SuggestionProvider returns completions for an argument, given the part of
+// it typed so far.
+type SuggestionProvider func(ctx *Context, partial string) []Suggestion
+
+// Node is a literal or argument in the command tree. Nodes are built with
+// Literal and Argument, and chained with Then.
+type Node struct {
+	kind        nodeType
+	name        string
+	argument    ArgumentType
+	children    []*Node
+	command     Command
+	requirement func(source any) bool
+	redirect    *Node
+	suggestions SuggestionProvider
+}
+
+// Literal creates a node that matches the given word exactly.
+func Literal(name string) *Node {
+	return &Node{kind: literalNode, name: name}
+}
+
+// Argument creates a node that parses a value of the given type, stored in
+// the command context under name.
+func Argument(name string, argument ArgumentType) *Node {
+	return &Node{kind: argumentNode, name: name, argument: argument}
+}
+
+// Name returns the word matched by a literal node, or the name of an
+// argument node.
+func (n *Node) Name() string {
+	return n.name
+}
+
+// Children returns the nodes that may follow this one.
+func (n *Node) Children() []*Node {
+	return n.children
+}
+
+// Then adds children to the node and returns the node, so trees can be
+// declared in a single expression. A child with the same name as an existing
+// one replaces it.
+func (n *Node) Then(children ...*Node) *Node {
+	for _, child := range children {
+		if child.kind == rootNode {
+			panic("commandutil: the root node can't be a child")
+		}
+		replaced := false
+		for i, existing := range n.children {
+			if existing.name == child.name && existing.kind == child.kind {
+				n.children[i] = child
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			n.children = append(n.children, child)
+		}
+	}
+	return n
+}
+
+// Executes makes the node executable, so a command line may end there.
+func (n *Node) Executes(command Command) *Node {
+	n.command = command
+	return n
+}
+
+// Requires restricts the node, and everything below it, to sources for which
+// requirement returns true. Restricted nodes are also left out of the
+// Commands packet sent to those sources.
+func (n *Node) Requires(requirement func(source any) bool) *Node {
+	n.requirement = requirement
+	return n
+}
+
+// Redirect continues parsing at target once this node has been parsed, the
+// way /execute loops back on itself.
+func (n *Node) Redirect(target *Node) *Node {
+	n.redirect = target
+	return n
+}
+
+// Suggests sets the completion provider of an argument node. The client is
+// told to ask the server for completions of the argument.
+func (n *Node) Suggests(provider SuggestionProvider) *Node {
+	if n.kind != argumentNode {
+		panic(fmt.Sprintf("commandutil: only argument nodes take suggestions, %q is not one", n.name))
+	}
+	n.suggestions = provider
+	return n
+}
+
+func (n *Node) canUse(source any) bool {
+	return n.requirement == nil || n.requirement(source)
+}
+
+func (n *Node) parse(sr *StringReader, ctx *Context) error {
+	start := sr.Cursor()
+
+	if n.kind == literalNode {
+		remaining := sr.Remaining()
+		if len(remaining) >= len(n.name) && remaining[:len(n.name)] == n.name &&
+			(len(remaining) == len(n.name) || remaining[len(n.name)] == ' ') {
+			sr.SetCursor(start + len(n.name))
+			return nil
+		}
+		return sr.Errorf("Incorrect literal for argument %s", n.name)
+	}
+
+	val, err := n.argument.Parse(sr)
+	if err != nil {
+		return err
+	}
+	ctx.arguments[n.name] = ParsedArgument{val, start, sr.Cursor()}
+	return nil
+}
+
+// relevantChildren returns the children worth trying at the reader's
+// position: a literal that matches the next word shadows every argument.
+func (n *Node) relevantChildren(sr *StringReader) []*Node {
+	remaining := sr.Remaining()
+	word := remaining
+	for i := 0; i < len(remaining); i++ {
+		if remaining[i] == ' ' {
+			word = remaining[:i]
+			break
+		}
+	}
+
+	var arguments []*Node
+	for _, child := range n.children {
+		if child.kind == literalNode && child.name == word {
+			return []*Node{child}
+		}
+		if child.kind == argumentNode {
+			arguments = append(arguments, child)
+		}
+	}
+	return arguments
+}
@@ -0,0 +1,136 @@
+package commandutil
+
+import (
+	"fmt"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Node flags in the Commands packet; the low two bits hold the node type.
+const (
+	flagExecutable     = 0x04
+	flagHasRedirect    = 0x08
+	flagHasSuggestions = 0x10
+)
+
+// askServerSuggestions is the suggestion type that makes the client send a
+// Command Suggestions Request for the argument.
+const askServerSuggestions = "minecraft:ask_server"
+
+// BrigadierParserIDs maps the Brigadier parsers to their IDs in the
+// command_argument_type registry. These six have kept the same IDs since the
+// registry was introduced in 1.19; the minecraft: parsers shift between
+// versions and have to be looked up for the protocol being spoken.
+func BrigadierParserIDs(parser string) (int32, bool) {
+	switch parser {
+	case "brigadier:bool":
+		return 0, true
+	case "brigadier:float":
+		return 1, true
+	case "brigadier:double":
+		return 2, true
+	case "brigadier:integer":
+		return 3, true
+	case "brigadier:long":
+		return 4, true
+	case "brigadier:string":
+		return 5, true
+	}
+	return 0, false
+}
+
+// WriteCommands writes the body of the Commands packet, describing the part of
+// the tree available to source, to pw. parserID resolves parser identifiers
+// to registry IDs; passing nil writes the identifiers themselves, as clients
+// before 1.19 expect.
+func (d *Dispatcher) WriteCommands(pw *packetutil.PacketWriter, source any, parserID func(parser string) (int32, bool)) error {
+	nodes := []*Node{d.root}
+	indices := map[*Node]int32{d.root: 0}
+	visible := func(node *Node) bool {
+		return node.kind == rootNode || node.canUse(source)
+	}
+
+	// Number every reachable node breadth first, following redirects so
+	// their targets are part of the packet too.
+	for i := 0; i < len(nodes); i++ {
+		node := nodes[i]
+		related := node.children
+		if node.redirect != nil {
+			related = append([]*Node{node.redirect}, related...)
+		}
+		for _, other := range related {
+			if _, seen := indices[other]; seen || !visible(other) {
+				continue
+			}
+			indices[other] = int32(len(nodes))
+			nodes = append(nodes, other)
+		}
+	}
+
+	// Resolve every parser up front, so an unknown one doesn't leave the
+	// packet half written.
+	parserIDs := make(map[*Node]int32)
+	if parserID != nil {
+		for _, node := range nodes {
+			if node.kind != argumentNode {
+				continue
+			}
+			id, ok := parserID(node.argument.Parser())
+			if !ok {
+				return fmt.Errorf("no registry ID known for parser %s of argument %s", node.argument.Parser(), node.name)
+			}
+			parserIDs[node] = id
+		}
+	}
+
+	pw.WriteVarInt(int32(len(nodes)))
+	for _, node := range nodes {
+		flags := byte(node.kind)
+		if node.command != nil {
+			flags |= flagExecutable
+		}
+		redirect, hasRedirect := indices[node.redirect]
+		if node.redirect != nil && hasRedirect {
+			flags |= flagHasRedirect
+		}
+		if node.suggestions != nil {
+			flags |= flagHasSuggestions
+		}
+		pw.WriteUnsignedByte(flags)
+
+		var children []int32
+		for _, child := range node.children {
+			if index, ok := indices[child]; ok && visible(child) {
+				children = append(children, index)
+			}
+		}
+		pw.WriteVarInt(int32(len(children)))
+		for _, index := range children {
+			pw.WriteVarInt(index)
+		}
+
+		if flags&flagHasRedirect != 0 {
+			pw.WriteVarInt(redirect)
+		}
+		if node.kind == rootNode {
+			continue
+		}
+		pw.WriteString(node.name)
+		if node.kind == literalNode {
+			continue
+		}
+
+		if parserID == nil {
+			pw.WriteString(node.argument.Parser())
+		} else {
+			pw.WriteVarInt(parserIDs[node])
+		}
+		node.argument.WriteProperties(pw)
+		if node.suggestions != nil {
+			pw.WriteString(askServerSuggestions)
+		}
+	}
+	pw.WriteVarInt(0)
+
+	return nil
+}
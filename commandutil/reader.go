@@ -0,0 +1,240 @@
+package commandutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SyntaxError is returned when a command can't be parsed. Cursor is the index
+// into Input at which parsing failed.
+type SyntaxError struct {
+	Message string
+	Input   string
+	Cursor  int
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Input == "" {
+		return e.Message
+	}
+	// Mirror vanilla, which shows at most ten characters of context.
+	start := max(0, e.Cursor-10)
+	prefix := ""
+	if start > 0 {
+		prefix = "..."
+	}
+	return fmt.Sprintf("%s at position %d: %s%s<--[HERE]", e.Message, e.Cursor, prefix, e.Input[start:e.Cursor])
+}
+
+// StringReader walks over a command line, the way Brigadier's StringReader
+// does. Argument types consume their input through it.
+type StringReader struct {
+	input  string
+	cursor int
+}
+
+// CreateStringReader is a factory function for creating a new StringReader.
+func CreateStringReader(input string) *StringReader {
+	sr := new(StringReader)
+	sr.input = input
+	return sr
+}
+
+// Input returns the whole string being read.
+func (sr *StringReader) Input() string {
+	return sr.input
+}
+
+// Cursor returns the index of the next character to be read.
+func (sr *StringReader) Cursor() int {
+	return sr.cursor
+}
+
+// SetCursor moves the reader to the given index.
+func (sr *StringReader) SetCursor(cursor int) {
+	sr.cursor = cursor
+}
+
+// Remaining returns the part of the input that hasn't been read yet.
+func (sr *StringReader) Remaining() string {
+	return sr.input[sr.cursor:]
+}
+
+// CanRead reports whether there is any input left.
+func (sr *StringReader) CanRead() bool {
+	return sr.cursor < len(sr.input)
+}
+
+// Peek returns the next character without consuming it.
+func (sr *StringReader) Peek() byte {
+	return sr.input[sr.cursor]
+}
+
+// Skip consumes a single character.
+func (sr *StringReader) Skip() {
+	sr.cursor++
+}
+
+// Errorf builds a SyntaxError at the reader's current position.
+func (sr *StringReader) Errorf(format string, args ...any) error {
+	return &SyntaxError{fmt.Sprintf(format, args...), sr.input, sr.cursor}
+}
+
+func isAllowedInUnquotedString(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' ||
+		c == '_' || c == '-' || c == '.' || c == '+'
+}
+
+func isAllowedNumber(c byte) bool {
+	return c >= '0' && c <= '9' || c == '.' || c == '-'
+}
+
+func isQuote(c byte) bool {
+	return c == '"' || c == '\''
+}
+
+// ReadUnquotedString reads a run of characters that are allowed in an
+// unquoted string: letters, digits and _ - . +
+func (sr *StringReader) ReadUnquotedString() string {
+	start := sr.cursor
+	for sr.CanRead() && isAllowedInUnquotedString(sr.Peek()) {
+		sr.Skip()
+	}
+	return sr.input[start:sr.cursor]
+}
+
+// ReadQuotedString reads a string wrapped in single or double quotes, with
+// backslash escapes for the quote character and the backslash itself.
+func (sr *StringReader) ReadQuotedString() (string, error) {
+	if !sr.CanRead() {
+		return "", nil
+	}
+	quote := sr.Peek()
+	if !isQuote(quote) {
+		return "", sr.Errorf("Expected quote to start a string")
+	}
+	sr.Skip()
+
+	var result strings.Builder
+	escaped := false
+	for sr.CanRead() {
+		c := sr.Peek()
+		sr.Skip()
+		switch {
+		case escaped:
+			if c != quote && c != '\\' {
+				sr.cursor--
+				return "", sr.Errorf("Invalid escape sequence '%c' in quoted string", c)
+			}
+			result.WriteByte(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == quote:
+			return result.String(), nil
+		default:
+			result.WriteByte(c)
+		}
+	}
+	return "", sr.Errorf("Unclosed quoted string")
+}
+
+// ReadString reads either a quoted or an unquoted string.
+func (sr *StringReader) ReadString() (string, error) {
+	if sr.CanRead() && isQuote(sr.Peek()) {
+		return sr.ReadQuotedString()
+	}
+	return sr.ReadUnquotedString(), nil
+}
+
+func (sr *StringReader) readNumber(kind string) (string, error) {
+	start := sr.cursor
+	for sr.CanRead() && isAllowedNumber(sr.Peek()) {
+		sr.Skip()
+	}
+	number := sr.input[start:sr.cursor]
+	if number == "" {
+		return "", sr.Errorf("Expected %s", kind)
+	}
+	return number, nil
+}
+
+// ReadInt reads a 32-bit integer.
+func (sr *StringReader) ReadInt() (int32, error) {
+	start := sr.cursor
+	number, err := sr.readNumber("integer")
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseInt(number, 10, 32)
+	if err != nil {
+		sr.cursor = start
+		return 0, sr.Errorf("Invalid integer '%s'", number)
+	}
+	return int32(val), nil
+}
+
+// ReadLong reads a 64-bit integer.
+func (sr *StringReader) ReadLong() (int64, error) {
+	start := sr.cursor
+	number, err := sr.readNumber("long")
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseInt(number, 10, 64)
+	if err != nil {
+		sr.cursor = start
+		return 0, sr.Errorf("Invalid long '%s'", number)
+	}
+	return val, nil
+}
+
+// ReadFloat reads a 32-bit floating point number.
+func (sr *StringReader) ReadFloat() (float32, error) {
+	start := sr.cursor
+	number, err := sr.readNumber("float")
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseFloat(number, 32)
+	if err != nil {
+		sr.cursor = start
+		return 0, sr.Errorf("Invalid float '%s'", number)
+	}
+	return float32(val), nil
+}
+
+// ReadDouble reads a 64-bit floating point number.
+func (sr *StringReader) ReadDouble() (float64, error) {
+	start := sr.cursor
+	number, err := sr.readNumber("double")
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		sr.cursor = start
+		return 0, sr.Errorf("Invalid double '%s'", number)
+	}
+	return val, nil
+}
+
+// ReadBoolean reads the literal true or false.
+func (sr *StringReader) ReadBoolean() (bool, error) {
+	start := sr.cursor
+	val, err := sr.ReadString()
+	if err != nil {
+		return false, err
+	}
+	switch val {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "":
+		return false, sr.Errorf("Expected bool")
+	}
+	sr.cursor = start
+	return false, sr.Errorf("Invalid bool, expected true or false but found '%s'", val)
+}
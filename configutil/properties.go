@@ -0,0 +1,239 @@
+package configutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Properties is a Java .properties file. Keys keep the order they were read
+// in, and new keys are added at the end.
+type Properties struct {
+	keys   []string
+	values map[string]string
+}
+
+// CreateProperties is a factory function for creating a new, empty
+// Properties.
+func CreateProperties() *Properties {
+	p := new(Properties)
+	p.values = make(map[string]string)
+	return p
+}
+
+// ReadProperties parses a .properties file, handling comments, the =, : and
+// whitespace separators, line continuations and backslash escapes.
+func ReadProperties(r io.Reader) (*Properties, error) {
+	p := CreateProperties()
+	scanner := bufio.NewScanner(r)
+
+	var logical strings.Builder
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if logical.Len() == 0 {
+			line = strings.TrimLeft(line, " \t\f")
+			if line == "" || line[0] == '#' || line[0] == '!' {
+				continue
+			}
+		} else {
+			line = strings.TrimLeft(line, " \t\f")
+		}
+
+		// An odd number of trailing backslashes continues the line.
+		trailing := len(line) - len(strings.TrimRight(line, "\\"))
+		if trailing%2 == 1 {
+			logical.WriteString(line[:len(line)-1])
+			continue
+		}
+		logical.WriteString(line)
+
+		key, val, err := splitProperty(logical.String())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		p.Set(key, val)
+		logical.Reset()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if logical.Len() > 0 {
+		key, val, err := splitProperty(logical.String())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		p.Set(key, val)
+	}
+	return p, nil
+}
+
+func splitProperty(line string) (string, string, error) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' {
+			i++
+			continue
+		}
+		if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+			end = i
+			break
+		}
+	}
+
+	rest := strings.TrimLeft(line[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+
+	key, err := unescape(line[:end])
+	if err != nil {
+		return "", "", err
+	}
+	val, err := unescape(rest)
+	return key, val, err
+}
+
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'u':
+			if i+4 >= len(s) {
+				return "", fmt.Errorf("truncated unicode escape")
+			}
+			code, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("invalid unicode escape \\u%s", s[i+1:i+5])
+			}
+			i += 4
+			r := rune(code)
+			// Characters outside the BMP are escaped as a surrogate pair,
+			// as escape writes them.
+			if utf16.IsSurrogate(r) && i+6 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+				if low, err := strconv.ParseUint(s[i+3:i+7], 16, 16); err == nil {
+					if pair := utf16.DecodeRune(r, rune(low)); pair != utf8.RuneError {
+						r = pair
+						i += 6
+					}
+				}
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String(), nil
+}
+
+func escape(s string, isKey bool) string {
+	var sb strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			sb.WriteString(`\\`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\f':
+			sb.WriteString(`\f`)
+		case r == '=' || r == ':' || r == '#' || r == '!':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r == ' ' && (isKey || i == 0):
+			sb.WriteString(`\ `)
+		case r < 0x20 || r > 0x7E:
+			// Java writes everything outside printable ASCII as escapes;
+			// characters outside the BMP become surrogate pairs.
+			if r > 0xFFFF {
+				r -= 0x10000
+				fmt.Fprintf(&sb, `\u%04X\u%04X`, 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+			} else {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// Get returns the value of a key.
+func (p *Properties) Get(key string) (string, bool) {
+	val, ok := p.values[key]
+	return val, ok
+}
+
+// Set sets the value of a key, adding it at the end if it is new.
+func (p *Properties) Set(key, val string) {
+	if _, ok := p.values[key]; !ok {
+		p.keys = append(p.keys, key)
+	}
+	p.values[key] = val
+}
+
+// Delete removes a key.
+func (p *Properties) Delete(key string) {
+	if _, ok := p.values[key]; !ok {
+		return
+	}
+	delete(p.values, key)
+	for i, existing := range p.keys {
+		if existing == key {
+			p.keys = append(p.keys[:i], p.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns the keys in order.
+func (p *Properties) Keys() []string {
+	return append([]string(nil), p.keys...)
+}
+
+// Write writes the properties sorted by key, after a comment header and
+// the current date, the way vanilla saves server.properties.
+func (p *Properties) Write(w io.Writer, comment string) error {
+	bw := bufio.NewWriter(w)
+	if comment != "" {
+		for _, line := range strings.Split(comment, "\n") {
+			fmt.Fprintf(bw, "#%s\n", line)
+		}
+	}
+	fmt.Fprintf(bw, "#%s\n", time.Now().Format("Mon Jan 02 15:04:05 MST 2006"))
+
+	keys := p.Keys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(bw, "%s=%s\n", escape(key, true), escape(p.values[key], false))
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,288 @@
+package configutil
+
+import (
+	"io"
+	"math"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/PurpurProject/elytra/connutil"
+)
+
+// ServerPropertiesFile is the file name of the server properties, relative
+// to the server directory.
+const ServerPropertiesFile = "server.properties"
+
+// ServerProperties is the typed contents of server.properties. Each field is
+// tagged with its key; keys without a field are kept as they were read and
+// written back on save.
+type ServerProperties struct {
+	ServerIP                    string `property:"server-ip"`
+	ServerPort                  int    `property:"server-port"`
+	MOTD                        string `property:"motd"`
+	OnlineMode                  bool   `property:"online-mode"`
+	PreventProxyConnections     bool   `property:"prevent-proxy-connections"`
+	EnforceSecureProfile        bool   `property:"enforce-secure-profile"`
+	MaxPlayers                  int    `property:"max-players"`
+	ViewDistance                int    `property:"view-distance"`
+	SimulationDistance          int    `property:"simulation-distance"`
+	NetworkCompressionThreshold int    `property:"network-compression-threshold"`
+	RateLimit                   int    `property:"rate-limit"`
+	PlayerIdleTimeout           int    `property:"player-idle-timeout"`
+	AcceptsTransfers            bool   `property:"accepts-transfers"`
+	HideOnlinePlayers           bool   `property:"hide-online-players"`
+	EnableStatus                bool   `property:"enable-status"`
+
+	WhiteList         bool `property:"white-list"`
+	EnforceWhitelist  bool `property:"enforce-whitelist"`
+	OpPermissionLevel int  `property:"op-permission-level"`
+	SpawnProtection   int  `property:"spawn-protection"`
+
+	LevelName     string `property:"level-name"`
+	LevelSeed     string `property:"level-seed"`
+	LevelType     string `property:"level-type"`
+	Gamemode      string `property:"gamemode"`
+	ForceGamemode bool   `property:"force-gamemode"`
+	Difficulty    string `property:"difficulty"`
+	Hardcore      bool   `property:"hardcore"`
+	PVP           bool   `property:"pvp"`
+	AllowFlight   bool   `property:"allow-flight"`
+	AllowNether   bool   `property:"allow-nether"`
+	MaxWorldSize  int    `property:"max-world-size"`
+	MaxTickTime   int    `property:"max-tick-time"`
+
+	ResourcePack        string `property:"resource-pack"`
+	ResourcePackSHA1    string `property:"resource-pack-sha1"`
+	ResourcePackID      string `property:"resource-pack-id"`
+	ResourcePackPrompt  string `property:"resource-pack-prompt"`
+	RequireResourcePack bool   `property:"require-resource-pack"`
+
+	EnableQuery  bool   `property:"enable-query"`
+	QueryPort    int    `property:"query.port"`
+	EnableRcon   bool   `property:"enable-rcon"`
+	RconPort     int    `property:"rcon.port"`
+	RconPassword string `property:"rcon.password"`
+
+	// other holds the keys without a field.
+	other *Properties
+}
+
+// DefaultServerProperties returns the properties vanilla writes for a new
+// server.
+func DefaultServerProperties() *ServerProperties {
+	sp := new(ServerProperties)
+	sp.ServerPort = 25565
+	sp.MOTD = "A Minecraft Server"
+	sp.OnlineMode = true
+	sp.EnforceSecureProfile = true
+	sp.MaxPlayers = 20
+	sp.ViewDistance = 10
+	sp.SimulationDistance = 10
+	sp.NetworkCompressionThreshold = 256
+	sp.EnableStatus = true
+	sp.OpPermissionLevel = 4
+	sp.SpawnProtection = 16
+	sp.LevelName = "world"
+	sp.LevelType = "minecraft:normal"
+	sp.Gamemode = "survival"
+	sp.Difficulty = "easy"
+	sp.PVP = true
+	sp.AllowNether = true
+	sp.MaxWorldSize = 29999984
+	sp.MaxTickTime = 60000
+	sp.QueryPort = 25565
+	sp.RconPort = 25575
+	sp.other = CreateProperties()
+	return sp
+}
+
+// ReadServerProperties parses server.properties. Keys missing from the file
+// keep their defaults.
+func ReadServerProperties(r io.Reader) (*ServerProperties, error) {
+	p, err := ReadProperties(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sp := DefaultServerProperties()
+	v := reflect.ValueOf(sp).Elem()
+	t := v.Type()
+	known := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("property")
+		if key == "" {
+			continue
+		}
+		known[key] = true
+
+		val, ok := p.Get(key)
+		if !ok {
+			continue
+		}
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(val)
+		case reflect.Int:
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				// Vanilla falls back to the default for unparseable values.
+				continue
+			}
+			field.SetInt(int64(n))
+		case reflect.Bool:
+			// Like Java's Boolean.parseBoolean, anything but "true" in any
+			// case is false.
+			field.SetBool(strings.EqualFold(val, "true"))
+		}
+	}
+
+	for _, key := range p.Keys() {
+		if !known[key] {
+			val, _ := p.Get(key)
+			sp.other.Set(key, val)
+		}
+	}
+	return sp, nil
+}
+
+// LoadServerProperties reads the server.properties at path. A missing file
+// gives the defaults.
+func LoadServerProperties(path string) (*ServerProperties, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return DefaultServerProperties(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadServerProperties(file)
+}
+
+// Properties returns every key, typed and not, as untyped properties.
+func (sp *ServerProperties) Properties() *Properties {
+	p := CreateProperties()
+	if sp.other != nil {
+		for _, key := range sp.other.Keys() {
+			val, _ := sp.other.Get(key)
+			p.Set(key, val)
+		}
+	}
+
+	v := reflect.ValueOf(sp).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("property")
+		if key == "" {
+			continue
+		}
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			p.Set(key, field.String())
+		case reflect.Int:
+			p.Set(key, strconv.FormatInt(field.Int(), 10))
+		case reflect.Bool:
+			p.Set(key, strconv.FormatBool(field.Bool()))
+		}
+	}
+	return p
+}
+
+// Get returns the value of a key that has no typed field.
+func (sp *ServerProperties) Get(key string) (string, bool) {
+	if sp.other == nil {
+		return "", false
+	}
+	return sp.other.Get(key)
+}
+
+// Set sets the value of a key that has no typed field. Typed keys must be
+// set through their field, or the field's value will overwrite them on save.
+func (sp *ServerProperties) Set(key, val string) {
+	if sp.other == nil {
+		sp.other = CreateProperties()
+	}
+	sp.other.Set(key, val)
+}
+
+// Write writes the properties in vanilla's format.
+func (sp *ServerProperties) Write(w io.Writer) error {
+	return sp.Properties().Write(w, "Minecraft server properties")
+}
+
+// Save writes the properties to the file at path. They are written to a
+// new file first and renamed over the old one, so a crash part way through
+// leaves the old file whole.
+func (sp *ServerProperties) Save(path string) error {
+	file, err := os.Create(path + "_new")
+	if err != nil {
+		return err
+	}
+	err = sp.Write(file)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + "_new")
+		return err
+	}
+	return os.Rename(path+"_new", path)
+}
+
+// Address returns the host and port to listen on.
+func (sp *ServerProperties) Address() string {
+	return net.JoinHostPort(sp.ServerIP, strconv.Itoa(sp.ServerPort))
+}
+
+// CompressionThreshold returns the threshold to pass to Conn.SetCompression:
+// packets of at least this many bytes are compressed, and -1, which any
+// negative network-compression-threshold becomes, turns compression off.
+func (sp *ServerProperties) CompressionThreshold() int32 {
+	if sp.NetworkCompressionThreshold < 0 {
+		return -1
+	}
+	return int32(min(sp.NetworkCompressionThreshold, math.MaxInt32))
+}
+
+// ListenerLimits returns the per-IP limits to create the Listener with.
+// Vanilla has no keys for these, so they start from
+// connutil.DefaultListenerLimits and are only changed by the untyped keys
+// connection-rate, connection-burst, max-connections-per-ip, status-rate
+// and status-burst where the file has them. rate-limit, vanilla's cap on
+// the packets a player sends each second, isn't a Listener limit; it is
+// left to the caller to enforce on each connection.
+func (sp *ServerProperties) ListenerLimits() connutil.ListenerLimits {
+	limits := connutil.DefaultListenerLimits
+	sp.getFloat("connection-rate", &limits.ConnectionRate)
+	sp.getInt("connection-burst", &limits.ConnectionBurst)
+	sp.getInt("max-connections-per-ip", &limits.MaxConnections)
+	sp.getFloat("status-rate", &limits.StatusRate)
+	sp.getInt("status-burst", &limits.StatusBurst)
+	return limits
+}
+
+// getInt and getFloat parse an untyped key into val, leaving val as it is
+// if the key is missing or unparseable, as vanilla does.
+func (sp *ServerProperties) getInt(key string, val *int) {
+	if s, ok := sp.Get(key); ok {
+		if n, err := strconv.Atoi(s); err == nil {
+			*val = n
+		}
+	}
+}
+
+func (sp *ServerProperties) getFloat(key string, val *float64) {
+	if s, ok := sp.Get(key); ok {
+		if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(f) {
+			*val = f
+		}
+	}
+}
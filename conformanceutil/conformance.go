@@ -0,0 +1,189 @@
+// Package conformanceutil checks packet definitions against captured vanilla
+// packets. Fixtures are laid out on disk as
+//
+//	<root>/<protocol version>/<state>/<direction>/<name>.bin
+//
+// where state is one of handshaking, status, login, configuration or play,
+// direction is serverbound or clientbound, and each file holds exactly one
+// packet: its VarInt packet ID followed by its body, without the length
+// prefix or compression.
+package conformanceutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/PurpurProject/elytra/packetutil"
+	"github.com/PurpurProject/elytra/sessionutil"
+)
+
+// Fixture is a single captured packet.
+type Fixture struct {
+	Path      string
+	Version   int32
+	State     string
+	Direction sessionutil.Direction
+	Packet    packetutil.Packet
+}
+
+// RoundTripper decodes the fixture's packet with the definition under test
+// and encodes it again, returning the re-encoded body without the packet ID.
+type RoundTripper func(f *Fixture) ([]byte, error)
+
+// Failure describes a fixture that did not survive a round trip.
+type Failure struct {
+	Fixture *Fixture
+	// Err is set if the round trip itself failed.
+	Err error
+	// Offset is the index of the first byte that differs between the
+	// fixture and the re-encoded body, if the round trip succeeded.
+	Offset int
+	Got    []byte
+}
+
+func (f *Failure) Error() string {
+	if f.Err != nil {
+		return fmt.Sprintf("%s: %v", f.Fixture.Path, f.Err)
+	}
+	return fmt.Sprintf("%s: re-encoded packet 0x%02X differs at byte %d (want %d bytes, got %d)",
+		f.Fixture.Path, f.Fixture.Packet.ID, f.Offset, len(f.Fixture.Packet.Data), len(f.Got))
+}
+
+// LoadFixtures walks root and loads every fixture beneath it, sorted by path.
+// Files that don't end in .bin are ignored, but a .bin file in the wrong
+// place or without a readable packet ID is an error.
+func LoadFixtures(root string) ([]*Fixture, error) {
+	var fixtures []*Fixture
+
+	err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".bin" {
+			return nil
+		}
+
+		fixture, err := loadFixture(root, path)
+		if err != nil {
+			return err
+		}
+		fixtures = append(fixtures, fixture)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(fixtures, func(i, j int) bool {
+		return fixtures[i].Path < fixtures[j].Path
+	})
+	return fixtures, nil
+}
+
+func loadFixture(root, path string) (*Fixture, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("%s: expected <version>/<state>/<direction>/<name>.bin", path)
+	}
+
+	fixture := &Fixture{Path: path, State: parts[1]}
+
+	version, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid protocol version %q", path, parts[0])
+	}
+	fixture.Version = int32(version)
+
+	switch parts[1] {
+	case "handshaking", "status", "login", "configuration", "play":
+	default:
+		return nil, fmt.Errorf("%s: unknown state %q", path, parts[1])
+	}
+
+	switch parts[2] {
+	case "serverbound":
+		fixture.Direction = sessionutil.Serverbound
+	case "clientbound":
+		fixture.Direction = sessionutil.Clientbound
+	default:
+		return nil, fmt.Errorf("%s: unknown direction %q", path, parts[2])
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pr := packetutil.CreatePacketReader(data)
+	fixture.Packet.ID, err = pr.ReadVarInt()
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not read packet ID: %w", path, err)
+	}
+	offset, _ := pr.Seek(0, io.SeekCurrent)
+	fixture.Packet.Data = data[offset:]
+
+	return fixture, nil
+}
+
+// Check round trips every fixture and returns those that failed.
+func Check(fixtures []*Fixture, rt RoundTripper) []*Failure {
+	var failures []*Failure
+	for _, fixture := range fixtures {
+		if failure := check(fixture, rt); failure != nil {
+			failures = append(failures, failure)
+		}
+	}
+	return failures
+}
+
+func check(fixture *Fixture, rt RoundTripper) *Failure {
+	got, err := rt(fixture)
+	if err != nil {
+		return &Failure{Fixture: fixture, Err: err}
+	}
+
+	want := fixture.Packet.Data
+	if bytes.Equal(got, want) {
+		return nil
+	}
+
+	offset := 0
+	for offset < len(got) && offset < len(want) && got[offset] == want[offset] {
+		offset++
+	}
+	return &Failure{Fixture: fixture, Offset: offset, Got: got}
+}
+
+// Run loads the fixtures under root and round trips each of them in its own
+// subtest, named after its path relative to root. It fails the test if root
+// contains no fixtures, so a misplaced directory doesn't pass silently.
+func Run(t *testing.T, root string, rt RoundTripper) {
+	t.Helper()
+
+	fixtures, err := LoadFixtures(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatalf("no fixtures found under %s", root)
+	}
+
+	for _, fixture := range fixtures {
+		name, _ := filepath.Rel(root, fixture.Path)
+		t.Run(filepath.ToSlash(name), func(t *testing.T) {
+			if failure := check(fixture, rt); failure != nil {
+				t.Error(failure)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package connutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+)
+
+// cfb8 is AES in 8-bit cipher feedback mode, which the protocol encrypts
+// with. The standard library only has full-block CFB.
+type cfb8 struct {
+	block   cipher.Block
+	iv      []byte
+	out     []byte
+	decrypt bool
+}
+
+// newCFB8 returns the stream for one direction of a connection. The shared
+// secret doubles as the key and the IV.
+func newCFB8(secret []byte, decrypt bool) (cipher.Stream, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	c := new(cfb8)
+	c.block = block
+	c.iv = append([]byte(nil), secret...)
+	c.out = make([]byte, block.BlockSize())
+	c.decrypt = decrypt
+	return c, nil
+}
+
+func (c *cfb8) XORKeyStream(dst, src []byte) {
+	for i, in := range src {
+		c.block.Encrypt(c.out, c.iv)
+		out := in ^ c.out[0]
+		copy(c.iv, c.iv[1:])
+		if c.decrypt {
+			c.iv[len(c.iv)-1] = in
+		} else {
+			c.iv[len(c.iv)-1] = out
+		}
+		dst[i] = out
+	}
+}
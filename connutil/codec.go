@@ -0,0 +1,90 @@
+package connutil
+
+import (
+	"fmt"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Codec replaces zlib for compressed packets on a link where both ends
+// have agreed to it, such as between a proxy and its backends. Frames are
+// laid out as usual, with the data length before the compressed payload.
+// Vanilla only speaks zlib, so a Codec must never be set on a connection to
+// a client.
+//
+// elytra ships Snappy; others, such as zstd, can be plugged in by
+// implementing Codec.
+type Codec interface {
+	// Name identifies the codec during negotiation.
+	Name() string
+	// Compress appends the compressed payload to dst.
+	Compress(dst, payload []byte) []byte
+	// Decompress returns the payload, which the frame says is size bytes.
+	Decompress(data []byte, size int) ([]byte, error)
+}
+
+// CodecChannel is the login plugin channel codecs are negotiated on. The
+// backend sends a Login Plugin Request on it before Set Compression, with
+// CodecOffer as its data. A vanilla client answers that it doesn't
+// understand, and the link keeps zlib.
+const CodecChannel = "elytra:compression"
+
+// CodecOffer returns the data of the Login Plugin Request that offers
+// codecs, most preferred first.
+func CodecOffer(codecs ...Codec) []byte {
+	data := appendVarInt(nil, int32(len(codecs)))
+	for _, codec := range codecs {
+		data = appendString(data, codec.Name())
+	}
+	return data
+}
+
+// ChooseCodec picks the first offered codec the connecting end supports,
+// returning it and the data of the successful Login Plugin Response. It
+// returns a nil codec if there is none in common, and the response should
+// then be sent as unsuccessful.
+func ChooseCodec(offer []byte, supported ...Codec) (Codec, []byte, error) {
+	pr := packetutil.CreatePacketReader(offer)
+	count, err := pr.ReadVarInt()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read codec offer: %w", err)
+	}
+	for range count {
+		name, err := pr.ReadString()
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read codec offer: %w", err)
+		}
+		if codec := findCodec(name, supported); codec != nil {
+			return codec, appendString(nil, name), nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// AcceptCodec returns the codec chosen in a successful Login Plugin
+// Response; unsuccessful responses leave the link on zlib.
+func AcceptCodec(response []byte, offered ...Codec) (Codec, error) {
+	name, err := packetutil.CreatePacketReader(response).ReadString()
+	if err != nil {
+		return nil, fmt.Errorf("could not read codec choice: %w", err)
+	}
+	codec := findCodec(name, offered)
+	if codec == nil {
+		return nil, fmt.Errorf("codec %q was not offered", name)
+	}
+	return codec, nil
+}
+
+func findCodec(name string, codecs []Codec) Codec {
+	for _, codec := range codecs {
+		if codec.Name() == name {
+			return codec
+		}
+	}
+	return nil
+}
+
+func appendString(data []byte, val string) []byte {
+	data = appendVarInt(data, int32(len(val)))
+	return append(data, val...)
+}
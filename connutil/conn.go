@@ -0,0 +1,414 @@
+package connutil
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/cipher"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// MaxFrameSize is the largest frame the protocol allows: the longest length
+// a three byte VarInt prefix can hold.
+const MaxFrameSize = 1<<21 - 1
+
+// MaxDecompressedSize is the largest size a compressed packet may claim to
+// decompress to, as in vanilla.
+const MaxDecompressedSize = 1 << 23
+
+// Conn is a connection speaking the framed protocol: length-prefixed
+// packets, optionally compressed once Set Compression has been sent, and
+// optionally encrypted once the shared secret is agreed. Reads and writes
+// may happen on different goroutines; writes are serialised.
+type Conn struct {
+	conn      net.Conn
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	readLock sync.Mutex
+	reader   *bufio.Reader
+	decrypt  cipher.Stream
+	inflate  Codec
+
+	writeLock sync.Mutex
+	writer    io.Writer
+	deflater  *zlib.Writer
+	level     int
+	tuning    CompressionHeuristics
+	deflate   Codec
+	throttle  *tokenBucket
+
+	// limits is guarded by both locks, so either side can read it.
+	limits SizeLimits
+
+	// Compression thresholds, or -1 for none. The two sides of a
+	// connection switch at different points of the stream, so they are set
+	// separately.
+	readThreshold  atomic.Int32
+	writeThreshold atomic.Int32
+}
+
+// CreateConn is a factory function for creating a new Conn over a network
+// connection, with compression and encryption off.
+func CreateConn(conn net.Conn) *Conn {
+	c := new(Conn)
+	c.conn = conn
+	c.closed = make(chan struct{})
+	c.reader = bufio.NewReader(conn)
+	c.writer = conn
+	c.level = zlib.DefaultCompression
+	c.limits = DefaultSizeLimits
+	c.readThreshold.Store(-1)
+	c.writeThreshold.Store(-1)
+	return c
+}
+
+// NetConn returns the underlying network connection.
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+// SetCompression sets the compression threshold for both directions, or
+// turns compression off if it is negative. A server calls it right after
+// writing Set Compression; a client right after reading it.
+func (c *Conn) SetCompression(threshold int32) {
+	c.SetReadCompression(threshold)
+	c.SetWriteCompression(threshold)
+}
+
+// SetReadCompression sets the compression threshold for packets read.
+func (c *Conn) SetReadCompression(threshold int32) {
+	c.readThreshold.Store(max(threshold, -1))
+}
+
+// SetWriteCompression sets the compression threshold for packets written.
+func (c *Conn) SetWriteCompression(threshold int32) {
+	c.writeThreshold.Store(max(threshold, -1))
+}
+
+// WriteThreshold returns the compression threshold for packets written, or
+// -1 if compression is off.
+func (c *Conn) WriteThreshold() int32 {
+	return c.writeThreshold.Load()
+}
+
+// CompressionHeuristics decide when a packet at or above the threshold is
+// sent uncompressed anyway, with a data length of 0. The vanilla decoder
+// accepts that at any size, but some proxies reject uncompressed packets
+// above their threshold, so heuristics are off unless set.
+type CompressionHeuristics struct {
+	// MinSize is the smallest payload worth compressing, for thresholds set
+	// lower than that.
+	MinSize int
+	// SkipIncompressible skips payloads that look compressed already, and
+	// sends a payload uncompressed when compressing it saved nothing.
+	SkipIncompressible bool
+}
+
+// SetCompressionLevel sets the zlib level packets are written with, from
+// zlib.HuffmanOnly to zlib.BestCompression.
+func (c *Conn) SetCompressionLevel(level int) error {
+	if level < zlib.HuffmanOnly || level > zlib.BestCompression {
+		return fmt.Errorf("compression level of %d is out of range", level)
+	}
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if c.level != level {
+		c.level = level
+		c.deflater = nil
+	}
+	return nil
+}
+
+// SetCompressionHeuristics sets when packets above the threshold are sent
+// uncompressed.
+func (c *Conn) SetCompressionHeuristics(h CompressionHeuristics) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	c.tuning = h
+}
+
+// SetCodec switches both directions from zlib to a codec negotiated with
+// the other end, or back to zlib if codec is nil. Like compression, it takes
+// effect from the next packet.
+func (c *Conn) SetCodec(codec Codec) {
+	c.readLock.Lock()
+	c.inflate = codec
+	c.readLock.Unlock()
+
+	c.writeLock.Lock()
+	c.deflate = codec
+	c.writeLock.Unlock()
+}
+
+// EnableEncryption turns on AES/CFB8 encryption in both directions with the
+// shared secret from Encryption Response. Bytes already buffered but not
+// yet read are decrypted as they are read, so it is safe to call between
+// any two packets.
+func (c *Conn) EnableEncryption(secret []byte) error {
+	decrypt, err := newCFB8(secret, true)
+	if err != nil {
+		return err
+	}
+	encrypt, err := newCFB8(secret, false)
+	if err != nil {
+		return err
+	}
+
+	c.readLock.Lock()
+	c.decrypt = decrypt
+	c.readLock.Unlock()
+
+	c.writeLock.Lock()
+	c.writer = cipher.StreamWriter{S: encrypt, W: c.conn}
+	c.writeLock.Unlock()
+	return nil
+}
+
+func (c *Conn) readByte() (byte, error) {
+	b, err := c.reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if c.decrypt != nil {
+		buf := [1]byte{b}
+		c.decrypt.XORKeyStream(buf[:], buf[:])
+		b = buf[0]
+	}
+	return b, nil
+}
+
+func (c *Conn) readFull(buf []byte) error {
+	if _, err := io.ReadFull(c.reader, buf); err != nil {
+		return err
+	}
+	if c.decrypt != nil {
+		c.decrypt.XORKeyStream(buf, buf)
+	}
+	return nil
+}
+
+func (c *Conn) readVarInt() (int32, error) {
+	var result int32
+	for i := 0; ; i++ {
+		if i == 5 {
+			return 0, fmt.Errorf("varint was over five bytes without termination")
+		}
+		b, err := c.readByte()
+		if err != nil {
+			return 0, err
+		}
+		result |= int32(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			return result, nil
+		}
+	}
+}
+
+// ReadPacket reads the next packet, undoing compression.
+func (c *Conn) ReadPacket() (*packetutil.Packet, error) {
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+
+	size, err := c.readVarInt()
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("frame length of %d is out of range", size)
+	}
+	if int(size) > c.limits.Frame {
+		return nil, &PacketSizeError{Size: int(size), Limit: c.limits.Frame}
+	}
+	frame := make([]byte, size)
+	if err := c.readFull(frame); err != nil {
+		return nil, err
+	}
+
+	payload := frame
+	if threshold := c.readThreshold.Load(); threshold >= 0 {
+		if payload, err = decompress(frame, threshold, c.inflate, c.limits.Decompressed); err != nil {
+			return nil, err
+		}
+	}
+
+	pr := packetutil.CreatePacketReader(payload)
+	packetID, err := pr.ReadVarInt()
+	if err != nil {
+		return nil, fmt.Errorf("could not read packet ID: %w", err)
+	}
+	offset, _ := pr.Seek(0, io.SeekCurrent)
+	return &packetutil.Packet{ID: packetID, Data: payload[offset:]}, nil
+}
+
+func decompress(frame []byte, threshold int32, codec Codec, limit int) ([]byte, error) {
+	pr := packetutil.CreatePacketReader(frame)
+	dataSize, err := pr.ReadVarInt()
+	if err != nil {
+		return nil, fmt.Errorf("compressed frame is missing its data length")
+	}
+	offset, _ := pr.Seek(0, io.SeekCurrent)
+	if dataSize == 0 {
+		return frame[offset:], nil
+	}
+	if dataSize < threshold {
+		return nil, fmt.Errorf("compressed packet of %d bytes is below the threshold of %d", dataSize, threshold)
+	}
+	if int(dataSize) > limit {
+		return nil, &PacketSizeError{Size: int(dataSize), Limit: limit, Decompressed: true}
+	}
+	if codec != nil {
+		return codec.Decompress(frame[offset:], int(dataSize))
+	}
+
+	inflater, err := zlib.NewReader(bytes.NewReader(frame[offset:]))
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress packet: %w", err)
+	}
+	defer inflater.Close()
+
+	decompressed := make([]byte, dataSize)
+	if _, err := io.ReadFull(inflater, decompressed); err != nil {
+		return nil, fmt.Errorf("could not decompress packet: %w", err)
+	}
+	return decompressed, nil
+}
+
+func appendVarInt(data []byte, val int32) []byte {
+	uval := uint32(val)
+	for uval >= 0x80 {
+		data = append(data, byte(uval)|0x80)
+		uval >>= 7
+	}
+	return append(data, byte(uval))
+}
+
+// WritePacket writes a packet, compressing it if compression is on and it
+// is at least as large as the threshold.
+func (c *Conn) WritePacket(p *packetutil.Packet) error {
+	payload := appendVarInt(make([]byte, 0, 5+len(p.Data)), p.ID)
+	payload = append(payload, p.Data...)
+	return c.writePayload(payload)
+}
+
+// WriteFrame writes a packet built with PacketWriter, whose GetPacket
+// returns it framed for an uncompressed connection. It is reframed for the
+// connection's compression.
+func (c *Conn) WriteFrame(frame []byte) error {
+	pr := packetutil.CreatePacketReader(frame)
+	size, err := pr.ReadVarInt()
+	if err != nil {
+		return err
+	}
+	offset, _ := pr.Seek(0, io.SeekCurrent)
+	if int(offset)+int(size) != len(frame) {
+		return fmt.Errorf("frame length of %d doesn't match its %d bytes", size, len(frame)-int(offset))
+	}
+	return c.writePayload(frame[offset:])
+}
+
+func (c *Conn) writePayload(payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	// Compression happens under the lock so one deflater can be reused.
+	var body []byte
+	threshold := c.writeThreshold.Load()
+	if threshold >= 0 && len(payload) > c.limits.Decompressed {
+		return &PacketSizeError{Size: len(payload), Limit: c.limits.Decompressed, Decompressed: true, Outbound: true}
+	}
+	switch {
+	case threshold < 0:
+		body = payload
+	case len(payload) < int(threshold) || !c.worthCompressing(payload):
+		body = append([]byte{0}, payload...)
+	default:
+		var err error
+		if body, err = c.compress(payload); err != nil {
+			return err
+		}
+	}
+	if len(body) > c.limits.Frame {
+		return &PacketSizeError{Size: len(body), Limit: c.limits.Frame, Outbound: true}
+	}
+
+	frame := appendVarInt(make([]byte, 0, 3+len(body)), int32(len(body)))
+	frame = append(frame, body...)
+	c.wait(len(frame))
+	_, err := c.writer.Write(frame)
+	return err
+}
+
+func (c *Conn) compress(payload []byte) ([]byte, error) {
+	if c.deflate != nil {
+		body := appendVarInt(nil, int32(len(payload)))
+		if body = c.deflate.Compress(body, payload); c.tuning.SkipIncompressible && len(body) > len(payload) {
+			return append([]byte{0}, payload...), nil
+		}
+		return body, nil
+	}
+
+	var compressed bytes.Buffer
+	compressed.Write(appendVarInt(nil, int32(len(payload))))
+	if c.deflater == nil {
+		// The level is checked when it is set.
+		c.deflater, _ = zlib.NewWriterLevel(&compressed, c.level)
+	} else {
+		c.deflater.Reset(&compressed)
+	}
+	c.deflater.Write(payload)
+	if err := c.deflater.Close(); err != nil {
+		return nil, err
+	}
+
+	if c.tuning.SkipIncompressible && compressed.Len() > len(payload) {
+		return append([]byte{0}, payload...), nil
+	}
+	return compressed.Bytes(), nil
+}
+
+// incompressibleSample is how much of a payload is looked at to guess
+// whether it is compressed already.
+const incompressibleSample = 512
+
+func (c *Conn) worthCompressing(payload []byte) bool {
+	if len(payload) < c.tuning.MinSize {
+		return false
+	}
+	if !c.tuning.SkipIncompressible || len(payload) < incompressibleSample {
+		return true
+	}
+
+	// Compressed and encrypted data uses nearly every byte value evenly, so
+	// a sample with close to eight bits of entropy per byte won't shrink.
+	// The sample is taken from the middle to skip headers and packet fields.
+	start := (len(payload) - incompressibleSample) / 2
+	var counts [256]int
+	for _, b := range payload[start : start+incompressibleSample] {
+		counts[b]++
+	}
+	entropy := 0.0
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / incompressibleSample
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy < 7.5
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return c.conn.Close()
+}
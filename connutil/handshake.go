@@ -0,0 +1,111 @@
+package connutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Handshake intents.
+const (
+	IntentStatus   int32 = 1
+	IntentLogin    int32 = 2
+	IntentTransfer int32 = 3
+)
+
+// MaxHandshakeSize is the largest handshake ReadHandshake accepts. Vanilla
+// allows 255 characters of address, but proxies forwarding player data in
+// the address field need more.
+const MaxHandshakeSize = 4096
+
+// ErrLegacyPing is returned by ReadHandshake when the client sent a pre-1.7
+// server list ping instead of a handshake.
+var ErrLegacyPing = errors.New("client sent a legacy server list ping")
+
+// Handshake is the first packet of every connection.
+type Handshake struct {
+	Protocol int32
+	Address  string
+	Port     uint16
+	Intent   int32
+}
+
+// ReadHandshake reads and checks the handshake straight from a new
+// connection, before anything is allocated for it: no more than the
+// handshake is read, so the connection can be handed to CreateConn
+// afterwards. It gives up after timeout, so a client that connects and
+// says nothing doesn't hold the connection open.
+func ReadHandshake(conn net.Conn, timeout time.Duration) (Handshake, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return Handshake{}, err
+	}
+	defer conn.SetReadDeadline(time.Time{})
+
+	var buf [1]byte
+	var size int32
+	for i := 0; ; i++ {
+		if i == 3 {
+			return Handshake{}, fmt.Errorf("handshake length is over three bytes")
+		}
+		if _, err := io.ReadFull(conn, buf[:]); err != nil {
+			return Handshake{}, err
+		}
+		if i == 0 && buf[0] == 0xFE {
+			return Handshake{}, ErrLegacyPing
+		}
+		size |= int32(buf[0]&0x7F) << (7 * i)
+		if buf[0]&0x80 == 0 {
+			break
+		}
+	}
+	if size <= 0 || size > MaxHandshakeSize {
+		return Handshake{}, fmt.Errorf("handshake length of %d is out of range", size)
+	}
+
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(conn, frame); err != nil {
+		return Handshake{}, err
+	}
+	return parseHandshake(frame)
+}
+
+func parseHandshake(frame []byte) (Handshake, error) {
+	var hs Handshake
+	pr := packetutil.CreatePacketReader(frame)
+	packetID, err := pr.ReadVarInt()
+	if err != nil {
+		return hs, err
+	}
+	if packetID != 0x00 {
+		return hs, fmt.Errorf("first packet is 0x%02X rather than a handshake", packetID)
+	}
+	if hs.Protocol, err = pr.ReadVarInt(); err != nil {
+		return hs, fmt.Errorf("could not read protocol version: %w", err)
+	}
+	if hs.Address, err = pr.ReadString(); err != nil {
+		return hs, fmt.Errorf("could not read server address: %w", err)
+	}
+	if hs.Port, err = pr.ReadUnsignedShort(); err != nil {
+		return hs, fmt.Errorf("could not read server port: %w", err)
+	}
+	if hs.Intent, err = pr.ReadVarInt(); err != nil {
+		return hs, fmt.Errorf("could not read intent: %w", err)
+	}
+
+	// Clients that don't know the server's version ping with -1, but
+	// nothing else is negative.
+	if hs.Protocol < 0 && !(hs.Protocol == -1 && hs.Intent == IntentStatus) {
+		return hs, fmt.Errorf("protocol version of %d is invalid", hs.Protocol)
+	}
+	if hs.Intent < IntentStatus || hs.Intent > IntentTransfer {
+		return hs, fmt.Errorf("intent of %d is invalid", hs.Intent)
+	}
+	if offset, _ := pr.Seek(0, io.SeekCurrent); int(offset) != len(frame) {
+		return hs, fmt.Errorf("handshake has %d bytes left over", len(frame)-int(offset))
+	}
+	return hs, nil
+}
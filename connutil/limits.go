@@ -0,0 +1,138 @@
+package connutil
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// SizeLimits are the packet sizes a Conn accepts and sends.
+type SizeLimits struct {
+	// Frame is the largest frame, after compression, up to MaxFrameSize.
+	Frame int
+	// Decompressed is the largest packet a compressed frame may hold.
+	Decompressed int
+}
+
+// DefaultSizeLimits are vanilla's limits.
+var DefaultSizeLimits = SizeLimits{Frame: MaxFrameSize, Decompressed: MaxDecompressedSize}
+
+// PacketSizeError is returned when a packet is larger than a Conn's
+// SizeLimits allow. Oversized packets are refused rather than sent, since
+// the client would drop the connection over them anyway.
+type PacketSizeError struct {
+	Size  int
+	Limit int
+	// Decompressed is set when the packet's decompressed size was over
+	// the limit, and Outbound when the packet was being written.
+	Decompressed bool
+	Outbound     bool
+}
+
+func (pse *PacketSizeError) Error() string {
+	kind := "frame"
+	if pse.Decompressed {
+		kind = "decompressed packet"
+	}
+	direction := "received"
+	if pse.Outbound {
+		direction = "sent"
+	}
+	return fmt.Sprintf("%s %s is %d bytes, over the limit of %d", direction, kind, pse.Size, pse.Limit)
+}
+
+// SetSizeLimits changes the packet sizes the connection accepts and sends.
+func (c *Conn) SetSizeLimits(limits SizeLimits) error {
+	if limits.Frame <= 0 || limits.Frame > MaxFrameSize {
+		return fmt.Errorf("frame limit of %d is out of range", limits.Frame)
+	}
+	if limits.Decompressed <= 0 {
+		return fmt.Errorf("decompressed limit of %d is out of range", limits.Decompressed)
+	}
+	c.readLock.Lock()
+	c.writeLock.Lock()
+	c.limits = limits
+	c.writeLock.Unlock()
+	c.readLock.Unlock()
+	return nil
+}
+
+// SplitPayload cuts a payload too large for one packet, such as a plugin
+// message, into parts of at most size bytes of data, each headed by its
+// index and the number of parts as VarInts. A Reassembler on the other end
+// puts them back together.
+func SplitPayload(payload []byte, size int) ([][]byte, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("part size of %d is out of range", size)
+	}
+	count := max((len(payload)+size-1)/size, 1)
+	parts := make([][]byte, 0, count)
+	for i := range count {
+		data := payload[i*size : min((i+1)*size, len(payload))]
+		part := appendVarInt(make([]byte, 0, 10+len(data)), int32(i))
+		part = appendVarInt(part, int32(count))
+		parts = append(parts, append(part, data...))
+	}
+	return parts, nil
+}
+
+// Reassembler puts back together a payload cut up by SplitPayload. Parts
+// must arrive in order.
+type Reassembler struct {
+	// MaxSize caps the reassembled payload, so a peer can't make the
+	// Reassembler buffer without end.
+	MaxSize int
+
+	buff  []byte
+	next  int32
+	count int32
+}
+
+// CreateReassembler is a factory function for creating a new Reassembler
+// that accepts payloads of up to maxSize bytes.
+func CreateReassembler(maxSize int) *Reassembler {
+	r := new(Reassembler)
+	r.MaxSize = maxSize
+	return r
+}
+
+// Add takes the next part, returning the payload and true once it has the
+// last one. After an error, the Reassembler starts over.
+func (r *Reassembler) Add(part []byte) ([]byte, bool, error) {
+	payload, done, err := r.add(part)
+	if err != nil || done {
+		r.buff, r.next, r.count = nil, 0, 0
+	}
+	return payload, done, err
+}
+
+func (r *Reassembler) add(part []byte) ([]byte, bool, error) {
+	pr := packetutil.CreatePacketReader(part)
+	index, err := pr.ReadVarInt()
+	if err != nil {
+		return nil, false, err
+	}
+	count, err := pr.ReadVarInt()
+	if err != nil {
+		return nil, false, err
+	}
+	if r.next == 0 {
+		r.count = count
+	}
+	if index != r.next || count != r.count || count <= 0 {
+		return nil, false, fmt.Errorf("part %d of %d arrived out of order", index, count)
+	}
+
+	offset, _ := pr.Seek(0, io.SeekCurrent)
+	data := part[offset:]
+	if len(r.buff)+len(data) > r.MaxSize {
+		return nil, false, fmt.Errorf("reassembled payload of %d bytes is over the limit of %d", len(r.buff)+len(data), r.MaxSize)
+	}
+	r.buff = append(r.buff, data...)
+	r.next++
+	if r.next < r.count {
+		return nil, false, nil
+	}
+	return r.buff, true, nil
+}
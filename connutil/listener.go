@@ -0,0 +1,195 @@
+package connutil
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// ListenerLimits are the per-IP limits a Listener enforces. Zero values turn
+// a limit off.
+type ListenerLimits struct {
+	// ConnectionRate is how many new connections per second an IP may open,
+	// with up to ConnectionBurst at once.
+	ConnectionRate  float64
+	ConnectionBurst int
+	// MaxConnections is how many connections an IP may hold open.
+	MaxConnections int
+	// StatusRate is how many status requests per second an IP may make,
+	// with up to StatusBurst at once. It is enforced by AllowStatus.
+	StatusRate  float64
+	StatusBurst int
+}
+
+// DefaultListenerLimits suit a public server: a player reconnecting or a
+// few players behind one router are fine, a join bot isn't.
+var DefaultListenerLimits = ListenerLimits{
+	ConnectionRate:  1,
+	ConnectionBurst: 5,
+	MaxConnections:  8,
+	StatusRate:      2,
+	StatusBurst:     10,
+}
+
+// idleSweep is how often the Listener forgets IPs it has no reason to
+// remember.
+const idleSweep = time.Minute
+
+type ipState struct {
+	open        int
+	connections *tokenBucket
+	status      *tokenBucket
+}
+
+// Listener accepts connections like a net.Listener, first turning away
+// addresses over their limits or rejected by Verdict. Rejected connections
+// are closed straight away, before anything is read from them.
+type Listener struct {
+	net.Listener
+	limits ListenerLimits
+
+	// Verdict, if set, is asked about every new connection's address and
+	// rejects it by returning false. It is where blocklists and external
+	// reputation checks plug in. It must be set before Accept is first
+	// called, and must not block for long.
+	Verdict func(addr netip.Addr) bool
+
+	lock      sync.Mutex
+	ips       map[netip.Addr]*ipState
+	lastSweep time.Time
+}
+
+// CreateListener is a factory function for creating a new Listener over l.
+func CreateListener(l net.Listener, limits ListenerLimits) *Listener {
+	ln := new(Listener)
+	ln.Listener = l
+	ln.limits = limits
+	ln.ips = make(map[netip.Addr]*ipState)
+	ln.lastSweep = time.Now()
+	return ln
+}
+
+// remoteAddr returns a connection's IP, with IPv4 in IPv6 unwrapped so both
+// forms share limits.
+func remoteAddr(conn net.Conn) (netip.Addr, bool) {
+	addrPort, err := netip.ParseAddrPort(conn.RemoteAddr().String())
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addrPort.Addr().Unmap(), true
+}
+
+// Accept waits for the next connection that passes the limits and Verdict.
+func (ln *Listener) Accept() (net.Conn, error) {
+	for {
+		conn, err := ln.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		addr, ok := remoteAddr(conn)
+		if !ok {
+			// Not an IP connection, such as a unix socket from a local
+			// proxy; there is nothing to limit by.
+			return conn, nil
+		}
+		// The limits are cheaper than whatever Verdict does, so they go
+		// first.
+		if !ln.admit(addr) {
+			conn.Close()
+			continue
+		}
+		if ln.Verdict != nil && !ln.Verdict(addr) {
+			ln.release(addr)
+			conn.Close()
+			continue
+		}
+		return &limitedConn{Conn: conn, listener: ln, addr: addr}, nil
+	}
+}
+
+func (ln *Listener) state(addr netip.Addr, now time.Time) *ipState {
+	if now.Sub(ln.lastSweep) >= idleSweep {
+		ln.lastSweep = now
+		for ip, st := range ln.ips {
+			if st.open == 0 && (st.connections == nil || st.connections.full(now)) && (st.status == nil || st.status.full(now)) {
+				delete(ln.ips, ip)
+			}
+		}
+	}
+
+	st, ok := ln.ips[addr]
+	if !ok {
+		st = new(ipState)
+		if ln.limits.ConnectionRate > 0 {
+			st.connections = createTokenBucket(ln.limits.ConnectionRate, ln.limits.ConnectionBurst, now)
+		}
+		if ln.limits.StatusRate > 0 {
+			st.status = createTokenBucket(ln.limits.StatusRate, ln.limits.StatusBurst, now)
+		}
+		ln.ips[addr] = st
+	}
+	return st
+}
+
+func (ln *Listener) admit(addr netip.Addr) bool {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	now := time.Now()
+	st := ln.state(addr, now)
+	if ln.limits.MaxConnections > 0 && st.open >= ln.limits.MaxConnections {
+		return false
+	}
+	if st.connections != nil && !st.connections.allow(now) {
+		return false
+	}
+	st.open++
+	return true
+}
+
+func (ln *Listener) release(addr netip.Addr) {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if st, ok := ln.ips[addr]; ok && st.open > 0 {
+		st.open--
+	}
+}
+
+// AllowStatus reports whether a connection accepted by the Listener may
+// have its status request answered, spending one from its IP's status
+// budget. Status pings need no login, so they are the cheapest flood.
+func (ln *Listener) AllowStatus(conn net.Conn) bool {
+	lc, ok := conn.(*limitedConn)
+	if !ok {
+		return true
+	}
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	now := time.Now()
+	st := ln.state(lc.addr, now)
+	return st.status == nil || st.status.allow(now)
+}
+
+// limitedConn gives its IP's connection slot back when closed.
+type limitedConn struct {
+	net.Conn
+	listener *Listener
+	addr     netip.Addr
+	once     sync.Once
+}
+
+func (lc *limitedConn) Close() error {
+	err := lc.Conn.Close()
+	lc.once.Do(func() {
+		lc.listener.release(lc.addr)
+	})
+	return err
+}
+
+// Unwrap returns the accepted connection.
+func (lc *limitedConn) Unwrap() net.Conn {
+	return lc.Conn
+}
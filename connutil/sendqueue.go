@@ -0,0 +1,186 @@
+package connutil
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Priority is the class a queued packet is sent in. Higher classes always
+// go first, so a keep-alive isn't stuck behind megabytes of chunk data on a
+// slow link.
+type Priority int
+
+const (
+	// PriorityBulk is for chunk data and other large, latency tolerant
+	// packets.
+	PriorityBulk Priority = iota
+	// PriorityEntity is for entity and block updates.
+	PriorityEntity
+	// PriorityChat is for chat and other interface updates.
+	PriorityChat
+	// PriorityUrgent is for keep-alives, teleports and anything else the
+	// client is waiting on.
+	PriorityUrgent
+
+	priorityCount
+)
+
+// SendQueue queues packets for a Conn and writes them from one goroutine,
+// highest priority first. Packets in the same class are sent in order, but
+// a packet may overtake one queued earlier in a lower class, so packets that
+// depend on each other, like an entity's spawn and its first update, must
+// share a class.
+type SendQueue struct {
+	conn *Conn
+
+	lock   sync.Mutex
+	wake   *sync.Cond
+	queues [priorityCount][]*packetutil.Packet
+	bytes  [priorityCount]int
+	closed bool
+	// draining is set by Close while Run writes out what is left, and
+	// stopped is closed when Run returns.
+	draining bool
+	stopped  chan struct{}
+}
+
+// CreateSendQueue is a factory function for creating a new SendQueue that
+// writes to conn once Run is called.
+func CreateSendQueue(conn *Conn) *SendQueue {
+	q := new(SendQueue)
+	q.conn = conn
+	q.wake = sync.NewCond(&q.lock)
+	q.stopped = make(chan struct{})
+	return q
+}
+
+// Send queues a packet.
+func (q *SendQueue) Send(priority Priority, p *packetutil.Packet) error {
+	if priority < 0 || priority >= priorityCount {
+		return fmt.Errorf("priority %d is out of range", priority)
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed || q.draining {
+		return fmt.Errorf("send queue is closed")
+	}
+	q.queues[priority] = append(q.queues[priority], p)
+	q.bytes[priority] += len(p.Data)
+	q.wake.Signal()
+	return nil
+}
+
+// SendFrame queues a packet built with PacketWriter.
+func (q *SendQueue) SendFrame(priority Priority, frame []byte) error {
+	pr := packetutil.CreatePacketReader(frame)
+	if _, err := pr.ReadVarInt(); err != nil {
+		return err
+	}
+	packetID, err := pr.ReadVarInt()
+	if err != nil {
+		return err
+	}
+	offset, _ := pr.Seek(0, io.SeekCurrent)
+	return q.Send(priority, &packetutil.Packet{ID: packetID, Data: frame[offset:]})
+}
+
+// Queued returns how many bytes of packet data are waiting in a class, or 0
+// for a priority out of range.
+func (q *SendQueue) Queued(priority Priority) int {
+	if priority < 0 || priority >= priorityCount {
+		return 0
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.bytes[priority]
+}
+
+func (q *SendQueue) next() (*packetutil.Packet, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for {
+		if q.closed {
+			return nil, false
+		}
+		for priority := priorityCount - 1; priority >= 0; priority-- {
+			if queue := q.queues[priority]; len(queue) > 0 {
+				p := queue[0]
+				queue[0] = nil
+				q.queues[priority] = queue[1:]
+				q.bytes[priority] -= len(p.Data)
+				return p, true
+			}
+		}
+		if q.draining {
+			return nil, false
+		}
+		q.wake.Wait()
+	}
+}
+
+// Run writes queued packets until Close is called or a write fails, and
+// returns the write error, if any. It must only be called once.
+func (q *SendQueue) Run() error {
+	defer close(q.stopped)
+	for {
+		p, ok := q.next()
+		if !ok {
+			return nil
+		}
+		if err := q.conn.WritePacket(p); err != nil {
+			q.stop()
+			return err
+		}
+	}
+}
+
+// Close refuses new packets and gives Run up to timeout to write the ones
+// still queued, so a Disconnect sent just before is delivered, then stops
+// Run and drops whatever is left. It returns an error if packets had to be
+// dropped. A timeout of 0 or less drops them at once.
+func (q *SendQueue) Close(timeout time.Duration) error {
+	q.lock.Lock()
+	if q.closed {
+		q.lock.Unlock()
+		return nil
+	}
+	q.draining = true
+	q.wake.Broadcast()
+	q.lock.Unlock()
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-q.stopped:
+		case <-timer.C:
+		}
+	}
+	if dropped := q.stop(); dropped > 0 {
+		return fmt.Errorf("dropped %d queued packets on close", dropped)
+	}
+	return nil
+}
+
+// stop stops Run and drops whatever is still queued, returning how many
+// packets it dropped.
+func (q *SendQueue) stop() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	dropped := 0
+	for _, queue := range q.queues {
+		dropped += len(queue)
+	}
+	q.closed = true
+	q.queues = [priorityCount][]*packetutil.Packet{}
+	q.bytes = [priorityCount]int{}
+	q.wake.Broadcast()
+	return dropped
+}
@@ -0,0 +1,169 @@
+package connutil
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Snappy compresses with the Snappy block format: far cheaper than zlib for
+// a somewhat larger result, which suits links between servers on a fast
+// network.
+var Snappy Codec = snappyCodec{}
+
+type snappyCodec struct{}
+
+func (snappyCodec) Name() string {
+	return "snappy"
+}
+
+const (
+	snappyLiteral = 0
+	snappyCopy1   = 1
+	snappyCopy2   = 2
+	snappyCopy4   = 3
+
+	snappyTableBits = 14
+	snappyMaxOffset = 1<<16 - 1
+)
+
+func load32(b []byte, i int) uint32 {
+	return binary.LittleEndian.Uint32(b[i:])
+}
+
+func (snappyCodec) Compress(dst, src []byte) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(src)))
+
+	var table [1 << snappyTableBits]int32
+	literal := 0
+	for s := 0; s+4 <= len(src); {
+		cur := load32(src, s)
+		h := cur * 0x1E35A7BD >> (32 - snappyTableBits)
+		candidate := int(table[h]) - 1
+		table[h] = int32(s + 1)
+
+		if candidate < 0 || s-candidate > snappyMaxOffset || load32(src, candidate) != cur {
+			// Step over incompressible runs faster the longer they get.
+			s += 1 + (s-literal)>>5
+			continue
+		}
+
+		dst = appendSnappyLiteral(dst, src[literal:s])
+		length := 4
+		for s+length < len(src) && src[candidate+length] == src[s+length] {
+			length++
+		}
+		dst = appendSnappyCopy(dst, s-candidate, length)
+		s += length
+		literal = s
+	}
+	return appendSnappyLiteral(dst, src[literal:])
+}
+
+func appendSnappyLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	n := uint32(len(lit) - 1)
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|snappyLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|snappyLiteral, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2|snappyLiteral, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2|snappyLiteral, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2|snappyLiteral, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+// appendSnappyCopy emits a back reference, splitting lengths over 64 so that
+// every piece is at least four bytes long.
+func appendSnappyCopy(dst []byte, offset, length int) []byte {
+	for length >= 68 {
+		dst = append(dst, 63<<2|snappyCopy2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+	if length > 64 {
+		dst = append(dst, 59<<2|snappyCopy2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+	if length >= 12 || offset >= 2048 {
+		return append(dst, byte(length-1)<<2|snappyCopy2, byte(offset), byte(offset>>8))
+	}
+	return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|snappyCopy1, byte(offset))
+}
+
+func (snappyCodec) Decompress(src []byte, size int) ([]byte, error) {
+	claimed, n := binary.Uvarint(src)
+	if n <= 0 || claimed != uint64(size) {
+		return nil, fmt.Errorf("snappy block doesn't hold %d bytes", size)
+	}
+	src = src[n:]
+
+	dst := make([]byte, 0, size)
+	for len(src) > 0 {
+		tag := src[0]
+		var length, offset int
+		switch tag & 3 {
+		case snappyLiteral:
+			length = int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				extra := length - 59
+				if len(src) < extra {
+					return nil, fmt.Errorf("snappy literal is truncated")
+				}
+				length = 0
+				for i := extra - 1; i >= 0; i-- {
+					length = length<<8 | int(src[i])
+				}
+				src = src[extra:]
+			}
+			length++
+			if length > len(src) || length > size-len(dst) {
+				return nil, fmt.Errorf("snappy literal is out of range")
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case snappyCopy1:
+			if len(src) < 2 {
+				return nil, fmt.Errorf("snappy copy is truncated")
+			}
+			length = int(tag>>2&7) + 4
+			offset = int(tag>>5)<<8 | int(src[1])
+			src = src[2:]
+		case snappyCopy2:
+			if len(src) < 3 {
+				return nil, fmt.Errorf("snappy copy is truncated")
+			}
+			length = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case snappyCopy4:
+			if len(src) < 5 {
+				return nil, fmt.Errorf("snappy copy is truncated")
+			}
+			length = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+
+		if offset <= 0 || offset > len(dst) || length > size-len(dst) {
+			return nil, fmt.Errorf("snappy copy is out of range")
+		}
+		// Copies may overlap what they produce, so go a byte at a time.
+		start := len(dst) - offset
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[start+i])
+		}
+	}
+
+	if len(dst) != size {
+		return nil, fmt.Errorf("snappy block held %d bytes rather than %d", len(dst), size)
+	}
+	return dst, nil
+}
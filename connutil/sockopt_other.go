@@ -0,0 +1,13 @@
+//go:build !unix
+
+package connutil
+
+import (
+	"fmt"
+	"net"
+)
+
+// SendBufferSize can't read socket options on this platform.
+func SendBufferSize(conn net.Conn) (int, error) {
+	return 0, fmt.Errorf("socket send buffer size is unavailable on this platform")
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package connutil
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// SendBufferSize returns the size of a connection's socket send buffer, as
+// the kernel reports it.
+func SendBufferSize(conn net.Conn) (int, error) {
+	for {
+		wrapper, ok := conn.(interface{ Unwrap() net.Conn })
+		if !ok {
+			break
+		}
+		conn = wrapper.Unwrap()
+	}
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0, fmt.Errorf("connection has no socket")
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var size int
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		size, sockErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return size, sockErr
+}
@@ -0,0 +1,85 @@
+package connutil
+
+import (
+	"time"
+)
+
+// tokenBucket caps how fast bytes leave a connection. A frame larger than
+// what is saved up still goes out, leaving the bucket in debt, so frames
+// are never split and the average rate holds.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// take spends n bytes and returns how long to wait before sending them.
+func (tb *tokenBucket) take(n int, now time.Time) time.Duration {
+	tb.tokens = min(tb.tokens+now.Sub(tb.last).Seconds()*tb.rate, tb.burst)
+	tb.last = now
+	tb.tokens -= float64(n)
+	if tb.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+// SetBandwidthLimit caps the bytes written per second, counted as they go
+// on the wire, after compression. Up to burst bytes may go out at once after
+// the connection has been idle. A rate of 0 or less removes the cap.
+func (c *Conn) SetBandwidthLimit(bytesPerSecond, burst int) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if bytesPerSecond <= 0 {
+		c.throttle = nil
+		return
+	}
+	c.throttle = createTokenBucket(float64(bytesPerSecond), burst, time.Now())
+}
+
+// wait holds a frame back until the bandwidth limit allows it, or the
+// connection is closed.
+func (c *Conn) wait(n int) {
+	if c.throttle == nil {
+		return
+	}
+	delay := c.throttle.take(n, time.Now())
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-c.closed:
+	}
+}
+
+// allow spends one token if there is one, for counting events rather than
+// bytes.
+func (tb *tokenBucket) allow(now time.Time) bool {
+	tb.tokens = min(tb.tokens+now.Sub(tb.last).Seconds()*tb.rate, tb.burst)
+	tb.last = now
+	if tb.tokens < 1 {
+		return false
+	}
+	tb.tokens--
+	return true
+}
+
+// full reports whether the bucket has refilled, so forgetting it changes
+// nothing.
+func (tb *tokenBucket) full(now time.Time) bool {
+	return tb.tokens+now.Sub(tb.last).Seconds()*tb.rate >= tb.burst
+}
+
+func createTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	tb := new(tokenBucket)
+	tb.rate = rate
+	tb.burst = float64(max(burst, 1))
+	tb.tokens = tb.burst
+	tb.last = now
+	return tb
+}
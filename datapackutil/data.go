@@ -0,0 +1,225 @@
+package datapackutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/PurpurProject/elytra/lootutil"
+)
+
+// tagFile is the contents of a tag file.
+type tagFile struct {
+	Replace bool       `json:"replace"`
+	Values  []tagValue `json:"values"`
+}
+
+// tagValue is an element or a reference to another tag ("#namespace:path"),
+// which is optional when given as {"id": ..., "required": false}.
+type tagValue struct {
+	ID       string
+	Required bool
+}
+
+func (tv *tagValue) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		tv.ID, tv.Required = id, true
+		return nil
+	}
+
+	var raw struct {
+		ID       string `json:"id"`
+		Required *bool  `json:"required"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	tv.ID = raw.ID
+	tv.Required = raw.Required == nil || *raw.Required
+	return nil
+}
+
+// Data is the combined contents of a list of data packs.
+type Data struct {
+	// Entries holds every JSON file outside of tags, by registry (such as
+	// "recipe" or "worldgen/biome") and then identifier. Registries before
+	// 1.21 used plural directory names; they are stored under the current
+	// singular ones.
+	Entries map[string]map[string]json.RawMessage
+	// Tags holds the resolved elements of every tag, by registry and then
+	// tag identifier, with references to other tags expanded.
+	Tags map[string]map[string][]string
+	// LootTables holds the parsed loot tables.
+	LootTables map[string]*lootutil.LootTable
+}
+
+// Load combines data packs, in order from lowest to highest priority. A file
+// in a later pack replaces the same file in an earlier one, except tags,
+// which add to the earlier tag unless they set replace.
+func Load(packs ...*Pack) (*Data, error) {
+	d := new(Data)
+	d.Entries = make(map[string]map[string]json.RawMessage)
+	d.Tags = make(map[string]map[string][]string)
+	d.LootTables = make(map[string]*lootutil.LootTable)
+
+	tags := make(map[string]map[string][]tagValue)
+	for _, pack := range packs {
+		files, err := pack.files()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", pack.Name, err)
+		}
+		for _, f := range files {
+			data, err := fs.ReadFile(pack.fsys, f.path)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", pack.Name, err)
+			}
+
+			if f.tag {
+				var tf tagFile
+				if err := json.Unmarshal(data, &tf); err != nil {
+					return nil, fmt.Errorf("%s: %s: %w", pack.Name, f.path, err)
+				}
+				if tags[f.registry] == nil {
+					tags[f.registry] = make(map[string][]tagValue)
+				}
+				if tf.Replace {
+					tags[f.registry][f.id] = nil
+				}
+				tags[f.registry][f.id] = append(tags[f.registry][f.id], tf.Values...)
+				continue
+			}
+
+			if f.registry == "loot_table" {
+				table, err := lootutil.ParseLootTable(bytes.NewReader(data))
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s: %w", pack.Name, f.path, err)
+				}
+				d.LootTables[f.id] = table
+			}
+			if d.Entries[f.registry] == nil {
+				d.Entries[f.registry] = make(map[string]json.RawMessage)
+			}
+			d.Entries[f.registry][f.id] = json.RawMessage(data)
+		}
+	}
+
+	for registry, registryTags := range tags {
+		resolved := make(map[string][]string, len(registryTags))
+		for id := range registryTags {
+			if _, err := resolveTag(registryTags, resolved, id, nil); err != nil {
+				return nil, fmt.Errorf("%s tag #%s: %w", registry, id, err)
+			}
+		}
+		d.Tags[registry] = resolved
+	}
+	return d, nil
+}
+
+// resolveTag expands a tag into its elements, resolving referenced tags
+// first. stack is the chain of tags being resolved, to catch cycles.
+func resolveTag(tags map[string][]tagValue, resolved map[string][]string, id string, stack []string) ([]string, error) {
+	if elements, ok := resolved[id]; ok {
+		return elements, nil
+	}
+	for _, parent := range stack {
+		if parent == id {
+			return nil, fmt.Errorf("tag #%s refers to itself through %s", id, strings.Join(stack, " -> "))
+		}
+	}
+	stack = append(stack, id)
+
+	var elements []string
+	seen := make(map[string]bool)
+	for _, value := range tags[id] {
+		var found []string
+		if ref, ok := strings.CutPrefix(value.ID, "#"); ok {
+			ref = qualify(ref)
+			if _, exists := tags[ref]; !exists {
+				if value.Required {
+					return nil, fmt.Errorf("missing referenced tag #%s", ref)
+				}
+				continue
+			}
+			var err error
+			if found, err = resolveTag(tags, resolved, ref, stack); err != nil {
+				return nil, err
+			}
+		} else {
+			found = []string{qualify(value.ID)}
+		}
+		for _, element := range found {
+			if !seen[element] {
+				seen[element] = true
+				elements = append(elements, element)
+			}
+		}
+	}
+	resolved[id] = elements
+	return elements, nil
+}
+
+func qualify(id string) string {
+	if strings.Contains(id, ":") {
+		return id
+	}
+	return "minecraft:" + id
+}
+
+// Entry returns a file from a registry, such as a recipe or a biome.
+func (d *Data) Entry(registry, id string) (json.RawMessage, bool) {
+	entry, ok := d.Entries[registry][qualify(id)]
+	return entry, ok
+}
+
+// Tag returns the elements of a tag in a registry, such as "block" or
+// "worldgen/biome".
+func (d *Data) Tag(registry, id string) ([]string, bool) {
+	elements, ok := d.Tags[registry][qualify(strings.TrimPrefix(id, "#"))]
+	return elements, ok
+}
+
+// LootTable returns a loot table. It fits lootutil.Context.Tables.
+func (d *Data) LootTable(id string) (*lootutil.LootTable, bool) {
+	table, ok := d.LootTables[qualify(id)]
+	return table, ok
+}
+
+// ItemTag returns the items in an item tag. It fits lootutil.Context.Tags.
+func (d *Data) ItemTag(id string) []string {
+	elements, _ := d.Tag("item", id)
+	return elements
+}
+
+// AdvancementRequirements returns the requirements of every advancement:
+// groups of criteria, each needing one obtained for the advancement to be
+// done. Advancements without requirements need all of their criteria.
+func (d *Data) AdvancementRequirements() (map[string][][]string, error) {
+	requirements := make(map[string][][]string, len(d.Entries["advancement"]))
+	for id, data := range d.Entries["advancement"] {
+		var advancement struct {
+			Criteria     map[string]json.RawMessage `json:"criteria"`
+			Requirements [][]string                 `json:"requirements"`
+		}
+		if err := json.Unmarshal(data, &advancement); err != nil {
+			return nil, fmt.Errorf("advancement %s: %w", id, err)
+		}
+
+		groups := advancement.Requirements
+		if groups == nil {
+			names := make([]string, 0, len(advancement.Criteria))
+			for criterion := range advancement.Criteria {
+				names = append(names, criterion)
+			}
+			sort.Strings(names)
+			for _, criterion := range names {
+				groups = append(groups, []string{criterion})
+			}
+		}
+		requirements[id] = groups
+	}
+	return requirements, nil
+}
@@ -0,0 +1,206 @@
+package datapackutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/PurpurProject/elytra/levelutil"
+	"github.com/PurpurProject/elytra/nbtutil"
+)
+
+// Ingredient is what a recipe accepts in one slot: any of a set of items or
+// of the items in some tags. Components, if set, must also be carried by the
+// item, with the same values; compounds within them need only hold the
+// given tags, as in vanilla's NBT predicates.
+type Ingredient struct {
+	Items      []string
+	Tags       []string
+	Components nbtutil.Compound
+}
+
+// ParseIngredient reads an ingredient in any of the forms recipes have
+// used: "minecraft:stick" or "#minecraft:planks" from 1.21.2, {"item": ...}
+// or {"tag": ...} before, or a list of these. An object may also carry
+// "components", as mod loaders allow.
+func ParseIngredient(data json.RawMessage) (Ingredient, error) {
+	var ing Ingredient
+	if err := ing.add(data); err != nil {
+		return ing, err
+	}
+	if len(ing.Items)+len(ing.Tags) == 0 {
+		return ing, fmt.Errorf("ingredient matches no items")
+	}
+	return ing, nil
+}
+
+func (ing *Ingredient) add(data json.RawMessage) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		if tag, ok := strings.CutPrefix(id, "#"); ok {
+			ing.Tags = append(ing.Tags, qualify(tag))
+		} else {
+			ing.Items = append(ing.Items, qualify(id))
+		}
+		return nil
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err == nil {
+		for _, elem := range list {
+			if err := ing.add(elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var raw struct {
+		Item       string          `json:"item"`
+		Tag        string          `json:"tag"`
+		Items      json.RawMessage `json:"items"`
+		Components map[string]any  `json:"components"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("ingredient is neither an ID, a list nor an object")
+	}
+	if raw.Item != "" {
+		ing.Items = append(ing.Items, qualify(raw.Item))
+	}
+	if raw.Tag != "" {
+		ing.Tags = append(ing.Tags, qualify(raw.Tag))
+	}
+	if raw.Items != nil {
+		if err := ing.add(raw.Items); err != nil {
+			return err
+		}
+	}
+	if raw.Components != nil {
+		if ing.Components == nil {
+			ing.Components = make(nbtutil.Compound)
+		}
+		for name, val := range raw.Components {
+			ing.Components[qualify(name)] = fromJSON(val)
+		}
+	}
+	return nil
+}
+
+// fromJSON converts a decoded JSON value to the nearest NBT value. Numbers
+// all become float64, which sameValue compares against any numeric tag.
+func fromJSON(val any) any {
+	switch val := val.(type) {
+	case bool:
+		if val {
+			return int8(1)
+		}
+		return int8(0)
+	case map[string]any:
+		c := make(nbtutil.Compound, len(val))
+		for name, elem := range val {
+			c[name] = fromJSON(elem)
+		}
+		return c
+	case []any:
+		list := nbtutil.List{Type: nbtutil.TagEnd}
+		for _, elem := range val {
+			list.Elements = append(list.Elements, fromJSON(elem))
+		}
+		return list
+	}
+	return val
+}
+
+// Matches reports whether an item with the given ID and components fits the
+// ingredient. tags returns the items in an item tag, as Data.ItemTag does.
+func (ing Ingredient) Matches(item string, components nbtutil.Compound, tags func(tag string) []string) bool {
+	item = qualify(item)
+	found := false
+	for _, id := range ing.Items {
+		if id == item {
+			found = true
+			break
+		}
+	}
+	for i := 0; !found && i < len(ing.Tags) && tags != nil; i++ {
+		for _, id := range tags(ing.Tags[i]) {
+			if id == item {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return false
+	}
+	for name, want := range ing.Components {
+		have, ok := components[name]
+		if !ok {
+			have, ok = components[strings.TrimPrefix(name, "minecraft:")]
+		}
+		if !ok || !sameValue(want, have) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesStack reports whether a stack fits the ingredient.
+func (ing Ingredient) MatchesStack(stack levelutil.ItemStack, tags func(tag string) []string) bool {
+	return stack.Count > 0 && ing.Matches(stack.ID, stack.Components, tags)
+}
+
+func number(val any) (float64, bool) {
+	switch val := val.(type) {
+	case int8:
+		return float64(val), true
+	case int16:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case float32:
+		return float64(val), true
+	case float64:
+		return val, true
+	}
+	return 0, false
+}
+
+// sameValue reports whether have satisfies want: numbers are compared by
+// value whatever their tag, compounds need only hold want's tags, and lists
+// must match element for element.
+func sameValue(want, have any) bool {
+	if w, ok := number(want); ok {
+		h, ok := number(have)
+		return ok && w == h
+	}
+	switch want := want.(type) {
+	case nbtutil.Compound:
+		have, ok := have.(nbtutil.Compound)
+		if !ok {
+			return false
+		}
+		for name, val := range want {
+			if !sameValue(val, have[name]) {
+				return false
+			}
+		}
+		return true
+	case nbtutil.List:
+		have, ok := have.(nbtutil.List)
+		if !ok || len(have.Elements) != len(want.Elements) {
+			return false
+		}
+		for i, val := range want.Elements {
+			if !sameValue(val, have.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case string:
+		return want == have
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package datapackutil
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Pack is a data pack, read from a directory or a zip file.
+type Pack struct {
+	Name   string
+	Format int
+	// Description is the pack's description as a text component.
+	Description json.RawMessage
+
+	fsys   fs.FS
+	closer io.Closer
+}
+
+// OpenPack opens a data pack from a directory or a zip file, named after
+// the file as vanilla does ("file/<name>").
+func OpenPack(path string) (*Pack, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	name := "file/" + filepath.Base(path)
+	if info.IsDir() {
+		return LoadPack(name, os.DirFS(path))
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	pack, err := LoadPack(name, zr)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	pack.closer = zr
+	return pack, nil
+}
+
+// LoadPack reads a data pack from a file system whose root holds
+// pack.mcmeta and the data directory, such as an embedded vanilla pack.
+func LoadPack(name string, fsys fs.FS) (*Pack, error) {
+	data, err := fs.ReadFile(fsys, "pack.mcmeta")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	var meta struct {
+		Pack struct {
+			PackFormat  int             `json:"pack_format"`
+			Description json.RawMessage `json:"description"`
+		} `json:"pack"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("%s: invalid pack.mcmeta: %w", name, err)
+	}
+
+	pack := new(Pack)
+	pack.Name = name
+	pack.Format = meta.Pack.PackFormat
+	pack.Description = meta.Pack.Description
+	pack.fsys = fsys
+	return pack, nil
+}
+
+// Close closes the pack's zip file, if it has one.
+func (p *Pack) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
+}
+
+// registryNames maps the plural directory names used before 1.21 to the
+// registry names, which the directories were renamed to.
+var registryNames = map[string]string{
+	"advancements":   "advancement",
+	"blocks":         "block",
+	"entity_types":   "entity_type",
+	"fluids":         "fluid",
+	"functions":      "function",
+	"game_events":    "game_event",
+	"item_modifiers": "item_modifier",
+	"items":          "item",
+	"loot_tables":    "loot_table",
+	"predicates":     "predicate",
+	"recipes":        "recipe",
+	"structures":     "structure",
+}
+
+// splitRegistry splits a path below data/<namespace>/ into its registry and
+// the rest. Worldgen registries take two segments, as in worldgen/biome.
+func splitRegistry(path string) (string, string, bool) {
+	registry, rest, ok := strings.Cut(path, "/")
+	if !ok {
+		return "", "", false
+	}
+	if registry == "worldgen" {
+		var sub string
+		if sub, rest, ok = strings.Cut(rest, "/"); !ok {
+			return "", "", false
+		}
+		return registry + "/" + sub, rest, true
+	}
+	if renamed, ok := registryNames[registry]; ok {
+		registry = renamed
+	}
+	return registry, rest, true
+}
+
+// file is a JSON file from a pack's data directory.
+type file struct {
+	registry string
+	id       string
+	tag      bool
+	path     string
+}
+
+// files lists every JSON file in the pack's data directory.
+func (p *Pack) files() ([]file, error) {
+	var files []file
+	err := fs.WalkDir(p.fsys, "data", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == "data" && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+
+		namespace, rest, ok := strings.Cut(strings.TrimPrefix(path, "data/"), "/")
+		if !ok {
+			return nil
+		}
+		f := file{path: path}
+		if tagPath, ok := strings.CutPrefix(rest, "tags/"); ok {
+			rest, f.tag = tagPath, true
+		}
+		registry, name, ok := splitRegistry(rest)
+		if !ok {
+			return nil
+		}
+		f.registry = registry
+		f.id = namespace + ":" + strings.TrimSuffix(name, ".json")
+		files = append(files, f)
+		return nil
+	})
+	return files, err
+}
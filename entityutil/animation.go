@@ -0,0 +1,161 @@
+package entityutil
+
+import "github.com/PurpurProject/elytra/packetutil"
+
+// Animation is an animation of Entity Animation.
+type Animation byte
+
+// Animations, numbered as they have been since 1.19.4, when the hurt
+// animation, once 1, got a packet of its own.
+const (
+	SwingMainArm        Animation = 0
+	LeaveBed            Animation = 2
+	SwingOffhand        Animation = 3
+	CriticalEffect      Animation = 4
+	MagicCriticalEffect Animation = 5
+)
+
+// EntityAnimation is the Entity Animation packet, which plays an animation
+// on an entity for everyone watching it.
+type EntityAnimation struct {
+	EntityID  int32
+	Animation Animation
+}
+
+// EntityAnimationCodec reads and writes the body of Entity Animation.
+var EntityAnimationCodec = packetutil.StructOf(
+	packetutil.Field(func(ea *EntityAnimation) *int32 { return &ea.EntityID }, packetutil.VarIntCodec),
+	packetutil.Field(func(ea *EntityAnimation) *Animation { return &ea.Animation }, packetutil.Convert(packetutil.UnsignedByteCodec,
+		func(a Animation) byte { return byte(a) },
+		func(b byte) (Animation, error) { return Animation(b), nil },
+	)),
+)
+
+// EntityAnimationPacket returns the Entity Animation packet.
+func EntityAnimationPacket(packetID int32, entityID int32, animation Animation) ([]byte, error) {
+	return EntityAnimationCodec.Marshal(packetID, EntityAnimation{entityID, animation})
+}
+
+// HurtAnimationPacket returns the Hurt Animation packet, which makes an
+// entity flash red and, for the player's own, tilts the camera away from
+// yaw, the direction the damage came from.
+func HurtAnimationPacket(packetID int32, entityID int32, yaw float32) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteVarInt(entityID)
+	pw.WriteFloat(yaw)
+	return pw.GetPacket()
+}
+
+// Status is a status of Entity Event. What a status does depends on the
+// kind of entity it is sent for, so the same number has a meaning for each.
+type Status int8
+
+// Statuses for any entity.
+const (
+	StatusHoneySlide Status = 53
+	StatusHoneyFall  Status = 54
+)
+
+// Statuses for living entities, players included.
+const (
+	StatusDeath            Status = 3
+	StatusShieldBlock      Status = 29
+	StatusShieldBreak      Status = 30
+	StatusTotemOfUndying   Status = 35
+	StatusTeleportParticle Status = 46
+	StatusBreakMainHand    Status = 47
+	StatusBreakOffhand     Status = 48
+	StatusBreakHead        Status = 49
+	StatusBreakChest       Status = 50
+	StatusBreakLegs        Status = 51
+	StatusBreakFeet        Status = 52
+	StatusSwapHands        Status = 55
+	StatusDeathSmoke       Status = 60
+)
+
+// Statuses for players, sent only to the player they are about.
+// StatusOpLevel0 to StatusOpLevel4 set the permission level the client
+// offers commands for, and the reduced debug statuses override the game
+// rule sent in Join Game.
+const (
+	StatusItemUseFinished  Status = 9
+	StatusReducedDebugInfo Status = 22
+	StatusFullDebugInfo    Status = 23
+	StatusOpLevel0         Status = 24
+	StatusOpLevel1         Status = 25
+	StatusOpLevel2         Status = 26
+	StatusOpLevel3         Status = 27
+	StatusOpLevel4         Status = 28
+	StatusBadOmen          Status = 43
+)
+
+// Statuses for animals and tameable mobs. The taming statuses are also
+// used by horses.
+const (
+	StatusTamingFailed    Status = 6
+	StatusTamingSucceeded Status = 7
+	StatusInLove          Status = 18
+)
+
+// Statuses for particular kinds of entity.
+const (
+	StatusTippedArrowParticles  Status = 0  // arrow
+	StatusRabbitJump            Status = 1  // rabbit
+	StatusSpawnerReset          Status = 1  // minecart with spawner
+	StatusProjectileBreak       Status = 3  // snowball and egg
+	StatusAttack                Status = 4  // iron golem, ravager, evoker fangs, hoglin, zoglin and warden
+	StatusWolfShake             Status = 8  // wolf
+	StatusSheepEat              Status = 10 // sheep
+	StatusTNTMinecartIgnite     Status = 10 // minecart with TNT
+	StatusGolemOfferFlower      Status = 11 // iron golem
+	StatusVillagerMating        Status = 12 // villager
+	StatusVillagerAngry         Status = 13 // villager
+	StatusVillagerHappy         Status = 14 // villager
+	StatusWitchMagic            Status = 15 // witch
+	StatusZombieVillagerCure    Status = 16 // zombie villager
+	StatusFireworkExplode       Status = 17 // firework rocket
+	StatusGuardianAttack        Status = 21 // guardian
+	StatusFishingHookPull       Status = 31 // fishing bobber
+	StatusArmorStandHit         Status = 32 // armour stand
+	StatusGolemPutAwayFlower    Status = 34 // iron golem
+	StatusDolphinHappy          Status = 38 // dolphin
+	StatusRavagerStunned        Status = 39 // ravager
+	StatusOcelotTamingFailed    Status = 40 // ocelot
+	StatusOcelotTamingSucceeded Status = 41 // ocelot
+	StatusVillagerSplash        Status = 42 // villager
+	StatusFoxChew               Status = 45 // fox
+	StatusWolfStopShaking       Status = 56 // wolf
+	StatusGoatLowerHead         Status = 58 // goat
+	StatusGoatRaiseHead         Status = 59 // goat
+	StatusWardenTendrils        Status = 61 // warden
+	StatusWardenSonicBoom       Status = 62 // warden
+	StatusSnifferDig            Status = 63 // sniffer
+)
+
+// OpLevelStatus returns the status that tells a player their permission
+// level, from 0 to 4.
+func OpLevelStatus(level int) Status {
+	return StatusOpLevel0 + Status(min(max(level, 0), 4))
+}
+
+// EntityEvent is the Entity Event packet, which tells the client something
+// has happened to an entity that it shows by itself.
+type EntityEvent struct {
+	EntityID int32
+	Status   Status
+}
+
+// EntityEventCodec reads and writes the body of Entity Event. Unlike most
+// packets, it sends the entity ID as an Int.
+var EntityEventCodec = packetutil.StructOf(
+	packetutil.Field(func(ee *EntityEvent) *int32 { return &ee.EntityID }, packetutil.IntCodec),
+	packetutil.Field(func(ee *EntityEvent) *Status { return &ee.Status }, packetutil.Convert(packetutil.ByteCodec,
+		func(s Status) int8 { return int8(s) },
+		func(b int8) (Status, error) { return Status(b), nil },
+	)),
+)
+
+// EntityEventPacket returns the Entity Event packet.
+func EntityEventPacket(packetID int32, entityID int32, status Status) ([]byte, error) {
+	return EntityEventCodec.Marshal(packetID, EntityEvent{entityID, status})
+}
@@ -0,0 +1,188 @@
+// Package entityutil keeps track of the entities a server has spawned: the
+// network IDs packets refer to them by, their UUIDs, the server's own
+// objects for them, and which of them ride which.
+package entityutil
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultReuseDelay is how long an entity ID rests after its entity is
+// removed before it is handed out again. Packets about the old entity may
+// still be on their way in either direction, and a client that has missed
+// the removal would apply them to the new one.
+const DefaultReuseDelay = time.Minute
+
+type freedID struct {
+	id    int32
+	freed time.Time
+}
+
+// IDAllocator hands out entity IDs, starting from 1 as vanilla does, and
+// takes them back for reuse once they have rested for ReuseDelay. It is
+// safe for concurrent use.
+type IDAllocator struct {
+	ReuseDelay time.Duration
+
+	lock sync.Mutex
+	next int32
+	free []freedID
+}
+
+// CreateIDAllocator is a factory function for creating a new IDAllocator.
+func CreateIDAllocator() *IDAllocator {
+	ia := new(IDAllocator)
+	ia.ReuseDelay = DefaultReuseDelay
+	ia.next = 1
+	return ia
+}
+
+// Allocate returns an unused entity ID, preferring a rested one.
+func (ia *IDAllocator) Allocate() (int32, error) {
+	ia.lock.Lock()
+	defer ia.lock.Unlock()
+
+	if len(ia.free) > 0 && time.Since(ia.free[0].freed) >= ia.ReuseDelay {
+		id := ia.free[0].id
+		ia.free = ia.free[1:]
+		return id, nil
+	}
+	if ia.next == math.MaxInt32 {
+		return 0, fmt.Errorf("no entity IDs are left")
+	}
+	id := ia.next
+	ia.next++
+	return id, nil
+}
+
+// Free gives an ID back once its entity is gone.
+func (ia *IDAllocator) Free(id int32) {
+	ia.lock.Lock()
+	defer ia.lock.Unlock()
+	ia.free = append(ia.free, freedID{id, time.Now()})
+}
+
+// RandomUUID returns a random version 4 UUID, for entities other than
+// players.
+func RandomUUID() [16]byte {
+	var uuid [16]byte
+	rand.Read(uuid[:])
+	uuid[6] = uuid[6]&0x0F | 0x40
+	uuid[8] = uuid[8]&0x3F | 0x80
+	return uuid
+}
+
+type entry[E any] struct {
+	uuid   [16]byte
+	entity E
+}
+
+// Registry maps entity IDs and UUIDs to the server's objects for the
+// entities, allocating IDs as entities are added. It is safe for concurrent
+// use.
+type Registry[E any] struct {
+	ids *IDAllocator
+
+	lock   sync.RWMutex
+	byID   map[int32]*entry[E]
+	byUUID map[[16]byte]int32
+}
+
+// CreateRegistry is a factory function for creating a new, empty Registry.
+func CreateRegistry[E any]() *Registry[E] {
+	r := new(Registry[E])
+	r.ids = CreateIDAllocator()
+	r.byID = make(map[int32]*entry[E])
+	r.byUUID = make(map[[16]byte]int32)
+	return r
+}
+
+// IDs returns the allocator the registry takes IDs from, so its ReuseDelay
+// can be changed.
+func (r *Registry[E]) IDs() *IDAllocator {
+	return r.ids
+}
+
+// Add registers an entity, returning the ID to spawn it with. UUIDs must be
+// unique; a player who is already in the world is refused.
+func (r *Registry[E]) Add(uuid [16]byte, entity E) (int32, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if id, ok := r.byUUID[uuid]; ok {
+		return 0, fmt.Errorf("UUID %x is already in use by entity %d", uuid, id)
+	}
+	id, err := r.ids.Allocate()
+	if err != nil {
+		return 0, err
+	}
+	r.byID[id] = &entry[E]{uuid, entity}
+	r.byUUID[uuid] = id
+	return id, nil
+}
+
+// Remove unregisters an entity once it has been despawned, reporting
+// whether it was registered.
+func (r *Registry[E]) Remove(id int32) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	e, ok := r.byID[id]
+	if !ok {
+		return false
+	}
+	delete(r.byID, id)
+	delete(r.byUUID, e.uuid)
+	r.ids.Free(id)
+	return true
+}
+
+// ByID returns an entity and its UUID.
+func (r *Registry[E]) ByID(id int32) (E, [16]byte, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	e, ok := r.byID[id]
+	if !ok {
+		var zero E
+		return zero, [16]byte{}, false
+	}
+	return e.entity, e.uuid, true
+}
+
+// ByUUID returns an entity and its ID.
+func (r *Registry[E]) ByUUID(uuid [16]byte) (E, int32, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	id, ok := r.byUUID[uuid]
+	if !ok {
+		var zero E
+		return zero, 0, false
+	}
+	return r.byID[id].entity, id, true
+}
+
+// Len returns how many entities are registered.
+func (r *Registry[E]) Len() int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return len(r.byID)
+}
+
+// Each calls fn for every entity, in no particular order, until it returns
+// false. The registry must not be changed from fn.
+func (r *Registry[E]) Each(fn func(id int32, uuid [16]byte, entity E) bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for id, e := range r.byID {
+		if !fn(id, e.uuid, e.entity) {
+			return
+		}
+	}
+}
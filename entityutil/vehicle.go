@@ -0,0 +1,314 @@
+package entityutil
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// protocol1_21_2 is the version from which Player Input sends which keys
+// are held rather than the movement they make, and serverbound Move Vehicle
+// carries whether the vehicle is on the ground.
+const protocol1_21_2 = 768
+
+// MountTracker keeps track of which entities ride which. An entity rides at
+// most one vehicle, and a vehicle's passengers are kept in the order they
+// got on, the first of them being the one that steers. It is safe for
+// concurrent use.
+type MountTracker struct {
+	lock       sync.RWMutex
+	vehicles   map[int32]int32
+	passengers map[int32][]int32
+}
+
+// CreateMountTracker is a factory function for creating a new MountTracker.
+func CreateMountTracker() *MountTracker {
+	mt := new(MountTracker)
+	mt.vehicles = make(map[int32]int32)
+	mt.passengers = make(map[int32][]int32)
+	return mt
+}
+
+// Mount puts passenger on vehicle. An entity already riding must dismount
+// first, and an entity can't ride itself or anything riding it.
+func (mt *MountTracker) Mount(passenger, vehicle int32) error {
+	mt.lock.Lock()
+	defer mt.lock.Unlock()
+
+	if current, ok := mt.vehicles[passenger]; ok {
+		return fmt.Errorf("entity %d is already riding %d", passenger, current)
+	}
+	for v, ok := vehicle, true; ok; v, ok = mt.vehicles[v] {
+		if v == passenger {
+			return fmt.Errorf("entity %d can't ride %d, which it carries", passenger, vehicle)
+		}
+	}
+	mt.vehicles[passenger] = vehicle
+	mt.passengers[vehicle] = append(mt.passengers[vehicle], passenger)
+	return nil
+}
+
+// Dismount takes passenger off its vehicle, returning the vehicle.
+func (mt *MountTracker) Dismount(passenger int32) (int32, bool) {
+	mt.lock.Lock()
+	defer mt.lock.Unlock()
+	return mt.dismount(passenger)
+}
+
+func (mt *MountTracker) dismount(passenger int32) (int32, bool) {
+	vehicle, ok := mt.vehicles[passenger]
+	if !ok {
+		return 0, false
+	}
+	delete(mt.vehicles, passenger)
+
+	riders := mt.passengers[vehicle]
+	for i, id := range riders {
+		if id == passenger {
+			riders = append(riders[:i:i], riders[i+1:]...)
+			break
+		}
+	}
+	if len(riders) == 0 {
+		delete(mt.passengers, vehicle)
+	} else {
+		mt.passengers[vehicle] = riders
+	}
+	return vehicle, true
+}
+
+// Remove forgets an entity that has been despawned, taking it off its
+// vehicle and its passengers off it. It returns the vehicles whose
+// passengers changed, for which Set Passengers must be sent; the removed
+// entity itself is among them if it carried anyone.
+func (mt *MountTracker) Remove(id int32) []int32 {
+	mt.lock.Lock()
+	defer mt.lock.Unlock()
+
+	var changed []int32
+	if vehicle, ok := mt.dismount(id); ok {
+		changed = append(changed, vehicle)
+	}
+	if riders, ok := mt.passengers[id]; ok {
+		for _, passenger := range riders {
+			delete(mt.vehicles, passenger)
+		}
+		delete(mt.passengers, id)
+		changed = append(changed, id)
+	}
+	return changed
+}
+
+// Vehicle returns the vehicle an entity rides.
+func (mt *MountTracker) Vehicle(passenger int32) (int32, bool) {
+	mt.lock.RLock()
+	defer mt.lock.RUnlock()
+	vehicle, ok := mt.vehicles[passenger]
+	return vehicle, ok
+}
+
+// RootVehicle returns the bottom of the stack an entity is part of, which
+// is the entity itself if it rides nothing.
+func (mt *MountTracker) RootVehicle(id int32) int32 {
+	mt.lock.RLock()
+	defer mt.lock.RUnlock()
+	for {
+		vehicle, ok := mt.vehicles[id]
+		if !ok {
+			return id
+		}
+		id = vehicle
+	}
+}
+
+// Passengers returns the entities riding a vehicle, in the order they got
+// on.
+func (mt *MountTracker) Passengers(vehicle int32) []int32 {
+	mt.lock.RLock()
+	defer mt.lock.RUnlock()
+	return append([]int32(nil), mt.passengers[vehicle]...)
+}
+
+// Controller returns the passenger that steers a vehicle: the first one.
+func (mt *MountTracker) Controller(vehicle int32) (int32, bool) {
+	mt.lock.RLock()
+	defer mt.lock.RUnlock()
+	riders := mt.passengers[vehicle]
+	if len(riders) == 0 {
+		return 0, false
+	}
+	return riders[0], true
+}
+
+// PassengersPacket returns the Set Passengers packet for a vehicle's
+// current passengers.
+func (mt *MountTracker) PassengersPacket(packetID int32, vehicle int32) []byte {
+	return SetPassengersPacket(packetID, vehicle, mt.Passengers(vehicle))
+}
+
+// SetPassengersPacket returns the Set Passengers packet, which replaces the
+// passengers the client shows on a vehicle. An empty list dismounts them
+// all.
+func SetPassengersPacket(packetID int32, vehicle int32, passengers []int32) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteVarInt(vehicle)
+	pw.WriteVarInt(int32(len(passengers)))
+	for _, passenger := range passengers {
+		pw.WriteVarInt(passenger)
+	}
+	return pw.GetPacket()
+}
+
+// VehicleMove is Move Vehicle, sent by the client steering a vehicle where
+// it has moved it, and by the server to correct it. OnGround is only sent
+// by clients from 1.21.2.
+type VehicleMove struct {
+	X, Y, Z    float64
+	Yaw, Pitch float32
+	OnGround   bool
+}
+
+var vehicleMoveFields = []packetutil.StructField[VehicleMove]{
+	packetutil.Field(func(vm *VehicleMove) *float64 { return &vm.X }, packetutil.DoubleCodec),
+	packetutil.Field(func(vm *VehicleMove) *float64 { return &vm.Y }, packetutil.DoubleCodec),
+	packetutil.Field(func(vm *VehicleMove) *float64 { return &vm.Z }, packetutil.DoubleCodec),
+	packetutil.Field(func(vm *VehicleMove) *float32 { return &vm.Yaw }, packetutil.FloatCodec),
+	packetutil.Field(func(vm *VehicleMove) *float32 { return &vm.Pitch }, packetutil.FloatCodec),
+}
+
+// VehicleMoveCodec reads and writes the body of clientbound Move Vehicle.
+var VehicleMoveCodec = packetutil.StructOf(vehicleMoveFields...)
+
+// ServerboundVehicleMoveCodec returns the codec for the body of serverbound
+// Move Vehicle in a protocol version.
+func ServerboundVehicleMoveCodec(protocol int32) packetutil.Codec[VehicleMove] {
+	if protocol < protocol1_21_2 {
+		return VehicleMoveCodec
+	}
+	fields := append(vehicleMoveFields[:len(vehicleMoveFields):len(vehicleMoveFields)],
+		packetutil.Field(func(vm *VehicleMove) *bool { return &vm.OnGround }, packetutil.BooleanCodec))
+	return packetutil.StructOf(fields...)
+}
+
+// PaddleBoat is Paddle Boat, sent while a player rowing a boat holds the
+// keys that turn each paddle.
+type PaddleBoat struct {
+	Left, Right bool
+}
+
+// PaddleBoatCodec reads and writes the body of Paddle Boat.
+var PaddleBoatCodec = packetutil.StructOf(
+	packetutil.Field(func(pb *PaddleBoat) *bool { return &pb.Left }, packetutil.BooleanCodec),
+	packetutil.Field(func(pb *PaddleBoat) *bool { return &pb.Right }, packetutil.BooleanCodec),
+)
+
+// PlayerInput is Player Input, with which a player steers what they ride.
+// Forward and Sideways run from -1 to 1, positive meaning forwards and
+// left. From 1.21.2 the client sends only which keys are held, so they are
+// -1, 0 or 1; before then Sprint isn't sent.
+type PlayerInput struct {
+	Forward, Sideways float32
+	Jump              bool
+	// Sneak also asks to dismount.
+	Sneak  bool
+	Sprint bool
+}
+
+// Key flags in Player Input from 1.21.2, and the jump and sneak flags
+// before.
+const (
+	inputForward  = 0x01
+	inputBackward = 0x02
+	inputLeft     = 0x04
+	inputRight    = 0x08
+	inputJump     = 0x10
+	inputSneak    = 0x20
+	inputSprint   = 0x40
+
+	legacyInputJump  = 0x01
+	legacyInputSneak = 0x02
+)
+
+// PlayerInputCodec returns the codec for the body of Player Input in a
+// protocol version.
+func PlayerInputCodec(protocol int32) packetutil.Codec[PlayerInput] {
+	return packetutil.Codec[PlayerInput]{
+		Encode: func(pw *packetutil.PacketWriter, pi PlayerInput) {
+			if protocol < protocol1_21_2 {
+				var flags byte
+				if pi.Jump {
+					flags |= legacyInputJump
+				}
+				if pi.Sneak {
+					flags |= legacyInputSneak
+				}
+				pw.WriteFloat(pi.Sideways)
+				pw.WriteFloat(pi.Forward)
+				pw.WriteUnsignedByte(flags)
+				return
+			}
+
+			var flags byte
+			switch {
+			case pi.Forward > 0:
+				flags |= inputForward
+			case pi.Forward < 0:
+				flags |= inputBackward
+			}
+			switch {
+			case pi.Sideways > 0:
+				flags |= inputLeft
+			case pi.Sideways < 0:
+				flags |= inputRight
+			}
+			if pi.Jump {
+				flags |= inputJump
+			}
+			if pi.Sneak {
+				flags |= inputSneak
+			}
+			if pi.Sprint {
+				flags |= inputSprint
+			}
+			pw.WriteUnsignedByte(flags)
+		},
+		Decode: func(pr *packetutil.PacketReader) (PlayerInput, error) {
+			var pi PlayerInput
+			if protocol < protocol1_21_2 {
+				var err error
+				if pi.Sideways, err = pr.ReadFloat(); err != nil {
+					return pi, err
+				}
+				if pi.Forward, err = pr.ReadFloat(); err != nil {
+					return pi, err
+				}
+				flags, err := pr.ReadUnsignedByte()
+				pi.Jump = flags&legacyInputJump != 0
+				pi.Sneak = flags&legacyInputSneak != 0
+				return pi, err
+			}
+
+			flags, err := pr.ReadUnsignedByte()
+			if err != nil {
+				return pi, err
+			}
+			if flags&inputForward != 0 {
+				pi.Forward++
+			}
+			if flags&inputBackward != 0 {
+				pi.Forward--
+			}
+			if flags&inputLeft != 0 {
+				pi.Sideways++
+			}
+			if flags&inputRight != 0 {
+				pi.Sideways--
+			}
+			pi.Jump = flags&inputJump != 0
+			pi.Sneak = flags&inputSneak != 0
+			pi.Sprint = flags&inputSprint != 0
+			return pi, nil
+		},
+	}
+}
@@ -0,0 +1,208 @@
+package inventoryutil
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// ClickMode is the kind of click in Click Container, which, with the
+// button, decides what the click does.
+type ClickMode int32
+
+const (
+	Pickup ClickMode = iota
+	QuickMove
+	Swap
+	Clone
+	Throw
+	QuickCraft
+	PickupAll
+)
+
+// OutsideWindow is the slot of a click outside the window, which drops the
+// item the player is carrying.
+const OutsideWindow = -999
+
+// maxChangedSlots is the most changed slots vanilla reads from one click.
+const maxChangedSlots = 128
+
+// ChangedSlot is a slot the client changed on its own side after a click,
+// with the item it now expects there.
+type ChangedSlot struct {
+	Slot int16
+	Item Slot
+}
+
+// Click is Click Container: a player clicking a slot of a window. The client
+// applies the click itself and sends the slots it changed and the item it
+// carries afterwards. From 1.21.5 it sends hashes of item components rather
+// than their data, so the Data of each component in Changed and Carried is
+// its four-byte hash.
+type Click struct {
+	WindowID int32
+	StateID  int32
+	Slot     int16
+	Button   int8
+	Mode     ClickMode
+	Changed  []ChangedSlot
+	Carried  Slot
+}
+
+// ClickCodec returns the codec for the body of Click Container in a
+// protocol version. Items are read with rules, as Set Creative Mode Slot's
+// are; clients only send back items they were given, so the rules must
+// allow every component the server puts on items. rules isn't used from
+// 1.21.5, when components are hashed.
+func ClickCodec(protocol int32, rules *CreativeRules) packetutil.Codec[Click] {
+	writeItem := func(pw *packetutil.PacketWriter, s Slot) {
+		if protocol >= protocol1_21_5 {
+			writeHashedSlot(pw, s)
+		} else if err := WriteSlot(pw, protocol, s); err != nil {
+			pw.Fail(err)
+		}
+	}
+	readItem := func(pr *packetutil.PacketReader) (Slot, error) {
+		if protocol >= protocol1_21_5 {
+			return readHashedSlot(pr)
+		}
+		return rules.readSlot(pr, protocol)
+	}
+	return packetutil.Codec[Click]{
+		Encode: func(pw *packetutil.PacketWriter, c Click) {
+			writeWindowID(pw, protocol, c.WindowID)
+			pw.WriteVarInt(c.StateID)
+			pw.WriteShort(c.Slot)
+			pw.WriteByte(c.Button)
+			pw.WriteVarInt(int32(c.Mode))
+			pw.WriteVarInt(int32(len(c.Changed)))
+			for _, cs := range c.Changed {
+				pw.WriteShort(cs.Slot)
+				writeItem(pw, cs.Item)
+			}
+			writeItem(pw, c.Carried)
+		},
+		Decode: func(pr *packetutil.PacketReader) (Click, error) {
+			var c Click
+			var err error
+			if c.WindowID, err = readWindowID(pr, protocol); err != nil {
+				return c, err
+			}
+			if c.StateID, err = pr.ReadVarInt(); err != nil {
+				return c, err
+			}
+			if c.Slot, err = pr.ReadShort(); err != nil {
+				return c, err
+			}
+			if c.Button, err = pr.ReadByte(); err != nil {
+				return c, err
+			}
+			mode, err := pr.ReadEnum(int32(PickupAll))
+			if err != nil {
+				return c, err
+			}
+			c.Mode = ClickMode(mode)
+
+			changed, err := pr.ReadVarInt()
+			if err != nil {
+				return c, err
+			}
+			if changed < 0 || changed > maxChangedSlots {
+				return c, fmt.Errorf("%d changed slots exceed the limit of %d", changed, maxChangedSlots)
+			}
+			c.Changed = make([]ChangedSlot, changed)
+			for i := range c.Changed {
+				if c.Changed[i].Slot, err = pr.ReadShort(); err != nil {
+					return c, err
+				}
+				if c.Changed[i].Item, err = readItem(pr); err != nil {
+					return c, fmt.Errorf("could not read item in slot %d: %w", c.Changed[i].Slot, err)
+				}
+			}
+			if c.Carried, err = readItem(pr); err != nil {
+				return c, fmt.Errorf("could not read carried item: %w", err)
+			}
+			return c, nil
+		},
+	}
+}
+
+// maxHashedComponents is the most added or removed components vanilla reads
+// from a hashed item.
+const maxHashedComponents = 256
+
+func writeHashedSlot(pw *packetutil.PacketWriter, s Slot) {
+	pw.WriteBoolean(!s.Empty())
+	if s.Empty() {
+		return
+	}
+	pw.WriteVarInt(s.ItemID)
+	pw.WriteVarInt(s.Count)
+	pw.WriteVarInt(int32(len(s.Components)))
+	for _, c := range s.Components {
+		if len(c.Data) != 4 {
+			pw.Fail(fmt.Errorf("hash of item component %d is %d bytes rather than 4", c.Type, len(c.Data)))
+			return
+		}
+		pw.WriteVarInt(c.Type)
+		pw.WriteBytes(c.Data)
+	}
+	pw.WriteVarInt(int32(len(s.Removed)))
+	for _, typ := range s.Removed {
+		pw.WriteVarInt(typ)
+	}
+}
+
+// readHashedSlot reads an item as 1.21.5 clicks send it, with a hash in
+// place of each added component's data.
+func readHashedSlot(pr *packetutil.PacketReader) (Slot, error) {
+	var s Slot
+	present, err := pr.ReadBoolean()
+	if err != nil || !present {
+		return s, err
+	}
+	if s.ItemID, err = pr.ReadVarInt(); err != nil {
+		return s, err
+	}
+	if s.Count, err = pr.ReadVarInt(); err != nil {
+		return s, err
+	}
+	if s.Count <= 0 {
+		return s, fmt.Errorf("item is present with a stack of %d", s.Count)
+	}
+
+	added, err := pr.ReadVarInt()
+	if err != nil {
+		return s, err
+	}
+	if added < 0 || added > maxHashedComponents {
+		return s, fmt.Errorf("item has %d added components", added)
+	}
+	for i := int32(0); i < added; i++ {
+		typ, err := pr.ReadVarInt()
+		if err != nil {
+			return s, err
+		}
+		hash := make([]byte, 4)
+		if _, err := io.ReadFull(pr, hash); err != nil {
+			return s, err
+		}
+		s.Components = append(s.Components, Component{typ, hash})
+	}
+	removed, err := pr.ReadVarInt()
+	if err != nil {
+		return s, err
+	}
+	if removed < 0 || removed > maxHashedComponents {
+		return s, fmt.Errorf("item has %d removed components", removed)
+	}
+	for i := int32(0); i < removed; i++ {
+		typ, err := pr.ReadVarInt()
+		if err != nil {
+			return s, err
+		}
+		s.Removed = append(s.Removed, typ)
+	}
+	return s, nil
+}
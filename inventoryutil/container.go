@@ -0,0 +1,389 @@
+package inventoryutil
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// ContainerIDs are the clientbound packet IDs Container sends, which depend
+// on the protocol version. SetCursorItem only exists from 1.21.2; before,
+// the carried item is set with Set Container Slot.
+type ContainerIDs struct {
+	SetContainerContent int32
+	SetContainerSlot    int32
+	SetCursorItem       int32
+}
+
+// SetContainerContentPacket returns the Set Container Content packet, which
+// replaces every item in a window and the item the player carries.
+func SetContainerContentPacket(packetID int32, protocol int32, windowID, stateID int32, items []Slot, carried Slot) ([]byte, error) {
+	pw := packetutil.CreatePacketWriter(packetID)
+	writeWindowID(pw, protocol, windowID)
+	pw.WriteVarInt(stateID)
+	pw.WriteVarInt(int32(len(items)))
+	for i, item := range items {
+		if err := WriteSlot(pw, protocol, item); err != nil {
+			return nil, fmt.Errorf("slot %d: %w", i, err)
+		}
+	}
+	if err := WriteSlot(pw, protocol, carried); err != nil {
+		return nil, fmt.Errorf("carried item: %w", err)
+	}
+	return pw.GetPacket(), nil
+}
+
+// SetContainerSlotPacket returns the Set Container Slot packet, which
+// replaces the item in one slot of a window. Before 1.21.2, window -1 and
+// slot -1 set the item the player carries.
+func SetContainerSlotPacket(packetID int32, protocol int32, windowID, stateID int32, slot int16, item Slot) ([]byte, error) {
+	pw := packetutil.CreatePacketWriter(packetID)
+	writeWindowID(pw, protocol, windowID)
+	pw.WriteVarInt(stateID)
+	pw.WriteShort(slot)
+	if err := WriteSlot(pw, protocol, item); err != nil {
+		return nil, err
+	}
+	return pw.GetPacket(), nil
+}
+
+// SetCursorItemPacket returns the Set Cursor Item packet, which replaces the
+// item the player carries from 1.21.2.
+func SetCursorItemPacket(packetID int32, protocol int32, item Slot) ([]byte, error) {
+	pw := packetutil.CreatePacketWriter(packetID)
+	if err := WriteSlot(pw, protocol, item); err != nil {
+		return nil, err
+	}
+	return pw.GetPacket(), nil
+}
+
+// ClickHandler is called with a click on a container. It may change the
+// container's items.
+type ClickHandler func(c Click)
+
+// Container holds the items in a window, such as the player's inventory or
+// a chest they have open, and the item the player carries, and builds the
+// packets that show them to the player. Slots are numbered as the window
+// numbers them; see MenuType.Slots and PlayerSlot for the layout of menus.
+// Clicks are passed to the handler for the slot clicked, so a menu can act
+// on them.
+//
+// The container remembers what the player was last shown in each slot and,
+// after a click, what the client expects there. Changes then corrects every
+// slot where that differs from the container, so clicks a handler doesn't
+// act on are undone on the client, and resends everything if the client
+// clicked with an old state ID. It is safe for concurrent use.
+type Container struct {
+	ids      ContainerIDs
+	protocol int32
+	windowID int32
+	rules    *CreativeRules
+
+	lock        sync.Mutex
+	stateID     int32
+	items       []Slot
+	remote      []remoteSlot
+	carried     Slot
+	remoteCarry remoteSlot
+	resync      bool
+	handlers    map[int16]ClickHandler
+	fallback    ClickHandler
+}
+
+// CreateContainer is a factory function for creating a new, empty
+// Container for a window with the given ID and number of slots, whose
+// packets are built for the given protocol version. Clicks are read with
+// rules, as ClickCodec describes.
+func CreateContainer(ids ContainerIDs, protocol int32, windowID int32, size int, rules *CreativeRules) *Container {
+	c := new(Container)
+	c.ids = ids
+	c.protocol = protocol
+	c.windowID = windowID
+	c.rules = rules
+	c.items = make([]Slot, size)
+	c.remote = make([]remoteSlot, size)
+	c.handlers = make(map[int16]ClickHandler)
+	return c
+}
+
+// WindowID returns the ID of the container's window.
+func (c *Container) WindowID() int32 {
+	return c.windowID
+}
+
+// Size returns the number of slots in the container.
+func (c *Container) Size() int {
+	return len(c.items)
+}
+
+func (c *Container) checkSlot(slot int) error {
+	if slot < 0 || slot >= len(c.items) {
+		return fmt.Errorf("slot %d is outside the %d slots of window %d", slot, len(c.items), c.windowID)
+	}
+	return nil
+}
+
+// Item returns the item in a slot.
+func (c *Container) Item(slot int) (Slot, error) {
+	if err := c.checkSlot(slot); err != nil {
+		return Slot{}, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.items[slot], nil
+}
+
+// Set puts an item in a slot. The player sees it once Changes is sent.
+func (c *Container) Set(slot int, item Slot) error {
+	if err := c.checkSlot(slot); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.items[slot] = item
+	return nil
+}
+
+// Carried returns the item the player carries on their cursor.
+func (c *Container) Carried() Slot {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.carried
+}
+
+// SetCarried sets the item the player carries on their cursor. The player
+// sees it once Changes is sent.
+func (c *Container) SetCarried(item Slot) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.carried = item
+}
+
+// nextStateID returns the state ID after id. Every packet that changes a
+// window's items carries a new one, which the client sends back with its
+// clicks.
+func nextStateID(id int32) int32 {
+	return (id + 1) & 0x7FFF
+}
+
+// Content returns the Set Container Content packet showing every item in
+// the container, to be sent when its window is opened.
+func (c *Container) Content() ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.content()
+}
+
+func (c *Container) content() ([]byte, error) {
+	stateID := nextStateID(c.stateID)
+	packet, err := SetContainerContentPacket(c.ids.SetContainerContent, c.protocol, c.windowID, stateID, c.items, c.carried)
+	if err != nil {
+		return nil, err
+	}
+	c.stateID = stateID
+	c.resync = false
+	c.shown()
+	return packet, nil
+}
+
+// shown records that the player has been shown the container's items.
+func (c *Container) shown() {
+	for i, item := range c.items {
+		c.remote[i] = remoteSlot{item: item}
+	}
+	c.remoteCarry = remoteSlot{item: c.carried}
+}
+
+// Changes returns the packets showing the player every item that differs
+// from what they last saw or expect after a click, one per slot, or the Set
+// Container Content packet if they are out of step. Nothing is marked sent
+// if any item can't be encoded.
+func (c *Container) Changes() ([][]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.changes()
+}
+
+func (c *Container) changes() ([][]byte, error) {
+	if c.resync {
+		packet, err := c.content()
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{packet}, nil
+	}
+
+	var packets [][]byte
+	stateID := c.stateID
+	for i, item := range c.items {
+		if c.remote[i].matches(item) {
+			continue
+		}
+		stateID = nextStateID(stateID)
+		packet, err := SetContainerSlotPacket(c.ids.SetContainerSlot, c.protocol, c.windowID, stateID, int16(i), item)
+		if err != nil {
+			return nil, fmt.Errorf("slot %d: %w", i, err)
+		}
+		packets = append(packets, packet)
+	}
+	if !c.remoteCarry.matches(c.carried) {
+		var packet []byte
+		var err error
+		if c.protocol >= protocol1_21_2 {
+			packet, err = SetCursorItemPacket(c.ids.SetCursorItem, c.protocol, c.carried)
+		} else {
+			stateID = nextStateID(stateID)
+			packet, err = SetContainerSlotPacket(c.ids.SetContainerSlot, c.protocol, -1, stateID, -1, c.carried)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("carried item: %w", err)
+		}
+		packets = append(packets, packet)
+	}
+
+	c.stateID = stateID
+	c.shown()
+	return packets, nil
+}
+
+// Handle routes clicks on a slot, which may be OutsideWindow, to h. A nil h
+// removes the slot's handler.
+func (c *Container) Handle(slot int16, h ClickHandler) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if h == nil {
+		delete(c.handlers, slot)
+	} else {
+		c.handlers[slot] = h
+	}
+}
+
+// HandleOther routes clicks on slots without a handler of their own to h.
+func (c *Container) HandleOther(h ClickHandler) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.fallback = h
+}
+
+// HandleClick handles the serverbound Click Container packet, passing the
+// click to the handler for the slot clicked. Clicks for another window are
+// stale and are returned with false, unhandled. Clicks naming slots outside
+// the window are refused. Changes should be sent afterwards to bring the
+// client back in step with the container.
+func (c *Container) HandleClick(data []byte) (Click, bool, error) {
+	click, err := ClickCodec(c.protocol, c.rules).Unmarshal(data)
+	if err != nil {
+		return Click{}, false, fmt.Errorf("could not read click: %w", err)
+	}
+	if click.WindowID != c.windowID {
+		return click, false, nil
+	}
+	if click.Slot != OutsideWindow && click.Slot != -1 {
+		if err := c.checkSlot(int(click.Slot)); err != nil {
+			return click, false, err
+		}
+	}
+	seen := make(map[int16]bool, len(click.Changed))
+	for _, cs := range click.Changed {
+		if err := c.checkSlot(int(cs.Slot)); err != nil {
+			return click, false, err
+		}
+		if seen[cs.Slot] {
+			return click, false, fmt.Errorf("slot %d is changed twice", cs.Slot)
+		}
+		seen[cs.Slot] = true
+	}
+
+	c.lock.Lock()
+	// What the client expects is recorded whatever the handler does, so
+	// Changes corrects the client wherever it guessed wrong.
+	hashed := c.protocol >= protocol1_21_5
+	for _, cs := range click.Changed {
+		c.remote[cs.Slot] = remoteSlot{cs.Item, hashed}
+	}
+	c.remoteCarry = remoteSlot{click.Carried, hashed}
+	if click.StateID != c.stateID {
+		c.resync = true
+	}
+	h, ok := c.handlers[click.Slot]
+	if !ok {
+		h = c.fallback
+	}
+	c.lock.Unlock()
+
+	// The handler is called unlocked so it can change the container.
+	if h != nil {
+		h(click)
+	}
+	return click, true, nil
+}
+
+// remoteSlot is the item the client has in a slot, as far as the server
+// knows. From 1.21.5 the items clicks send have hashed components, which
+// can't be compared with the server's without hashing them the way vanilla
+// does.
+type remoteSlot struct {
+	item   Slot
+	hashed bool
+}
+
+// matches reports whether the client has the given item. Hashed items match
+// if they have the same item, count and component types, so a client with
+// the wrong data for a component isn't corrected.
+func (rs remoteSlot) matches(item Slot) bool {
+	if !rs.hashed {
+		return sameSlot(rs.item, item)
+	}
+	if rs.item.Empty() || item.Empty() {
+		return rs.item.Empty() == item.Empty()
+	}
+	if rs.item.ItemID != item.ItemID || rs.item.Count != item.Count {
+		return false
+	}
+	return sameTypes(componentTypes(rs.item.Components), componentTypes(item.Components)) && sameTypes(rs.item.Removed, item.Removed)
+}
+
+func componentTypes(components []Component) []int32 {
+	types := make([]int32, len(components))
+	for i, c := range components {
+		types[i] = c.Type
+	}
+	return types
+}
+
+// sameTypes reports whether a and b hold the same component types in any
+// order.
+func sameTypes(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// sameSlot reports whether two items are the same stack.
+func sameSlot(a, b Slot) bool {
+	if a.Empty() || b.Empty() {
+		return a.Empty() == b.Empty()
+	}
+	if a.ItemID != b.ItemID || a.Count != b.Count || !slices.Equal(a.Removed, b.Removed) || len(a.Components) != len(b.Components) {
+		return false
+	}
+	if (len(a.NBT) != 0 || len(b.NBT) != 0) && !reflect.DeepEqual(a.NBT, b.NBT) {
+		return false
+	}
+	for i := range a.Components {
+		if a.Components[i].Type != b.Components[i].Type || !bytes.Equal(a.Components[i].Data, b.Components[i].Data) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,339 @@
+package inventoryutil
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/PurpurProject/elytra/nbtutil"
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Slot is an item stack as packets send it. Items are numbered by their ID
+// in the item registry. Before 1.20.5 extra data is carried as NBT; from
+// then it is carried as components added to or removed from the item's
+// defaults.
+type Slot struct {
+	ItemID int32
+	Count  int32
+	NBT    nbtutil.Compound
+
+	Components []Component
+	Removed    []int32
+}
+
+// Empty reports whether the slot holds nothing.
+func (s Slot) Empty() bool {
+	return s.Count <= 0
+}
+
+// Component is a data component on an item, numbered by its ID in the
+// data_component_type registry. Data is left encoded.
+type Component struct {
+	Type int32
+	Data []byte
+}
+
+// ComponentReader reads past the data of one type of component. Components
+// don't say how long they are, so a slot can only be read if there is a
+// reader for every component on it.
+type ComponentReader func(pr *packetutil.PacketReader) error
+
+// EmptyComponent reads a component that has no data, such as
+// hide_tooltip.
+func EmptyComponent(pr *packetutil.PacketReader) error {
+	return nil
+}
+
+// VarIntComponent reads a component holding a single VarInt, such as
+// max_stack_size or damage.
+func VarIntComponent(pr *packetutil.PacketReader) error {
+	_, err := pr.ReadVarInt()
+	return err
+}
+
+// FixedComponent returns a reader for a component of a fixed size, such as
+// enchantment_glint_override, a single boolean.
+func FixedComponent(size int) ComponentReader {
+	return func(pr *packetutil.PacketReader) error {
+		_, err := io.ReadFull(pr, make([]byte, size))
+		return err
+	}
+}
+
+// NBTComponent returns a reader for a component holding NBT, such as
+// custom_data or custom_name, refusing more than maxBytes of arrays,
+// strings and lists.
+func NBTComponent(maxBytes int64) ComponentReader {
+	return func(pr *packetutil.PacketReader) error {
+		nr := nbtutil.CreateReader(pr)
+		nr.MaxBytes = maxBytes
+		_, err := nr.ReadNetwork()
+		return err
+	}
+}
+
+// ListComponent returns a reader for a component holding a list, such as
+// lore, whose elements are read with elem. Lists longer than maxLength are
+// refused.
+func ListComponent(elem ComponentReader, maxLength int32) ComponentReader {
+	return func(pr *packetutil.PacketReader) error {
+		length, err := pr.ReadVarInt()
+		if err != nil {
+			return err
+		}
+		if length < 0 || length > maxLength {
+			return fmt.Errorf("list of %d elements exceeds the limit of %d", length, maxLength)
+		}
+		for i := int32(0); i < length; i++ {
+			if err := elem(pr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// CreativeRules decide which items a player in creative mode may put in
+// their inventory with Set Creative Mode Slot. The client can send any item
+// at all, so anything not allowed here is refused before it reaches the
+// player's inventory or storage.
+type CreativeRules struct {
+	// MaxCount is the largest stack allowed.
+	MaxCount int32
+	// Components are the component types players may set from 1.20.5, by
+	// registry ID, each with how to read its data. Items with any other
+	// component are refused.
+	Components map[int32]ComponentReader
+	// Tags are the top-level NBT tags players may set before 1.20.5. Nil
+	// allows any.
+	Tags map[string]bool
+	// MaxNBTBytes caps the item's NBT before 1.20.5, as NBTComponent caps a
+	// component's.
+	MaxNBTBytes int64
+	// MaxDataBytes caps the total encoded size of an item's components.
+	MaxDataBytes int
+	// Check, if set, is called once an item has passed the other rules,
+	// and may refuse it too.
+	Check func(cs CreativeSlot) error
+}
+
+// DefaultCreativeRules returns rules that allow stacks of up to 99 with any
+// NBT before 1.20.5, but no components, within vanilla's packet NBT limit.
+// Components players may set must be added to Components.
+func DefaultCreativeRules() *CreativeRules {
+	cr := new(CreativeRules)
+	cr.MaxCount = 99
+	cr.Components = make(map[int32]ComponentReader)
+	cr.MaxNBTBytes = 2097152
+	cr.MaxDataBytes = 2097152
+	return cr
+}
+
+// CreativeSlot is Set Creative Mode Slot: an item a player in creative mode
+// puts into a slot of their inventory, or drops if Slot is -1.
+type CreativeSlot struct {
+	Slot int16
+	Item Slot
+}
+
+// Drop reports whether the player is throwing the item away rather than
+// putting it in their inventory.
+func (cs CreativeSlot) Drop() bool {
+	return cs.Slot < 0
+}
+
+// Player inventory slots a creative player may set, as vanilla numbers them:
+// the crafting grid is excluded, but armour, the main inventory, the hotbar
+// and the offhand are not.
+const (
+	minCreativeSlot = 1
+	maxCreativeSlot = 45
+)
+
+// CreativeSlotCodec returns the codec for the body of Set Creative Mode
+// Slot in a protocol version. Reading applies the rules, which must not be
+// nil: if it succeeds, the item is safe to store.
+func CreativeSlotCodec(protocol int32, rules *CreativeRules) packetutil.Codec[CreativeSlot] {
+	return packetutil.Codec[CreativeSlot]{
+		Encode: func(pw *packetutil.PacketWriter, cs CreativeSlot) {
+			pw.WriteShort(cs.Slot)
+			if err := WriteSlot(pw, protocol, cs.Item); err != nil {
+				pw.Fail(err)
+			}
+		},
+		Decode: func(pr *packetutil.PacketReader) (CreativeSlot, error) {
+			var cs CreativeSlot
+			var err error
+			if cs.Slot, err = pr.ReadShort(); err != nil {
+				return cs, err
+			}
+			if cs.Slot != -1 && (cs.Slot < minCreativeSlot || cs.Slot > maxCreativeSlot) {
+				return cs, fmt.Errorf("slot %d can't be set in creative mode", cs.Slot)
+			}
+			if cs.Item, err = rules.readSlot(pr, protocol); err != nil {
+				return cs, err
+			}
+			if !cs.Item.Empty() && rules.Check != nil {
+				if err := rules.Check(cs); err != nil {
+					return cs, err
+				}
+			}
+			return cs, nil
+		},
+	}
+}
+
+func (cr *CreativeRules) readSlot(pr *packetutil.PacketReader, protocol int32) (Slot, error) {
+	var s Slot
+	if protocol < protocol1_20_5 {
+		present, err := pr.ReadBoolean()
+		if err != nil || !present {
+			return s, err
+		}
+		if s.ItemID, err = pr.ReadVarInt(); err != nil {
+			return s, err
+		}
+		count, err := pr.ReadByte()
+		if err != nil {
+			return s, err
+		}
+		s.Count = int32(count)
+		if err := cr.checkCount(s.Count); err != nil {
+			return s, err
+		}
+		nr := nbtutil.CreateReader(pr)
+		nr.MaxBytes = cr.MaxNBTBytes
+		if s.NBT, err = nr.ReadNetwork(); err != nil {
+			return s, fmt.Errorf("could not read item NBT: %w", err)
+		}
+		if cr.Tags != nil {
+			for name := range s.NBT {
+				if !cr.Tags[name] {
+					return s, fmt.Errorf("item tag %s isn't allowed", name)
+				}
+			}
+		}
+		return s, nil
+	}
+
+	var err error
+	if s.Count, err = pr.ReadVarInt(); err != nil || s.Count == 0 {
+		return s, err
+	}
+	if err := cr.checkCount(s.Count); err != nil {
+		return s, err
+	}
+	if s.ItemID, err = pr.ReadVarInt(); err != nil {
+		return s, err
+	}
+	added, err := pr.ReadVarInt()
+	if err != nil {
+		return s, err
+	}
+	removed, err := pr.ReadVarInt()
+	if err != nil {
+		return s, err
+	}
+	if added < 0 || removed < 0 || int(added)+int(removed) > len(cr.Components) {
+		return s, fmt.Errorf("item has %d added and %d removed components", added, removed)
+	}
+
+	size := 0
+	seen := make(map[int32]bool, added+removed)
+	for i := int32(0); i < added; i++ {
+		typ, err := pr.ReadVarInt()
+		if err != nil {
+			return s, err
+		}
+		read, ok := cr.Components[typ]
+		if !ok {
+			return s, fmt.Errorf("item component %d isn't allowed", typ)
+		}
+		if seen[typ] {
+			return s, fmt.Errorf("item component %d is given twice", typ)
+		}
+		seen[typ] = true
+
+		data, err := readComponent(pr, read)
+		if err != nil {
+			return s, fmt.Errorf("could not read item component %d: %w", typ, err)
+		}
+		if size += len(data); size > cr.MaxDataBytes {
+			return s, fmt.Errorf("item components exceed the limit of %d bytes", cr.MaxDataBytes)
+		}
+		s.Components = append(s.Components, Component{typ, data})
+	}
+	for i := int32(0); i < removed; i++ {
+		typ, err := pr.ReadVarInt()
+		if err != nil {
+			return s, err
+		}
+		if _, ok := cr.Components[typ]; !ok {
+			return s, fmt.Errorf("item component %d isn't allowed", typ)
+		}
+		if seen[typ] {
+			return s, fmt.Errorf("item component %d is given twice", typ)
+		}
+		seen[typ] = true
+		s.Removed = append(s.Removed, typ)
+	}
+	return s, nil
+}
+
+func (cr *CreativeRules) checkCount(count int32) error {
+	if count < 0 || count > cr.MaxCount {
+		return fmt.Errorf("stack of %d is outside 1 to %d", count, cr.MaxCount)
+	}
+	return nil
+}
+
+// readComponent reads past a component's data with read, returning the
+// bytes it took up.
+func readComponent(pr *packetutil.PacketReader, read ComponentReader) ([]byte, error) {
+	start, _ := pr.Seek(0, io.SeekCurrent)
+	if err := read(pr); err != nil {
+		return nil, err
+	}
+	end, _ := pr.Seek(0, io.SeekCurrent)
+	pr.Seek(start, io.SeekStart)
+	data := make([]byte, end-start)
+	_, err := io.ReadFull(pr, data)
+	return data, err
+}
+
+// WriteSlot writes an item stack as a protocol version sends it, or
+// returns an error, writing nothing, if its NBT can't be encoded.
+func WriteSlot(pw *packetutil.PacketWriter, protocol int32, s Slot) error {
+	if protocol < protocol1_20_5 {
+		if s.Empty() {
+			pw.WriteBoolean(false)
+			return nil
+		}
+		nw := nbtutil.CreateWriter()
+		if err := nw.WriteNetwork(s.NBT); err != nil {
+			return fmt.Errorf("encoding item NBT: %w", err)
+		}
+		pw.WriteBoolean(true)
+		pw.WriteVarInt(s.ItemID)
+		pw.WriteByte(int8(min(s.Count, 127)))
+		pw.WriteBytes(nw.Bytes())
+		return nil
+	}
+
+	if s.Empty() {
+		pw.WriteVarInt(0)
+		return nil
+	}
+	pw.WriteVarInt(s.Count)
+	pw.WriteVarInt(s.ItemID)
+	pw.WriteVarInt(int32(len(s.Components)))
+	pw.WriteVarInt(int32(len(s.Removed)))
+	for _, c := range s.Components {
+		pw.WriteVarInt(c.Type)
+		pw.WriteBytes(c.Data)
+	}
+	for _, typ := range s.Removed {
+		pw.WriteVarInt(typ)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package inventoryutil
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// HotbarSize is the number of slots in the hotbar.
+const HotbarSize = 9
+
+// Player inventory slots as window 0 numbers them. Container windows show
+// the main inventory and hotbar, from firstMainSlot on, below their own.
+const (
+	firstMainSlot    = 9
+	firstHotbarSlot  = 36
+	shownPlayerSlots = 36
+)
+
+// PlayerInventorySize is the number of slots in window 0: the crafting
+// result and grid, armour, the main inventory, the hotbar and the offhand.
+const PlayerInventorySize = 46
+
+// InventorySlot returns the player inventory slot, as window 0 and Set
+// Creative Mode Slot number them, of a hotbar slot.
+func InventorySlot(hotbar int) int16 {
+	return int16(firstHotbarSlot + hotbar)
+}
+
+// HotbarSlot returns the hotbar slot of a player inventory slot, or false
+// if it isn't in the hotbar.
+func HotbarSlot(slot int16) (int, bool) {
+	hotbar := int(slot) - firstHotbarSlot
+	return hotbar, hotbar >= 0 && hotbar < HotbarSize
+}
+
+func checkHotbarSlot(slot int) error {
+	if slot < 0 || slot >= HotbarSize {
+		return fmt.Errorf("hotbar slot %d is out of range", slot)
+	}
+	return nil
+}
+
+// SetHeldItemPacket returns the clientbound Set Held Item packet, which
+// selects a hotbar slot. Its slot is a byte before 1.21.2 and a VarInt from
+// then.
+func SetHeldItemPacket(packetID int32, protocol int32, slot int) ([]byte, error) {
+	if err := checkHotbarSlot(slot); err != nil {
+		return nil, err
+	}
+	pw := packetutil.CreatePacketWriter(packetID)
+	if protocol >= protocol1_21_2 {
+		pw.WriteVarInt(int32(slot))
+	} else {
+		pw.WriteByte(int8(slot))
+	}
+	return pw.GetPacket(), nil
+}
+
+// ServerboundSetHeldItemPacket returns the serverbound Set Held Item
+// packet, which a client sends when the player scrolls to another slot.
+func ServerboundSetHeldItemPacket(packetID int32, slot int) ([]byte, error) {
+	if err := checkHotbarSlot(slot); err != nil {
+		return nil, err
+	}
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteShort(int16(slot))
+	return pw.GetPacket(), nil
+}
+
+// ReadSetHeldItem reads the serverbound Set Held Item packet, refusing slots
+// outside the hotbar.
+func ReadSetHeldItem(data []byte) (int, error) {
+	slot, err := packetutil.ShortCodec.Unmarshal(data)
+	if err != nil {
+		return 0, fmt.Errorf("could not read held item: %w", err)
+	}
+	if err := checkHotbarSlot(int(slot)); err != nil {
+		return 0, err
+	}
+	return int(slot), nil
+}
+
+// Hotbar keeps track of a player's hotbar: which slot they hold and the item
+// in each. Items are kept in step with the player inventory by SetSlot,
+// which takes slots as window 0 numbers them. It is safe for concurrent use.
+type Hotbar struct {
+	packetID int32
+	protocol int32
+
+	lock     sync.Mutex
+	selected int
+	items    [HotbarSize]Slot
+}
+
+// CreateHotbar is a factory function for creating a new Hotbar, with the
+// first slot selected, whose Set Held Item packets are built for the given
+// protocol version.
+func CreateHotbar(packetID int32, protocol int32) *Hotbar {
+	hb := new(Hotbar)
+	hb.packetID = packetID
+	hb.protocol = protocol
+	return hb
+}
+
+// Selected returns the selected hotbar slot.
+func (hb *Hotbar) Selected() int {
+	hb.lock.Lock()
+	defer hb.lock.Unlock()
+	return hb.selected
+}
+
+// Held returns the item in the selected slot.
+func (hb *Hotbar) Held() Slot {
+	hb.lock.Lock()
+	defer hb.lock.Unlock()
+	return hb.items[hb.selected]
+}
+
+// Item returns the item in a hotbar slot.
+func (hb *Hotbar) Item(slot int) (Slot, error) {
+	if err := checkHotbarSlot(slot); err != nil {
+		return Slot{}, err
+	}
+
+	hb.lock.Lock()
+	defer hb.lock.Unlock()
+	return hb.items[slot], nil
+}
+
+// SetSlot records the item in a player inventory slot, reporting whether the
+// slot is in the hotbar. Other slots are ignored, so every change to the
+// inventory can be passed in.
+func (hb *Hotbar) SetSlot(slot int16, item Slot) bool {
+	hotbar, ok := HotbarSlot(slot)
+	if !ok {
+		return false
+	}
+
+	hb.lock.Lock()
+	defer hb.lock.Unlock()
+	hb.items[hotbar] = item
+	return true
+}
+
+// Select selects a hotbar slot from the server's side, returning the Set
+// Held Item packet to send.
+func (hb *Hotbar) Select(slot int) ([]byte, error) {
+	packet, err := SetHeldItemPacket(hb.packetID, hb.protocol, slot)
+	if err != nil {
+		return nil, err
+	}
+
+	hb.lock.Lock()
+	defer hb.lock.Unlock()
+	hb.selected = slot
+	return packet, nil
+}
+
+// HandleSetHeldItem handles the serverbound Set Held Item packet, reporting
+// whether the selected slot changed. A slot outside the hotbar is an error
+// and leaves the selection alone; vanilla ignores such packets.
+func (hb *Hotbar) HandleSetHeldItem(data []byte) (bool, error) {
+	slot, err := ReadSetHeldItem(data)
+	if err != nil {
+		return false, err
+	}
+
+	hb.lock.Lock()
+	defer hb.lock.Unlock()
+	changed := slot != hb.selected
+	hb.selected = slot
+	return changed, nil
+}
@@ -0,0 +1,367 @@
+// Package inventoryutil handles the containers a player can have open: the
+// window IDs they are known by, the packets that open and close them and
+// set their contents, the clicks and items players send for them, and the
+// hotbar slot a player holds.
+package inventoryutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/PurpurProject/elytra/jsonutil"
+	"github.com/PurpurProject/elytra/nbtutil"
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Protocol versions at which the packets built here changed shape.
+const (
+	protocol1_20_3 = 765
+	protocol1_20_5 = 766
+	protocol1_21_2 = 768
+	protocol1_21_5 = 770
+)
+
+// MenuType is a kind of container screen, numbered as the menu registry is
+// from 1.20.3. Use ID for the number a given protocol sends.
+type MenuType int32
+
+const (
+	Generic9x1 MenuType = iota
+	Generic9x2
+	Generic9x3
+	Generic9x4
+	Generic9x5
+	Generic9x6
+	Generic3x3
+	Crafter3x3
+	Anvil
+	Beacon
+	BlastFurnace
+	BrewingStand
+	Crafting
+	Enchantment
+	Furnace
+	Grindstone
+	Hopper
+	Lectern
+	Loom
+	Merchant
+	ShulkerBox
+	Smithing
+	Smoker
+	CartographyTable
+	Stonecutter
+)
+
+var menuNames = [...]string{
+	Generic9x1:       "minecraft:generic_9x1",
+	Generic9x2:       "minecraft:generic_9x2",
+	Generic9x3:       "minecraft:generic_9x3",
+	Generic9x4:       "minecraft:generic_9x4",
+	Generic9x5:       "minecraft:generic_9x5",
+	Generic9x6:       "minecraft:generic_9x6",
+	Generic3x3:       "minecraft:generic_3x3",
+	Crafter3x3:       "minecraft:crafter_3x3",
+	Anvil:            "minecraft:anvil",
+	Beacon:           "minecraft:beacon",
+	BlastFurnace:     "minecraft:blast_furnace",
+	BrewingStand:     "minecraft:brewing_stand",
+	Crafting:         "minecraft:crafting",
+	Enchantment:      "minecraft:enchantment",
+	Furnace:          "minecraft:furnace",
+	Grindstone:       "minecraft:grindstone",
+	Hopper:           "minecraft:hopper",
+	Lectern:          "minecraft:lectern",
+	Loom:             "minecraft:loom",
+	Merchant:         "minecraft:merchant",
+	ShulkerBox:       "minecraft:shulker_box",
+	Smithing:         "minecraft:smithing",
+	Smoker:           "minecraft:smoker",
+	CartographyTable: "minecraft:cartography_table",
+	Stonecutter:      "minecraft:stonecutter",
+}
+
+var menuSizes = [...]int{
+	Generic9x1:       9,
+	Generic9x2:       18,
+	Generic9x3:       27,
+	Generic9x4:       36,
+	Generic9x5:       45,
+	Generic9x6:       54,
+	Generic3x3:       9,
+	Crafter3x3:       10,
+	Anvil:            3,
+	Beacon:           1,
+	BlastFurnace:     3,
+	BrewingStand:     5,
+	Crafting:         10,
+	Enchantment:      2,
+	Furnace:          3,
+	Grindstone:       3,
+	Hopper:           5,
+	Lectern:          1,
+	Loom:             4,
+	Merchant:         3,
+	ShulkerBox:       27,
+	Smithing:         4,
+	Smoker:           3,
+	CartographyTable: 3,
+	Stonecutter:      2,
+}
+
+// ParseMenuType returns the menu type with the given ID, such as
+// minecraft:generic_9x3.
+func ParseMenuType(id string) (MenuType, bool) {
+	for mt, name := range menuNames {
+		if name == id || name == "minecraft:"+id {
+			return MenuType(mt), true
+		}
+	}
+	return 0, false
+}
+
+// ID returns the number the protocol sends the menu type as. 1.20.2 has no
+// crafter, so the types after it are numbered one lower.
+func (mt MenuType) ID(protocol int32) (int32, bool) {
+	if mt < 0 || int(mt) >= len(menuNames) {
+		return 0, false
+	}
+	if protocol < protocol1_20_3 {
+		switch {
+		case mt == Crafter3x3:
+			return 0, false
+		case mt > Crafter3x3:
+			return int32(mt) - 1, true
+		}
+	}
+	return int32(mt), true
+}
+
+// Size returns the number of slots of the menu type's own, which come before
+// the player's inventory in its window, or 0 if it is unknown.
+func (mt MenuType) Size() int {
+	if mt < 0 || int(mt) >= len(menuSizes) {
+		return 0
+	}
+	return menuSizes[mt]
+}
+
+// Slots returns the number of slots in a window of the menu type. Every menu
+// but the lectern shows the player's main inventory and hotbar below its own
+// slots.
+func (mt MenuType) Slots() int {
+	if mt == Lectern {
+		return mt.Size()
+	}
+	return mt.Size() + shownPlayerSlots
+}
+
+// PlayerSlot returns the player inventory slot, as window 0 numbers them,
+// shown in a slot of the menu type's window, or false if the slot is one of
+// the menu's own.
+func (mt MenuType) PlayerSlot(slot int) (int16, bool) {
+	own := mt.Size()
+	if mt == Lectern || slot < own || slot >= own+shownPlayerSlots {
+		return 0, false
+	}
+	return int16(firstMainSlot + slot - own), true
+}
+
+func (mt MenuType) String() string {
+	if mt >= 0 && int(mt) < len(menuNames) {
+		return menuNames[mt]
+	}
+	return fmt.Sprintf("MenuType(%d)", int32(mt))
+}
+
+// maxWindowID is the highest window ID vanilla hands out before starting
+// again from 1. 0 is always the player's own inventory.
+const maxWindowID = 100
+
+// WindowIDs are the clientbound packet IDs Windows sends, which depend on
+// the protocol version.
+type WindowIDs struct {
+	OpenScreen      int32
+	OpenHorseScreen int32
+	CloseContainer  int32
+}
+
+// Window is a container a player has open. Horse windows show a horse's
+// inventory and have no menu type; EntityID is the horse they belong to.
+type Window struct {
+	ID       int32
+	Menu     MenuType
+	Horse    bool
+	EntityID int32
+}
+
+// Windows tracks the container a player has open, handing out window IDs as
+// vanilla does, and builds the packets that open and close it. A player has
+// at most one open at a time, besides their own inventory. It is safe for
+// concurrent use.
+type Windows struct {
+	ids      WindowIDs
+	protocol int32
+
+	lock    sync.Mutex
+	counter int32
+	current *Window
+}
+
+// CreateWindows is a factory function for creating a new Windows for a
+// player, whose packets are built for the given protocol version.
+func CreateWindows(ids WindowIDs, protocol int32) *Windows {
+	ws := new(Windows)
+	ws.ids = ids
+	ws.protocol = protocol
+	return ws
+}
+
+func (ws *Windows) nextID() int32 {
+	ws.counter = ws.counter%maxWindowID + 1
+	return ws.counter
+}
+
+// Open opens a container screen with the given title, returning the window
+// and the Open Screen packet. Any window already open is replaced, as the
+// client closes it by itself on receiving the packet.
+func (ws *Windows) Open(menu MenuType, title jsonutil.ChatObject) (Window, []byte, error) {
+	typeID, ok := menu.ID(ws.protocol)
+	if !ok {
+		return Window{}, nil, fmt.Errorf("menu %s doesn't exist in protocol %d", menu, ws.protocol)
+	}
+	var encoded []byte
+	if ws.protocol >= protocol1_20_3 {
+		nw := nbtutil.CreateWriter()
+		if err := nw.WriteNetwork(title.Compound()); err != nil {
+			return Window{}, nil, fmt.Errorf("encoding window title: %w", err)
+		}
+		encoded = nw.Bytes()
+	} else {
+		var err error
+		if encoded, err = json.Marshal(title); err != nil {
+			return Window{}, nil, fmt.Errorf("encoding window title: %w", err)
+		}
+	}
+
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	w := Window{ID: ws.nextID(), Menu: menu}
+	ws.current = &w
+
+	pw := packetutil.CreatePacketWriter(ws.ids.OpenScreen)
+	pw.WriteVarInt(w.ID)
+	pw.WriteVarInt(typeID)
+	if ws.protocol >= protocol1_20_3 {
+		pw.WriteBytes(encoded)
+	} else {
+		pw.WriteString(string(encoded))
+	}
+	return w, pw.GetPacket(), nil
+}
+
+// OpenHorse opens a horse's inventory, which has its own packet rather than
+// a menu type, as the client builds the screen from the horse itself.
+// slots is how many chest slots the horse has, not counting the saddle and
+// armour: 0 for one without a chest, and a multiple of 3 otherwise.
+func (ws *Windows) OpenHorse(entityID int32, slots int32) (Window, []byte) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	w := Window{ID: ws.nextID(), Horse: true, EntityID: entityID}
+	ws.current = &w
+
+	pw := packetutil.CreatePacketWriter(ws.ids.OpenHorseScreen)
+	if ws.protocol >= protocol1_21_2 {
+		// 1.21.2 counts the chest in columns of three.
+		pw.WriteVarInt(w.ID)
+		pw.WriteVarInt(slots / 3)
+	} else {
+		pw.WriteUnsignedByte(byte(w.ID))
+		pw.WriteVarInt(slots)
+	}
+	pw.WriteInt(entityID)
+	return w, pw.GetPacket()
+}
+
+// Current returns the window the player has open.
+func (ws *Windows) Current() (Window, bool) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	if ws.current == nil {
+		return Window{}, false
+	}
+	return *ws.current, true
+}
+
+// IsOpen reports whether a window ID from a serverbound packet, such as
+// Click Container, refers to the player's inventory or the window they have
+// open. Packets for any other window are stale and should be ignored.
+func (ws *Windows) IsOpen(windowID int32) bool {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	return windowID == 0 || ws.current != nil && ws.current.ID == windowID
+}
+
+// Close closes the player's window from the server's side, returning it and
+// the Close Container packet to send, or false if none is open.
+func (ws *Windows) Close() (Window, []byte, bool) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if ws.current == nil {
+		return Window{}, nil, false
+	}
+	w := *ws.current
+	ws.current = nil
+	return w, CloseContainerPacket(ws.ids.CloseContainer, ws.protocol, w.ID), true
+}
+
+// HandleClose handles the serverbound Close Container packet, returning the
+// window the player closed, or false if they only closed their inventory.
+// As in vanilla, the open window is closed whatever ID the client names.
+func (ws *Windows) HandleClose(data []byte) (Window, bool, error) {
+	pr := packetutil.CreatePacketReader(data)
+	if _, err := readWindowID(pr, ws.protocol); err != nil {
+		return Window{}, false, fmt.Errorf("could not read window ID: %w", err)
+	}
+
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if ws.current == nil {
+		return Window{}, false, nil
+	}
+	w := *ws.current
+	ws.current = nil
+	return w, true, nil
+}
+
+// readWindowID reads a window ID, which is an unsigned byte in most packets
+// before 1.21.2 and a VarInt from then.
+func readWindowID(pr *packetutil.PacketReader, protocol int32) (int32, error) {
+	if protocol >= protocol1_21_2 {
+		return pr.ReadVarInt()
+	}
+	id, err := pr.ReadUnsignedByte()
+	return int32(id), err
+}
+
+func writeWindowID(pw *packetutil.PacketWriter, protocol int32, id int32) {
+	if protocol >= protocol1_21_2 {
+		pw.WriteVarInt(id)
+	} else {
+		pw.WriteUnsignedByte(byte(id))
+	}
+}
+
+// CloseContainerPacket returns the Close Container packet. Both directions
+// share its layout, so this also builds the serverbound one, as a proxy or
+// bot closing a window sends it.
+func CloseContainerPacket(packetID int32, protocol int32, windowID int32) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	writeWindowID(pw, protocol, windowID)
+	return pw.GetPacket()
+}
@@ -0,0 +1,32 @@
+package jsonutil
+
+import "github.com/PurpurProject/elytra/nbtutil"
+
+// Compound converts the chat component to the NBT form used on the wire
+// since 1.20.3.
+func (co ChatObject) Compound() nbtutil.Compound {
+	c := nbtutil.Compound{"text": co.Text}
+	flags := []struct {
+		name string
+		set  bool
+	}{
+		{"bold", co.Bold}, {"italic", co.Italic}, {"underlined", co.Underlined},
+		{"strikethrough", co.Strikethrough}, {"obfuscated", co.Obfuscated},
+	}
+	for _, flag := range flags {
+		if flag.set {
+			c[flag.name] = int8(1)
+		}
+	}
+	if co.Color != "" {
+		c["color"] = co.Color
+	}
+	if len(co.Extra) > 0 {
+		extra := nbtutil.List{Type: nbtutil.TagCompound}
+		for _, child := range co.Extra {
+			extra.Elements = append(extra.Elements, child.Compound())
+		}
+		c["extra"] = extra
+	}
+	return c
+}
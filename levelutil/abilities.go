@@ -0,0 +1,130 @@
+package levelutil
+
+import (
+	"fmt"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Vanilla's default flying and walking speeds. The walking speed is also
+// what the client widens its field of view by as the player speeds up.
+const (
+	DefaultFlySpeed  = 0.05
+	DefaultWalkSpeed = 0.1
+)
+
+// Flags of the Player Abilities packets. The client only ever sends
+// abilityFlying.
+const (
+	abilityInvulnerable = 0x01
+	abilityFlying       = 0x02
+	abilityMayFly       = 0x04
+	abilityInstaBuild   = 0x08
+)
+
+// DefaultAbilities returns the abilities of a player who has just joined in
+// a game mode, with the default speeds.
+func DefaultAbilities(gameMode int32) Abilities {
+	a := Abilities{FlySpeed: DefaultFlySpeed, WalkSpeed: DefaultWalkSpeed}
+	a.ApplyGameMode(gameMode)
+	return a
+}
+
+// ApplyGameMode sets the abilities a game mode grants, as vanilla does on
+// changing it. Speeds are left alone, and so is flying in creative mode;
+// spectators always fly.
+func (a *Abilities) ApplyGameMode(gameMode int32) {
+	switch gameMode {
+	case Creative:
+		a.MayFly = true
+		a.InstaBuild = true
+		a.Invulnerable = true
+	case Spectator:
+		a.MayFly = true
+		a.InstaBuild = false
+		a.Invulnerable = true
+		a.Flying = true
+	default:
+		a.MayFly = false
+		a.InstaBuild = false
+		a.Invulnerable = false
+		a.Flying = false
+	}
+	a.MayBuild = gameMode != Adventure && gameMode != Spectator
+}
+
+// Packet returns the clientbound Player Abilities packet.
+func (a Abilities) Packet(packetID int32) []byte {
+	var flags byte
+	if a.Invulnerable {
+		flags |= abilityInvulnerable
+	}
+	if a.Flying {
+		flags |= abilityFlying
+	}
+	if a.MayFly {
+		flags |= abilityMayFly
+	}
+	if a.InstaBuild {
+		flags |= abilityInstaBuild
+	}
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteUnsignedByte(flags)
+	pw.WriteFloat(a.FlySpeed)
+	pw.WriteFloat(a.WalkSpeed)
+	return pw.GetPacket()
+}
+
+// ServerboundAbilitiesPacket returns the serverbound Player Abilities
+// packet, which a client sends when it starts or stops flying.
+func ServerboundAbilitiesPacket(packetID int32, flying bool) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	if flying {
+		pw.WriteUnsignedByte(abilityFlying)
+	} else {
+		pw.WriteUnsignedByte(0)
+	}
+	return pw.GetPacket()
+}
+
+// HandleAbilities handles the serverbound Player Abilities packet, taking
+// the player's flying state from it. A player who may not fly can't start,
+// as in vanilla; the returned bool reports whether Flying changed.
+func (a *Abilities) HandleAbilities(data []byte) (bool, error) {
+	pr := packetutil.CreatePacketReader(data)
+	flags, err := pr.ReadUnsignedByte()
+	if err != nil {
+		return false, fmt.Errorf("could not read abilities: %w", err)
+	}
+	flying := flags&abilityFlying != 0 && a.MayFly
+	changed := flying != a.Flying
+	a.Flying = flying
+	return changed, nil
+}
+
+// AbilityIDs are the clientbound packet IDs SwitchGameMode sends, which
+// depend on the protocol version.
+type AbilityIDs struct {
+	GameEvent       int32
+	PlayerAbilities int32
+}
+
+// SwitchGameMode changes a player's game mode, updating their abilities to
+// match, and returns the packets to send them, in the order vanilla does:
+// the Game Event that switches the client over, then the new abilities.
+// The player's entry in everyone's tab list must also be updated, as
+// tablistutil's SetGameMode does.
+func SwitchGameMode(ids AbilityIDs, player *PlayerState, a *Abilities, gameMode int32) ([][]byte, error) {
+	if gameMode < Survival || gameMode > Spectator {
+		return nil, fmt.Errorf("unknown game mode %d", gameMode)
+	}
+	if player.GameMode != gameMode {
+		player.PreviousGameMode = player.GameMode
+		player.GameMode = gameMode
+	}
+	a.ApplyGameMode(gameMode)
+	return [][]byte{
+		GameModeEvent(ids.GameEvent, gameMode),
+		a.Packet(ids.PlayerAbilities),
+	}, nil
+}
@@ -0,0 +1,275 @@
+package levelutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// AdvancementsDir is the directory in a world that holds each player's
+// advancement progress, as <uuid>.json.
+const AdvancementsDir = "advancements"
+
+// advancementTimeLayout is the layout of the criterion timestamps.
+const advancementTimeLayout = "2006-01-02 15:04:05 -0700"
+
+// AdvancementProgress is one player's progress on advancements: which
+// criteria they have obtained and when. It keeps track of the advancements
+// that changed since the last packet, so only those are sent.
+type AdvancementProgress struct {
+	DataVersion int32
+
+	// requirements gives each known advancement's requirements: groups of
+	// criteria, each needing at least one obtained for it to be done.
+	requirements map[string][][]string
+	criteria     map[string]map[string]time.Time
+	// done holds the done flag from the file for advancements without
+	// requirements, such as those from data packs no longer enabled.
+	done    map[string]bool
+	changed map[string]bool
+}
+
+// CreateAdvancementProgress is a factory function for creating a new, empty
+// AdvancementProgress. requirements holds the requirements of every
+// advancement, by identifier.
+func CreateAdvancementProgress(requirements map[string][][]string) *AdvancementProgress {
+	ap := new(AdvancementProgress)
+	ap.requirements = requirements
+	ap.criteria = make(map[string]map[string]time.Time)
+	ap.done = make(map[string]bool)
+	ap.changed = make(map[string]bool)
+	return ap
+}
+
+// ReadAdvancementProgress reads a player's advancements file. Every
+// advancement in it is sent with the first packet.
+func ReadAdvancementProgress(r io.Reader, requirements map[string][][]string) (*AdvancementProgress, error) {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	ap := CreateAdvancementProgress(requirements)
+	for id, data := range raw {
+		if id == "DataVersion" {
+			if err := json.Unmarshal(data, &ap.DataVersion); err != nil {
+				return nil, fmt.Errorf("invalid DataVersion: %w", err)
+			}
+			continue
+		}
+
+		var entry struct {
+			Criteria map[string]string `json:"criteria"`
+			Done     bool              `json:"done"`
+		}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("advancement %s: %w", id, err)
+		}
+		obtained := make(map[string]time.Time, len(entry.Criteria))
+		for criterion, at := range entry.Criteria {
+			t, err := time.Parse(advancementTimeLayout, at)
+			if err != nil {
+				return nil, fmt.Errorf("advancement %s criterion %s: %w", id, criterion, err)
+			}
+			obtained[criterion] = t
+		}
+		ap.criteria[id] = obtained
+		ap.done[id] = entry.Done
+		ap.changed[id] = true
+	}
+	return ap, nil
+}
+
+// LoadAdvancementProgress loads an advancements file. A player without one
+// starts with no progress.
+func LoadAdvancementProgress(path string, requirements map[string][][]string) (*AdvancementProgress, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return CreateAdvancementProgress(requirements), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadAdvancementProgress(file, requirements)
+}
+
+// Grant marks a criterion as obtained at the given time. It returns false if
+// the criterion was already obtained.
+func (ap *AdvancementProgress) Grant(id, criterion string, at time.Time) bool {
+	obtained := ap.criteria[id]
+	if obtained == nil {
+		obtained = make(map[string]time.Time)
+		ap.criteria[id] = obtained
+	}
+	if _, ok := obtained[criterion]; ok {
+		return false
+	}
+	obtained[criterion] = at
+	ap.changed[id] = true
+	return true
+}
+
+// Revoke removes an obtained criterion. It returns false if it wasn't
+// obtained.
+func (ap *AdvancementProgress) Revoke(id, criterion string) bool {
+	if _, ok := ap.criteria[id][criterion]; !ok {
+		return false
+	}
+	delete(ap.criteria[id], criterion)
+	delete(ap.done, id)
+	ap.changed[id] = true
+	return true
+}
+
+// GrantAll obtains every remaining criterion of an advancement.
+func (ap *AdvancementProgress) GrantAll(id string, at time.Time) {
+	for _, group := range ap.requirements[id] {
+		for _, criterion := range group {
+			ap.Grant(id, criterion, at)
+		}
+	}
+}
+
+// RevokeAll removes every obtained criterion of an advancement.
+func (ap *AdvancementProgress) RevokeAll(id string) {
+	if len(ap.criteria[id]) > 0 {
+		ap.changed[id] = true
+	}
+	delete(ap.criteria, id)
+	delete(ap.done, id)
+}
+
+// Obtained returns when a criterion was obtained, if it has been.
+func (ap *AdvancementProgress) Obtained(id, criterion string) (time.Time, bool) {
+	at, ok := ap.criteria[id][criterion]
+	return at, ok
+}
+
+// Done reports whether an advancement is complete: each group of its
+// requirements has an obtained criterion.
+func (ap *AdvancementProgress) Done(id string) bool {
+	requirements, ok := ap.requirements[id]
+	if !ok {
+		return ap.done[id]
+	}
+	if len(requirements) == 0 {
+		return false
+	}
+	for _, group := range requirements {
+		met := false
+		for _, criterion := range group {
+			if _, ok := ap.criteria[id][criterion]; ok {
+				met = true
+				break
+			}
+		}
+		if !met {
+			return false
+		}
+	}
+	return true
+}
+
+// Resend marks every advancement with progress as changed, for when the
+// client has been sent the advancement tree again and lost its progress.
+func (ap *AdvancementProgress) Resend() {
+	for id := range ap.criteria {
+		ap.changed[id] = true
+	}
+}
+
+// Packet builds an Update Advancements packet carrying the progress of the
+// advancements that changed since the last call, or returns false if none
+// did. It adds and removes no advancements, so the tree itself must already
+// have been sent. From 1.21.5 the packet ends with whether to show toasts
+// for newly completed advancements.
+func (ap *AdvancementProgress) Packet(packetID, protocol int32) ([]byte, bool) {
+	if len(ap.changed) == 0 {
+		return nil, false
+	}
+	ids := make([]string, 0, len(ap.changed))
+	for id := range ap.changed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	clear(ap.changed)
+
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteBoolean(false)
+	pw.WriteVarInt(0)
+	pw.WriteVarInt(0)
+	pw.WriteVarInt(int32(len(ids)))
+	for _, id := range ids {
+		// Every criterion of the advancement is sent, obtained or not, so
+		// the client sees revoked ones too.
+		criteria := make(map[string]bool)
+		for _, group := range ap.requirements[id] {
+			for _, criterion := range group {
+				criteria[criterion] = true
+			}
+		}
+		for criterion := range ap.criteria[id] {
+			criteria[criterion] = true
+		}
+		names := make([]string, 0, len(criteria))
+		for criterion := range criteria {
+			names = append(names, criterion)
+		}
+		sort.Strings(names)
+
+		pw.WriteString(id)
+		pw.WriteVarInt(int32(len(names)))
+		for _, criterion := range names {
+			pw.WriteString(criterion)
+			at, ok := ap.criteria[id][criterion]
+			pw.WriteBoolean(ok)
+			if ok {
+				pw.WriteLong(at.UnixMilli())
+			}
+		}
+	}
+	if protocol >= protocol1_21_5 {
+		pw.WriteBoolean(true)
+	}
+	return pw.GetPacket(), true
+}
+
+// Write writes the progress in the format of the advancements file. Only
+// advancements with obtained criteria are written, as in vanilla.
+func (ap *AdvancementProgress) Write(w io.Writer) error {
+	type entry struct {
+		Criteria map[string]string `json:"criteria"`
+		Done     bool              `json:"done"`
+	}
+	out := make(map[string]any, len(ap.criteria)+1)
+	for id, obtained := range ap.criteria {
+		if len(obtained) == 0 {
+			continue
+		}
+		criteria := make(map[string]string, len(obtained))
+		for criterion, at := range obtained {
+			criteria[criterion] = at.Format(advancementTimeLayout)
+		}
+		out[id] = entry{criteria, ap.Done(id)}
+	}
+	out["DataVersion"] = ap.DataVersion
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Save writes the progress to an advancements file, keeping the previous
+// one as a backup.
+func (ap *AdvancementProgress) Save(path string) error {
+	return saveFile(path, ap.Write)
+}
@@ -0,0 +1,299 @@
+package levelutil
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+)
+
+// DefaultChunkCacheSize is how many chunks a ChunkCache keeps loaded before
+// it starts evicting clean, unticketed ones.
+const DefaultChunkCacheSize = 1024
+
+// ChunkPos is the position of a chunk, in chunk coordinates.
+type ChunkPos struct {
+	X, Z int32
+}
+
+// ChunkStorage is where a ChunkCache loads chunks from and saves them to,
+// such as a region file backend. C is whatever the server uses to hold a
+// chunk.
+type ChunkStorage[C any] interface {
+	LoadChunk(pos ChunkPos) (C, error)
+	// SaveChunks saves a batch of dirty chunks. If it fails, the whole
+	// batch stays dirty and is retried by the next save.
+	SaveChunks(chunks map[ChunkPos]C) error
+}
+
+type cachedChunk[C any] struct {
+	pos   ChunkPos
+	chunk C
+	// dirty is the generation in which the chunk was first marked dirty
+	// since it was last saved, or zero if it is clean.
+	dirty uint64
+	// modified is the generation of the last MarkDirty, so a save only
+	// cleans chunks that weren't modified while it was in progress.
+	modified uint64
+	elem     *list.Element
+}
+
+// ChunkCache sits between a ChunkStorage and the server. It keeps recently
+// used chunks loaded, evicting the least recently used clean ones past
+// Capacity, and collects dirty chunks so they can be saved in batches.
+// Chunks covered by a ticket, such as those around a player, are never
+// evicted.
+type ChunkCache[C any] struct {
+	// Capacity is the number of loaded chunks above which clean chunks
+	// without tickets are evicted. The cache grows past it when dirty and
+	// ticketed chunks fill it.
+	Capacity int
+
+	storage    ChunkStorage[C]
+	lock       sync.Mutex
+	chunks     map[ChunkPos]*cachedChunk[C]
+	lru        *list.List
+	tickets    map[ChunkPos]int
+	generation uint64
+}
+
+// CreateChunkCache is a factory function for creating a new ChunkCache over
+// a storage backend.
+func CreateChunkCache[C any](storage ChunkStorage[C]) *ChunkCache[C] {
+	cc := new(ChunkCache[C])
+	cc.Capacity = DefaultChunkCacheSize
+	cc.storage = storage
+	cc.chunks = make(map[ChunkPos]*cachedChunk[C])
+	cc.lru = list.New()
+	cc.tickets = make(map[ChunkPos]int)
+	return cc
+}
+
+// Get returns a chunk, loading it from storage if it isn't cached.
+func (cc *ChunkCache[C]) Get(pos ChunkPos) (C, error) {
+	if chunk, ok := cc.Peek(pos); ok {
+		return chunk, nil
+	}
+
+	// Load without holding the lock, so slow storage doesn't stall every
+	// other chunk. If another goroutine loaded it meanwhile, theirs wins.
+	chunk, err := cc.storage.LoadChunk(pos)
+	if err != nil {
+		var zero C
+		return zero, err
+	}
+
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	if cached, ok := cc.chunks[pos]; ok {
+		cc.lru.MoveToFront(cached.elem)
+		return cached.chunk, nil
+	}
+	cc.insert(pos, chunk)
+	cc.evict()
+	return chunk, nil
+}
+
+// Peek returns a chunk if it is cached, without loading it.
+func (cc *ChunkCache[C]) Peek(pos ChunkPos) (C, bool) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	cached, ok := cc.chunks[pos]
+	if !ok {
+		var zero C
+		return zero, false
+	}
+	cc.lru.MoveToFront(cached.elem)
+	return cached.chunk, true
+}
+
+// Put adds a chunk that didn't come from storage, such as a freshly
+// generated one, replacing any cached chunk at its position. It is marked
+// dirty so it gets saved.
+func (cc *ChunkCache[C]) Put(pos ChunkPos, chunk C) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	cached, ok := cc.chunks[pos]
+	if ok {
+		cached.chunk = chunk
+		cc.lru.MoveToFront(cached.elem)
+	} else {
+		cached = cc.insert(pos, chunk)
+	}
+	cc.markDirty(cached)
+	cc.evict()
+}
+
+func (cc *ChunkCache[C]) insert(pos ChunkPos, chunk C) *cachedChunk[C] {
+	cached := &cachedChunk[C]{pos: pos, chunk: chunk}
+	cached.elem = cc.lru.PushFront(cached)
+	cc.chunks[pos] = cached
+	return cached
+}
+
+// MarkDirty records that a cached chunk has changed and needs saving. It
+// returns false if the chunk isn't cached.
+func (cc *ChunkCache[C]) MarkDirty(pos ChunkPos) bool {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	cached, ok := cc.chunks[pos]
+	if !ok {
+		return false
+	}
+	cc.markDirty(cached)
+	return true
+}
+
+func (cc *ChunkCache[C]) markDirty(cached *cachedChunk[C]) {
+	cc.generation++
+	if cached.dirty == 0 {
+		cached.dirty = cc.generation
+	}
+	cached.modified = cc.generation
+}
+
+// Dirty returns the number of chunks waiting to be saved.
+func (cc *ChunkCache[C]) Dirty() int {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	count := 0
+	for _, cached := range cc.chunks {
+		if cached.dirty != 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// Len returns the number of cached chunks.
+func (cc *ChunkCache[C]) Len() int {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	return len(cc.chunks)
+}
+
+// Save saves up to limit dirty chunks in one batch, those that have been
+// dirty longest first, and returns how many it saved. A limit of zero or
+// less saves every dirty chunk. Servers typically call it with a small
+// limit every few ticks, and with no limit on shutdown.
+func (cc *ChunkCache[C]) Save(limit int) (int, error) {
+	cc.lock.Lock()
+	var dirty []*cachedChunk[C]
+	for _, cached := range cc.chunks {
+		if cached.dirty != 0 {
+			dirty = append(dirty, cached)
+		}
+	}
+	sort.Slice(dirty, func(i, j int) bool {
+		return dirty[i].dirty < dirty[j].dirty
+	})
+	if limit > 0 && len(dirty) > limit {
+		dirty = dirty[:limit]
+	}
+
+	batch := make(map[ChunkPos]C, len(dirty))
+	saved := make(map[ChunkPos]uint64, len(dirty))
+	for _, cached := range dirty {
+		batch[cached.pos] = cached.chunk
+		saved[cached.pos] = cached.modified
+	}
+	cc.lock.Unlock()
+
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := cc.storage.SaveChunks(batch); err != nil {
+		return 0, err
+	}
+
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	for pos, modified := range saved {
+		cached, ok := cc.chunks[pos]
+		if ok && cached.modified == modified {
+			cached.dirty = 0
+		}
+	}
+	cc.evict()
+	return len(batch), nil
+}
+
+// evict drops the least recently used clean chunks without tickets until the
+// cache is back within Capacity.
+func (cc *ChunkCache[C]) evict() {
+	elem := cc.lru.Back()
+	for len(cc.chunks) > cc.Capacity && elem != nil {
+		prev := elem.Prev()
+		cached := elem.Value.(*cachedChunk[C])
+		if cached.dirty == 0 && cc.tickets[cached.pos] == 0 {
+			cc.lru.Remove(elem)
+			delete(cc.chunks, cached.pos)
+		}
+		elem = prev
+	}
+}
+
+// Ticket keeps a square of chunks from being evicted while it is held, such
+// as the view distance around a player. It doesn't load them; the server
+// does that as it needs them.
+type Ticket[C any] struct {
+	cache    *ChunkCache[C]
+	center   ChunkPos
+	radius   int32
+	released bool
+}
+
+// AddTicket pins every chunk within radius chunks of center.
+func (cc *ChunkCache[C]) AddTicket(center ChunkPos, radius int32) *Ticket[C] {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	t := &Ticket[C]{cache: cc, center: center, radius: radius}
+	cc.ticketArea(center, radius, 1)
+	return t
+}
+
+func (cc *ChunkCache[C]) ticketArea(center ChunkPos, radius int32, delta int) {
+	for x := center.X - radius; x <= center.X+radius; x++ {
+		for z := center.Z - radius; z <= center.Z+radius; z++ {
+			pos := ChunkPos{x, z}
+			count := cc.tickets[pos] + delta
+			if count <= 0 {
+				delete(cc.tickets, pos)
+			} else {
+				cc.tickets[pos] = count
+			}
+		}
+	}
+}
+
+// Move moves the ticket to a new center, such as when a player crosses a
+// chunk border. Chunks that fall out of the ticket become evictable.
+func (t *Ticket[C]) Move(center ChunkPos) {
+	t.cache.lock.Lock()
+	defer t.cache.lock.Unlock()
+
+	if t.released || center == t.center {
+		return
+	}
+	t.cache.ticketArea(center, t.radius, 1)
+	t.cache.ticketArea(t.center, t.radius, -1)
+	t.center = center
+	t.cache.evict()
+}
+
+// Release drops the ticket. Releasing twice does nothing.
+func (t *Ticket[C]) Release() {
+	t.cache.lock.Lock()
+	defer t.cache.lock.Unlock()
+
+	if t.released {
+		return
+	}
+	t.released = true
+	t.cache.ticketArea(t.center, t.radius, -1)
+	t.cache.evict()
+}
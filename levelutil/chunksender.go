@@ -0,0 +1,217 @@
+package levelutil
+
+import (
+	"math"
+	"sync"
+
+	"github.com/PurpurProject/elytra/connutil"
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// DefaultChunkBudget is how many bytes of chunk data may be unacknowledged
+// when the socket's send buffer size can't be read.
+const DefaultChunkBudget = 1 << 20
+
+// Chunk sending limits, as in vanilla: how fast a client may ask for chunks,
+// and how many batches may await acknowledgement once it has answered one.
+const (
+	minChunksPerTick    = 0.01
+	maxChunksPerTick    = 64
+	startChunksPerTick  = 9
+	maxUnackedBatches   = 10
+	startUnackedBatches = 1
+)
+
+// ChunkBatchIDs are the clientbound packet IDs that frame a batch of chunks,
+// which depend on the protocol version.
+type ChunkBatchIDs struct {
+	Start    int32
+	Finished int32
+}
+
+// ChunkSender paces the chunks sent to one player, as 1.20.2+ clients expect.
+// Chunks are sent nearest first in batches, each answered by Chunk Batch
+// Received with the rate the client can keep up with. On top of that rate,
+// the bytes of unacknowledged batches are held under a budget, so a slow
+// link builds up no more than its socket can hold.
+//
+// Chunk packets are only encoded as they are sent, so a chunk the player
+// leaves before its turn costs nothing. A chunk that fails to encode is
+// dropped rather than tried every tick; Queue it again to retry.
+type ChunkSender struct {
+	queue  *connutil.SendQueue
+	ids    ChunkBatchIDs
+	encode func(pos ChunkPos) ([]byte, error)
+
+	// Budget is how many bytes of chunk data may await acknowledgement.
+	Budget int
+
+	lock       sync.Mutex
+	center     ChunkPos
+	pending    map[ChunkPos]bool
+	sent       map[ChunkPos]bool
+	inFlight   []int
+	unacked    int
+	maxUnacked int
+	perTick    float64
+	allowance  float64
+}
+
+// CreateChunkSender is a factory function for creating a new ChunkSender.
+// Chunk packets, built by encode with PacketWriter, go out through queue at
+// bulk priority. The budget starts at the socket's send buffer size if conn
+// has one, and DefaultChunkBudget otherwise.
+func CreateChunkSender(conn *connutil.Conn, queue *connutil.SendQueue, ids ChunkBatchIDs, encode func(pos ChunkPos) ([]byte, error)) *ChunkSender {
+	cs := new(ChunkSender)
+	cs.queue = queue
+	cs.ids = ids
+	cs.encode = encode
+	cs.Budget = DefaultChunkBudget
+	if size, err := connutil.SendBufferSize(conn.NetConn()); err == nil && size > 0 {
+		cs.Budget = size
+	}
+	cs.pending = make(map[ChunkPos]bool)
+	cs.sent = make(map[ChunkPos]bool)
+	cs.maxUnacked = startUnackedBatches
+	cs.perTick = startChunksPerTick
+	return cs
+}
+
+// SetCenter moves the point chunks are sent nearest to, normally the
+// player's chunk.
+func (cs *ChunkSender) SetCenter(pos ChunkPos) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.center = pos
+}
+
+// Queue marks a chunk to be sent. Chunks already sent or queued are skipped.
+func (cs *ChunkSender) Queue(pos ChunkPos) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	if !cs.sent[pos] {
+		cs.pending[pos] = true
+	}
+}
+
+// Drop forgets a chunk the player has left. A chunk still waiting is never
+// sent; Drop returns true if the chunk had already been sent, in which case
+// the caller must send Unload Chunk.
+func (cs *ChunkSender) Drop(pos ChunkPos) bool {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	delete(cs.pending, pos)
+	if cs.sent[pos] {
+		delete(cs.sent, pos)
+		return true
+	}
+	return false
+}
+
+// Pending returns how many chunks are waiting to be sent.
+func (cs *ChunkSender) Pending() int {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	return len(cs.pending)
+}
+
+// Acknowledge handles Chunk Batch Received, which answers the oldest
+// unacknowledged batch with how many chunks per tick the client wants.
+func (cs *ChunkSender) Acknowledge(chunksPerTick float32) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	if math.IsNaN(float64(chunksPerTick)) {
+		cs.perTick = minChunksPerTick
+	} else {
+		cs.perTick = min(max(float64(chunksPerTick), minChunksPerTick), maxChunksPerTick)
+	}
+	if len(cs.inFlight) > 0 {
+		cs.unacked -= cs.inFlight[0]
+		cs.inFlight = cs.inFlight[1:]
+	}
+	// Once the client has caught up, vanilla lets the next tick send at
+	// once, however slow the rate.
+	if len(cs.inFlight) == 0 {
+		cs.allowance = 1
+	}
+	cs.maxUnacked = maxUnackedBatches
+}
+
+// nearest returns the pending chunk closest to the center.
+func (cs *ChunkSender) nearest() ChunkPos {
+	var best ChunkPos
+	bestDist := int64(math.MaxInt64)
+	for pos := range cs.pending {
+		dx, dz := int64(pos.X-cs.center.X), int64(pos.Z-cs.center.Z)
+		if dist := dx*dx + dz*dz; dist < bestDist || dist == bestDist && (pos.X < best.X || pos.X == best.X && pos.Z < best.Z) {
+			best, bestDist = pos, dist
+		}
+	}
+	return best
+}
+
+// Tick sends a batch if the client's rate, the number of unacknowledged
+// batches and the byte budget all allow. It is called once a server tick.
+// As in vanilla, the rate only builds up on ticks that could send, and to
+// no more than a tick's worth, so a stall isn't followed by a burst.
+func (cs *ChunkSender) Tick() error {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	if len(cs.pending) == 0 {
+		cs.allowance = 0
+		return nil
+	}
+	if len(cs.inFlight) >= cs.maxUnacked || cs.unacked >= cs.Budget {
+		return nil
+	}
+	cs.allowance = min(cs.allowance+cs.perTick, max(1, cs.perTick))
+	if cs.allowance < 1 {
+		return nil
+	}
+
+	// Chunks are encoded before the batch is started, so one that fails
+	// can't leave the client in a batch that never finishes.
+	var packets [][]byte
+	var encodeErr error
+	size := 0
+	for cs.allowance >= 1 && len(cs.pending) > 0 && cs.unacked+size < cs.Budget {
+		pos := cs.nearest()
+		delete(cs.pending, pos)
+		packet, err := cs.encode(pos)
+		if err != nil {
+			if encodeErr == nil {
+				encodeErr = err
+			}
+			continue
+		}
+		cs.sent[pos] = true
+		cs.allowance--
+		packets = append(packets, packet)
+		size += len(packet)
+	}
+	if len(packets) == 0 {
+		return encodeErr
+	}
+
+	start := packetutil.CreatePacketWriter(cs.ids.Start)
+	if err := cs.queue.SendFrame(connutil.PriorityBulk, start.GetPacket()); err != nil {
+		return err
+	}
+	for _, packet := range packets {
+		if err := cs.queue.SendFrame(connutil.PriorityBulk, packet); err != nil {
+			return err
+		}
+	}
+	finished := packetutil.CreatePacketWriter(cs.ids.Finished)
+	finished.WriteVarInt(int32(len(packets)))
+	if err := cs.queue.SendFrame(connutil.PriorityBulk, finished.GetPacket()); err != nil {
+		return err
+	}
+	cs.inFlight = append(cs.inFlight, size)
+	cs.unacked += size
+	return encodeErr
+}
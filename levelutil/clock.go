@@ -0,0 +1,233 @@
+package levelutil
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Weather durations in ticks, as in vanilla: how long each spell lasts
+// before the weather cycle picks the next.
+const (
+	minRainDelay     = 12000
+	maxRainDelay     = 180000
+	minRainTime      = 12000
+	maxRainTime      = 24000
+	minThunderDelay  = 12000
+	maxThunderDelay  = 180000
+	minThunderTime   = 3600
+	maxThunderTime   = 15600
+	weatherLevelStep = 0.01
+)
+
+// ClockIDs are the clientbound packet IDs a WorldClock sends, which depend
+// on the protocol version.
+type ClockIDs struct {
+	UpdateTime int32
+	GameEvent  int32
+}
+
+// WorldClock keeps a world's time and weather as vanilla does, counting
+// them in the world's LevelData so they are saved with it, and works out
+// which Update Time and Game Event packets players need each tick. The
+// doDaylightCycle and doWeatherCycle game rules are honoured.
+type WorldClock struct {
+	Level *LevelData
+	// Random picks how long each spell of weather lasts.
+	Random *rand.Rand
+
+	ids      ClockIDs
+	protocol int32
+	rain     float32
+	thunder  float32
+}
+
+// CreateWorldClock is a factory function for creating a new WorldClock for
+// a world, whose packets are built for the given protocol version.
+func CreateWorldClock(ld *LevelData, ids ClockIDs, protocol int32) *WorldClock {
+	wc := new(WorldClock)
+	wc.Level = ld
+	wc.Random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	wc.ids = ids
+	wc.protocol = protocol
+	if ld.Raining {
+		wc.rain = 1
+	}
+	if ld.Thundering {
+		wc.thunder = 1
+	}
+	return wc
+}
+
+// rule reads a boolean game rule, which is on unless set to false.
+func (wc *WorldClock) rule(name string) bool {
+	return wc.Level.GameRules[name] != "false"
+}
+
+// Raining reports whether players see rain, which lags behind the world
+// starting or stopping to rain as the rain fades in and out.
+func (wc *WorldClock) Raining() bool {
+	return wc.rain > 0.2
+}
+
+// RainLevel returns how heavy the rain is, from 0 to 1.
+func (wc *WorldClock) RainLevel() float32 {
+	return wc.rain
+}
+
+// ThunderLevel returns how heavy the thunder is, from 0 to 1.
+func (wc *WorldClock) ThunderLevel() float32 {
+	return wc.thunder
+}
+
+// TimePacket returns the Update Time packet for the world as it stands.
+func (wc *WorldClock) TimePacket() []byte {
+	ld := wc.Level
+	cycle := wc.rule("doDaylightCycle")
+	pw := packetutil.CreatePacketWriter(wc.ids.UpdateTime)
+	pw.WriteLong(ld.Time)
+	if wc.protocol >= protocol1_21_2 {
+		pw.WriteLong(ld.DayTime)
+		pw.WriteBoolean(cycle)
+		return pw.GetPacket()
+	}
+	// Older clients take a negative time of day to mean the sun stands
+	// still, which can't say so for a time of zero.
+	dayTime := ld.DayTime
+	if !cycle {
+		dayTime = -dayTime
+		if dayTime == 0 {
+			dayTime = -1
+		}
+	}
+	pw.WriteLong(dayTime)
+	return pw.GetPacket()
+}
+
+// JoinPackets returns the packets that bring a player who has just joined
+// or moved into the world up to date with its time and weather.
+func (wc *WorldClock) JoinPackets() [][]byte {
+	packets := [][]byte{wc.TimePacket()}
+	if wc.Raining() {
+		packets = append(packets,
+			GameEventPacket(wc.ids.GameEvent, BeginRaining, 0),
+			GameEventPacket(wc.ids.GameEvent, RainLevelChange, wc.rain),
+			GameEventPacket(wc.ids.GameEvent, ThunderLevelChange, wc.thunder),
+		)
+	}
+	return packets
+}
+
+// SetDayTime sets the time of day, as /time set does, returning the Update
+// Time packet to send to every player in the world.
+func (wc *WorldClock) SetDayTime(dayTime int64) []byte {
+	wc.Level.DayTime = dayTime
+	return wc.TimePacket()
+}
+
+// SetWeather sets the weather as /weather does: clear for clear ticks, or
+// raining, and thundering too if thunder is set, for weather ticks. The new
+// weather fades in over the following ticks.
+func (wc *WorldClock) SetWeather(clear, weather int32, rain, thunder bool) {
+	ld := wc.Level
+	ld.ClearWeatherTime = clear
+	ld.RainTime = weather
+	ld.ThunderTime = weather
+	ld.Raining = rain
+	ld.Thundering = thunder
+}
+
+func (wc *WorldClock) between(lo, hi int32) int32 {
+	return lo + wc.Random.Int31n(hi-lo+1)
+}
+
+// advanceWeather runs one tick of vanilla's weather cycle.
+func (wc *WorldClock) advanceWeather() {
+	ld := wc.Level
+	if ld.ClearWeatherTime > 0 {
+		ld.ClearWeatherTime--
+		ld.ThunderTime, ld.RainTime = 1, 1
+		if ld.Thundering {
+			ld.ThunderTime = 0
+		}
+		if ld.Raining {
+			ld.RainTime = 0
+		}
+		ld.Thundering, ld.Raining = false, false
+		return
+	}
+
+	switch {
+	case ld.ThunderTime > 0:
+		ld.ThunderTime--
+		if ld.ThunderTime == 0 {
+			ld.Thundering = !ld.Thundering
+		}
+	case ld.Thundering:
+		ld.ThunderTime = wc.between(minThunderTime, maxThunderTime)
+	default:
+		ld.ThunderTime = wc.between(minThunderDelay, maxThunderDelay)
+	}
+
+	switch {
+	case ld.RainTime > 0:
+		ld.RainTime--
+		if ld.RainTime == 0 {
+			ld.Raining = !ld.Raining
+		}
+	case ld.Raining:
+		ld.RainTime = wc.between(minRainTime, maxRainTime)
+	default:
+		ld.RainTime = wc.between(minRainDelay, maxRainDelay)
+	}
+}
+
+func step(level float32, up bool) float32 {
+	if up {
+		return min(level+weatherLevelStep, 1)
+	}
+	return max(level-weatherLevelStep, 0)
+}
+
+// Tick advances the world by a tick, returning the packets to send to every
+// player in it: the time once a second, and changes to the weather as they
+// happen.
+func (wc *WorldClock) Tick() [][]byte {
+	ld := wc.Level
+	var packets [][]byte
+
+	wasRaining := wc.Raining()
+	if wc.rule("doWeatherCycle") {
+		wc.advanceWeather()
+	}
+	oldRain, oldThunder := wc.rain, wc.thunder
+	wc.rain = step(wc.rain, ld.Raining)
+	wc.thunder = step(wc.thunder, ld.Thundering)
+	if wc.rain != oldRain {
+		packets = append(packets, GameEventPacket(wc.ids.GameEvent, RainLevelChange, wc.rain))
+	}
+	if wc.thunder != oldThunder {
+		packets = append(packets, GameEventPacket(wc.ids.GameEvent, ThunderLevelChange, wc.thunder))
+	}
+	if raining := wc.Raining(); raining != wasRaining {
+		event := EndRaining
+		if raining {
+			event = BeginRaining
+		}
+		packets = append(packets,
+			GameEventPacket(wc.ids.GameEvent, event, 0),
+			GameEventPacket(wc.ids.GameEvent, RainLevelChange, wc.rain),
+			GameEventPacket(wc.ids.GameEvent, ThunderLevelChange, wc.thunder),
+		)
+	}
+
+	ld.Time++
+	if wc.rule("doDaylightCycle") {
+		ld.DayTime++
+	}
+	if ld.Time%20 == 0 {
+		packets = append(packets, wc.TimePacket())
+	}
+	return packets
+}
@@ -0,0 +1,96 @@
+package levelutil
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/PurpurProject/elytra/jsonutil"
+	"github.com/PurpurProject/elytra/nbtutil"
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// DamageEvent is the Damage Event packet, sent since 1.19.4 when an entity
+// is hurt so the client can play the right effects.
+type DamageEvent struct {
+	EntityID int32
+	// SourceType is the damage type's ID in the damage_type registry.
+	SourceType int32
+	// SourceCause is the entity ultimately responsible, such as the player
+	// who shot an arrow, and SourceDirect is the one that did the damage,
+	// such as the arrow. Either is -1 for none.
+	SourceCause  int32
+	SourceDirect int32
+	// SourcePosition is where the damage came from, for damage without a
+	// source entity such as an explosion, or nil.
+	SourcePosition *[3]float64
+}
+
+// entityOrNone writes an entity ID as Damage Event does, plus one so that
+// zero can stand for none.
+var entityOrNone = packetutil.Convert(packetutil.VarIntCodec,
+	func(id int32) int32 { return id + 1 },
+	func(id int32) (int32, error) { return id - 1, nil },
+)
+
+var vec3Codec = packetutil.Codec[[3]float64]{
+	Encode: func(pw *packetutil.PacketWriter, val [3]float64) {
+		for _, coord := range val {
+			pw.WriteDouble(coord)
+		}
+	},
+	Decode: func(pr *packetutil.PacketReader) ([3]float64, error) {
+		var val [3]float64
+		for i := range val {
+			coord, err := pr.ReadDouble()
+			if err != nil {
+				return val, err
+			}
+			val[i] = coord
+		}
+		return val, nil
+	},
+}
+
+// DamageEventCodec reads and writes the body of Damage Event.
+var DamageEventCodec = packetutil.StructOf(
+	packetutil.Field(func(de *DamageEvent) *int32 { return &de.EntityID }, packetutil.VarIntCodec),
+	packetutil.Field(func(de *DamageEvent) *int32 { return &de.SourceType }, packetutil.VarIntCodec),
+	packetutil.Field(func(de *DamageEvent) *int32 { return &de.SourceCause }, entityOrNone),
+	packetutil.Field(func(de *DamageEvent) *int32 { return &de.SourceDirect }, entityOrNone),
+	packetutil.Field(func(de *DamageEvent) **[3]float64 { return &de.SourcePosition }, packetutil.OptionalOf(vec3Codec)),
+)
+
+// EnterCombatPacket returns the Enter Combat packet, which has no fields.
+func EnterCombatPacket(packetID int32) []byte {
+	return packetutil.CreatePacketWriter(packetID).GetPacket()
+}
+
+// EndCombatPacket returns the End Combat packet, with how many ticks the
+// combat lasted.
+func EndCombatPacket(packetID int32, duration int32) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteVarInt(duration)
+	return pw.GetPacket()
+}
+
+// CombatDeathPacket returns the Combat Death packet, which shows the death
+// screen with message to the player with the given entity ID. From 1.20.3
+// the message is sent as NBT rather than JSON.
+func CombatDeathPacket(packetID int32, protocol int32, playerID int32, message jsonutil.ChatObject) ([]byte, error) {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteVarInt(playerID)
+	if protocol >= protocol1_20_3 {
+		nw := nbtutil.CreateWriter()
+		if err := nw.WriteNetwork(message.Compound()); err != nil {
+			return nil, fmt.Errorf("encoding death message: %w", err)
+		}
+		pw.WriteBytes(nw.Bytes())
+	} else {
+		encoded, err := json.Marshal(message)
+		if err != nil {
+			return nil, fmt.Errorf("encoding death message: %w", err)
+		}
+		pw.WriteString(string(encoded))
+	}
+	return pw.GetPacket(), nil
+}
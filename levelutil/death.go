@@ -0,0 +1,156 @@
+package levelutil
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/PurpurProject/elytra/jsonutil"
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// ClientCommand is the action of a Client Command packet.
+type ClientCommand int32
+
+const (
+	// PerformRespawn asks to respawn, sent from the death screen, or by
+	// itself on death when the respawn screen is disabled.
+	PerformRespawn ClientCommand = iota
+	// RequestStats asks for the player's statistics.
+	RequestStats
+)
+
+// ClientCommandPacket returns the Client Command packet.
+func ClientCommandPacket(packetID int32, action ClientCommand) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteVarInt(int32(action))
+	return pw.GetPacket()
+}
+
+// ReadClientCommand reads a Client Command packet.
+func ReadClientCommand(data []byte) (ClientCommand, error) {
+	action, err := packetutil.VarIntCodec.Unmarshal(data)
+	if err != nil {
+		return 0, fmt.Errorf("could not read client command: %w", err)
+	}
+	if action < int32(PerformRespawn) || action > int32(RequestStats) {
+		return 0, fmt.Errorf("unknown client command %d", action)
+	}
+	return ClientCommand(action), nil
+}
+
+// SetHealthPacket returns the Set Health packet. A health of 0 or less marks
+// the player as dead on the client, but only Combat Death shows the death
+// screen.
+func SetHealthPacket(packetID int32, health float32, food int32, saturation float32) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteFloat(health)
+	pw.WriteVarInt(food)
+	pw.WriteFloat(saturation)
+	return pw.GetPacket()
+}
+
+// DeathIDs are the clientbound packet IDs Death sends, which depend on the
+// protocol version.
+type DeathIDs struct {
+	CombatDeath int32
+	Respawn     int32
+	GameEvent   int32
+}
+
+// Death takes a player through dying and respawning in the order the client
+// expects: Combat Death to show the death screen, the client's request to
+// respawn, then Respawn. Each Respawn leaves the client on the loading
+// screen until it is sent its position again, so requests from a living
+// player and repeated ones for the same death are ignored, as vanilla does.
+// It is safe for concurrent use.
+type Death struct {
+	ids      DeathIDs
+	protocol int32
+
+	lock      sync.Mutex
+	dead      bool
+	requested bool
+}
+
+// CreateDeath is a factory function for creating a new Death for a player,
+// whose packets are built for the given protocol version.
+func CreateDeath(ids DeathIDs, protocol int32) *Death {
+	d := new(Death)
+	d.ids = ids
+	d.protocol = protocol
+	return d
+}
+
+// Dead reports whether the player has died and not yet respawned.
+func (d *Death) Dead() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.dead
+}
+
+// Die marks the player with the given entity ID dead at pos in world,
+// recording where in player for the next Respawn, and returns the Combat
+// Death packet that shows them the death screen with message.
+func (d *Death) Die(playerID int32, player *PlayerState, world string, pos packetutil.Position, message jsonutil.ChatObject) ([]byte, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.dead {
+		return nil, fmt.Errorf("player %d is already dead", playerID)
+	}
+	packet, err := CombatDeathPacket(d.ids.CombatDeath, d.protocol, playerID, message)
+	if err != nil {
+		return nil, err
+	}
+	d.dead = true
+	d.requested = false
+
+	player.DeathDimension = world
+	player.DeathPosition = pos
+	return packet, nil
+}
+
+// HandleClientCommand handles a Client Command packet, reporting whether the
+// player should now be respawned with Respawn. Other commands are left to
+// the caller.
+func (d *Death) HandleClientCommand(data []byte) (ClientCommand, bool, error) {
+	action, err := ReadClientCommand(data)
+	if err != nil || action != PerformRespawn {
+		return action, false, err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if !d.dead || d.requested {
+		return action, false, nil
+	}
+	d.requested = true
+	return action, true, nil
+}
+
+// Respawn brings the dead player back in dim, returning the Respawn packet
+// and, from 1.20.3, the Game Event that has the client wait for chunks.
+// After death vanilla keeps nothing, so keep is usually 0; KeepAttributes
+// carries over attribute modifiers such as a raised maximum health. In
+// hardcore, player should be given Spectator first.
+func (d *Death) Respawn(dim Dimension, player PlayerState, keep byte) ([][]byte, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if !d.dead {
+		return nil, fmt.Errorf("player is not dead")
+	}
+	respawn, err := RespawnPacket(d.ids.Respawn, d.protocol, dim, player, keep)
+	if err != nil {
+		return nil, err
+	}
+	d.dead = false
+	d.requested = false
+
+	packets := [][]byte{respawn}
+	if d.protocol >= protocol1_20_3 {
+		packets = append(packets, GameEventPacket(d.ids.GameEvent, StartWaitingForChunks, 0))
+	}
+	return packets, nil
+}
@@ -0,0 +1,315 @@
+package levelutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PurpurProject/elytra/nbtutil"
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// EffectType is a status effect, numbered as the mob_effect registry is
+// from 1.20.5. Use ID for the number a given protocol sends.
+type EffectType int32
+
+const (
+	Speed EffectType = iota
+	Slowness
+	Haste
+	MiningFatigue
+	Strength
+	InstantHealth
+	InstantDamage
+	JumpBoost
+	Nausea
+	Regeneration
+	Resistance
+	FireResistance
+	WaterBreathing
+	Invisibility
+	Blindness
+	NightVision
+	Hunger
+	Weakness
+	Poison
+	Wither
+	HealthBoost
+	Absorption
+	Saturation
+	Glowing
+	Levitation
+	Luck
+	Unluck
+	SlowFalling
+	ConduitPower
+	DolphinsGrace
+	BadOmen
+	HeroOfTheVillage
+	Darkness
+	TrialOmen
+	RaidOmen
+	WindCharged
+	Weaving
+	Oozing
+	Infested
+)
+
+var effectNames = [...]string{
+	Speed:            "minecraft:speed",
+	Slowness:         "minecraft:slowness",
+	Haste:            "minecraft:haste",
+	MiningFatigue:    "minecraft:mining_fatigue",
+	Strength:         "minecraft:strength",
+	InstantHealth:    "minecraft:instant_health",
+	InstantDamage:    "minecraft:instant_damage",
+	JumpBoost:        "minecraft:jump_boost",
+	Nausea:           "minecraft:nausea",
+	Regeneration:     "minecraft:regeneration",
+	Resistance:       "minecraft:resistance",
+	FireResistance:   "minecraft:fire_resistance",
+	WaterBreathing:   "minecraft:water_breathing",
+	Invisibility:     "minecraft:invisibility",
+	Blindness:        "minecraft:blindness",
+	NightVision:      "minecraft:night_vision",
+	Hunger:           "minecraft:hunger",
+	Weakness:         "minecraft:weakness",
+	Poison:           "minecraft:poison",
+	Wither:           "minecraft:wither",
+	HealthBoost:      "minecraft:health_boost",
+	Absorption:       "minecraft:absorption",
+	Saturation:       "minecraft:saturation",
+	Glowing:          "minecraft:glowing",
+	Levitation:       "minecraft:levitation",
+	Luck:             "minecraft:luck",
+	Unluck:           "minecraft:unluck",
+	SlowFalling:      "minecraft:slow_falling",
+	ConduitPower:     "minecraft:conduit_power",
+	DolphinsGrace:    "minecraft:dolphins_grace",
+	BadOmen:          "minecraft:bad_omen",
+	HeroOfTheVillage: "minecraft:hero_of_the_village",
+	Darkness:         "minecraft:darkness",
+	TrialOmen:        "minecraft:trial_omen",
+	RaidOmen:         "minecraft:raid_omen",
+	WindCharged:      "minecraft:wind_charged",
+	Weaving:          "minecraft:weaving",
+	Oozing:           "minecraft:oozing",
+	Infested:         "minecraft:infested",
+}
+
+// ParseEffectType returns the effect type with the given ID, such as
+// minecraft:speed, as Effect holds it.
+func ParseEffectType(id string) (EffectType, bool) {
+	if !strings.Contains(id, ":") {
+		id = "minecraft:" + id
+	}
+	for et, name := range effectNames {
+		if name == id {
+			return EffectType(et), true
+		}
+	}
+	return 0, false
+}
+
+// Since returns the first supported protocol the effect type exists in.
+func (et EffectType) Since() int32 {
+	if et >= TrialOmen {
+		return protocol1_20_5
+	}
+	return protocol1_20_2
+}
+
+// ID returns the number the protocol sends the effect type as. Before
+// 1.20.5 effects were numbered from 1 rather than 0.
+func (et EffectType) ID(protocol int32) (int32, bool) {
+	if et < 0 || int(et) >= len(effectNames) || protocol < et.Since() {
+		return 0, false
+	}
+	if protocol < protocol1_20_5 {
+		return int32(et) + 1, true
+	}
+	return int32(et), true
+}
+
+// EffectTypeOf reads an effect type number as the protocol sends it.
+func EffectTypeOf(id int32, protocol int32) (EffectType, bool) {
+	if protocol < protocol1_20_5 {
+		id--
+	}
+	et := EffectType(id)
+	if _, ok := et.ID(protocol); !ok {
+		return 0, false
+	}
+	return et, true
+}
+
+func (et EffectType) String() string {
+	if et >= 0 && int(et) < len(effectNames) {
+		return effectNames[et]
+	}
+	return fmt.Sprintf("EffectType(%d)", int32(et))
+}
+
+// InfiniteDuration is the duration of an effect that never wears off, as
+// /effect gives with "infinite".
+const InfiniteDuration = -1
+
+// Flags in the flags byte of Entity Effect.
+const (
+	EffectAmbient       = 0x01
+	EffectShowParticles = 0x02
+	EffectShowIcon      = 0x04
+	// EffectBlend fades the effect in and out, as darkness does. It is only
+	// sent from 1.20.5, where it replaced the factor data.
+	EffectBlend = 0x08
+)
+
+// EntityEffect is the Entity Effect packet, which gives an entity a status
+// effect or updates one it has.
+type EntityEffect struct {
+	EntityID  int32
+	Effect    EffectType
+	Amplifier int32
+	// Duration is in ticks, or InfiniteDuration.
+	Duration int32
+	Flags    byte
+	// FactorData is darkness's fade state, sent only before 1.20.5, or nil.
+	FactorData nbtutil.Compound
+}
+
+// Infinite reports whether the effect never wears off.
+func (ee EntityEffect) Infinite() bool {
+	return ee.Duration == InfiniteDuration
+}
+
+// Packet returns the Entity Effect packet for a protocol version, or an
+// error if the protocol doesn't have the effect. The amplifier is a byte
+// before 1.20.5 and is clamped to fit.
+func (ee EntityEffect) Packet(packetID int32, protocol int32) ([]byte, error) {
+	if err := checkProtocol(protocol); err != nil {
+		return nil, err
+	}
+	id, ok := ee.Effect.ID(protocol)
+	if !ok {
+		return nil, fmt.Errorf("effect %s doesn't exist in protocol %d", ee.Effect, protocol)
+	}
+
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteVarInt(ee.EntityID)
+	pw.WriteVarInt(id)
+	if protocol >= protocol1_20_5 {
+		pw.WriteVarInt(ee.Amplifier)
+	} else {
+		pw.WriteByte(int8(max(-128, min(ee.Amplifier, 127))))
+	}
+	pw.WriteVarInt(ee.Duration)
+	if protocol >= protocol1_20_5 {
+		pw.WriteUnsignedByte(ee.Flags)
+		return pw.GetPacket(), nil
+	}
+	pw.WriteUnsignedByte(ee.Flags &^ EffectBlend)
+	pw.WriteBoolean(ee.FactorData != nil)
+	if ee.FactorData != nil {
+		nw := nbtutil.CreateWriter()
+		if err := nw.WriteNetwork(ee.FactorData); err != nil {
+			return nil, fmt.Errorf("encoding factor data: %w", err)
+		}
+		pw.WriteBytes(nw.Bytes())
+	}
+	return pw.GetPacket(), nil
+}
+
+// ReadEntityEffect reads the body of an Entity Effect packet sent with a
+// protocol version.
+func ReadEntityEffect(data []byte, protocol int32) (EntityEffect, error) {
+	decode := func(pr *packetutil.PacketReader) (EntityEffect, error) {
+		return readEntityEffect(pr, protocol)
+	}
+	ee, err := packetutil.Codec[EntityEffect]{Decode: decode}.Unmarshal(data)
+	if err != nil {
+		return ee, fmt.Errorf("could not read entity effect: %w", err)
+	}
+	return ee, nil
+}
+
+func readEntityEffect(pr *packetutil.PacketReader, protocol int32) (EntityEffect, error) {
+	var ee EntityEffect
+	var err error
+	if ee.EntityID, err = pr.ReadVarInt(); err != nil {
+		return ee, err
+	}
+	id, err := pr.ReadVarInt()
+	if err != nil {
+		return ee, err
+	}
+	et, ok := EffectTypeOf(id, protocol)
+	if !ok {
+		return ee, fmt.Errorf("unknown effect type %d", id)
+	}
+	ee.Effect = et
+	if protocol >= protocol1_20_5 {
+		ee.Amplifier, err = pr.ReadVarInt()
+	} else {
+		var amp int8
+		amp, err = pr.ReadByte()
+		ee.Amplifier = int32(amp)
+	}
+	if err != nil {
+		return ee, err
+	}
+	if ee.Duration, err = pr.ReadVarInt(); err != nil {
+		return ee, err
+	}
+	if ee.Flags, err = pr.ReadUnsignedByte(); err != nil {
+		return ee, err
+	}
+	if protocol >= protocol1_20_5 {
+		return ee, nil
+	}
+	hasFactor, err := pr.ReadBoolean()
+	if err != nil || !hasFactor {
+		return ee, err
+	}
+	ee.FactorData, err = nbtutil.CreateReader(pr).ReadNetwork()
+	return ee, err
+}
+
+// EntityEffectOf returns the Entity Effect packet that shows an entity one
+// of its saved effects.
+func EntityEffectOf(entityID int32, e Effect) (EntityEffect, error) {
+	et, ok := ParseEffectType(e.ID)
+	if !ok {
+		return EntityEffect{}, fmt.Errorf("unknown effect type %s", e.ID)
+	}
+	ee := EntityEffect{
+		EntityID:  entityID,
+		Effect:    et,
+		Amplifier: int32(e.Amplifier),
+		Duration:  e.Duration,
+	}
+	if e.Ambient {
+		ee.Flags |= EffectAmbient
+	}
+	if e.ShowParticles {
+		ee.Flags |= EffectShowParticles
+	}
+	if e.ShowIcon {
+		ee.Flags |= EffectShowIcon
+	}
+	return ee, nil
+}
+
+// RemoveEntityEffectPacket returns the Remove Entity Effect packet, which
+// takes a status effect away from an entity.
+func RemoveEntityEffectPacket(packetID int32, protocol int32, entityID int32, effect EffectType) ([]byte, error) {
+	if err := checkProtocol(protocol); err != nil {
+		return nil, err
+	}
+	id, ok := effect.ID(protocol)
+	if !ok {
+		return nil, fmt.Errorf("effect %s doesn't exist in protocol %d", effect, protocol)
+	}
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteVarInt(entityID)
+	pw.WriteVarInt(id)
+	return pw.GetPacket(), nil
+}
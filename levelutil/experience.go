@@ -0,0 +1,114 @@
+package levelutil
+
+import (
+	"math"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// XPToNextLevel returns how many experience points it takes to go from a
+// level to the next one.
+func XPToNextLevel(level int32) int32 {
+	switch {
+	case level >= 30:
+		return 112 + (level-30)*9
+	case level >= 15:
+		return 37 + (level-15)*5
+	}
+	return 7 + level*2
+}
+
+// XPForLevel returns the total experience points it takes to reach a level
+// from nothing.
+func XPForLevel(level int32) int32 {
+	l := float64(level)
+	switch {
+	case level <= 16:
+		return int32(l*l + 6*l)
+	case level <= 31:
+		return int32(2.5*l*l - 40.5*l + 360)
+	}
+	return int32(4.5*l*l - 162.5*l + 2220)
+}
+
+// LevelForXP splits a total of experience points into the level it reaches
+// and the progress towards the next, from 0 to 1, as the experience bar
+// shows it.
+func LevelForXP(total int32) (int32, float32) {
+	if total <= 0 {
+		return 0, 0
+	}
+	// Solve the quadratic for the piece total falls in, then settle any
+	// rounding by stepping.
+	t := float64(total)
+	var level int32
+	switch {
+	case total < XPForLevel(17):
+		level = int32(math.Sqrt(t+9) - 3)
+	case total < XPForLevel(32):
+		level = int32(81.0/10 + math.Sqrt(2.0/5*(t-7839.0/40)))
+	default:
+		level = int32(325.0/18 + math.Sqrt(2.0/9*(t-54215.0/72)))
+	}
+	for level > 0 && XPForLevel(level) > total {
+		level--
+	}
+	for XPForLevel(level+1) <= total {
+		level++
+	}
+	return level, float32(total-XPForLevel(level)) / float32(XPToNextLevel(level))
+}
+
+// SetExperiencePacket returns the Set Experience packet for a player with
+// the given total experience points.
+func SetExperiencePacket(packetID int32, total int32) []byte {
+	level, progress := LevelForXP(total)
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteFloat(progress)
+	pw.WriteVarInt(level)
+	pw.WriteVarInt(total)
+	return pw.GetPacket()
+}
+
+// orbSizes are the values vanilla splits dropped experience into, largest
+// first.
+var orbSizes = []int32{2477, 1237, 617, 307, 149, 73, 37, 17, 7, 3, 1}
+
+// SplitOrbs splits experience points into orbs as vanilla drops them,
+// largest first.
+func SplitOrbs(xp int32) []int32 {
+	var orbs []int32
+	for xp > 0 {
+		for _, size := range orbSizes {
+			if xp >= size {
+				orbs = append(orbs, size)
+				xp -= size
+				break
+			}
+		}
+	}
+	return orbs
+}
+
+// SpawnOrbPacket returns the Spawn Experience Orb packet for an orb worth
+// the given points.
+func SpawnOrbPacket(packetID int32, entityID int32, x, y, z float64, value int16) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteVarInt(entityID)
+	pw.WriteDouble(x)
+	pw.WriteDouble(y)
+	pw.WriteDouble(z)
+	pw.WriteShort(value)
+	return pw.GetPacket()
+}
+
+// PickupPacket returns the Pickup Item packet, which plays the animation of
+// collector picking up an orb or item entity. count is the number of items
+// picked up, and is 1 for orbs.
+func PickupPacket(packetID int32, collected, collector, count int32) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteVarInt(collected)
+	pw.WriteVarInt(collector)
+	pw.WriteVarInt(count)
+	return pw.GetPacket()
+}
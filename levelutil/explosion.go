@@ -0,0 +1,320 @@
+package levelutil
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// BlockInteraction is what an explosion does to the blocks it reaches, as
+// Explosion sends it from 1.20.3 to 1.21.1.
+type BlockInteraction int32
+
+const (
+	KeepBlocks BlockInteraction = iota
+	DestroyBlocks
+	// DestroyBlocksWithDecay drops only some of the destroyed blocks, as
+	// TNT does.
+	DestroyBlocksWithDecay
+	// TriggerBlocks presses buttons and the like without destroying
+	// anything, as wind charges do.
+	TriggerBlocks
+)
+
+// Particle is a particle as packets send it: its ID in the particle_type
+// registry, and whatever data that type of particle carries, already
+// encoded.
+type Particle struct {
+	ID   int32
+	Data []byte
+}
+
+// SoundEvent is a sound written out in full rather than by registry ID.
+// Range is how far the sound carries, or nil for it to depend on the
+// volume.
+type SoundEvent struct {
+	ID    string
+	Range *float32
+}
+
+// SoundEventCodec reads and writes a SoundEvent.
+var SoundEventCodec = packetutil.StructOf(
+	packetutil.Field(func(se *SoundEvent) *string { return &se.ID }, packetutil.StringCodec),
+	packetutil.Field(func(se *SoundEvent) **float32 { return &se.Range }, packetutil.OptionalOf(packetutil.FloatCodec)),
+)
+
+// Explosion is the Explosion packet, which shows an explosion, removes the
+// blocks it destroyed and knocks the receiving player back.
+type Explosion struct {
+	X, Y, Z float64
+	// Strength and Blocks are only sent before 1.21.2, when the client
+	// still removed the blocks itself. Blocks are absolute positions, sent
+	// as offsets from the block the explosion is in.
+	Strength float32
+	Blocks   [][3]int
+	// Knockback is the velocity added to the receiving player, or nil for
+	// none. It is sent as doubles from 1.21.2 and as floats before.
+	Knockback *[3]float64
+
+	// Interaction and the particles and sound are only sent from 1.20.3.
+	// 1.21.2 sends a single particle: the small one for weak explosions, or
+	// ones that keep blocks, and the large one otherwise. Reading a 1.21.2
+	// packet fills in both with it.
+	Interaction   BlockInteraction
+	SmallParticle Particle
+	LargeParticle Particle
+	// Sound is always written out in full before 1.20.5.
+	Sound packetutil.Holder[SoundEvent]
+}
+
+// writeParticle writes a particle. Particles are read back assuming they
+// carry no data, which holds for the explosion particles vanilla uses.
+func writeParticle(pw *packetutil.PacketWriter, p Particle) {
+	pw.WriteVarInt(p.ID)
+	pw.WriteBytes(p.Data)
+}
+
+// legacyKnockbackCodec is the knockback before 1.21.2, as three floats.
+var legacyKnockbackCodec = packetutil.Codec[[3]float64]{
+	Encode: func(pw *packetutil.PacketWriter, val [3]float64) {
+		for _, v := range val {
+			pw.WriteFloat(float32(v))
+		}
+	},
+	Decode: func(pr *packetutil.PacketReader) ([3]float64, error) {
+		var val [3]float64
+		for i := range val {
+			v, err := pr.ReadFloat()
+			if err != nil {
+				return val, err
+			}
+			val[i] = float64(v)
+		}
+		return val, nil
+	},
+}
+
+// knockbackCodec is the optional knockback from 1.21.2, as three doubles.
+var knockbackCodec = packetutil.OptionalOf(vec3Codec)
+
+var blockOffsetsCodec = packetutil.ListOf(packetutil.Codec[[3]int8]{
+	Encode: func(pw *packetutil.PacketWriter, val [3]int8) {
+		for _, v := range val {
+			pw.WriteByte(v)
+		}
+	},
+	Decode: func(pr *packetutil.PacketReader) ([3]int8, error) {
+		var val [3]int8
+		for i := range val {
+			v, err := pr.ReadByte()
+			if err != nil {
+				return val, err
+			}
+			val[i] = v
+		}
+		return val, nil
+	},
+})
+
+var soundHolderCodec = packetutil.HolderOf(SoundEventCodec, nil)
+
+// ExplosionCodec reads and writes the body of Explosion for a protocol
+// version.
+func ExplosionCodec(protocol int32) packetutil.Codec[Explosion] {
+	return packetutil.Codec[Explosion]{
+		Encode: func(pw *packetutil.PacketWriter, ex Explosion) {
+			pw.WriteDouble(ex.X)
+			pw.WriteDouble(ex.Y)
+			pw.WriteDouble(ex.Z)
+			if protocol >= protocol1_21_2 {
+				knockbackCodec.Encode(pw, ex.Knockback)
+				if ex.Strength < 2 || ex.Interaction == KeepBlocks {
+					writeParticle(pw, ex.SmallParticle)
+				} else {
+					writeParticle(pw, ex.LargeParticle)
+				}
+				soundHolderCodec.Encode(pw, ex.Sound)
+				return
+			}
+
+			pw.WriteFloat(ex.Strength)
+			origin := [3]int{floor(ex.X), floor(ex.Y), floor(ex.Z)}
+			offsets := make([][3]int8, len(ex.Blocks))
+			for i, pos := range ex.Blocks {
+				for j := range pos {
+					offsets[i][j] = int8(pos[j] - origin[j])
+				}
+			}
+			blockOffsetsCodec.Encode(pw, offsets)
+			var knockback [3]float64
+			if ex.Knockback != nil {
+				knockback = *ex.Knockback
+			}
+			legacyKnockbackCodec.Encode(pw, knockback)
+			if protocol < protocol1_20_3 {
+				return
+			}
+
+			pw.WriteVarInt(int32(ex.Interaction))
+			writeParticle(pw, ex.SmallParticle)
+			writeParticle(pw, ex.LargeParticle)
+			if protocol >= protocol1_20_5 {
+				soundHolderCodec.Encode(pw, ex.Sound)
+			} else if ex.Sound.Inline != nil {
+				SoundEventCodec.Encode(pw, *ex.Sound.Inline)
+			} else {
+				SoundEventCodec.Encode(pw, SoundEvent{})
+			}
+		},
+		Decode: func(pr *packetutil.PacketReader) (Explosion, error) {
+			var ex Explosion
+			var err error
+			if ex.X, err = pr.ReadDouble(); err != nil {
+				return ex, err
+			}
+			if ex.Y, err = pr.ReadDouble(); err != nil {
+				return ex, err
+			}
+			if ex.Z, err = pr.ReadDouble(); err != nil {
+				return ex, err
+			}
+			if protocol >= protocol1_21_2 {
+				if ex.Knockback, err = knockbackCodec.Decode(pr); err != nil {
+					return ex, err
+				}
+				if ex.SmallParticle.ID, err = pr.ReadVarInt(); err != nil {
+					return ex, err
+				}
+				ex.LargeParticle = ex.SmallParticle
+				ex.Sound, err = soundHolderCodec.Decode(pr)
+				return ex, err
+			}
+
+			if ex.Strength, err = pr.ReadFloat(); err != nil {
+				return ex, err
+			}
+			offsets, err := blockOffsetsCodec.Decode(pr)
+			if err != nil {
+				return ex, err
+			}
+			origin := [3]int{floor(ex.X), floor(ex.Y), floor(ex.Z)}
+			ex.Blocks = make([][3]int, len(offsets))
+			for i, offset := range offsets {
+				for j := range offset {
+					ex.Blocks[i][j] = origin[j] + int(offset[j])
+				}
+			}
+			knockback, err := legacyKnockbackCodec.Decode(pr)
+			if err != nil {
+				return ex, err
+			}
+			ex.Knockback = &knockback
+			if protocol < protocol1_20_3 {
+				return ex, nil
+			}
+
+			interaction, err := pr.ReadEnum(int32(TriggerBlocks))
+			if err != nil {
+				return ex, fmt.Errorf("could not read block interaction: %w", err)
+			}
+			ex.Interaction = BlockInteraction(interaction)
+			if ex.SmallParticle.ID, err = pr.ReadVarInt(); err != nil {
+				return ex, err
+			}
+			if ex.LargeParticle.ID, err = pr.ReadVarInt(); err != nil {
+				return ex, err
+			}
+			if protocol >= protocol1_20_5 {
+				ex.Sound, err = soundHolderCodec.Decode(pr)
+				return ex, err
+			}
+			sound, err := SoundEventCodec.Decode(pr)
+			ex.Sound.Inline = &sound
+			return ex, err
+		},
+	}
+}
+
+// Packet returns the Explosion packet for a protocol version.
+func (ex Explosion) Packet(packetID int32, protocol int32) ([]byte, error) {
+	if err := checkProtocol(protocol); err != nil {
+		return nil, err
+	}
+	if protocol >= protocol1_20_3 && protocol < protocol1_20_5 && ex.Sound.Inline == nil {
+		return nil, fmt.Errorf("protocol %d can't send sounds by registry ID", protocol)
+	}
+	return ExplosionCodec(protocol).Marshal(packetID, ex)
+}
+
+func floor(v float64) int {
+	return int(math.Floor(v))
+}
+
+// ExplosionWorld is what ExplosionBlocks needs to know about the blocks
+// around an explosion, usually taken from a snapshot of the world before it
+// is changed.
+type ExplosionWorld interface {
+	// BlastResistance returns how well the block, and any fluid in it,
+	// resists explosions, and false for air, which neither resists nor is
+	// destroyed. Blocks outside the world should resist with +Inf.
+	BlastResistance(x, y, z int) (resistance float32, solid bool)
+}
+
+// ExplosionBlocks works out which blocks an explosion of the given power
+// destroys, as vanilla does: by casting rays out from the centre to the
+// edges of a 16x16x16 cube, each of which loses strength as it passes
+// through blocks. The blocks are returned sorted, each once.
+func ExplosionBlocks(x, y, z float64, power float32, world ExplosionWorld, random *rand.Rand) [][3]int {
+	found := make(map[[3]int]bool)
+	for i := 0; i < 16; i++ {
+		for j := 0; j < 16; j++ {
+			for k := 0; k < 16; k++ {
+				if i != 0 && i != 15 && j != 0 && j != 15 && k != 0 && k != 15 {
+					continue
+				}
+				dx := float64(i)/15*2 - 1
+				dy := float64(j)/15*2 - 1
+				dz := float64(k)/15*2 - 1
+				length := math.Sqrt(dx*dx + dy*dy + dz*dz)
+				dx, dy, dz = dx/length*0.3, dy/length*0.3, dz/length*0.3
+
+				strength := power * (0.7 + random.Float32()*0.6)
+				rx, ry, rz := x, y, z
+				for strength > 0 {
+					pos := [3]int{floor(rx), floor(ry), floor(rz)}
+					resistance, solid := world.BlastResistance(pos[0], pos[1], pos[2])
+					if math.IsInf(float64(resistance), 1) {
+						break
+					}
+					if solid {
+						strength -= (resistance + 0.3) * 0.3
+						if strength > 0 {
+							found[pos] = true
+						}
+					}
+					rx, ry, rz = rx+dx, ry+dy, rz+dz
+					strength -= 0.22500001
+				}
+			}
+		}
+	}
+
+	blocks := make([][3]int, 0, len(found))
+	for pos := range found {
+		blocks = append(blocks, pos)
+	}
+	sort.Slice(blocks, func(a, b int) bool {
+		pa, pb := blocks[a], blocks[b]
+		if pa[1] != pb[1] {
+			return pa[1] < pb[1]
+		}
+		if pa[2] != pb[2] {
+			return pa[2] < pb[2]
+		}
+		return pa[0] < pb[0]
+	})
+	return blocks
+}
@@ -0,0 +1,408 @@
+package levelutil
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/PurpurProject/elytra/packetutil"
+	"github.com/PurpurProject/elytra/physicsutil"
+)
+
+// Hand is which hand a player uses an item with.
+type Hand int32
+
+const (
+	MainHand Hand = iota
+	OffHand
+)
+
+func readHand(pr *packetutil.PacketReader) (Hand, error) {
+	hand, err := pr.ReadEnum(int32(OffHand))
+	if err != nil {
+		return 0, fmt.Errorf("could not read hand: %w", err)
+	}
+	return Hand(hand), nil
+}
+
+func readFace(pr *packetutil.PacketReader, varInt bool) (physicsutil.BlockFace, error) {
+	var face int32
+	var err error
+	if varInt {
+		face, err = pr.ReadVarInt()
+	} else {
+		var b int8
+		b, err = pr.ReadByte()
+		face = int32(b)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if f := physicsutil.BlockFace(face); int32(f) == face && f.Valid() {
+		return f, nil
+	}
+	return 0, fmt.Errorf("unknown block face %d", face)
+}
+
+// UseItemOn is Use Item On, sent when a player right-clicks a block.
+type UseItemOn struct {
+	Hand     Hand
+	Position packetutil.Position
+	Face     physicsutil.BlockFace
+	// CursorX, CursorY and CursorZ are where on the block the player
+	// clicked, each from 0 to 1.
+	CursorX, CursorY, CursorZ float32
+	// Inside is set when the player's head is inside the block.
+	Inside bool
+	// WorldBorderHit is set when the player clicked the world border,
+	// which is only sent from 1.21.2.
+	WorldBorderHit bool
+	Sequence       int32
+}
+
+// UseItemOnCodec returns the codec for the body of Use Item On in a
+// protocol version.
+func UseItemOnCodec(protocol int32) packetutil.Codec[UseItemOn] {
+	return packetutil.Codec[UseItemOn]{
+		Encode: func(pw *packetutil.PacketWriter, u UseItemOn) {
+			pw.WriteVarInt(int32(u.Hand))
+			pw.WritePosition(u.Position)
+			pw.WriteVarInt(int32(u.Face))
+			pw.WriteFloat(u.CursorX)
+			pw.WriteFloat(u.CursorY)
+			pw.WriteFloat(u.CursorZ)
+			pw.WriteBoolean(u.Inside)
+			if protocol >= protocol1_21_2 {
+				pw.WriteBoolean(u.WorldBorderHit)
+			}
+			pw.WriteVarInt(u.Sequence)
+		},
+		Decode: func(pr *packetutil.PacketReader) (UseItemOn, error) {
+			var u UseItemOn
+			var err error
+			if u.Hand, err = readHand(pr); err != nil {
+				return u, err
+			}
+			if u.Position, err = pr.ReadPosition(); err != nil {
+				return u, err
+			}
+			if u.Face, err = readFace(pr, true); err != nil {
+				return u, err
+			}
+			for _, cursor := range []*float32{&u.CursorX, &u.CursorY, &u.CursorZ} {
+				if *cursor, err = pr.ReadFloat(); err != nil {
+					return u, err
+				}
+			}
+			if u.Inside, err = pr.ReadBoolean(); err != nil {
+				return u, err
+			}
+			if protocol >= protocol1_21_2 {
+				if u.WorldBorderHit, err = pr.ReadBoolean(); err != nil {
+					return u, err
+				}
+			}
+			u.Sequence, err = pr.ReadVarInt()
+			return u, err
+		},
+	}
+}
+
+// UpperHalf reports whether the player clicked the upper half of the block,
+// as slabs and stairs placed against it check: the top face counts as the
+// lower half, as the new block goes on top of it, and the bottom face as
+// the upper.
+func (u UseItemOn) UpperHalf() bool {
+	switch u.Face {
+	case physicsutil.Up:
+		return false
+	case physicsutil.Down:
+		return true
+	}
+	return u.CursorY > 0.5
+}
+
+// PlacementPosition returns where a block placed by the click goes: in the
+// clicked block itself if it can be replaced, such as grass or snow, and
+// against the clicked face otherwise.
+func (u UseItemOn) PlacementPosition(replaceable bool) packetutil.Position {
+	if replaceable {
+		return u.Position
+	}
+	dx, dy, dz := u.Face.Offset()
+	return packetutil.Position{
+		X: u.Position.X + int32(dx),
+		Y: u.Position.Y + int32(dy),
+		Z: u.Position.Z + int32(dz),
+	}
+}
+
+// UseItem is Use Item, sent when a player right-clicks with an item without
+// aiming at a block. Yaw and Pitch, where the player was looking, are only
+// sent from 1.21.
+type UseItem struct {
+	Hand       Hand
+	Sequence   int32
+	Yaw, Pitch float32
+}
+
+// UseItemCodec returns the codec for the body of Use Item in a protocol
+// version.
+func UseItemCodec(protocol int32) packetutil.Codec[UseItem] {
+	return packetutil.Codec[UseItem]{
+		Encode: func(pw *packetutil.PacketWriter, u UseItem) {
+			pw.WriteVarInt(int32(u.Hand))
+			pw.WriteVarInt(u.Sequence)
+			if protocol >= protocol1_21 {
+				pw.WriteFloat(u.Yaw)
+				pw.WriteFloat(u.Pitch)
+			}
+		},
+		Decode: func(pr *packetutil.PacketReader) (UseItem, error) {
+			var u UseItem
+			var err error
+			if u.Hand, err = readHand(pr); err != nil {
+				return u, err
+			}
+			if u.Sequence, err = pr.ReadVarInt(); err != nil || protocol < protocol1_21 {
+				return u, err
+			}
+			if u.Yaw, err = pr.ReadFloat(); err != nil {
+				return u, err
+			}
+			u.Pitch, err = pr.ReadFloat()
+			return u, err
+		},
+	}
+}
+
+// ActionStatus is what a player is doing in Player Action.
+type ActionStatus int32
+
+const (
+	StartDigging ActionStatus = iota
+	CancelDigging
+	FinishDigging
+	// DropItemStack drops the whole held stack, and DropItem one item of it.
+	DropItemStack
+	DropItem
+	// ReleaseUseItem finishes using the held item, such as shooting a bow.
+	ReleaseUseItem
+	SwapItemInHand
+)
+
+// PlayerAction is Player Action, sent when a player digs a block, drops an
+// item or swaps hands. Position and Face only mean anything for digging.
+type PlayerAction struct {
+	Status   ActionStatus
+	Position packetutil.Position
+	Face     physicsutil.BlockFace
+	Sequence int32
+}
+
+// Digging reports whether the action is about breaking a block, which is
+// acknowledged with Acknowledge Block Change.
+func (pa PlayerAction) Digging() bool {
+	return pa.Status <= FinishDigging
+}
+
+// PlayerActionCodec reads and writes the body of Player Action.
+var PlayerActionCodec = packetutil.Codec[PlayerAction]{
+	Encode: func(pw *packetutil.PacketWriter, pa PlayerAction) {
+		pw.WriteVarInt(int32(pa.Status))
+		pw.WritePosition(pa.Position)
+		pw.WriteByte(int8(pa.Face))
+		pw.WriteVarInt(pa.Sequence)
+	},
+	Decode: func(pr *packetutil.PacketReader) (PlayerAction, error) {
+		var pa PlayerAction
+		status, err := pr.ReadEnum(int32(SwapItemInHand))
+		if err != nil {
+			return pa, fmt.Errorf("could not read player action: %w", err)
+		}
+		pa.Status = ActionStatus(status)
+		if pa.Position, err = pr.ReadPosition(); err != nil {
+			return pa, err
+		}
+		if pa.Face, err = readFace(pr, false); err != nil {
+			return pa, err
+		}
+		pa.Sequence, err = pr.ReadVarInt()
+		return pa, err
+	},
+}
+
+// AcknowledgeBlockChangePacket returns the Acknowledge Block Change packet,
+// which tells the client the server has dealt with every block interaction
+// up to sequence, so it can drop its predicted changes and show the real
+// blocks.
+func AcknowledgeBlockChangePacket(packetID int32, sequence int32) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteVarInt(sequence)
+	return pw.GetPacket()
+}
+
+// BlockSequence tracks the sequence numbers of a player's block
+// interactions so they can be acknowledged once per tick, as vanilla does,
+// after the block updates they caused have been sent. The zero value is
+// ready to use, and it is safe for concurrent use.
+type BlockSequence struct {
+	lock    sync.Mutex
+	pending int32
+}
+
+// Observe records the sequence number of an interaction that has been
+// handled. Only the highest seen since the last Flush is acknowledged.
+func (bs *BlockSequence) Observe(sequence int32) error {
+	if sequence < 0 {
+		return fmt.Errorf("sequence %d is negative", sequence)
+	}
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+	bs.pending = max(bs.pending, sequence)
+	return nil
+}
+
+// Flush returns the Acknowledge Block Change packet for the interactions
+// observed since the last call, or nil if there were none. It is called at
+// the end of each tick.
+func (bs *BlockSequence) Flush(packetID int32) []byte {
+	bs.lock.Lock()
+	sequence := bs.pending
+	bs.pending = 0
+	bs.lock.Unlock()
+
+	if sequence == 0 {
+		return nil
+	}
+	return AcknowledgeBlockChangePacket(packetID, sequence)
+}
+
+// InteractType is what a player does to an entity in Interact.
+type InteractType int32
+
+const (
+	// Interact is a right-click. InteractAt is sent with it, first, with
+	// where on the entity the player clicked, which armour stands use.
+	Interact InteractType = iota
+	Attack
+	InteractAt
+)
+
+// EntityInteraction is Interact, sent when a player right-clicks or attacks
+// an entity. Hand isn't sent for attacks, and the target only for
+// InteractAt.
+type EntityInteraction struct {
+	EntityID                  int32
+	Type                      InteractType
+	TargetX, TargetY, TargetZ float32
+	Hand                      Hand
+	Sneaking                  bool
+}
+
+// EntityInteractionCodec reads and writes the body of Interact.
+var EntityInteractionCodec = packetutil.Codec[EntityInteraction]{
+	Encode: func(pw *packetutil.PacketWriter, ei EntityInteraction) {
+		pw.WriteVarInt(ei.EntityID)
+		pw.WriteVarInt(int32(ei.Type))
+		if ei.Type == InteractAt {
+			pw.WriteFloat(ei.TargetX)
+			pw.WriteFloat(ei.TargetY)
+			pw.WriteFloat(ei.TargetZ)
+		}
+		if ei.Type != Attack {
+			pw.WriteVarInt(int32(ei.Hand))
+		}
+		pw.WriteBoolean(ei.Sneaking)
+	},
+	Decode: func(pr *packetutil.PacketReader) (EntityInteraction, error) {
+		var ei EntityInteraction
+		var err error
+		if ei.EntityID, err = pr.ReadVarInt(); err != nil {
+			return ei, err
+		}
+		typ, err := pr.ReadEnum(int32(InteractAt))
+		if err != nil {
+			return ei, fmt.Errorf("could not read interaction type: %w", err)
+		}
+		ei.Type = InteractType(typ)
+		if ei.Type == InteractAt {
+			for _, target := range []*float32{&ei.TargetX, &ei.TargetY, &ei.TargetZ} {
+				if *target, err = pr.ReadFloat(); err != nil {
+					return ei, err
+				}
+			}
+		}
+		if ei.Type != Attack {
+			if ei.Hand, err = readHand(pr); err != nil {
+				return ei, err
+			}
+		}
+		ei.Sneaking, err = pr.ReadBoolean()
+		return ei, err
+	},
+}
+
+// InteractionHandler handles a player's interaction with one entity.
+type InteractionHandler func(ei EntityInteraction) error
+
+// InteractionRouter sends Interact packets to a handler for the entity
+// they name, such as an NPC's click action, or to a fallback for entities
+// without one, such as the server's combat. It is safe for concurrent use.
+type InteractionRouter struct {
+	// Fallback handles interactions with entities that have no handler of
+	// their own. If nil, they are ignored.
+	Fallback InteractionHandler
+
+	lock     sync.RWMutex
+	handlers map[int32]InteractionHandler
+}
+
+// CreateInteractionRouter is a factory function for creating a new
+// InteractionRouter.
+func CreateInteractionRouter(fallback InteractionHandler) *InteractionRouter {
+	ir := new(InteractionRouter)
+	ir.Fallback = fallback
+	ir.handlers = make(map[int32]InteractionHandler)
+	return ir
+}
+
+// Register sets the handler for interactions with an entity, replacing any
+// it had.
+func (ir *InteractionRouter) Register(entityID int32, handler InteractionHandler) {
+	ir.lock.Lock()
+	defer ir.lock.Unlock()
+	ir.handlers[entityID] = handler
+}
+
+// Unregister removes an entity's handler, as must be done when the entity
+// is removed and its ID can be reused.
+func (ir *InteractionRouter) Unregister(entityID int32) {
+	ir.lock.Lock()
+	defer ir.lock.Unlock()
+	delete(ir.handlers, entityID)
+}
+
+// Route passes an interaction to the handler for its entity.
+func (ir *InteractionRouter) Route(ei EntityInteraction) error {
+	ir.lock.RLock()
+	handler, ok := ir.handlers[ei.EntityID]
+	ir.lock.RUnlock()
+
+	if !ok {
+		handler = ir.Fallback
+	}
+	if handler == nil {
+		return nil
+	}
+	return handler(ei)
+}
+
+// Handle reads an Interact packet and routes it, so the router can be
+// registered with a Dispatcher as the packet's handler.
+func (ir *InteractionRouter) Handle(p *packetutil.Packet) error {
+	ei, err := EntityInteractionCodec.Unmarshal(p.Data)
+	if err != nil {
+		return fmt.Errorf("could not read interaction: %w", err)
+	}
+	return ir.Route(ei)
+}
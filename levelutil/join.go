@@ -0,0 +1,208 @@
+package levelutil
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Protocol versions at which the packets built here changed shape. Versions
+// before 1.20.2 sent the registries inside Join Game and aren't supported.
+const (
+	protocol1_20_2 = 764
+	protocol1_20_3 = 765
+	protocol1_20_5 = 766
+	protocol1_21   = 767
+	protocol1_21_2 = 768
+	protocol1_21_5 = 770
+)
+
+// Dimension describes the world a player is in, as Join Game and Respawn
+// send it.
+type Dimension struct {
+	// Type is the dimension type, such as minecraft:overworld. From 1.20.5
+	// it is sent as TypeID, its ID in the dimension_type registry.
+	Type   string
+	TypeID int32
+	// Name is the world's name, such as minecraft:the_nether.
+	Name string
+	// Seed is the world seed. Only a hash of it is sent, for biome noise.
+	Seed  int64
+	Debug bool
+	Flat  bool
+	// SeaLevel is sent from 1.21.2.
+	SeaLevel int32
+}
+
+// PlayerState is the part of a player's state Join Game and Respawn send.
+type PlayerState struct {
+	GameMode int32
+	// PreviousGameMode is -1 if the player hasn't had another game mode.
+	PreviousGameMode int32
+	// DeathDimension is the name of the world the player last died in, or
+	// empty if they haven't died.
+	DeathDimension string
+	DeathPosition  packetutil.Position
+	PortalCooldown int32
+}
+
+// JoinGame is the Login packet of the play state, which starts a player's
+// time in the world.
+type JoinGame struct {
+	EntityID int32
+	Hardcore bool
+	// Worlds holds the name of every world on the server.
+	Worlds             []string
+	MaxPlayers         int32
+	ViewDistance       int32
+	SimulationDistance int32
+	ReducedDebugInfo   bool
+	RespawnScreen      bool
+	LimitedCrafting    bool
+	// EnforcesSecureChat is sent from 1.20.5, having been part of Server
+	// Data before.
+	EnforcesSecureChat bool
+
+	Dimension Dimension
+	Player    PlayerState
+}
+
+// Data kept across a Respawn, as flags.
+const (
+	KeepAttributes byte = 0x01
+	KeepMetadata   byte = 0x02
+)
+
+// GameEvent is the event of a Game Event packet.
+type GameEvent byte
+
+// Game events, numbered as they have been since 1.20.3.
+const (
+	NoRespawnBlock GameEvent = iota
+	BeginRaining
+	EndRaining
+	ChangeGameMode
+	WinGame
+	ShowDemo
+	ArrowHitPlayer
+	RainLevelChange
+	ThunderLevelChange
+	PufferfishSting
+	ElderGuardianAppearance
+	EnableRespawnScreen
+	LimitedCrafting
+	StartWaitingForChunks
+)
+
+// HashSeed hashes a world seed as vanilla does before sending it.
+func HashSeed(seed int64) int64 {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(seed))
+	sum := sha256.Sum256(buf[:])
+	return int64(binary.LittleEndian.Uint64(sum[:8]))
+}
+
+func checkProtocol(protocol int32) error {
+	if protocol < protocol1_20_2 {
+		return fmt.Errorf("protocol %d is older than 1.20.2", protocol)
+	}
+	return nil
+}
+
+// writeSpawn writes the fields Join Game and Respawn share, from the
+// dimension type to the portal cooldown, or to the sea level from 1.21.2.
+func writeSpawn(pw *packetutil.PacketWriter, protocol int32, dim Dimension, player PlayerState) {
+	if protocol >= protocol1_20_5 {
+		pw.WriteVarInt(dim.TypeID)
+	} else {
+		pw.WriteString(dim.Type)
+	}
+	pw.WriteString(dim.Name)
+	pw.WriteLong(HashSeed(dim.Seed))
+	pw.WriteUnsignedByte(byte(player.GameMode))
+	pw.WriteByte(int8(player.PreviousGameMode))
+	pw.WriteBoolean(dim.Debug)
+	pw.WriteBoolean(dim.Flat)
+	pw.WriteBoolean(player.DeathDimension != "")
+	if player.DeathDimension != "" {
+		pw.WriteString(player.DeathDimension)
+		pw.WritePosition(player.DeathPosition)
+	}
+	pw.WriteVarInt(player.PortalCooldown)
+	if protocol >= protocol1_21_2 {
+		pw.WriteVarInt(dim.SeaLevel)
+	}
+}
+
+// Packet returns the packet as the given protocol version expects it.
+func (jg *JoinGame) Packet(packetID, protocol int32) ([]byte, error) {
+	if err := checkProtocol(protocol); err != nil {
+		return nil, err
+	}
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteInt(jg.EntityID)
+	pw.WriteBoolean(jg.Hardcore)
+	pw.WriteVarInt(int32(len(jg.Worlds)))
+	for _, world := range jg.Worlds {
+		pw.WriteString(world)
+	}
+	pw.WriteVarInt(jg.MaxPlayers)
+	pw.WriteVarInt(jg.ViewDistance)
+	pw.WriteVarInt(jg.SimulationDistance)
+	pw.WriteBoolean(jg.ReducedDebugInfo)
+	pw.WriteBoolean(jg.RespawnScreen)
+	pw.WriteBoolean(jg.LimitedCrafting)
+	writeSpawn(pw, protocol, jg.Dimension, jg.Player)
+	if protocol >= protocol1_20_5 {
+		pw.WriteBoolean(jg.EnforcesSecureChat)
+	}
+	return pw.GetPacket(), nil
+}
+
+// RespawnPacket returns a Respawn packet, which moves the player to another
+// world or back to life, as the given protocol version expects it. keep
+// holds the KeepAttributes and KeepMetadata flags.
+func RespawnPacket(packetID, protocol int32, dim Dimension, player PlayerState, keep byte) ([]byte, error) {
+	if err := checkProtocol(protocol); err != nil {
+		return nil, err
+	}
+	pw := packetutil.CreatePacketWriter(packetID)
+	writeSpawn(pw, protocol, dim, player)
+	pw.WriteUnsignedByte(keep)
+	return pw.GetPacket(), nil
+}
+
+// GameEventPacket returns a Game Event packet. What value means depends on
+// the event: the game mode for ChangeGameMode, the level from 0 to 1 for
+// RainLevelChange, and so on.
+func GameEventPacket(packetID int32, event GameEvent, value float32) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteUnsignedByte(byte(event))
+	pw.WriteFloat(value)
+	return pw.GetPacket()
+}
+
+// GameModeEvent returns the Game Event packet that changes the player's game
+// mode.
+func GameModeEvent(packetID int32, gameMode int32) []byte {
+	return GameEventPacket(packetID, ChangeGameMode, float32(gameMode))
+}
+
+// WeatherEvents returns the Game Event packets that bring a player's
+// weather in line with a world's, to send after Join Game or Respawn.
+func WeatherEvents(packetID int32, ld *LevelData) [][]byte {
+	if !ld.Raining {
+		return nil
+	}
+	thunder := float32(0)
+	if ld.Thundering {
+		thunder = 1
+	}
+	return [][]byte{
+		GameEventPacket(packetID, BeginRaining, 0),
+		GameEventPacket(packetID, RainLevelChange, 1),
+		GameEventPacket(packetID, ThunderLevelChange, thunder),
+	}
+}
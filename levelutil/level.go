@@ -0,0 +1,317 @@
+package levelutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/PurpurProject/elytra/nbtutil"
+)
+
+// Game modes, as stored in GameType.
+const (
+	Survival int32 = iota
+	Creative
+	Adventure
+	Spectator
+)
+
+// anvilVersion is the value of the version tag in every Anvil world.
+const anvilVersion = 19133
+
+// VersionInfo records the game version that last saved a world.
+type VersionInfo struct {
+	ID       int32
+	Name     string
+	Series   string
+	Snapshot bool
+}
+
+// LevelData is the contents of a world's level.dat. Only the commonly used
+// fields are typed; everything else is kept in Extra and written back as it
+// was, so saving a file from a newer version doesn't lose data.
+type LevelData struct {
+	LevelName   string
+	DataVersion int32
+	Version     VersionInfo
+
+	Seed             int64
+	GenerateFeatures bool
+	BonusChest       bool
+	// Dimensions is the world gen settings of each dimension, which vary too
+	// much between versions to be worth typing.
+	Dimensions nbtutil.Compound
+
+	SpawnX, SpawnY, SpawnZ int32
+	SpawnAngle             float32
+
+	GameType      int32
+	Hardcore      bool
+	Difficulty    int8
+	AllowCommands bool
+	Initialized   bool
+
+	Time       int64
+	DayTime    int64
+	LastPlayed time.Time
+
+	Raining          bool
+	Thundering       bool
+	RainTime         int32
+	ThunderTime      int32
+	ClearWeatherTime int32
+
+	// GameRules holds the game rules, which vanilla stores as strings.
+	GameRules map[string]string
+
+	// Extra holds every tag of the Data compound not covered above.
+	Extra nbtutil.Compound
+
+	// legacySeed is set for worlds from before 1.16, which keep the seed in
+	// RandomSeed instead of WorldGenSettings.
+	legacySeed bool
+	// extraRules holds game rules stored as anything other than a string.
+	extraRules nbtutil.Compound
+}
+
+// CreateLevelData is a factory function for creating a new LevelData with
+// vanilla's defaults for a fresh world.
+func CreateLevelData(name string, seed int64) *LevelData {
+	ld := new(LevelData)
+	ld.LevelName = name
+	ld.Seed = seed
+	ld.GenerateFeatures = true
+	ld.Dimensions = make(nbtutil.Compound)
+	ld.Difficulty = 2
+	ld.Initialized = true
+	ld.GameRules = make(map[string]string)
+	ld.Extra = make(nbtutil.Compound)
+	return ld
+}
+
+// take removes a tag from c and returns it, so what's left over ends up in
+// Extra.
+func take[T any](c nbtutil.Compound, name string) (T, bool) {
+	val, ok := c[name].(T)
+	if ok {
+		delete(c, name)
+	}
+	return val, ok
+}
+
+func takeBool(c nbtutil.Compound, name string) bool {
+	val, _ := take[int8](c, name)
+	return val != 0
+}
+
+func boolByte(val bool) int8 {
+	if val {
+		return 1
+	}
+	return 0
+}
+
+// ReadLevelData reads a level.dat file, which is normally gzip compressed.
+func ReadLevelData(r io.Reader) (*LevelData, error) {
+	_, root, err := nbtutil.ReadFile(r)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := root.Compound("Data")
+	if !ok {
+		return nil, fmt.Errorf("level.dat has no Data compound")
+	}
+	return ParseLevelData(data)
+}
+
+// LoadLevelData reads the level.dat at the given path.
+func LoadLevelData(path string) (*LevelData, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadLevelData(file)
+}
+
+// ParseLevelData builds a LevelData from the Data compound of a level.dat.
+func ParseLevelData(data nbtutil.Compound) (*LevelData, error) {
+	c := data.Clone()
+
+	ld := new(LevelData)
+	ld.LevelName, _ = take[string](c, "LevelName")
+	ld.DataVersion, _ = take[int32](c, "DataVersion")
+
+	if version, ok := take[nbtutil.Compound](c, "Version"); ok {
+		ld.Version.ID, _ = version.Int("Id")
+		ld.Version.Name, _ = version.String("Name")
+		ld.Version.Series, _ = version.String("Series")
+		ld.Version.Snapshot, _ = version.Bool("Snapshot")
+	}
+
+	if settings, ok := take[nbtutil.Compound](c, "WorldGenSettings"); ok {
+		ld.Seed, _ = take[int64](settings, "seed")
+		ld.GenerateFeatures = takeBool(settings, "generate_features")
+		ld.BonusChest = takeBool(settings, "bonus_chest")
+		ld.Dimensions, _ = take[nbtutil.Compound](settings, "dimensions")
+		if len(settings) > 0 {
+			// Keep anything newer versions add next to the seed.
+			c["WorldGenSettings"] = settings
+		}
+	} else if seed, ok := take[int64](c, "RandomSeed"); ok {
+		ld.Seed = seed
+		ld.GenerateFeatures = takeBool(c, "MapFeatures")
+		ld.legacySeed = true
+	} else {
+		return nil, fmt.Errorf("level.dat has no seed")
+	}
+
+	ld.SpawnX, _ = take[int32](c, "SpawnX")
+	ld.SpawnY, _ = take[int32](c, "SpawnY")
+	ld.SpawnZ, _ = take[int32](c, "SpawnZ")
+	ld.SpawnAngle, _ = take[float32](c, "SpawnAngle")
+
+	ld.GameType, _ = take[int32](c, "GameType")
+	ld.Hardcore = takeBool(c, "hardcore")
+	ld.Difficulty, _ = take[int8](c, "Difficulty")
+	ld.AllowCommands = takeBool(c, "allowCommands")
+	ld.Initialized = takeBool(c, "initialized")
+
+	ld.Time, _ = take[int64](c, "Time")
+	ld.DayTime, _ = take[int64](c, "DayTime")
+	if lastPlayed, ok := take[int64](c, "LastPlayed"); ok {
+		ld.LastPlayed = time.UnixMilli(lastPlayed)
+	}
+
+	ld.Raining = takeBool(c, "raining")
+	ld.Thundering = takeBool(c, "thundering")
+	ld.RainTime, _ = take[int32](c, "rainTime")
+	ld.ThunderTime, _ = take[int32](c, "thunderTime")
+	ld.ClearWeatherTime, _ = take[int32](c, "clearWeatherTime")
+
+	ld.GameRules = make(map[string]string)
+	if rules, ok := take[nbtutil.Compound](c, "GameRules"); ok {
+		for name, val := range rules {
+			if s, ok := val.(string); ok {
+				ld.GameRules[name] = s
+			} else {
+				if ld.extraRules == nil {
+					ld.extraRules = make(nbtutil.Compound)
+				}
+				ld.extraRules[name] = val
+			}
+		}
+	}
+
+	delete(c, "version")
+	ld.Extra = c
+	return ld, nil
+}
+
+// Compound returns the level data as the Data compound of a level.dat.
+func (ld *LevelData) Compound() nbtutil.Compound {
+	var c nbtutil.Compound
+	if ld.Extra != nil {
+		c = ld.Extra.Clone()
+	} else {
+		c = make(nbtutil.Compound)
+	}
+
+	c["LevelName"] = ld.LevelName
+	c["DataVersion"] = ld.DataVersion
+	c["version"] = int32(anvilVersion)
+	if ld.Version != (VersionInfo{}) {
+		c["Version"] = nbtutil.Compound{
+			"Id":       ld.Version.ID,
+			"Name":     ld.Version.Name,
+			"Series":   ld.Version.Series,
+			"Snapshot": boolByte(ld.Version.Snapshot),
+		}
+	}
+
+	if ld.legacySeed {
+		c["RandomSeed"] = ld.Seed
+		c["MapFeatures"] = boolByte(ld.GenerateFeatures)
+	} else {
+		settings, ok := c.Compound("WorldGenSettings")
+		if !ok {
+			settings = make(nbtutil.Compound)
+		}
+		settings["seed"] = ld.Seed
+		settings["generate_features"] = boolByte(ld.GenerateFeatures)
+		settings["bonus_chest"] = boolByte(ld.BonusChest)
+		if ld.Dimensions != nil {
+			settings["dimensions"] = ld.Dimensions.Clone()
+		} else {
+			settings["dimensions"] = make(nbtutil.Compound)
+		}
+		c["WorldGenSettings"] = settings
+	}
+
+	c["SpawnX"], c["SpawnY"], c["SpawnZ"] = ld.SpawnX, ld.SpawnY, ld.SpawnZ
+	c["SpawnAngle"] = ld.SpawnAngle
+
+	c["GameType"] = ld.GameType
+	c["hardcore"] = boolByte(ld.Hardcore)
+	c["Difficulty"] = ld.Difficulty
+	c["allowCommands"] = boolByte(ld.AllowCommands)
+	c["initialized"] = boolByte(ld.Initialized)
+
+	c["Time"] = ld.Time
+	c["DayTime"] = ld.DayTime
+	if !ld.LastPlayed.IsZero() {
+		c["LastPlayed"] = ld.LastPlayed.UnixMilli()
+	}
+
+	c["raining"] = boolByte(ld.Raining)
+	c["thundering"] = boolByte(ld.Thundering)
+	c["rainTime"] = ld.RainTime
+	c["thunderTime"] = ld.ThunderTime
+	c["clearWeatherTime"] = ld.ClearWeatherTime
+
+	rules := make(nbtutil.Compound, len(ld.GameRules)+len(ld.extraRules))
+	for name, val := range ld.extraRules {
+		rules[name] = val
+	}
+	for name, val := range ld.GameRules {
+		rules[name] = val
+	}
+	c["GameRules"] = rules
+	return c
+}
+
+// Write writes the level data as a compressed level.dat.
+func (ld *LevelData) Write(w io.Writer) error {
+	return nbtutil.WriteFile(w, "", nbtutil.Compound{"Data": ld.Compound()})
+}
+
+// Save writes the level data to the given path the way vanilla does: to
+// level.dat_new first, then moving the previous file to level.dat_old, so a
+// crash part way through never leaves the world without a readable copy.
+func (ld *LevelData) Save(path string) error {
+	return saveFile(path, ld.Write)
+}
+
+func saveFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path + "_new")
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(path, path+"_old"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(path+"_new", path)
+}
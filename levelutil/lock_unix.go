@@ -0,0 +1,20 @@
+//go:build unix
+
+package levelutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on the whole file without blocking. It
+// uses fcntl locks, as the JVM does for FileChannel.tryLock, so that it
+// conflicts with a vanilla server's lock.
+func lockFile(file *os.File) (bool, error) {
+	lock := syscall.Flock_t{Type: syscall.F_WRLCK, Whence: 0, Start: 0, Len: 0}
+	err := syscall.FcntlFlock(file.Fd(), syscall.F_SETLK, &lock)
+	if err == syscall.EAGAIN || err == syscall.EACCES {
+		return false, nil
+	}
+	return err == nil, err
+}
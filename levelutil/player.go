@@ -0,0 +1,357 @@
+package levelutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/PurpurProject/elytra/nbtutil"
+)
+
+// ItemStack is an item as saved in player data. Since 1.20.5 item data is
+// held in Components; older files use Tag instead, and the stack is written
+// back in whichever form it was read in.
+type ItemStack struct {
+	ID         string
+	Count      int32
+	Components nbtutil.Compound
+	Tag        nbtutil.Compound
+	// Extra holds any other tags of the item.
+	Extra nbtutil.Compound
+
+	legacy bool
+}
+
+// SlotItem is an ItemStack in a numbered inventory slot. In the player
+// inventory, slots 0-8 are the hotbar and 9-35 the main inventory. Before
+// 1.21.5 slots 100-103 are the armour from feet to head and -106 the
+// offhand; from 1.21.5 those are kept in PlayerData.Equipment instead.
+type SlotItem struct {
+	Slot int8
+	Item ItemStack
+}
+
+// Effect is an active status effect, in the form used since 1.20.2.
+type Effect struct {
+	ID            string
+	Amplifier     int8
+	Duration      int32
+	Ambient       bool
+	ShowParticles bool
+	ShowIcon      bool
+}
+
+// Abilities is the player's abilities compound.
+type Abilities struct {
+	Flying       bool
+	MayFly       bool
+	InstaBuild   bool
+	Invulnerable bool
+	MayBuild     bool
+	FlySpeed     float32
+	WalkSpeed    float32
+}
+
+// PlayerData is the contents of a playerdata/<uuid>.dat file. As with
+// LevelData, tags without a typed field are kept in Extra and saved back.
+type PlayerData struct {
+	DataVersion int32
+	Dimension   string
+
+	X, Y, Z    float64
+	Yaw, Pitch float32
+	OnGround   bool
+
+	Health    float32
+	FoodLevel int32
+	GameType  int32
+	// SelectedSlot is the selected hotbar slot, from 0 to 8.
+	SelectedSlot int32
+
+	XPLevel    int32
+	XPProgress float32
+	XPTotal    int32
+	Score      int32
+
+	Inventory  []SlotItem
+	EnderItems []SlotItem
+	Effects    []Effect
+	Abilities  Abilities
+
+	// Equipment holds the armour and offhand from 1.21.5, keyed by
+	// equipment slot: head, chest, legs, feet, offhand and body.
+	Equipment map[string]ItemStack
+
+	Extra nbtutil.Compound
+}
+
+func parseItem(c nbtutil.Compound) (ItemStack, error) {
+	var item ItemStack
+	var ok bool
+	if item.ID, ok = take[string](c, "id"); !ok {
+		return item, fmt.Errorf("item has no id")
+	}
+
+	if count, ok := take[int32](c, "count"); ok {
+		item.Count = count
+	} else if count, ok := take[int8](c, "Count"); ok {
+		item.Count = int32(count)
+		item.legacy = true
+	} else {
+		item.Count = 1
+	}
+	item.Components, _ = take[nbtutil.Compound](c, "components")
+	item.Tag, ok = take[nbtutil.Compound](c, "tag")
+	item.legacy = item.legacy || ok
+	if len(c) > 0 {
+		item.Extra = c
+	}
+	return item, nil
+}
+
+func (item ItemStack) compound() nbtutil.Compound {
+	var c nbtutil.Compound
+	if item.Extra != nil {
+		c = item.Extra.Clone()
+	} else {
+		c = make(nbtutil.Compound)
+	}
+
+	c["id"] = item.ID
+	if item.legacy {
+		c["Count"] = int8(item.Count)
+	} else {
+		c["count"] = item.Count
+	}
+	if item.Components != nil {
+		c["components"] = item.Components.Clone()
+	}
+	if item.Tag != nil {
+		c["tag"] = item.Tag.Clone()
+	}
+	return c
+}
+
+func parseSlots(c nbtutil.Compound, name string) ([]SlotItem, error) {
+	entries, ok := c.Compounds(name)
+	if !ok {
+		return nil, nil
+	}
+	delete(c, name)
+
+	slots := make([]SlotItem, 0, len(entries))
+	for i, entry := range entries {
+		entry = entry.Clone()
+		slot, ok := take[int8](entry, "Slot")
+		if !ok {
+			return nil, fmt.Errorf("%s entry %d has no slot", name, i)
+		}
+		item, err := parseItem(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s slot %d: %w", name, slot, err)
+		}
+		slots = append(slots, SlotItem{slot, item})
+	}
+	return slots, nil
+}
+
+func slotList(slots []SlotItem) nbtutil.List {
+	list := nbtutil.List{Type: nbtutil.TagCompound}
+	for _, slot := range slots {
+		c := slot.Item.compound()
+		c["Slot"] = slot.Slot
+		list.Elements = append(list.Elements, c)
+	}
+	return list
+}
+
+func floatTriple(c nbtutil.Compound, name string) ([]float64, bool) {
+	list, ok := c.List(name)
+	if !ok || list.Type != nbtutil.TagDouble || len(list.Elements) != 3 {
+		return nil, false
+	}
+	delete(c, name)
+	return []float64{list.Elements[0].(float64), list.Elements[1].(float64), list.Elements[2].(float64)}, true
+}
+
+// ReadPlayerData reads a compressed player data file.
+func ReadPlayerData(r io.Reader) (*PlayerData, error) {
+	_, root, err := nbtutil.ReadFile(r)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, fmt.Errorf("player data is empty")
+	}
+	return ParsePlayerData(root)
+}
+
+// LoadPlayerData reads the player data file at the given path.
+func LoadPlayerData(path string) (*PlayerData, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadPlayerData(file)
+}
+
+// ParsePlayerData builds a PlayerData from the root compound of a player data
+// file.
+func ParsePlayerData(root nbtutil.Compound) (*PlayerData, error) {
+	c := root.Clone()
+
+	pd := new(PlayerData)
+	pd.DataVersion, _ = take[int32](c, "DataVersion")
+	pd.Dimension, _ = take[string](c, "Dimension")
+
+	if pos, ok := floatTriple(c, "Pos"); ok {
+		pd.X, pd.Y, pd.Z = pos[0], pos[1], pos[2]
+	}
+	if rotation, ok := c.List("Rotation"); ok && rotation.Type == nbtutil.TagFloat && len(rotation.Elements) == 2 {
+		pd.Yaw, pd.Pitch = rotation.Elements[0].(float32), rotation.Elements[1].(float32)
+		delete(c, "Rotation")
+	}
+	pd.OnGround = takeBool(c, "OnGround")
+
+	pd.Health, _ = take[float32](c, "Health")
+	pd.FoodLevel, _ = take[int32](c, "foodLevel")
+	pd.GameType, _ = take[int32](c, "playerGameType")
+	pd.SelectedSlot, _ = take[int32](c, "SelectedItemSlot")
+
+	pd.XPLevel, _ = take[int32](c, "XpLevel")
+	pd.XPProgress, _ = take[float32](c, "XpP")
+	pd.XPTotal, _ = take[int32](c, "XpTotal")
+	pd.Score, _ = take[int32](c, "Score")
+
+	var err error
+	if pd.Inventory, err = parseSlots(c, "Inventory"); err != nil {
+		return nil, err
+	}
+	if pd.EnderItems, err = parseSlots(c, "EnderItems"); err != nil {
+		return nil, err
+	}
+	if equipment, ok := take[nbtutil.Compound](c, "equipment"); ok {
+		pd.Equipment = make(map[string]ItemStack, len(equipment))
+		for slot, tag := range equipment {
+			entry, ok := tag.(nbtutil.Compound)
+			if !ok {
+				return nil, fmt.Errorf("equipment slot %s isn't a compound", slot)
+			}
+			if pd.Equipment[slot], err = parseItem(entry.Clone()); err != nil {
+				return nil, fmt.Errorf("equipment slot %s: %w", slot, err)
+			}
+		}
+	}
+
+	if effects, ok := c.Compounds("active_effects"); ok {
+		delete(c, "active_effects")
+		for i, effect := range effects {
+			id, ok := effect.String("id")
+			if !ok {
+				return nil, fmt.Errorf("effect %d has no id", i)
+			}
+			amplifier, _ := effect.Byte("amplifier")
+			duration, _ := effect.Int("duration")
+			ambient, _ := effect.Bool("ambient")
+			showParticles, _ := effect.Bool("show_particles")
+			showIcon, _ := effect.Bool("show_icon")
+			pd.Effects = append(pd.Effects, Effect{id, amplifier, duration, ambient, showParticles, showIcon})
+		}
+	}
+
+	if abilities, ok := take[nbtutil.Compound](c, "abilities"); ok {
+		pd.Abilities.Flying = takeBool(abilities, "flying")
+		pd.Abilities.MayFly = takeBool(abilities, "mayfly")
+		pd.Abilities.InstaBuild = takeBool(abilities, "instabuild")
+		pd.Abilities.Invulnerable = takeBool(abilities, "invulnerable")
+		pd.Abilities.MayBuild = takeBool(abilities, "mayBuild")
+		pd.Abilities.FlySpeed, _ = take[float32](abilities, "flySpeed")
+		pd.Abilities.WalkSpeed, _ = take[float32](abilities, "walkSpeed")
+		if len(abilities) > 0 {
+			c["abilities"] = abilities
+		}
+	}
+
+	pd.Extra = c
+	return pd, nil
+}
+
+// Compound returns the player data as the root compound of a player data
+// file.
+func (pd *PlayerData) Compound() nbtutil.Compound {
+	var c nbtutil.Compound
+	if pd.Extra != nil {
+		c = pd.Extra.Clone()
+	} else {
+		c = make(nbtutil.Compound)
+	}
+
+	c["DataVersion"] = pd.DataVersion
+	if pd.Dimension != "" {
+		c["Dimension"] = pd.Dimension
+	}
+	c["Pos"] = nbtutil.CreateList(pd.X, pd.Y, pd.Z)
+	c["Rotation"] = nbtutil.CreateList(pd.Yaw, pd.Pitch)
+	c["OnGround"] = boolByte(pd.OnGround)
+
+	c["Health"] = pd.Health
+	c["foodLevel"] = pd.FoodLevel
+	c["playerGameType"] = pd.GameType
+	c["SelectedItemSlot"] = pd.SelectedSlot
+
+	c["XpLevel"] = pd.XPLevel
+	c["XpP"] = pd.XPProgress
+	c["XpTotal"] = pd.XPTotal
+	c["Score"] = pd.Score
+
+	c["Inventory"] = slotList(pd.Inventory)
+	c["EnderItems"] = slotList(pd.EnderItems)
+	if len(pd.Equipment) > 0 {
+		equipment := make(nbtutil.Compound, len(pd.Equipment))
+		for slot, item := range pd.Equipment {
+			equipment[slot] = item.compound()
+		}
+		c["equipment"] = equipment
+	}
+
+	if len(pd.Effects) > 0 {
+		effects := nbtutil.List{Type: nbtutil.TagCompound}
+		for _, effect := range pd.Effects {
+			effects.Elements = append(effects.Elements, nbtutil.Compound{
+				"id":             effect.ID,
+				"amplifier":      effect.Amplifier,
+				"duration":       effect.Duration,
+				"ambient":        boolByte(effect.Ambient),
+				"show_particles": boolByte(effect.ShowParticles),
+				"show_icon":      boolByte(effect.ShowIcon),
+			})
+		}
+		c["active_effects"] = effects
+	}
+
+	abilities, ok := c.Compound("abilities")
+	if !ok {
+		abilities = make(nbtutil.Compound)
+	}
+	abilities["flying"] = boolByte(pd.Abilities.Flying)
+	abilities["mayfly"] = boolByte(pd.Abilities.MayFly)
+	abilities["instabuild"] = boolByte(pd.Abilities.InstaBuild)
+	abilities["invulnerable"] = boolByte(pd.Abilities.Invulnerable)
+	abilities["mayBuild"] = boolByte(pd.Abilities.MayBuild)
+	abilities["flySpeed"] = pd.Abilities.FlySpeed
+	abilities["walkSpeed"] = pd.Abilities.WalkSpeed
+	c["abilities"] = abilities
+	return c
+}
+
+// Write writes the player data as a compressed player data file.
+func (pd *PlayerData) Write(w io.Writer) error {
+	return nbtutil.WriteFile(w, "", pd.Compound())
+}
+
+// Save writes the player data to the given path, keeping the previous file
+// as <path>_old like vanilla does.
+func (pd *PlayerData) Save(path string) error {
+	return saveFile(path, pd.Write)
+}
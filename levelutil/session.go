@@ -0,0 +1,142 @@
+package levelutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SessionLockFile is the name of the file in a world directory that guards
+// it against being opened twice.
+const SessionLockFile = "session.lock"
+
+// sessionLockMarker is what current versions write into session.lock: a
+// snowman, in UTF-8.
+var sessionLockMarker = []byte("☃")
+
+// SessionLockedError is returned when another process holds a world's
+// session lock.
+type SessionLockedError struct {
+	Path string
+}
+
+func (sle *SessionLockedError) Error() string {
+	return fmt.Sprintf("%s: already locked (possibly by other Minecraft instance?)", sle.Path)
+}
+
+// SessionLock is a held session.lock. There are two kinds, for the two ways
+// vanilla has used the file:
+//
+// Current versions write a marker and hold an operating system lock on the
+// file for as long as the world is open, so a second server refuses to
+// start. LockSession takes that kind.
+//
+// Older versions wrote the time the world was opened and, before every
+// save, read it back, aborting the save if another process had written a
+// newer one since. LockLegacySession takes that kind: writing it makes a
+// running old server stop saving, and Check reports when an old server has
+// taken the world back.
+type SessionLock struct {
+	path      string
+	file      *os.File
+	timestamp int64
+}
+
+// LockSession takes the session lock of a world, failing with a
+// SessionLockedError if a running server or another tool holds it. On
+// platforms without file locking, only the marker is written.
+func LockSession(dir string) (*SessionLock, error) {
+	path := filepath.Join(dir, SessionLockFile)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o666)
+	if err != nil {
+		return nil, err
+	}
+
+	locked, err := lockFile(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if !locked {
+		file.Close()
+		return nil, &SessionLockedError{path}
+	}
+
+	if _, err := file.WriteAt(sessionLockMarker, 0); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	sl := new(SessionLock)
+	sl.path = path
+	sl.file = file
+	return sl, nil
+}
+
+// LockLegacySession claims a world the way older versions do, by writing the
+// current time in milliseconds into its session.lock.
+func LockLegacySession(dir string) (*SessionLock, error) {
+	sl := new(SessionLock)
+	sl.path = filepath.Join(dir, SessionLockFile)
+	sl.timestamp = time.Now().UnixMilli()
+
+	data := binary.BigEndian.AppendUint64(nil, uint64(sl.timestamp))
+	if err := os.WriteFile(sl.path, data, 0o666); err != nil {
+		return nil, err
+	}
+	return sl, nil
+}
+
+// Check reports whether the world is still ours to write. It should be
+// called before every save. A legacy lock is lost once another process has
+// written its own time; a current lock is lost if the file was deleted or
+// replaced, such as by the world being removed.
+func (sl *SessionLock) Check() error {
+	if sl.file == nil {
+		data, err := os.ReadFile(sl.path)
+		if err != nil {
+			return fmt.Errorf("checking session lock: %w", err)
+		}
+		if len(data) < 8 || int64(binary.BigEndian.Uint64(data)) != sl.timestamp {
+			return fmt.Errorf("the save is being accessed from another location, aborting")
+		}
+		return nil
+	}
+
+	held, err := sl.file.Stat()
+	if err != nil {
+		return fmt.Errorf("checking session lock: %w", err)
+	}
+	current, err := os.Stat(sl.path)
+	if err != nil || !os.SameFile(held, current) {
+		return fmt.Errorf("%s: session lock is no longer valid", sl.path)
+	}
+
+	marker := make([]byte, len(sessionLockMarker))
+	if _, err := sl.file.ReadAt(marker, 0); err != nil && err != io.EOF {
+		return fmt.Errorf("checking session lock: %w", err)
+	}
+	if !bytes.Equal(marker, sessionLockMarker) {
+		return fmt.Errorf("%s: session lock was overwritten", sl.path)
+	}
+	return nil
+}
+
+// Unlock releases the lock. The file is left in place, as vanilla does.
+// Legacy locks have nothing to release.
+func (sl *SessionLock) Unlock() error {
+	if sl.file == nil {
+		return nil
+	}
+	err := sl.file.Close()
+	sl.file = nil
+	return err
+}
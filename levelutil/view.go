@@ -0,0 +1,178 @@
+package levelutil
+
+import (
+	"math"
+	"sync"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// ChunkPosOf returns the chunk a position is in.
+func ChunkPosOf(x, z float64) ChunkPos {
+	return ChunkPos{int32(math.Floor(x / 16)), int32(math.Floor(z / 16))}
+}
+
+// InView reports whether a chunk is within distance of center, as vanilla
+// decides which chunks a player is sent: by a circle rather than a square,
+// widened by a chunk so that those at the edge have their neighbours and
+// render fully.
+func InView(center ChunkPos, distance int32, pos ChunkPos) bool {
+	dx := max(0, abs(int64(pos.X)-int64(center.X))-2)
+	dz := max(0, abs(int64(pos.Z)-int64(center.Z))-2)
+	return dx*dx+dz*dz < int64(distance)*int64(distance)
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// SetCameraPacket returns the Set Camera packet, which makes the client
+// watch the world through another entity's eyes, as spectators do when
+// they click on one. Passing the player's own entity ID puts the camera
+// back.
+func SetCameraPacket(packetID int32, entityID int32) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteVarInt(entityID)
+	return pw.GetPacket()
+}
+
+// ReadSpectate reads Teleport To Entity, which a spectator sends to be
+// taken to an entity chosen from the spectator menu, returning its UUID.
+// The entity may be in another world.
+func ReadSpectate(data []byte) ([16]byte, error) {
+	return packetutil.UUIDCodec.Unmarshal(data)
+}
+
+// SetCenterChunkPacket returns the Set Center Chunk packet, which moves the
+// point the client keeps chunks around. Chunks outside the view around it
+// are dropped by the client without being told.
+func SetCenterChunkPacket(packetID int32, pos ChunkPos) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteVarInt(pos.X)
+	pw.WriteVarInt(pos.Z)
+	return pw.GetPacket()
+}
+
+// SetRenderDistancePacket returns the Set Render Distance packet, which
+// changes the view distance the client keeps chunks for.
+func SetRenderDistancePacket(packetID int32, distance int32) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteVarInt(distance)
+	return pw.GetPacket()
+}
+
+// UnloadChunkPacket returns the Unload Chunk packet.
+func UnloadChunkPacket(packetID int32, pos ChunkPos) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteInt(pos.Z)
+	pw.WriteInt(pos.X)
+	return pw.GetPacket()
+}
+
+// ViewIDs are the clientbound packet IDs a View sends, which depend on the
+// protocol version.
+type ViewIDs struct {
+	SetCenterChunk int32
+	UnloadChunk    int32
+}
+
+// View decides which chunks a player should have from where they watch the
+// world and how far they see, and keeps their ChunkSender and client in
+// step with it as either changes. Where they watch from is the camera: the
+// player themself, or the entity a spectator is watching through. It is
+// safe for concurrent use.
+type View struct {
+	sender *ChunkSender
+	ids    ViewIDs
+
+	lock     sync.Mutex
+	center   ChunkPos
+	distance int32
+	placed   bool
+}
+
+// CreateView is a factory function for creating a new View that queues
+// chunks on sender, for a view distance that is the lower of the server's
+// and the client's.
+func CreateView(sender *ChunkSender, ids ViewIDs, distance int32) *View {
+	v := new(View)
+	v.sender = sender
+	v.ids = ids
+	v.distance = distance
+	return v
+}
+
+// Center returns the chunk the view is around.
+func (v *View) Center() ChunkPos {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return v.center
+}
+
+// Move follows the camera to a new position. If it has entered another
+// chunk, the chunks that came into view are queued on the sender, and the
+// Set Center Chunk packet and Unload Chunk packets for chunks that left
+// view are returned to be sent before any more chunks.
+func (v *View) Move(x, z float64) [][]byte {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	pos := ChunkPosOf(x, z)
+	if v.placed && pos == v.center {
+		return nil
+	}
+	return v.update(pos, v.distance)
+}
+
+// SetDistance changes the view distance, as when the client changes its
+// render distance in Client Information.
+func (v *View) SetDistance(distance int32) [][]byte {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if !v.placed {
+		v.distance = distance
+		return nil
+	}
+	if distance == v.distance {
+		return nil
+	}
+	return v.update(v.center, distance)
+}
+
+func (v *View) update(center ChunkPos, distance int32) [][]byte {
+	var packets [][]byte
+	if !v.placed || center != v.center {
+		packets = append(packets, SetCenterChunkPacket(v.ids.SetCenterChunk, center))
+	}
+
+	if v.placed {
+		reach := v.distance + 2
+		for x := v.center.X - reach; x <= v.center.X+reach; x++ {
+			for z := v.center.Z - reach; z <= v.center.Z+reach; z++ {
+				pos := ChunkPos{x, z}
+				if InView(v.center, v.distance, pos) && !InView(center, distance, pos) && v.sender.Drop(pos) {
+					packets = append(packets, UnloadChunkPacket(v.ids.UnloadChunk, pos))
+				}
+			}
+		}
+	}
+
+	v.sender.SetCenter(center)
+	reach := distance + 2
+	for x := center.X - reach; x <= center.X+reach; x++ {
+		for z := center.Z - reach; z <= center.Z+reach; z++ {
+			if pos := (ChunkPos{x, z}); InView(center, distance, pos) {
+				v.sender.Queue(pos)
+			}
+		}
+	}
+
+	v.center = center
+	v.distance = distance
+	v.placed = true
+	return packets
+}
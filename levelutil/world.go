@@ -0,0 +1,223 @@
+package levelutil
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/PurpurProject/elytra/entityutil"
+)
+
+// World is one dimension of a server, such as the overworld: its chunks, the
+// entities in it, its clock, and which players are in it. C is whatever the
+// server holds a chunk in and E whatever it holds an entity in.
+type World[C, E any] struct {
+	Dimension Dimension
+	Chunks    *ChunkCache[C]
+	// Clock is the world's time and weather, which is only ticked from the
+	// world's own tick.
+	Clock *WorldClock
+
+	entities *entityutil.Registry[E]
+
+	lock    sync.RWMutex
+	members map[int32]bool
+	players map[[16]byte]bool
+}
+
+// CreateWorld is a factory function for creating a new World. Entity IDs are
+// unique across the whole server, so every world takes them from the same
+// registry.
+func CreateWorld[C, E any](dim Dimension, chunks *ChunkCache[C], clock *WorldClock, entities *entityutil.Registry[E]) *World[C, E] {
+	w := new(World[C, E])
+	w.Dimension = dim
+	w.Chunks = chunks
+	w.Clock = clock
+	w.entities = entities
+	w.members = make(map[int32]bool)
+	w.players = make(map[[16]byte]bool)
+	return w
+}
+
+// AddEntity spawns an entity into the world, returning its ID.
+func (w *World[C, E]) AddEntity(uuid [16]byte, entity E) (int32, error) {
+	id, err := w.entities.Add(uuid, entity)
+	if err != nil {
+		return 0, err
+	}
+	w.lock.Lock()
+	w.members[id] = true
+	w.lock.Unlock()
+	return id, nil
+}
+
+// RemoveEntity removes an entity from the world and frees its ID,
+// reporting whether it was in the world.
+func (w *World[C, E]) RemoveEntity(id int32) bool {
+	w.lock.Lock()
+	ok := w.members[id]
+	delete(w.members, id)
+	w.lock.Unlock()
+	return ok && w.entities.Remove(id)
+}
+
+// Entity returns an entity in the world.
+func (w *World[C, E]) Entity(id int32) (E, bool) {
+	w.lock.RLock()
+	ok := w.members[id]
+	w.lock.RUnlock()
+	if !ok {
+		var zero E
+		return zero, false
+	}
+	entity, _, ok := w.entities.ByID(id)
+	return entity, ok
+}
+
+// EachEntity calls fn for every entity in the world, in no particular order,
+// until it returns false.
+func (w *World[C, E]) EachEntity(fn func(id int32, entity E) bool) {
+	w.lock.RLock()
+	ids := make([]int32, 0, len(w.members))
+	for id := range w.members {
+		ids = append(ids, id)
+	}
+	w.lock.RUnlock()
+
+	for _, id := range ids {
+		if entity, _, ok := w.entities.ByID(id); ok && !fn(id, entity) {
+			return
+		}
+	}
+}
+
+// Players returns the UUIDs of the players in the world.
+func (w *World[C, E]) Players() [][16]byte {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	players := make([][16]byte, 0, len(w.players))
+	for uuid := range w.players {
+		players = append(players, uuid)
+	}
+	return players
+}
+
+// HasPlayer reports whether a player is in the world.
+func (w *World[C, E]) HasPlayer(uuid [16]byte) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.players[uuid]
+}
+
+// Tick ticks the world's clock, returning the packets to send to every
+// player in it.
+func (w *World[C, E]) Tick() [][]byte {
+	if w.Clock == nil {
+		return nil
+	}
+	return w.Clock.Tick()
+}
+
+// Worlds holds a server's worlds by name, and which one each player is in.
+// A player is in no more than one world at a time.
+type Worlds[C, E any] struct {
+	lock    sync.RWMutex
+	worlds  map[string]*World[C, E]
+	players map[[16]byte]*World[C, E]
+}
+
+// CreateWorlds is a factory function for creating a new, empty Worlds.
+func CreateWorlds[C, E any]() *Worlds[C, E] {
+	ws := new(Worlds[C, E])
+	ws.worlds = make(map[string]*World[C, E])
+	ws.players = make(map[[16]byte]*World[C, E])
+	return ws
+}
+
+// Add adds a world under its dimension's name.
+func (ws *Worlds[C, E]) Add(w *World[C, E]) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if _, ok := ws.worlds[w.Dimension.Name]; ok {
+		return fmt.Errorf("world %s already exists", w.Dimension.Name)
+	}
+	ws.worlds[w.Dimension.Name] = w
+	return nil
+}
+
+// World returns a world by name.
+func (ws *Worlds[C, E]) World(name string) (*World[C, E], bool) {
+	ws.lock.RLock()
+	defer ws.lock.RUnlock()
+	w, ok := ws.worlds[name]
+	return w, ok
+}
+
+// Names returns the names of every world, sorted, as Join Game lists them.
+func (ws *Worlds[C, E]) Names() []string {
+	ws.lock.RLock()
+	defer ws.lock.RUnlock()
+
+	names := make([]string, 0, len(ws.worlds))
+	for name := range ws.worlds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Move puts a player into a world, taking them out of the one they were in,
+// which is returned, or nil if they weren't in one. It returns an error,
+// moving no one, if to is nil or hasn't been added.
+func (ws *Worlds[C, E]) Move(uuid [16]byte, to *World[C, E]) (*World[C, E], error) {
+	if to == nil {
+		return nil, fmt.Errorf("no world to move player to")
+	}
+
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if ws.worlds[to.Dimension.Name] != to {
+		return nil, fmt.Errorf("world %s hasn't been added", to.Dimension.Name)
+	}
+	from := ws.players[uuid]
+	if from == to {
+		return from, nil
+	}
+	if from != nil {
+		from.lock.Lock()
+		delete(from.players, uuid)
+		from.lock.Unlock()
+	}
+	to.lock.Lock()
+	to.players[uuid] = true
+	to.lock.Unlock()
+	ws.players[uuid] = to
+	return from, nil
+}
+
+// Leave takes a player out of their world when they disconnect, returning
+// the world they were in, or nil.
+func (ws *Worlds[C, E]) Leave(uuid [16]byte) *World[C, E] {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	from := ws.players[uuid]
+	if from != nil {
+		from.lock.Lock()
+		delete(from.players, uuid)
+		from.lock.Unlock()
+		delete(ws.players, uuid)
+	}
+	return from
+}
+
+// WorldOf returns the world a player is in.
+func (ws *Worlds[C, E]) WorldOf(uuid [16]byte) (*World[C, E], bool) {
+	ws.lock.RLock()
+	defer ws.lock.RUnlock()
+	w, ok := ws.players[uuid]
+	return w, ok
+}
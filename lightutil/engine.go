@@ -0,0 +1,382 @@
+package lightutil
+
+// MaxLight is the highest light level.
+const MaxLight = 15
+
+// sectionBytes is the size of a section's light array: 4096 nibbles.
+const sectionBytes = 2048
+
+// World gives the engine the lighting properties of blocks. Emission is the
+// light a block gives off and opacity how much light it absorbs, both from
+// 0 to 15.
+type World interface {
+	LightProperties(x, y, z int) (emission, opacity uint8)
+}
+
+// ChunkPos is the position of a chunk, in chunk coordinates.
+type ChunkPos struct {
+	X, Z int32
+}
+
+// SectionPos is the position of a 16x16x16 section, in section coordinates.
+type SectionPos struct {
+	X, Y, Z int32
+}
+
+type nibbles [sectionBytes]byte
+
+func nibbleIndex(x, y, z int) int {
+	return (y&15)<<8 | (z&15)<<4 | (x & 15)
+}
+
+func (n *nibbles) get(x, y, z int) uint8 {
+	i := nibbleIndex(x, y, z)
+	return (n[i>>1] >> ((i & 1) * 4)) & 0xF
+}
+
+func (n *nibbles) set(x, y, z int, level uint8) {
+	i := nibbleIndex(x, y, z)
+	shift := (i & 1) * 4
+	n[i>>1] = n[i>>1]&^(0xF<<shift) | level<<shift
+}
+
+func (n *nibbles) empty() bool {
+	for _, b := range n {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type node struct {
+	x, y, z int
+	level   uint8
+}
+
+type queue struct {
+	nodes []node
+	head  int
+}
+
+func (q *queue) push(x, y, z int, level uint8) {
+	q.nodes = append(q.nodes, node{x, y, z, level})
+}
+
+func (q *queue) pop() (node, bool) {
+	if q.head == len(q.nodes) {
+		q.nodes = q.nodes[:0]
+		q.head = 0
+		return node{}, false
+	}
+	n := q.nodes[q.head]
+	q.head++
+	return n, true
+}
+
+// directions are the six neighbours of a block. Down comes first, which the
+// sky light rules rely on.
+var directions = [6][3]int{
+	{0, -1, 0}, {0, 1, 0}, {0, 0, -1}, {0, 0, 1}, {-1, 0, 0}, {1, 0, 0},
+}
+
+const down = 0
+
+// channel is the light of one kind, block or sky, with its work queues.
+type channel struct {
+	sky      bool
+	sections map[SectionPos]*nibbles
+	increase queue
+	decrease queue
+}
+
+// Engine keeps the block and sky light of the chunks it is given and
+// recomputes them as blocks change. Light spreads breadth first, losing at
+// least one level per block, across section and chunk borders; it doesn't
+// spread into chunks that haven't been added.
+//
+// Sky light enters from above the world at 15 and travels straight down
+// through fully transparent blocks without dimming, as in vanilla.
+type Engine struct {
+	world      World
+	minY, maxY int
+	chunks     map[ChunkPos]bool
+	block, sky *channel
+	pending    [][3]int
+	dirty      map[SectionPos]bool
+}
+
+// CreateEngine is a factory function for creating a new Engine for a world
+// whose blocks run from minY up to minY+height. Both must be multiples of 16.
+func CreateEngine(world World, minY, height int) *Engine {
+	e := new(Engine)
+	e.world = world
+	e.minY = minY
+	e.maxY = minY + height
+	e.chunks = make(map[ChunkPos]bool)
+	e.block = &channel{sections: make(map[SectionPos]*nibbles)}
+	e.sky = &channel{sky: true, sections: make(map[SectionPos]*nibbles)}
+	e.dirty = make(map[SectionPos]bool)
+	return e
+}
+
+func sectionOf(x, y, z int) SectionPos {
+	return SectionPos{int32(x >> 4), int32(y >> 4), int32(z >> 4)}
+}
+
+func (e *Engine) loaded(x, z int) bool {
+	return e.chunks[ChunkPos{int32(x >> 4), int32(z >> 4)}]
+}
+
+// inWorld reports whether light can be stored at a position.
+func (e *Engine) inWorld(x, y, z int) bool {
+	return y >= e.minY && y < e.maxY && e.loaded(x, z)
+}
+
+func (e *Engine) get(ch *channel, x, y, z int) uint8 {
+	if ch.sky && y >= e.maxY {
+		return MaxLight
+	}
+	section := ch.sections[sectionOf(x, y, z)]
+	if section == nil {
+		return 0
+	}
+	return section.get(x, y, z)
+}
+
+func (e *Engine) set(ch *channel, x, y, z int, level uint8) {
+	pos := sectionOf(x, y, z)
+	section := ch.sections[pos]
+	if section == nil {
+		if level == 0 {
+			return
+		}
+		section = new(nibbles)
+		ch.sections[pos] = section
+	}
+	section.set(x, y, z, level)
+	e.dirty[pos] = true
+}
+
+// BlockLight returns the block light at a position.
+func (e *Engine) BlockLight(x, y, z int) uint8 {
+	return e.get(e.block, x, y, z)
+}
+
+// SkyLight returns the sky light at a position.
+func (e *Engine) SkyLight(x, y, z int) uint8 {
+	if !e.loaded(x, z) {
+		return 0
+	}
+	return e.get(e.sky, x, y, z)
+}
+
+// propagate returns the level light arrives with when it moves in a
+// direction into a block of the given opacity.
+func propagate(sky bool, level, opacity uint8, dir int) uint8 {
+	if sky && dir == down && level == MaxLight && opacity == 0 {
+		return MaxLight
+	}
+	cost := max(opacity, 1)
+	if level <= cost {
+		return 0
+	}
+	return level - cost
+}
+
+func (e *Engine) runIncrease(ch *channel) {
+	for {
+		n, ok := ch.increase.pop()
+		if !ok {
+			return
+		}
+		if e.get(ch, n.x, n.y, n.z) != n.level {
+			continue
+		}
+		for dir, d := range directions {
+			x, y, z := n.x+d[0], n.y+d[1], n.z+d[2]
+			if !e.inWorld(x, y, z) {
+				continue
+			}
+			_, opacity := e.world.LightProperties(x, y, z)
+			level := propagate(ch.sky, n.level, opacity, dir)
+			if level > e.get(ch, x, y, z) {
+				e.set(ch, x, y, z, level)
+				ch.increase.push(x, y, z, level)
+			}
+		}
+	}
+}
+
+func (e *Engine) runDecrease(ch *channel) {
+	for {
+		n, ok := ch.decrease.pop()
+		if !ok {
+			return
+		}
+		for dir, d := range directions {
+			x, y, z := n.x+d[0], n.y+d[1], n.z+d[2]
+			if !e.inWorld(x, y, z) {
+				continue
+			}
+			level := e.get(ch, x, y, z)
+			if level == 0 {
+				continue
+			}
+			// Only light dimmer than the removed light, or sky light that
+			// came straight down through it, can have come from it. Anything
+			// else is lit from elsewhere and refills the gap.
+			if level < n.level || (ch.sky && dir == down && level == MaxLight && n.level == MaxLight) {
+				e.set(ch, x, y, z, 0)
+				ch.decrease.push(x, y, z, level)
+				if !ch.sky {
+					if emission, _ := e.world.LightProperties(x, y, z); emission > 0 {
+						e.set(ch, x, y, z, emission)
+						ch.increase.push(x, y, z, emission)
+					}
+				}
+			} else {
+				ch.increase.push(x, y, z, level)
+			}
+		}
+	}
+}
+
+// AddChunk lights a chunk whose blocks have just become available, taking in
+// light from neighbouring chunks that were added before it. Its own light
+// is sent in its Chunk Data packet, so unlike the light it spreads into
+// neighbours, it isn't reported by the next Update.
+func (e *Engine) AddChunk(chunkX, chunkZ int32) {
+	pos := ChunkPos{chunkX, chunkZ}
+	if e.chunks[pos] {
+		return
+	}
+	e.chunks[pos] = true
+
+	baseX, baseZ := int(chunkX)<<4, int(chunkZ)<<4
+	for x := baseX; x < baseX+16; x++ {
+		for z := baseZ; z < baseZ+16; z++ {
+			e.sky.increase.push(x, e.maxY, z, MaxLight)
+			for y := e.minY; y < e.maxY; y++ {
+				if emission, _ := e.world.LightProperties(x, y, z); emission > 0 {
+					e.set(e.block, x, y, z, emission)
+					e.block.increase.push(x, y, z, emission)
+				}
+			}
+		}
+	}
+
+	// Neighbours' border blocks spread into the new chunk.
+	for i := 0; i < 16; i++ {
+		for _, border := range [4][2]int{
+			{baseX - 1, baseZ + i}, {baseX + 16, baseZ + i},
+			{baseX + i, baseZ - 1}, {baseX + i, baseZ + 16},
+		} {
+			if !e.loaded(border[0], border[1]) {
+				continue
+			}
+			for y := e.minY; y < e.maxY; y++ {
+				for _, ch := range []*channel{e.block, e.sky} {
+					if level := e.get(ch, border[0], y, border[1]); level > 0 {
+						ch.increase.push(border[0], y, border[1], level)
+					}
+				}
+			}
+		}
+	}
+
+	e.runIncrease(e.block)
+	e.runIncrease(e.sky)
+	for section := range e.dirty {
+		if section.X == chunkX && section.Z == chunkZ {
+			delete(e.dirty, section)
+		}
+	}
+}
+
+// RemoveChunk forgets a chunk's light. Light it spread into neighbours stays
+// until they next change, as in vanilla.
+func (e *Engine) RemoveChunk(chunkX, chunkZ int32) {
+	delete(e.chunks, ChunkPos{chunkX, chunkZ})
+	for _, ch := range []*channel{e.block, e.sky} {
+		for section := range ch.sections {
+			if section.X == chunkX && section.Z == chunkZ {
+				delete(ch.sections, section)
+			}
+		}
+	}
+	for section := range e.dirty {
+		if section.X == chunkX && section.Z == chunkZ {
+			delete(e.dirty, section)
+		}
+	}
+}
+
+// BlockChanged records that a block's emission or opacity may have changed.
+// Light is recomputed by the next Update, so a batch of edits only spreads
+// once.
+func (e *Engine) BlockChanged(x, y, z int) {
+	if e.inWorld(x, y, z) {
+		e.pending = append(e.pending, [3]int{x, y, z})
+	}
+}
+
+// Update recomputes light around the blocks changed since the last call and
+// returns the light data of every section whose light changed, grouped by
+// chunk and ready to send in Update Light packets.
+func (e *Engine) Update() map[ChunkPos]*LightData {
+	for _, ch := range []*channel{e.block, e.sky} {
+		// Take out the light of every changed block first, then let sources
+		// and the surrounding light fill back in.
+		for _, p := range e.pending {
+			if level := e.get(ch, p[0], p[1], p[2]); level > 0 {
+				e.set(ch, p[0], p[1], p[2], 0)
+				ch.decrease.push(p[0], p[1], p[2], level)
+			}
+		}
+		e.runDecrease(ch)
+
+		for _, p := range e.pending {
+			if !ch.sky {
+				if emission, _ := e.world.LightProperties(p[0], p[1], p[2]); emission > e.get(ch, p[0], p[1], p[2]) {
+					e.set(ch, p[0], p[1], p[2], emission)
+					ch.increase.push(p[0], p[1], p[2], emission)
+				}
+			}
+			for _, d := range directions {
+				x, y, z := p[0]+d[0], p[1]+d[1], p[2]+d[2]
+				if !e.inWorld(x, y, z) && !(ch.sky && y >= e.maxY) {
+					continue
+				}
+				if level := e.get(ch, x, y, z); level > 0 {
+					ch.increase.push(x, y, z, level)
+				}
+			}
+		}
+		e.runIncrease(ch)
+	}
+	e.pending = e.pending[:0]
+
+	changed := make(map[ChunkPos]map[int32]bool)
+	for section := range e.dirty {
+		pos := ChunkPos{section.X, section.Z}
+		if changed[pos] == nil {
+			changed[pos] = make(map[int32]bool)
+		}
+		changed[pos][section.Y] = true
+	}
+	clear(e.dirty)
+
+	updates := make(map[ChunkPos]*LightData, len(changed))
+	for pos, sections := range changed {
+		updates[pos] = e.lightData(pos, func(sectionY int32) bool {
+			return sections[sectionY]
+		})
+	}
+	return updates
+}
+
+// ChunkLight returns all of a chunk's light, for its Chunk Data packet.
+func (e *Engine) ChunkLight(chunkX, chunkZ int32) *LightData {
+	return e.lightData(ChunkPos{chunkX, chunkZ}, nil)
+}
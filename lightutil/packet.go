@@ -0,0 +1,100 @@
+package lightutil
+
+import "github.com/PurpurProject/elytra/packetutil"
+
+// LightData is a chunk's light as sent in Update Light and at the end of
+// Chunk Data. Bit i of each mask stands for the section i-1 sections above
+// the bottom of the world, so the masks cover one section beyond each end.
+// Sections in an empty mask are all zero and sent without an array.
+type LightData struct {
+	SkyMask        []uint64
+	BlockMask      []uint64
+	EmptySkyMask   []uint64
+	EmptyBlockMask []uint64
+	// SkyLight and BlockLight hold a 2048 byte array for each bit set in
+	// the matching mask, lowest section first.
+	SkyLight   [][]byte
+	BlockLight [][]byte
+}
+
+func setBit(mask []uint64, i int) {
+	mask[i/64] |= 1 << (i % 64)
+}
+
+// lightData collects a chunk's light for the sections include accepts, or
+// every section if it is nil.
+func (e *Engine) lightData(pos ChunkPos, include func(sectionY int32) bool) *LightData {
+	minSection := int32(e.minY >> 4)
+	count := (e.maxY-e.minY)>>4 + 2
+	words := (count + 63) / 64
+
+	ld := new(LightData)
+	ld.SkyMask = make([]uint64, words)
+	ld.BlockMask = make([]uint64, words)
+	ld.EmptySkyMask = make([]uint64, words)
+	ld.EmptyBlockMask = make([]uint64, words)
+
+	for i := 0; i < count; i++ {
+		sectionY := minSection - 1 + int32(i)
+		if include != nil && !include(sectionY) {
+			continue
+		}
+		section := SectionPos{pos.X, sectionY, pos.Z}
+
+		switch {
+		case i == count-1:
+			// Above the world the sky is always fully lit.
+			full := make([]byte, sectionBytes)
+			for j := range full {
+				full[j] = 0xFF
+			}
+			setBit(ld.SkyMask, i)
+			ld.SkyLight = append(ld.SkyLight, full)
+		case i == 0:
+			setBit(ld.EmptySkyMask, i)
+		default:
+			if light := e.sky.sections[section]; light != nil && !light.empty() {
+				setBit(ld.SkyMask, i)
+				ld.SkyLight = append(ld.SkyLight, append([]byte(nil), light[:]...))
+			} else {
+				setBit(ld.EmptySkyMask, i)
+			}
+		}
+
+		if light := e.block.sections[section]; light != nil && !light.empty() {
+			setBit(ld.BlockMask, i)
+			ld.BlockLight = append(ld.BlockLight, append([]byte(nil), light[:]...))
+		} else {
+			setBit(ld.EmptyBlockMask, i)
+		}
+	}
+	return ld
+}
+
+func writeArrays(pw *packetutil.PacketWriter, arrays [][]byte) {
+	pw.WriteVarInt(int32(len(arrays)))
+	for _, array := range arrays {
+		pw.WriteVarInt(int32(len(array)))
+		pw.WriteBytes(array)
+	}
+}
+
+// Write writes the light data in the layout shared by Update Light and Chunk
+// Data since 1.20.
+func (ld *LightData) Write(pw *packetutil.PacketWriter) {
+	pw.WriteBitSet(ld.SkyMask)
+	pw.WriteBitSet(ld.BlockMask)
+	pw.WriteBitSet(ld.EmptySkyMask)
+	pw.WriteBitSet(ld.EmptyBlockMask)
+	writeArrays(pw, ld.SkyLight)
+	writeArrays(pw, ld.BlockLight)
+}
+
+// Packet returns an Update Light packet carrying the light data.
+func (ld *LightData) Packet(packetID int32, chunkX, chunkZ int32) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteVarInt(chunkX)
+	pw.WriteVarInt(chunkZ)
+	ld.Write(pw)
+	return pw.GetPacket()
+}
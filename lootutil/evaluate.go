@@ -0,0 +1,500 @@
+package lootutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// maxTableDepth bounds how deeply loot_table entries may nest, so a table
+// that references itself fails instead of recursing forever.
+const maxTableDepth = 32
+
+// Context is the situation a loot table is rolled in: the vanilla loot
+// context parameters the built-in conditions and functions need, and lookups
+// for everything the evaluator can't know by itself.
+type Context struct {
+	Random *rand.Rand
+	Luck   float64
+	// KilledByPlayer is whether the entity dropping the loot was last hurt
+	// by a player.
+	KilledByPlayer bool
+	// ExplosionRadius is the radius of the explosion that broke the block,
+	// or zero if it wasn't broken by one.
+	ExplosionRadius float64
+	Raining         bool
+	Thundering      bool
+	// DayTime is the world's time of day in ticks, used by time_check.
+	DayTime int64
+
+	// Tables resolves loot_table entries.
+	Tables func(name string) (*LootTable, bool)
+	// Tags returns the items in an item tag, for tag entries.
+	Tags func(tag string) []string
+	// Condition evaluates conditions the evaluator doesn't support itself,
+	// such as match_tool or reference. Without it, such conditions are
+	// errors.
+	Condition func(c Condition, ctx *Context) (bool, error)
+	// Function applies functions the evaluator doesn't support itself, such
+	// as enchant_randomly. Without it, such functions leave the stack as it
+	// is.
+	Function func(f Function, stack ItemStack, ctx *Context) (ItemStack, error)
+	// Number gives the value of number providers the evaluator doesn't
+	// support itself, such as score or storage. Without it, such providers
+	// are 0.
+	Number func(np *NumberProvider, ctx *Context) float64
+
+	depth int
+}
+
+// Float returns a value from the provider.
+func (np *NumberProvider) Float(ctx *Context) float64 {
+	switch np.Type {
+	case "minecraft:uniform":
+		low, high := np.Min.Float(ctx), np.Max.Float(ctx)
+		if high <= low {
+			return low
+		}
+		return low + ctx.Random.Float64()*(high-low)
+	case "minecraft:binomial":
+		return float64(np.Int(ctx))
+	case "minecraft:constant":
+		return np.Value
+	}
+	if ctx.Number == nil {
+		return 0
+	}
+	return ctx.Number(np, ctx)
+}
+
+// Int returns a whole value from the provider. Uniform providers pick
+// between their bounds inclusively, as vanilla does.
+func (np *NumberProvider) Int(ctx *Context) int {
+	switch np.Type {
+	case "minecraft:uniform":
+		low, high := np.Min.Int(ctx), np.Max.Int(ctx)
+		if high <= low {
+			return low
+		}
+		return low + ctx.Random.Intn(high-low+1)
+	case "minecraft:binomial":
+		n, p := np.N.Int(ctx), np.P.Float(ctx)
+		successes := 0
+		for i := 0; i < n; i++ {
+			if ctx.Random.Float64() < p {
+				successes++
+			}
+		}
+		return successes
+	case "minecraft:constant":
+		return int(math.Round(np.Value))
+	}
+	return int(math.Round(np.Float(ctx)))
+}
+
+// Generate rolls the loot table and returns the stacks it produces.
+func (lt *LootTable) Generate(ctx *Context) ([]ItemStack, error) {
+	if ctx.depth >= maxTableDepth {
+		return nil, fmt.Errorf("loot tables nest more than %d deep", maxTableDepth)
+	}
+	ctx.depth++
+	defer func() { ctx.depth-- }()
+
+	var stacks []ItemStack
+	for _, pool := range lt.Pools {
+		produced, err := pool.generate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stacks = append(stacks, produced...)
+	}
+	return applyFunctions(lt.Functions, stacks, ctx)
+}
+
+func (p *Pool) generate(ctx *Context) ([]ItemStack, error) {
+	if ok, err := testAll(p.Conditions, ctx); !ok || err != nil {
+		return nil, err
+	}
+
+	rolls := p.Rolls.Int(ctx)
+	if p.BonusRolls != nil {
+		rolls += int(math.Floor(p.BonusRolls.Float(ctx) * ctx.Luck))
+	}
+
+	var stacks []ItemStack
+	for i := 0; i < rolls; i++ {
+		var candidates []candidate
+		for _, entry := range p.Entries {
+			if _, err := entry.expand(ctx, &candidates); err != nil {
+				return nil, err
+			}
+		}
+		chosen := choose(candidates, ctx)
+		if chosen == nil {
+			continue
+		}
+
+		produced, err := chosen.produce(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stacks = append(stacks, produced...)
+	}
+	return applyFunctions(p.Functions, stacks, ctx)
+}
+
+// candidate is a leaf entry that passed its conditions and may be picked by
+// a roll. Expanded tag entries yield one candidate per item.
+type candidate struct {
+	entry *Entry
+	item  string
+}
+
+func (c *candidate) weight(luck float64) int {
+	return max(int(math.Floor(float64(c.entry.Weight)+float64(c.entry.Quality)*luck)), 0)
+}
+
+// choose picks a candidate by weight. As in vanilla, candidates with no
+// weight are never picked, and a lone candidate with some is picked without
+// drawing a number.
+func choose(candidates []candidate, ctx *Context) *candidate {
+	total, weighted := 0, 0
+	var only *candidate
+	for i := range candidates {
+		if weight := candidates[i].weight(ctx.Luck); weight > 0 {
+			total += weight
+			weighted++
+			only = &candidates[i]
+		}
+	}
+	switch weighted {
+	case 0:
+		return nil
+	case 1:
+		return only
+	}
+	pick := ctx.Random.Intn(total)
+	for i := range candidates {
+		pick -= candidates[i].weight(ctx.Luck)
+		if pick < 0 {
+			return &candidates[i]
+		}
+	}
+	return nil
+}
+
+// expand adds the entry's candidates and reports whether it passed its
+// conditions, which is what the composite entries branch on.
+func (e *Entry) expand(ctx *Context, candidates *[]candidate) (bool, error) {
+	if ok, err := testAll(e.Conditions, ctx); !ok || err != nil {
+		return false, err
+	}
+
+	switch e.Type {
+	case "minecraft:alternatives":
+		for _, child := range e.Children {
+			ok, err := child.expand(ctx, candidates)
+			if ok || err != nil {
+				return true, err
+			}
+		}
+		return false, nil
+	case "minecraft:sequence":
+		for _, child := range e.Children {
+			ok, err := child.expand(ctx, candidates)
+			if !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case "minecraft:group":
+		for _, child := range e.Children {
+			if _, err := child.expand(ctx, candidates); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case "minecraft:tag":
+		if e.Expand {
+			if ctx.Tags == nil {
+				return false, fmt.Errorf("no tag lookup to expand %s", e.Name)
+			}
+			for _, item := range ctx.Tags(e.Name) {
+				*candidates = append(*candidates, candidate{e, item})
+			}
+			return true, nil
+		}
+	}
+	*candidates = append(*candidates, candidate{e, ""})
+	return true, nil
+}
+
+func (c *candidate) produce(ctx *Context) ([]ItemStack, error) {
+	e := c.entry
+
+	var stacks []ItemStack
+	switch e.Type {
+	case "minecraft:item":
+		stacks = []ItemStack{{Item: e.Name, Count: 1}}
+	case "minecraft:tag":
+		if c.item != "" {
+			stacks = []ItemStack{{Item: c.item, Count: 1}}
+			break
+		}
+		if ctx.Tags == nil {
+			return nil, fmt.Errorf("no tag lookup for %s", e.Name)
+		}
+		for _, item := range ctx.Tags(e.Name) {
+			stacks = append(stacks, ItemStack{Item: item, Count: 1})
+		}
+	case "minecraft:loot_table":
+		table := e.Inline
+		if table == nil {
+			if ctx.Tables == nil {
+				return nil, fmt.Errorf("no loot table lookup for %s", e.Value)
+			}
+			var ok bool
+			if table, ok = ctx.Tables(e.Value); !ok {
+				return nil, fmt.Errorf("unknown loot table %s", e.Value)
+			}
+		}
+		produced, err := table.Generate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stacks = produced
+	case "minecraft:empty":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported loot entry type %s", e.Type)
+	}
+	return applyFunctions(e.Functions, stacks, ctx)
+}
+
+func testAll(conditions []Condition, ctx *Context) (bool, error) {
+	for _, c := range conditions {
+		if ok, err := c.Test(ctx); !ok || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Test evaluates the condition.
+func (c Condition) Test(ctx *Context) (bool, error) {
+	switch c.Type {
+	case "minecraft:random_chance":
+		var raw struct {
+			Chance *NumberProvider `json:"chance"`
+		}
+		if err := json.Unmarshal(c.Raw, &raw); err != nil {
+			return false, err
+		}
+		if raw.Chance == nil {
+			return false, fmt.Errorf("random_chance has no chance")
+		}
+		return ctx.Random.Float64() < raw.Chance.Float(ctx), nil
+	case "minecraft:killed_by_player":
+		return ctx.KilledByPlayer, nil
+	case "minecraft:survives_explosion":
+		if ctx.ExplosionRadius <= 0 {
+			return true, nil
+		}
+		return ctx.Random.Float64() <= 1/ctx.ExplosionRadius, nil
+	case "minecraft:weather_check":
+		var raw struct {
+			Raining    *bool `json:"raining"`
+			Thundering *bool `json:"thundering"`
+		}
+		if err := json.Unmarshal(c.Raw, &raw); err != nil {
+			return false, err
+		}
+		return (raw.Raining == nil || *raw.Raining == ctx.Raining) &&
+			(raw.Thundering == nil || *raw.Thundering == ctx.Thundering), nil
+	case "minecraft:time_check":
+		var raw struct {
+			Value  json.RawMessage `json:"value"`
+			Period int64           `json:"period"`
+		}
+		if err := json.Unmarshal(c.Raw, &raw); err != nil {
+			return false, err
+		}
+		low, high, err := parseRange(raw.Value, ctx)
+		if err != nil {
+			return false, err
+		}
+		time := ctx.DayTime
+		if raw.Period > 0 {
+			time %= raw.Period
+		}
+		return float64(time) >= low && float64(time) <= high, nil
+	case "minecraft:inverted":
+		var raw struct {
+			Term *Condition `json:"term"`
+		}
+		if err := json.Unmarshal(c.Raw, &raw); err != nil {
+			return false, err
+		}
+		if raw.Term == nil {
+			return false, fmt.Errorf("inverted has no term")
+		}
+		ok, err := raw.Term.Test(ctx)
+		return !ok, err
+	case "minecraft:any_of", "minecraft:alternative":
+		var raw struct {
+			Terms []Condition `json:"terms"`
+		}
+		if err := json.Unmarshal(c.Raw, &raw); err != nil {
+			return false, err
+		}
+		for _, term := range raw.Terms {
+			if ok, err := term.Test(ctx); ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+	case "minecraft:all_of":
+		var raw struct {
+			Terms []Condition `json:"terms"`
+		}
+		if err := json.Unmarshal(c.Raw, &raw); err != nil {
+			return false, err
+		}
+		return testAll(raw.Terms, ctx)
+	}
+
+	if ctx.Condition == nil {
+		return false, fmt.Errorf("unsupported loot condition %s", c.Type)
+	}
+	return ctx.Condition(c, ctx)
+}
+
+// parseRange reads a vanilla int range, which is either a single value or an
+// object with an optional min and max.
+func parseRange(data json.RawMessage, ctx *Context) (float64, float64, error) {
+	if len(data) == 0 {
+		return math.Inf(-1), math.Inf(1), nil
+	}
+
+	var exact float64
+	if err := json.Unmarshal(data, &exact); err == nil {
+		return exact, exact, nil
+	}
+	var raw struct {
+		Min *NumberProvider `json:"min"`
+		Max *NumberProvider `json:"max"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return 0, 0, err
+	}
+
+	low, high := math.Inf(-1), math.Inf(1)
+	if raw.Min != nil {
+		low = float64(raw.Min.Int(ctx))
+	}
+	if raw.Max != nil {
+		high = float64(raw.Max.Int(ctx))
+	}
+	return low, high, nil
+}
+
+func applyFunctions(functions []Function, stacks []ItemStack, ctx *Context) ([]ItemStack, error) {
+	if len(functions) == 0 {
+		return stacks, nil
+	}
+
+	applied := stacks[:0]
+	for _, stack := range stacks {
+		for _, f := range functions {
+			var err error
+			if stack, err = f.Apply(stack, ctx); err != nil {
+				return nil, err
+			}
+		}
+		if stack.Count > 0 {
+			applied = append(applied, stack)
+		}
+	}
+	return applied, nil
+}
+
+// Apply runs the function on a stack, if its conditions pass. A stack whose
+// count drops to zero is dropped from the loot.
+func (f Function) Apply(stack ItemStack, ctx *Context) (ItemStack, error) {
+	if ok, err := testAll(f.Conditions, ctx); !ok || err != nil {
+		return stack, err
+	}
+
+	switch f.Type {
+	case "minecraft:set_count":
+		var raw struct {
+			Count *NumberProvider `json:"count"`
+			Add   bool            `json:"add"`
+		}
+		if err := json.Unmarshal(f.Raw, &raw); err != nil {
+			return stack, err
+		}
+		if raw.Count == nil {
+			return stack, fmt.Errorf("set_count has no count")
+		}
+		if raw.Add {
+			stack.Count += raw.Count.Int(ctx)
+		} else {
+			stack.Count = raw.Count.Int(ctx)
+		}
+		return stack, nil
+	case "minecraft:limit_count":
+		var raw struct {
+			Limit json.RawMessage `json:"limit"`
+		}
+		if err := json.Unmarshal(f.Raw, &raw); err != nil {
+			return stack, err
+		}
+		low, high, err := parseRange(raw.Limit, ctx)
+		if err != nil {
+			return stack, err
+		}
+		stack.Count = int(math.Min(math.Max(float64(stack.Count), low), high))
+		return stack, nil
+	case "minecraft:explosion_decay":
+		if ctx.ExplosionRadius <= 0 {
+			return stack, nil
+		}
+		survived := 0
+		for i := 0; i < stack.Count; i++ {
+			if ctx.Random.Float64() <= 1/ctx.ExplosionRadius {
+				survived++
+			}
+		}
+		stack.Count = survived
+		return stack, nil
+	case "minecraft:set_components":
+		var raw struct {
+			Components map[string]any `json:"components"`
+		}
+		if err := json.Unmarshal(f.Raw, &raw); err != nil {
+			return stack, err
+		}
+		components := make(map[string]any, len(stack.Components)+len(raw.Components))
+		for name, val := range stack.Components {
+			components[name] = val
+		}
+		// A leading ! removes the component instead.
+		for name, val := range raw.Components {
+			if removed, ok := strings.CutPrefix(name, "!"); ok {
+				delete(components, qualify(removed))
+			} else {
+				components[qualify(name)] = val
+			}
+		}
+		stack.Components = components
+		return stack, nil
+	}
+
+	if ctx.Function == nil {
+		return stack, nil
+	}
+	return ctx.Function(f, stack, ctx)
+}
@@ -0,0 +1,295 @@
+package lootutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ItemStack is a stack of items produced by a loot table. Components holds
+// any data functions attach to the stack, keyed by component name, for the
+// caller to encode however its protocol version needs.
+type ItemStack struct {
+	Item       string
+	Count      int
+	Components map[string]any
+}
+
+// NumberProvider is a vanilla number provider: a constant, or a value drawn
+// from a distribution each time it is used. Providers of other types, such
+// as score, are kept as Raw for a Context hook to evaluate and are written
+// back unchanged.
+type NumberProvider struct {
+	Type string
+	// Value is used by constant providers.
+	Value float64
+	// Min and Max are used by uniform providers.
+	Min, Max *NumberProvider
+	// N and P are used by binomial providers.
+	N, P *NumberProvider
+	// Raw is the full JSON object of a provider of another type.
+	Raw json.RawMessage
+}
+
+// Constant is a factory function for creating a constant NumberProvider.
+func Constant(val float64) *NumberProvider {
+	np := new(NumberProvider)
+	np.Type = "minecraft:constant"
+	np.Value = val
+	return np
+}
+
+func (np *NumberProvider) UnmarshalJSON(data []byte) error {
+	var val float64
+	if err := json.Unmarshal(data, &val); err == nil {
+		*np = *Constant(val)
+		return nil
+	}
+
+	var raw struct {
+		Type  string          `json:"type"`
+		Value float64         `json:"value"`
+		Min   *NumberProvider `json:"min"`
+		Max   *NumberProvider `json:"max"`
+		N     *NumberProvider `json:"n"`
+		P     *NumberProvider `json:"p"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	np.Type = qualify(raw.Type)
+	np.Value, np.Min, np.Max, np.N, np.P = raw.Value, raw.Min, raw.Max, raw.N, raw.P
+	// A bare {"min": ..., "max": ...} is a uniform distribution.
+	if raw.Type == "" && raw.Min != nil && raw.Max != nil {
+		np.Type = "minecraft:uniform"
+	}
+
+	switch np.Type {
+	case "minecraft:constant":
+	case "minecraft:uniform":
+		if np.Min == nil || np.Max == nil {
+			return fmt.Errorf("uniform number provider needs a min and max")
+		}
+	case "minecraft:binomial":
+		if np.N == nil || np.P == nil {
+			return fmt.Errorf("binomial number provider needs n and p")
+		}
+	case "":
+		return fmt.Errorf("number provider has no type")
+	default:
+		np.Raw = append(json.RawMessage(nil), data...)
+	}
+	return nil
+}
+
+func (np *NumberProvider) MarshalJSON() ([]byte, error) {
+	switch np.Type {
+	case "minecraft:constant":
+		return json.Marshal(np.Value)
+	case "minecraft:uniform":
+		return json.Marshal(map[string]any{"type": np.Type, "min": np.Min, "max": np.Max})
+	case "minecraft:binomial":
+		return json.Marshal(map[string]any{"type": np.Type, "n": np.N, "p": np.P})
+	}
+	if np.Raw == nil {
+		return nil, fmt.Errorf("number provider %q has no JSON to write", np.Type)
+	}
+	return np.Raw, nil
+}
+
+// Condition is a loot condition, also called a predicate. Raw holds the full
+// JSON object, so conditions the evaluator doesn't know can still be handled
+// by a Context hook and written back unchanged.
+type Condition struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+func (c *Condition) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Condition string `json:"condition"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Condition == "" {
+		return fmt.Errorf("loot condition has no type")
+	}
+	c.Type = qualify(raw.Condition)
+	c.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (c Condition) MarshalJSON() ([]byte, error) {
+	return c.Raw, nil
+}
+
+// Function is a loot function, which modifies produced stacks. Like
+// Condition, it keeps the full JSON object.
+type Function struct {
+	Type       string
+	Conditions []Condition
+	Raw        json.RawMessage
+}
+
+func (f *Function) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Function   string      `json:"function"`
+		Conditions []Condition `json:"conditions"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Function == "" {
+		return fmt.Errorf("loot function has no type")
+	}
+	f.Type = qualify(raw.Function)
+	f.Conditions = raw.Conditions
+	f.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (f Function) MarshalJSON() ([]byte, error) {
+	return f.Raw, nil
+}
+
+// Entry is a loot pool entry. Which fields are used depends on Type: item
+// entries use Name, tag entries use Name and Expand, loot_table entries use
+// Value or Inline, and the composite alternatives, group and sequence
+// entries use Children.
+type Entry struct {
+	Type  string `json:"type"`
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+	// Inline is a loot_table entry's table written out in place of its
+	// name, as 1.20.5 allows. Value is then empty.
+	Inline     *LootTable  `json:"-"`
+	Expand     bool        `json:"expand,omitempty"`
+	Weight     int         `json:"weight"`
+	Quality    int         `json:"quality,omitempty"`
+	Children   []*Entry    `json:"children,omitempty"`
+	Conditions []Condition `json:"conditions,omitempty"`
+	Functions  []Function  `json:"functions,omitempty"`
+}
+
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	type plain Entry
+	raw := struct {
+		*plain
+		Value json.RawMessage `json:"value"`
+	}{plain: (*plain)(e)}
+	// A missing weight is 1, as in vanilla; an explicit 0 is kept, and
+	// never picked.
+	e.Weight = 1
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw.Value) == 0 || string(raw.Value) == "null" {
+		return nil
+	}
+	if raw.Value[0] == '{' {
+		e.Inline = new(LootTable)
+		return json.Unmarshal(raw.Value, e.Inline)
+	}
+	return json.Unmarshal(raw.Value, &e.Value)
+}
+
+func (e Entry) MarshalJSON() ([]byte, error) {
+	type plain Entry
+	if e.Inline == nil {
+		return json.Marshal(plain(e))
+	}
+	return json.Marshal(struct {
+		plain
+		Value *LootTable `json:"value"`
+	}{plain(e), e.Inline})
+}
+
+// Pool is a loot pool, which rolls its entries a number of times.
+type Pool struct {
+	Rolls      *NumberProvider `json:"rolls"`
+	BonusRolls *NumberProvider `json:"bonus_rolls,omitempty"`
+	Entries    []*Entry        `json:"entries"`
+	Conditions []Condition     `json:"conditions,omitempty"`
+	Functions  []Function      `json:"functions,omitempty"`
+}
+
+// LootTable is a vanilla loot table, as found in data packs under
+// data/<namespace>/loot_table.
+type LootTable struct {
+	Type           string     `json:"type,omitempty"`
+	Pools          []*Pool    `json:"pools,omitempty"`
+	Functions      []Function `json:"functions,omitempty"`
+	RandomSequence string     `json:"random_sequence,omitempty"`
+}
+
+// ParseLootTable reads a loot table from JSON.
+func ParseLootTable(r io.Reader) (*LootTable, error) {
+	lt := new(LootTable)
+	if err := json.NewDecoder(r).Decode(lt); err != nil {
+		return nil, err
+	}
+	if err := lt.normalise(); err != nil {
+		return nil, err
+	}
+	return lt, nil
+}
+
+func (lt *LootTable) normalise() error {
+	for i, pool := range lt.Pools {
+		if pool.Rolls == nil {
+			return fmt.Errorf("pool %d has no rolls", i)
+		}
+		for _, entry := range pool.Entries {
+			if err := entry.normalise(); err != nil {
+				return fmt.Errorf("pool %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Entry) normalise() error {
+	if e.Type == "" {
+		return fmt.Errorf("loot entry has no type")
+	}
+	e.Type = qualify(e.Type)
+	if e.Name != "" {
+		e.Name = qualify(e.Name)
+	}
+
+	switch e.Type {
+	case "minecraft:item", "minecraft:tag":
+		if e.Name == "" {
+			return fmt.Errorf("%s entry has no name", e.Type)
+		}
+	case "minecraft:loot_table":
+		if e.Inline != nil {
+			if err := e.Inline.normalise(); err != nil {
+				return fmt.Errorf("inline loot table: %w", err)
+			}
+			break
+		}
+		if e.Value == "" {
+			return fmt.Errorf("loot_table entry has no value")
+		}
+		e.Value = qualify(e.Value)
+	}
+
+	for _, child := range e.Children {
+		if err := child.normalise(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// qualify adds the minecraft namespace to identifiers that have none.
+func qualify(id string) string {
+	if id == "" || strings.Contains(id, ":") {
+		return id
+	}
+	return "minecraft:" + id
+}
@@ -0,0 +1,148 @@
+package movementutil
+
+import (
+	"math"
+	"sync"
+)
+
+// DefaultResendTicks is how long vanilla waits for a teleport to be confirmed
+// before sending it again.
+const DefaultResendTicks = 20
+
+// PendingTeleport is a Synchronize Player Position that the client has not
+// confirmed yet.
+type PendingTeleport struct {
+	ID         int32
+	X, Y, Z    float64
+	Yaw, Pitch float32
+	// Attempts counts how many times the teleport has been sent.
+	Attempts int
+}
+
+// TeleportResult tells the caller what to do after a tick.
+type TeleportResult int
+
+const (
+	// TeleportIdle means nothing needs to be done.
+	TeleportIdle TeleportResult = iota
+	// TeleportResend means the pending teleport has been given a new ID and
+	// must be sent to the client again.
+	TeleportResend
+	// TeleportTimedOut means the client failed to confirm the teleport in
+	// time, and should be disconnected.
+	TeleportTimedOut
+)
+
+// TeleportTracker follows the teleport confirmation handshake of a single
+// player, matching vanilla: every Synchronize Player Position gets a fresh
+// teleport ID, movement packets are ignored until the client confirms that ID
+// with Confirm Teleportation, and unconfirmed teleports are sent again every
+// ResendTicks ticks. A TeleportTracker is safe for concurrent use.
+type TeleportTracker struct {
+	lock    sync.Mutex
+	nextID  int32
+	pending *PendingTeleport
+	waited  int
+
+	// ResendTicks is how many ticks to wait for a confirmation before
+	// sending the teleport again.
+	ResendTicks int
+	// MaxAttempts is how many times a teleport is sent before the client is
+	// considered to be misbehaving. Zero means it is resent forever, which
+	// is what vanilla does.
+	MaxAttempts int
+}
+
+// CreateTeleportTracker is a factory function for creating a new
+// TeleportTracker that gives up on a client after maxAttempts unconfirmed
+// sends, or never if maxAttempts is zero.
+func CreateTeleportTracker(maxAttempts int) *TeleportTracker {
+	tt := new(TeleportTracker)
+	tt.ResendTicks = DefaultResendTicks
+	tt.MaxAttempts = maxAttempts
+	return tt
+}
+
+// Teleport registers a new teleport, replacing any that is still pending, and
+// returns it. Its ID goes in the Synchronize Player Position packet.
+func (tt *TeleportTracker) Teleport(x, y, z float64, yaw, pitch float32) *PendingTeleport {
+	tt.lock.Lock()
+	defer tt.lock.Unlock()
+
+	tt.pending = &PendingTeleport{X: x, Y: y, Z: z, Yaw: yaw, Pitch: pitch}
+	tt.issue()
+	return tt.copyPending()
+}
+
+// issue hands the pending teleport the next ID, wrapping back to zero the way
+// vanilla does once it reaches the largest int.
+func (tt *TeleportTracker) issue() {
+	tt.nextID++
+	if tt.nextID == math.MaxInt32 {
+		tt.nextID = 0
+	}
+	tt.pending.ID = tt.nextID
+	tt.pending.Attempts++
+	tt.waited = 0
+}
+
+func (tt *TeleportTracker) copyPending() *PendingTeleport {
+	pending := *tt.pending
+	return &pending
+}
+
+// Confirm handles a Confirm Teleportation packet. It returns the teleport the
+// ID confirmed, so the caller can move the player to it, or false if the ID
+// doesn't belong to the pending teleport; vanilla silently ignores those.
+func (tt *TeleportTracker) Confirm(id int32) (*PendingTeleport, bool) {
+	tt.lock.Lock()
+	defer tt.lock.Unlock()
+
+	if tt.pending == nil || tt.pending.ID != id {
+		return nil, false
+	}
+	confirmed := tt.pending
+	tt.pending = nil
+	return confirmed, true
+}
+
+// Pending returns the teleport that is waiting to be confirmed, if any.
+func (tt *TeleportTracker) Pending() (*PendingTeleport, bool) {
+	tt.lock.Lock()
+	defer tt.lock.Unlock()
+
+	if tt.pending == nil {
+		return nil, false
+	}
+	return tt.copyPending(), true
+}
+
+// AcceptsMovement reports whether movement packets from the client should be
+// processed. While a teleport is pending they are stale, and vanilla drops
+// them.
+func (tt *TeleportTracker) AcceptsMovement() bool {
+	tt.lock.Lock()
+	defer tt.lock.Unlock()
+	return tt.pending == nil
+}
+
+// Tick must be called once every game tick. When it returns TeleportResend,
+// the teleport returned by Pending must be sent again.
+func (tt *TeleportTracker) Tick() TeleportResult {
+	tt.lock.Lock()
+	defer tt.lock.Unlock()
+
+	if tt.pending == nil {
+		return TeleportIdle
+	}
+
+	tt.waited++
+	if tt.waited <= tt.ResendTicks {
+		return TeleportIdle
+	}
+	if tt.MaxAttempts > 0 && tt.pending.Attempts >= tt.MaxAttempts {
+		return TeleportTimedOut
+	}
+	tt.issue()
+	return TeleportResend
+}
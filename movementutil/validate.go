@@ -0,0 +1,240 @@
+package movementutil
+
+import (
+	"fmt"
+	"math"
+)
+
+// Coordinate limits past which vanilla refuses a position outright.
+const (
+	MaxHorizontalCoordinate = 3.0e7
+	MaxVerticalCoordinate   = 2.0e7
+)
+
+// DefaultMaxDistancePerTick matches vanilla's "moved too quickly" check, which
+// allows a squared distance of 100 per tick for a player that isn't gliding.
+const DefaultMaxDistancePerTick = 10.0
+
+// DefaultMaxAirTicks matches vanilla's "flying is not enabled" check, which
+// fires once a player has hovered for more than 80 ticks.
+const DefaultMaxAirTicks = 80
+
+// maxCatchUpTicks caps how many ticks of movement a single packet may cover,
+// so a client that goes quiet can't bank distance to spend later.
+const maxCatchUpTicks = 5
+
+// Move is the content of a serverbound movement packet. Position and rotation
+// are only checked when the packet carries them.
+type Move struct {
+	X, Y, Z     float64
+	Yaw, Pitch  float32
+	HasPosition bool
+	HasRotation bool
+	OnGround    bool
+}
+
+// ViolationKind classifies a failed check.
+type ViolationKind int
+
+const (
+	// InvalidNumber means a coordinate or angle was NaN or infinite.
+	InvalidNumber ViolationKind = iota
+	// OutOfWorld means the position is outside the coordinates vanilla
+	// accepts, or outside the configured world bounds.
+	OutOfWorld
+	// MovedTooFast means the position is further from the last accepted one
+	// than the per-tick limit allows.
+	MovedTooFast
+	// Flying means the player stayed in the air for too long.
+	Flying
+	// Custom is used by checks supplied by the caller.
+	Custom
+)
+
+func (k ViolationKind) String() string {
+	switch k {
+	case InvalidNumber:
+		return "invalid number"
+	case OutOfWorld:
+		return "out of world"
+	case MovedTooFast:
+		return "moved too fast"
+	case Flying:
+		return "flying"
+	case Custom:
+		return "custom"
+	}
+	return fmt.Sprintf("ViolationKind(%d)", int(k))
+}
+
+// Violation describes a movement packet that failed a check.
+type Violation struct {
+	Kind   ViolationKind
+	Detail string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Kind, v.Detail)
+}
+
+// MovementConfig holds the limits a MovementValidator checks against.
+type MovementConfig struct {
+	// MaxDistancePerTick is the furthest a player may move in one tick.
+	// Zero disables the check.
+	MaxDistancePerTick float64
+	// MinY and MaxY bound the world vertically. If both are zero only the
+	// vanilla coordinate limits apply.
+	MinY, MaxY float64
+	// MaxAirTicks is how many consecutive ticks a player may spend off the
+	// ground without descending. Zero disables the check.
+	MaxAirTicks int
+	// AllowFlight disables the air time check, for players in creative or
+	// spectator mode or with the may-fly ability.
+	AllowFlight bool
+}
+
+// DefaultMovementConfig returns the limits vanilla applies to a survival
+// player.
+func DefaultMovementConfig() MovementConfig {
+	return MovementConfig{
+		MaxDistancePerTick: DefaultMaxDistancePerTick,
+		MaxAirTicks:        DefaultMaxAirTicks,
+	}
+}
+
+// FlightCheck is an additional check run against every move after the
+// built-in ones. It receives the last accepted move and how many ticks the
+// player has been airborne, and returns nil if the move is acceptable.
+type FlightCheck func(previous, next Move, airTicks int) *Violation
+
+// MovementValidator sanity checks the serverbound movement packets of a
+// single player. It only reports violations; what to do about them, whether
+// to teleport the player back or to disconnect them, is up to the caller.
+type MovementValidator struct {
+	Config MovementConfig
+	// Checks are run, in order, after the built-in checks pass.
+	Checks []FlightCheck
+
+	last     Move
+	hasLast  bool
+	ticks    int
+	airTicks int
+	// lastGroundY is the height the player last stood at, or the lowest
+	// point they have since fallen to.
+	lastGroundY float64
+}
+
+// CreateMovementValidator is a factory function for creating a new
+// MovementValidator with the given limits.
+func CreateMovementValidator(config MovementConfig) *MovementValidator {
+	mv := new(MovementValidator)
+	mv.Config = config
+	return mv
+}
+
+// Reset sets the player's known position, so the next move is measured from
+// there. Call it once the player has spawned, and again whenever a teleport
+// has been confirmed.
+func (mv *MovementValidator) Reset(x, y, z float64) {
+	mv.last = Move{X: x, Y: y, Z: z, HasPosition: true}
+	mv.hasLast = true
+	mv.ticks = 0
+	mv.airTicks = 0
+	mv.lastGroundY = y
+}
+
+// Tick must be called once every game tick; it advances the distance budget
+// and the air time counter.
+func (mv *MovementValidator) Tick() {
+	mv.ticks++
+	if !mv.last.OnGround {
+		mv.airTicks++
+	}
+}
+
+// Validate checks a movement packet. If it returns no violations the move is
+// remembered as the player's new position; otherwise the previous position is
+// kept, so the caller can teleport the player back to it.
+func (mv *MovementValidator) Validate(m Move) []Violation {
+	var violations []Violation
+
+	if m.HasPosition {
+		for _, coord := range []float64{m.X, m.Y, m.Z} {
+			if math.IsNaN(coord) || math.IsInf(coord, 0) {
+				violations = append(violations, Violation{InvalidNumber, "position is not a finite number"})
+				return violations
+			}
+		}
+	}
+	if m.HasRotation {
+		for _, angle := range []float32{m.Yaw, m.Pitch} {
+			if math.IsNaN(float64(angle)) || math.IsInf(float64(angle), 0) {
+				violations = append(violations, Violation{InvalidNumber, "rotation is not a finite number"})
+				return violations
+			}
+		}
+	}
+
+	if m.HasPosition {
+		if math.Abs(m.X) > MaxHorizontalCoordinate || math.Abs(m.Z) > MaxHorizontalCoordinate || math.Abs(m.Y) > MaxVerticalCoordinate {
+			violations = append(violations, Violation{OutOfWorld, fmt.Sprintf("position %.2f, %.2f, %.2f is outside the world", m.X, m.Y, m.Z)})
+		} else if (mv.Config.MinY != 0 || mv.Config.MaxY != 0) && (m.Y < mv.Config.MinY || m.Y > mv.Config.MaxY) {
+			violations = append(violations, Violation{OutOfWorld, fmt.Sprintf("y of %.2f is outside %.0f to %.0f", m.Y, mv.Config.MinY, mv.Config.MaxY)})
+		}
+
+		if mv.hasLast && mv.Config.MaxDistancePerTick > 0 {
+			ticks := min(max(mv.ticks, 1), maxCatchUpTicks)
+			limit := mv.Config.MaxDistancePerTick * float64(ticks)
+			dx, dy, dz := m.X-mv.last.X, m.Y-mv.last.Y, m.Z-mv.last.Z
+			if distance := math.Sqrt(dx*dx + dy*dy + dz*dz); distance > limit {
+				violations = append(violations, Violation{MovedTooFast, fmt.Sprintf("moved %.2f blocks, the limit is %.2f", distance, limit)})
+			}
+		}
+	}
+
+	airTicks := mv.airTicks
+	if m.OnGround {
+		airTicks = 0
+	} else if m.HasPosition && m.Y < mv.lastGroundY {
+		// Falling is not flying; measure hovering from the lowest point.
+		airTicks = 0
+	}
+	if !mv.Config.AllowFlight && mv.Config.MaxAirTicks > 0 && airTicks > mv.Config.MaxAirTicks {
+		violations = append(violations, Violation{Flying, fmt.Sprintf("airborne for %d ticks", airTicks)})
+	}
+
+	if len(violations) == 0 {
+		for _, check := range mv.Checks {
+			if violation := check(mv.last, m, airTicks); violation != nil {
+				violations = append(violations, *violation)
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return violations
+	}
+
+	mv.accept(m, airTicks)
+	return nil
+}
+
+func (mv *MovementValidator) accept(m Move, airTicks int) {
+	next := mv.last
+	if m.HasPosition {
+		next.X, next.Y, next.Z = m.X, m.Y, m.Z
+		next.HasPosition = true
+		mv.ticks = 0
+		if m.OnGround || m.Y < mv.lastGroundY {
+			mv.lastGroundY = m.Y
+		}
+	}
+	if m.HasRotation {
+		next.Yaw, next.Pitch = m.Yaw, m.Pitch
+		next.HasRotation = true
+	}
+	next.OnGround = m.OnGround
+
+	mv.last = next
+	mv.hasLast = mv.hasLast || m.HasPosition
+	mv.airTicks = airTicks
+}
@@ -0,0 +1,223 @@
+package nbtutil
+
+import "fmt"
+
+// Tag type IDs, as they appear on the wire.
+const (
+	TagEnd byte = iota
+	TagByte
+	TagShort
+	TagInt
+	TagLong
+	TagFloat
+	TagDouble
+	TagByteArray
+	TagString
+	TagList
+	TagCompound
+	TagIntArray
+	TagLongArray
+)
+
+// MaxDepth is how deeply lists and compounds may nest, matching vanilla's
+// limit.
+const MaxDepth = 512
+
+// Compound is an NBT compound tag. Values are held as the Go type matching
+// their tag:
+//
+//	TagByte       int8
+//	TagShort      int16
+//	TagInt        int32
+//	TagLong       int64
+//	TagFloat      float32
+//	TagDouble     float64
+//	TagByteArray  []byte
+//	TagString     string
+//	TagList       List
+//	TagCompound   Compound
+//	TagIntArray   []int32
+//	TagLongArray  []int64
+type Compound map[string]any
+
+// List is an NBT list tag. The element type is kept alongside the elements,
+// so an empty list that was read keeps the type it was sent with, but like
+// vanilla the writer always writes an empty list as a list of TagEnd.
+type List struct {
+	Type     byte
+	Elements []any
+}
+
+// CreateList is a factory function for creating a List from its elements,
+// inferring the element type from the first one. It panics if the elements
+// are not all of the same NBT type.
+func CreateList(elements ...any) List {
+	list := List{Type: TagEnd, Elements: elements}
+	for i, element := range elements {
+		tagType, ok := TypeOf(element)
+		if !ok {
+			panic(fmt.Sprintf("nbtutil: %T is not an NBT value", element))
+		}
+		if i == 0 {
+			list.Type = tagType
+		} else if tagType != list.Type {
+			panic(fmt.Sprintf("nbtutil: list mixes tag types %d and %d", list.Type, tagType))
+		}
+	}
+	return list
+}
+
+// TypeOf returns the tag type a Go value is encoded as, or false if it has
+// no NBT equivalent.
+func TypeOf(val any) (byte, bool) {
+	switch val.(type) {
+	case int8:
+		return TagByte, true
+	case int16:
+		return TagShort, true
+	case int32:
+		return TagInt, true
+	case int64:
+		return TagLong, true
+	case float32:
+		return TagFloat, true
+	case float64:
+		return TagDouble, true
+	case []byte:
+		return TagByteArray, true
+	case string:
+		return TagString, true
+	case List:
+		return TagList, true
+	case Compound:
+		return TagCompound, true
+	case []int32:
+		return TagIntArray, true
+	case []int64:
+		return TagLongArray, true
+	}
+	return TagEnd, false
+}
+
+// Byte returns the named byte tag, and whether it was present with that type.
+func (c Compound) Byte(name string) (int8, bool) {
+	val, ok := c[name].(int8)
+	return val, ok
+}
+
+// Bool returns the named byte tag interpreted as a boolean, the way vanilla
+// stores flags.
+func (c Compound) Bool(name string) (bool, bool) {
+	val, ok := c[name].(int8)
+	return val != 0, ok
+}
+
+// Short returns the named short tag.
+func (c Compound) Short(name string) (int16, bool) {
+	val, ok := c[name].(int16)
+	return val, ok
+}
+
+// Int returns the named int tag.
+func (c Compound) Int(name string) (int32, bool) {
+	val, ok := c[name].(int32)
+	return val, ok
+}
+
+// Long returns the named long tag.
+func (c Compound) Long(name string) (int64, bool) {
+	val, ok := c[name].(int64)
+	return val, ok
+}
+
+// Float returns the named float tag.
+func (c Compound) Float(name string) (float32, bool) {
+	val, ok := c[name].(float32)
+	return val, ok
+}
+
+// Double returns the named double tag.
+func (c Compound) Double(name string) (float64, bool) {
+	val, ok := c[name].(float64)
+	return val, ok
+}
+
+// String returns the named string tag.
+func (c Compound) String(name string) (string, bool) {
+	val, ok := c[name].(string)
+	return val, ok
+}
+
+// ByteArray returns the named byte array tag.
+func (c Compound) ByteArray(name string) ([]byte, bool) {
+	val, ok := c[name].([]byte)
+	return val, ok
+}
+
+// IntArray returns the named int array tag.
+func (c Compound) IntArray(name string) ([]int32, bool) {
+	val, ok := c[name].([]int32)
+	return val, ok
+}
+
+// LongArray returns the named long array tag.
+func (c Compound) LongArray(name string) ([]int64, bool) {
+	val, ok := c[name].([]int64)
+	return val, ok
+}
+
+// List returns the named list tag.
+func (c Compound) List(name string) (List, bool) {
+	val, ok := c[name].(List)
+	return val, ok
+}
+
+// Compound returns the named compound tag.
+func (c Compound) Compound(name string) (Compound, bool) {
+	val, ok := c[name].(Compound)
+	return val, ok
+}
+
+// Compounds returns the elements of the named list of compounds. It returns
+// false if the tag is missing or holds anything other than compounds; an
+// empty list of any type is accepted.
+func (c Compound) Compounds(name string) ([]Compound, bool) {
+	list, ok := c.List(name)
+	if !ok || (list.Type != TagCompound && len(list.Elements) > 0) {
+		return nil, false
+	}
+	compounds := make([]Compound, len(list.Elements))
+	for i, element := range list.Elements {
+		compounds[i] = element.(Compound)
+	}
+	return compounds, true
+}
+
+// Clone returns a deep copy of the compound.
+func (c Compound) Clone() Compound {
+	return cloneValue(c).(Compound)
+}
+
+func cloneValue(val any) any {
+	switch v := val.(type) {
+	case Compound:
+		clone := make(Compound, len(v))
+		for name, child := range v {
+			clone[name] = cloneValue(child)
+		}
+		return clone
+	case List:
+		elements := make([]any, len(v.Elements))
+		for i, element := range v.Elements {
+			elements[i] = cloneValue(element)
+		}
+		return List{v.Type, elements}
+	case []byte:
+		return append([]byte(nil), v...)
+	case []int32:
+		return append([]int32(nil), v...)
+	case []int64:
+		return append([]int64(nil), v...)
+	}
+	return val
+}
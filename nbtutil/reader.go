@@ -0,0 +1,362 @@
+package nbtutil
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf16"
+)
+
+// DefaultMaxBytes is the limit a Reader starts with: 100 MiB, the most
+// vanilla accepts when reading an NBT file.
+const DefaultMaxBytes = 104857600
+
+// Unlimited turns off a Reader's limit, for data that is trusted.
+const Unlimited = -1
+
+// Reader decodes NBT from a stream.
+type Reader struct {
+	reader io.Reader
+	buff   [8]byte
+	// MaxBytes caps the total size of the arrays, strings and lists read,
+	// so a hostile length prefix can't exhaust memory. It starts at
+	// DefaultMaxBytes, as does zero; set it to Unlimited for no limit.
+	MaxBytes int64
+	budget   int64
+}
+
+// CreateReader is a factory function for creating a new Reader, limited to
+// DefaultMaxBytes.
+func CreateReader(r io.Reader) *Reader {
+	nr := new(Reader)
+	nr.reader = r
+	nr.MaxBytes = DefaultMaxBytes
+	return nr
+}
+
+// limit returns the budget a read starts with, or a negative number for
+// none.
+func (nr *Reader) limit() int64 {
+	if nr.MaxBytes == 0 {
+		return DefaultMaxBytes
+	}
+	return nr.MaxBytes
+}
+
+// ReadNamed reads a root compound with a name, the form used in files and in
+// packets before 1.20.2. A lone TagEnd, which vanilla sends for "no NBT",
+// returns a nil compound.
+func (nr *Reader) ReadNamed() (string, Compound, error) {
+	nr.budget = nr.limit()
+	tagType, err := nr.readByte()
+	if err != nil {
+		return "", nil, err
+	}
+	if tagType == TagEnd {
+		return "", nil, nil
+	}
+	if tagType != TagCompound {
+		return "", nil, fmt.Errorf("root tag has type %d, expected a compound", tagType)
+	}
+
+	name, err := nr.readString()
+	if err != nil {
+		return "", nil, err
+	}
+	root, err := nr.readCompound(0)
+	return name, root, err
+}
+
+// ReadNetwork reads a root compound without a name, the form used in packets
+// since 1.20.2. A lone TagEnd returns a nil compound.
+func (nr *Reader) ReadNetwork() (Compound, error) {
+	nr.budget = nr.limit()
+	tagType, err := nr.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if tagType == TagEnd {
+		return nil, nil
+	}
+	if tagType != TagCompound {
+		return nil, fmt.Errorf("root tag has type %d, expected a compound", tagType)
+	}
+	return nr.readCompound(0)
+}
+
+// ReadFile reads a named root compound from r, decompressing it first if it
+// is gzip or zlib compressed, as level.dat, player data and structure files
+// are.
+func ReadFile(r io.Reader) (string, Compound, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var source io.Reader = br
+	switch {
+	case magic[0] == 0x1F && magic[1] == 0x8B:
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return "", nil, err
+		}
+		defer gz.Close()
+		source = gz
+	case magic[0] == 0x78:
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return "", nil, err
+		}
+		defer zr.Close()
+		source = zr
+	}
+	return CreateReader(source).ReadNamed()
+}
+
+// Unmarshal reads a named root compound from data.
+func Unmarshal(data []byte) (string, Compound, error) {
+	return CreateReader(bytes.NewReader(data)).ReadNamed()
+}
+
+func (nr *Reader) spend(size int64) error {
+	if nr.MaxBytes < 0 {
+		return nil
+	}
+	nr.budget -= size
+	if nr.budget < 0 {
+		return fmt.Errorf("nbt data exceeds the limit of %d bytes", nr.limit())
+	}
+	return nil
+}
+
+func (nr *Reader) readFull(size int) ([]byte, error) {
+	if size <= len(nr.buff) {
+		_, err := io.ReadFull(nr.reader, nr.buff[:size])
+		return nr.buff[:size], err
+	}
+	data := make([]byte, size)
+	_, err := io.ReadFull(nr.reader, data)
+	return data, err
+}
+
+func (nr *Reader) readByte() (byte, error) {
+	data, err := nr.readFull(1)
+	if err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+func (nr *Reader) readUint16() (uint16, error) {
+	data, err := nr.readFull(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(data), nil
+}
+
+func (nr *Reader) readUint32() (uint32, error) {
+	data, err := nr.readFull(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(data), nil
+}
+
+func (nr *Reader) readUint64() (uint64, error) {
+	data, err := nr.readFull(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+func (nr *Reader) readLength() (int, error) {
+	length, err := nr.readUint32()
+	if err != nil {
+		return 0, err
+	}
+	if int32(length) < 0 {
+		return 0, fmt.Errorf("negative nbt length of %d", int32(length))
+	}
+	return int(length), nil
+}
+
+func (nr *Reader) readString() (string, error) {
+	length, err := nr.readUint16()
+	if err != nil {
+		return "", err
+	}
+	if err := nr.spend(int64(length)); err != nil {
+		return "", err
+	}
+	data, err := nr.readFull(int(length))
+	if err != nil {
+		return "", err
+	}
+	return decodeModifiedUTF8(data), nil
+}
+
+func (nr *Reader) readCompound(depth int) (Compound, error) {
+	if depth >= MaxDepth {
+		return nil, fmt.Errorf("nbt is nested more than %d levels deep", MaxDepth)
+	}
+
+	compound := make(Compound)
+	for {
+		tagType, err := nr.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if tagType == TagEnd {
+			return compound, nil
+		}
+
+		name, err := nr.readString()
+		if err != nil {
+			return nil, err
+		}
+		val, err := nr.readPayload(tagType, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		compound[name] = val
+	}
+}
+
+func (nr *Reader) readPayload(tagType byte, depth int) (any, error) {
+	switch tagType {
+	case TagByte:
+		val, err := nr.readByte()
+		return int8(val), err
+	case TagShort:
+		val, err := nr.readUint16()
+		return int16(val), err
+	case TagInt:
+		val, err := nr.readUint32()
+		return int32(val), err
+	case TagLong:
+		val, err := nr.readUint64()
+		return int64(val), err
+	case TagFloat:
+		val, err := nr.readUint32()
+		return math.Float32frombits(val), err
+	case TagDouble:
+		val, err := nr.readUint64()
+		return math.Float64frombits(val), err
+	case TagByteArray:
+		length, err := nr.readLength()
+		if err != nil {
+			return nil, err
+		}
+		if err := nr.spend(int64(length)); err != nil {
+			return nil, err
+		}
+		data := make([]byte, length)
+		_, err = io.ReadFull(nr.reader, data)
+		return data, err
+	case TagString:
+		return nr.readString()
+	case TagList:
+		return nr.readList(depth)
+	case TagCompound:
+		return nr.readCompound(depth)
+	case TagIntArray:
+		length, err := nr.readLength()
+		if err != nil {
+			return nil, err
+		}
+		if err := nr.spend(int64(length) * 4); err != nil {
+			return nil, err
+		}
+		data := make([]int32, length)
+		for i := range data {
+			val, err := nr.readUint32()
+			if err != nil {
+				return nil, err
+			}
+			data[i] = int32(val)
+		}
+		return data, nil
+	case TagLongArray:
+		length, err := nr.readLength()
+		if err != nil {
+			return nil, err
+		}
+		if err := nr.spend(int64(length) * 8); err != nil {
+			return nil, err
+		}
+		data := make([]int64, length)
+		for i := range data {
+			val, err := nr.readUint64()
+			if err != nil {
+				return nil, err
+			}
+			data[i] = int64(val)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unknown nbt tag type %d", tagType)
+}
+
+func (nr *Reader) readList(depth int) (List, error) {
+	if depth >= MaxDepth {
+		return List{}, fmt.Errorf("nbt is nested more than %d levels deep", MaxDepth)
+	}
+
+	elementType, err := nr.readByte()
+	if err != nil {
+		return List{}, err
+	}
+	length, err := nr.readLength()
+	if err != nil {
+		return List{}, err
+	}
+	if elementType == TagEnd && length > 0 {
+		return List{}, fmt.Errorf("list of %d end tags", length)
+	}
+	// Every element takes at least a byte, which bounds hostile lengths.
+	if err := nr.spend(int64(length)); err != nil {
+		return List{}, err
+	}
+
+	list := List{Type: elementType}
+	for i := 0; i < length; i++ {
+		val, err := nr.readPayload(elementType, depth+1)
+		if err != nil {
+			return List{}, err
+		}
+		list.Elements = append(list.Elements, val)
+	}
+	return list, nil
+}
+
+// decodeModifiedUTF8 decodes Java's modified UTF-8, which encodes NUL as two
+// bytes and characters outside the BMP as UTF-16 surrogate pairs.
+func decodeModifiedUTF8(data []byte) string {
+	units := make([]uint16, 0, len(data))
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c < 0x80:
+			units = append(units, uint16(c))
+			i++
+		case c&0xE0 == 0xC0 && i+1 < len(data):
+			units = append(units, uint16(c&0x1F)<<6|uint16(data[i+1]&0x3F))
+			i += 2
+		case c&0xF0 == 0xE0 && i+2 < len(data):
+			units = append(units, uint16(c&0x0F)<<12|uint16(data[i+1]&0x3F)<<6|uint16(data[i+2]&0x3F))
+			i += 3
+		default:
+			units = append(units, 0xFFFD)
+			i++
+		}
+	}
+	return string(utf16.Decode(units))
+}
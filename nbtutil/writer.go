@@ -0,0 +1,210 @@
+package nbtutil
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"unicode/utf16"
+)
+
+// Writer encodes NBT to a stream. Compound entries are written in sorted
+// order, so the same data always encodes to the same bytes.
+type Writer struct {
+	buff bytes.Buffer
+}
+
+// CreateWriter is a factory function for creating a new Writer.
+func CreateWriter() *Writer {
+	return new(Writer)
+}
+
+// Bytes returns everything written so far.
+func (nw *Writer) Bytes() []byte {
+	return nw.buff.Bytes()
+}
+
+// WriteNamed writes a root compound with a name. A nil compound is written as
+// a lone TagEnd.
+func (nw *Writer) WriteNamed(name string, root Compound) error {
+	if root == nil {
+		nw.buff.WriteByte(TagEnd)
+		return nil
+	}
+	nw.buff.WriteByte(TagCompound)
+	if err := nw.writeString(name); err != nil {
+		return err
+	}
+	return nw.writeCompound(root, 0)
+}
+
+// WriteNetwork writes a root compound without a name, as packets have done
+// since 1.20.2. A nil compound is written as a lone TagEnd.
+func (nw *Writer) WriteNetwork(root Compound) error {
+	if root == nil {
+		nw.buff.WriteByte(TagEnd)
+		return nil
+	}
+	nw.buff.WriteByte(TagCompound)
+	return nw.writeCompound(root, 0)
+}
+
+// Marshal encodes a named root compound.
+func Marshal(name string, root Compound) ([]byte, error) {
+	nw := CreateWriter()
+	if err := nw.WriteNamed(name, root); err != nil {
+		return nil, err
+	}
+	return nw.Bytes(), nil
+}
+
+// WriteFile writes a gzip-compressed named root compound to w, the form used
+// for level.dat, player data and structure files.
+func WriteFile(w io.Writer, name string, root Compound) error {
+	data, err := Marshal(name, root)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	gz := gzip.NewWriter(bw)
+	if _, err := gz.Write(data); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (nw *Writer) writeUint16(val uint16) {
+	nw.buff.Write(binary.BigEndian.AppendUint16(nil, val))
+}
+
+func (nw *Writer) writeUint32(val uint32) {
+	nw.buff.Write(binary.BigEndian.AppendUint32(nil, val))
+}
+
+func (nw *Writer) writeUint64(val uint64) {
+	nw.buff.Write(binary.BigEndian.AppendUint64(nil, val))
+}
+
+func (nw *Writer) writeString(val string) error {
+	encoded := encodeModifiedUTF8(val)
+	if len(encoded) > math.MaxUint16 {
+		return fmt.Errorf("nbt string of %d bytes is too long", len(encoded))
+	}
+	nw.writeUint16(uint16(len(encoded)))
+	nw.buff.Write(encoded)
+	return nil
+}
+
+func (nw *Writer) writeCompound(compound Compound, depth int) error {
+	if depth >= MaxDepth {
+		return fmt.Errorf("nbt is nested more than %d levels deep", MaxDepth)
+	}
+
+	names := make([]string, 0, len(compound))
+	for name := range compound {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		val := compound[name]
+		tagType, ok := TypeOf(val)
+		if !ok {
+			return fmt.Errorf("value of %q is a %T, which has no nbt type", name, val)
+		}
+		nw.buff.WriteByte(tagType)
+		if err := nw.writeString(name); err != nil {
+			return err
+		}
+		if err := nw.writePayload(val, depth+1); err != nil {
+			return err
+		}
+	}
+	nw.buff.WriteByte(TagEnd)
+	return nil
+}
+
+func (nw *Writer) writePayload(val any, depth int) error {
+	switch v := val.(type) {
+	case int8:
+		nw.buff.WriteByte(byte(v))
+	case int16:
+		nw.writeUint16(uint16(v))
+	case int32:
+		nw.writeUint32(uint32(v))
+	case int64:
+		nw.writeUint64(uint64(v))
+	case float32:
+		nw.writeUint32(math.Float32bits(v))
+	case float64:
+		nw.writeUint64(math.Float64bits(v))
+	case []byte:
+		nw.writeUint32(uint32(len(v)))
+		nw.buff.Write(v)
+	case string:
+		return nw.writeString(v)
+	case List:
+		return nw.writeList(v, depth)
+	case Compound:
+		return nw.writeCompound(v, depth)
+	case []int32:
+		nw.writeUint32(uint32(len(v)))
+		for _, element := range v {
+			nw.writeUint32(uint32(element))
+		}
+	case []int64:
+		nw.writeUint32(uint32(len(v)))
+		for _, element := range v {
+			nw.writeUint64(uint64(element))
+		}
+	default:
+		return fmt.Errorf("%T has no nbt type", val)
+	}
+	return nil
+}
+
+func (nw *Writer) writeList(list List, depth int) error {
+	if depth >= MaxDepth {
+		return fmt.Errorf("nbt is nested more than %d levels deep", MaxDepth)
+	}
+
+	elementType := list.Type
+	if len(list.Elements) == 0 {
+		elementType = TagEnd
+	}
+	nw.buff.WriteByte(elementType)
+	nw.writeUint32(uint32(len(list.Elements)))
+	for i, element := range list.Elements {
+		if tagType, _ := TypeOf(element); tagType != list.Type {
+			return fmt.Errorf("element %d of a list of type %d is a %T", i, list.Type, element)
+		}
+		if err := nw.writePayload(element, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeModifiedUTF8 encodes s as Java's modified UTF-8.
+func encodeModifiedUTF8(s string) []byte {
+	encoded := make([]byte, 0, len(s))
+	for _, unit := range utf16.Encode([]rune(s)) {
+		switch {
+		case unit != 0 && unit < 0x80:
+			encoded = append(encoded, byte(unit))
+		case unit < 0x800:
+			encoded = append(encoded, 0xC0|byte(unit>>6), 0x80|byte(unit&0x3F))
+		default:
+			encoded = append(encoded, 0xE0|byte(unit>>12), 0x80|byte(unit>>6&0x3F), 0x80|byte(unit&0x3F))
+		}
+	}
+	return encoded
+}
@@ -0,0 +1,37 @@
+package packetutil
+
+import "math"
+
+// Angle is a rotation as entity packets send it, in steps of 1/256 of a
+// full turn.
+type Angle uint8
+
+// AngleFromDegrees converts degrees to an Angle, rounding down as vanilla
+// does. Angles outside 0 to 360 wrap around.
+func AngleFromDegrees(deg float32) Angle {
+	return Angle(int64(math.Floor(float64(deg) * 256 / 360)))
+}
+
+// AngleFromRadians converts radians to an Angle.
+func AngleFromRadians(rad float64) Angle {
+	return Angle(int64(math.Floor(rad * 128 / math.Pi)))
+}
+
+// Degrees returns the angle in degrees, from 0 to 360.
+func (a Angle) Degrees() float32 {
+	return float32(a) * 360 / 256
+}
+
+// Radians returns the angle in radians, from 0 to 2π.
+func (a Angle) Radians() float64 {
+	return float64(a) * math.Pi / 128
+}
+
+func (pr *PacketReader) ReadAngle() (Angle, error) {
+	val, err := pr.ReadUnsignedByte()
+	return Angle(val), err
+}
+
+func (pw *PacketWriter) WriteAngle(val Angle) {
+	pw.WriteUnsignedByte(byte(val))
+}
@@ -0,0 +1,131 @@
+package packetutil
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// BitSet is a set of bits kept in 64-bit words, bit i being bit i%64 of word
+// i/64, the layout of Java's BitSet. The zero value is empty and grows as
+// bits are set.
+type BitSet []uint64
+
+// Get reports whether bit i is set.
+func (bs BitSet) Get(i int) bool {
+	if i < 0 || i/64 >= len(bs) {
+		return false
+	}
+	return bs[i/64]&(1<<(i%64)) != 0
+}
+
+// Set sets bit i, growing the set if it needs to. A negative i is ignored.
+func (bs *BitSet) Set(i int) {
+	if i < 0 {
+		return
+	}
+	for i/64 >= len(*bs) {
+		*bs = append(*bs, 0)
+	}
+	(*bs)[i/64] |= 1 << (i % 64)
+}
+
+// Clear clears bit i.
+func (bs BitSet) Clear(i int) {
+	if i >= 0 && i/64 < len(bs) {
+		bs[i/64] &^= 1 << (i % 64)
+	}
+}
+
+// Len returns one more than the highest bit set, as Java's BitSet.length
+// does, or 0 if none are.
+func (bs BitSet) Len() int {
+	for i := len(bs) - 1; i >= 0; i-- {
+		if bs[i] != 0 {
+			for bit := 63; ; bit-- {
+				if bs[i]&(1<<bit) != 0 {
+					return i*64 + bit + 1
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// ReadBitSet reads a BitSet sent as a VarInt-prefixed array of longs, as
+// light masks are.
+func (pr *PacketReader) ReadBitSet() (BitSet, error) {
+	length, err := pr.ReadVarInt()
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 || int64(length)*8 > pr.end-pr.seek {
+		return nil, fmt.Errorf("bit set length of %d is out of range", length)
+	}
+
+	bs := make(BitSet, length)
+	for i := range bs {
+		bs[i] = binary.BigEndian.Uint64(pr.data[pr.seek:])
+		pr.seek += 8
+	}
+	return bs, nil
+}
+
+// ReadFixedBitSet reads a BitSet of a size both sides know, sent unprefixed
+// in as many bytes as it takes, lowest bits first, as Java's
+// BitSet.toByteArray writes it. Chat acknowledgements are sent this way.
+func (pr *PacketReader) ReadFixedBitSet(bits int) (BitSet, error) {
+	size := int64(bits+7) / 8
+	if bits < 0 || size > pr.end-pr.seek {
+		return nil, fmt.Errorf("could not read bit set of %d bits", bits)
+	}
+
+	bs := make(BitSet, (bits+63)/64)
+	for i, b := range pr.data[pr.seek : pr.seek+size] {
+		bs[i/8] |= uint64(b) << (i % 8 * 8)
+	}
+	pr.seek += size
+	return bs, nil
+}
+
+// WriteBitSet writes a BitSet as a VarInt-prefixed array of longs. Trailing
+// zero words are left off, as Java's BitSet.toLongArray does.
+func (pw *PacketWriter) WriteBitSet(bs BitSet) {
+	for len(bs) > 0 && bs[len(bs)-1] == 0 {
+		bs = bs[:len(bs)-1]
+	}
+	pw.WriteVarInt(int32(len(bs)))
+	for _, word := range bs {
+		pw.WriteLong(int64(word))
+	}
+}
+
+// WriteFixedBitSet writes the first bits bits of a BitSet in the layout
+// ReadFixedBitSet reads. Bits beyond them are left off.
+func (pw *PacketWriter) WriteFixedBitSet(bs BitSet, bits int) {
+	data := make([]byte, (bits+7)/8)
+	for i := range data {
+		if i/8 < len(bs) {
+			data[i] = byte(bs[i/8] >> (i % 8 * 8))
+		}
+	}
+	if rem := bits % 8; rem != 0 {
+		data[len(data)-1] &= 1<<rem - 1
+	}
+	pw.WriteBytes(data)
+}
+
+// BitSetCodec is a codec for a VarInt-prefixed BitSet.
+var BitSetCodec = Codec[BitSet]{(*PacketWriter).WriteBitSet, (*PacketReader).ReadBitSet}
+
+// FixedBitSetOf returns a codec for a BitSet of the given number of bits,
+// written as WriteFixedBitSet does.
+func FixedBitSetOf(bits int) Codec[BitSet] {
+	return Codec[BitSet]{
+		Encode: func(pw *PacketWriter, val BitSet) {
+			pw.WriteFixedBitSet(val, bits)
+		},
+		Decode: func(pr *PacketReader) (BitSet, error) {
+			return pr.ReadFixedBitSet(bits)
+		},
+	}
+}
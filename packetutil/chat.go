@@ -0,0 +1,63 @@
+package packetutil
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/PurpurProject/elytra/jsonutil"
+)
+
+// ReadChat reads a chat component sent as a JSON string, as chat and
+// disconnect packets did before 1.20.3.
+func (pr *PacketReader) ReadChat() (jsonutil.ChatObject, error) {
+	var chat jsonutil.ChatObject
+
+	str, err := pr.ReadString()
+	if err != nil {
+		return chat, err
+	}
+	if err := decodeChat([]byte(str), &chat); err != nil {
+		return chat, fmt.Errorf("could not decode chat component: %w", err)
+	}
+	return chat, nil
+}
+
+// decodeChat decodes a component in any of the forms vanilla accepts: an
+// object, a plain string, or a list whose first element is the parent of
+// the rest.
+func decodeChat(data []byte, chat *jsonutil.ChatObject) error {
+	if err := json.Unmarshal(data, chat); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &chat.Text); err == nil {
+		return nil
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("component is an empty list")
+	}
+	if err := decodeChat(list[0], chat); err != nil {
+		return err
+	}
+	for _, elem := range list[1:] {
+		var extra jsonutil.ChatObject
+		if err := decodeChat(elem, &extra); err != nil {
+			return err
+		}
+		chat.Extra = append(chat.Extra, extra)
+	}
+	return nil
+}
+
+// WriteChat writes a chat component as a JSON string.
+func (pw *PacketWriter) WriteChat(val jsonutil.ChatObject) {
+	// This can't fail: a ChatObject only holds strings, bytes and lists.
+	encoded, _ := json.Marshal(val)
+	pw.WriteString(string(encoded))
+}
+
+// ChatCodec is a codec for a chat component sent as a JSON string.
+var ChatCodec = Codec[jsonutil.ChatObject]{(*PacketWriter).WriteChat, (*PacketReader).ReadChat}
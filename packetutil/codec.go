@@ -0,0 +1,316 @@
+package packetutil
+
+import (
+	"fmt"
+	"math"
+)
+
+// Codec reads and writes one kind of value in packet data. Codecs for fields
+// are combined into codecs for whole packets, so a packet is defined once
+// rather than as a pair of read and write functions that have to be kept in
+// step:
+//
+//	var HandshakeCodec = StructOf(
+//		Field(func(h *Handshake) *int32 { return &h.Protocol }, VarIntCodec),
+//		Field(func(h *Handshake) *string { return &h.Address }, StringCodec),
+//		Field(func(h *Handshake) *uint16 { return &h.Port }, UnsignedShortCodec),
+//		Field(func(h *Handshake) *int32 { return &h.Intent }, VarIntCodec),
+//	)
+//
+// An Encode given a value it can't write reports it with the writer's Fail.
+type Codec[T any] struct {
+	Encode func(pw *PacketWriter, val T)
+	Decode func(pr *PacketReader) (T, error)
+}
+
+// Marshal writes val as the body of a packet with the given ID, returning
+// the length-prefixed packet as GetPacket does, or the error Encode recorded
+// with Fail.
+func (c Codec[T]) Marshal(packetID int32, val T) ([]byte, error) {
+	pw := CreatePacketWriter(packetID)
+	c.Encode(pw, val)
+	if err := pw.Err(); err != nil {
+		return nil, err
+	}
+	return pw.GetPacket(), nil
+}
+
+// Unmarshal reads a value that must take up all of data, such as the Data of
+// a Packet.
+func (c Codec[T]) Unmarshal(data []byte) (T, error) {
+	pr := CreatePacketReader(data)
+	val, err := c.Decode(pr)
+	if err != nil {
+		return val, err
+	}
+	if left := pr.end - pr.seek; left > 0 {
+		return val, fmt.Errorf("%d bytes left over after decoding", left)
+	}
+	return val, nil
+}
+
+// Codecs for the primitive types PacketReader and PacketWriter handle.
+var (
+	BooleanCodec       = Codec[bool]{(*PacketWriter).WriteBoolean, (*PacketReader).ReadBoolean}
+	ByteCodec          = Codec[int8]{(*PacketWriter).WriteByte, (*PacketReader).ReadByte}
+	UnsignedByteCodec  = Codec[byte]{(*PacketWriter).WriteUnsignedByte, (*PacketReader).ReadUnsignedByte}
+	ShortCodec         = Codec[int16]{(*PacketWriter).WriteShort, (*PacketReader).ReadShort}
+	UnsignedShortCodec = Codec[uint16]{(*PacketWriter).WriteUnsignedShort, (*PacketReader).ReadUnsignedShort}
+	IntCodec           = Codec[int32]{(*PacketWriter).WriteInt, (*PacketReader).ReadInt}
+	LongCodec          = Codec[int64]{(*PacketWriter).WriteLong, (*PacketReader).ReadLong}
+	FloatCodec         = Codec[float32]{(*PacketWriter).WriteFloat, (*PacketReader).ReadFloat}
+	DoubleCodec        = Codec[float64]{(*PacketWriter).WriteDouble, (*PacketReader).ReadDouble}
+	StringCodec        = Codec[string]{(*PacketWriter).WriteString, (*PacketReader).ReadString}
+	VarIntCodec        = Codec[int32]{(*PacketWriter).WriteVarInt, (*PacketReader).ReadVarInt}
+	VarLongCodec       = Codec[int64]{(*PacketWriter).WriteVarLong, (*PacketReader).ReadVarLong}
+	UUIDCodec          = Codec[[16]byte]{(*PacketWriter).WriteUUID, (*PacketReader).ReadUUID}
+	PositionCodec      = Codec[Position]{(*PacketWriter).WritePosition, (*PacketReader).ReadPosition}
+	AngleCodec         = Codec[Angle]{(*PacketWriter).WriteAngle, (*PacketReader).ReadAngle}
+	IdentifierCodec    = Codec[Identifier]{(*PacketWriter).WriteIdentifier, (*PacketReader).ReadIdentifier}
+	ByteArrayCodec     = Codec[[]byte]{(*PacketWriter).WriteByteArray, (*PacketReader).ReadByteArray}
+)
+
+// StructField is one field of a struct codec, made with Field.
+type StructField[S any] struct {
+	encode func(pw *PacketWriter, s *S)
+	decode func(pr *PacketReader, s *S) error
+}
+
+// Field describes a field of S, found through get, that is written with
+// codec.
+func Field[S, F any](get func(s *S) *F, codec Codec[F]) StructField[S] {
+	return StructField[S]{
+		encode: func(pw *PacketWriter, s *S) {
+			codec.Encode(pw, *get(s))
+		},
+		decode: func(pr *PacketReader, s *S) error {
+			val, err := codec.Decode(pr)
+			if err != nil {
+				return err
+			}
+			*get(s) = val
+			return nil
+		},
+	}
+}
+
+// StructOf returns a codec that writes the given fields of S one after
+// another, in order.
+func StructOf[S any](fields ...StructField[S]) Codec[S] {
+	return Codec[S]{
+		Encode: func(pw *PacketWriter, val S) {
+			for _, field := range fields {
+				field.encode(pw, &val)
+			}
+		},
+		Decode: func(pr *PacketReader) (S, error) {
+			var val S
+			for i, field := range fields {
+				if err := field.decode(pr, &val); err != nil {
+					return val, fmt.Errorf("could not read field %d: %w", i, err)
+				}
+			}
+			return val, nil
+		},
+	}
+}
+
+// ReadOptional reads a value that may be absent: a boolean, and the value
+// if it is true, read with read. Absent values are nil.
+//
+//	signature, err := ReadOptional(pr, (*PacketReader).ReadByteArray)
+func ReadOptional[T any](pr *PacketReader, read func(pr *PacketReader) (T, error)) (*T, error) {
+	present, err := pr.ReadBoolean()
+	if err != nil || !present {
+		return nil, err
+	}
+	val, err := read(pr)
+	if err != nil {
+		return nil, err
+	}
+	return &val, nil
+}
+
+// WriteOptional writes a value that may be absent, as ReadOptional reads
+// it.
+func WriteOptional[T any](pw *PacketWriter, val *T, write func(pw *PacketWriter, val T)) {
+	pw.WriteBoolean(val != nil)
+	if val != nil {
+		write(pw, *val)
+	}
+}
+
+// OptionalOf returns a codec for a value that may be absent, as
+// ReadOptional and WriteOptional handle it.
+func OptionalOf[T any](codec Codec[T]) Codec[*T] {
+	return Codec[*T]{
+		Encode: func(pw *PacketWriter, val *T) {
+			WriteOptional(pw, val, codec.Encode)
+		},
+		Decode: func(pr *PacketReader) (*T, error) {
+			return ReadOptional(pr, codec.Decode)
+		},
+	}
+}
+
+// readLength reads a VarInt count of elements. Every element takes at least
+// a byte, so a count larger than what is left of the packet is refused
+// before anything is allocated for it.
+func readLength(pr *PacketReader) (int, error) {
+	length, err := pr.ReadVarInt()
+	if err != nil {
+		return 0, err
+	}
+	if length < 0 || int64(length) > pr.end-pr.seek {
+		return 0, fmt.Errorf("length of %d is out of range", length)
+	}
+	return int(length), nil
+}
+
+// ReadPrefixedArray reads a VarInt count of elements, then each element
+// with read. Counts above max are refused before anything is read, as are
+// counts larger than what is left of the packet.
+//
+//	properties, err := ReadPrefixedArray(pr, readProperty, 16)
+func ReadPrefixedArray[T any](pr *PacketReader, read func(pr *PacketReader) (T, error), max int) ([]T, error) {
+	length, err := readLength(pr)
+	if err != nil {
+		return nil, err
+	}
+	if length > max {
+		return nil, fmt.Errorf("%d elements is more than the %d allowed", length, max)
+	}
+	val := make([]T, length)
+	for i := range val {
+		if val[i], err = read(pr); err != nil {
+			return nil, fmt.Errorf("could not read element %d: %w", i, err)
+		}
+	}
+	return val, nil
+}
+
+// WritePrefixedArray writes a VarInt count of elements, then each element
+// with write.
+func WritePrefixedArray[T any](pw *PacketWriter, val []T, write func(pw *PacketWriter, val T)) {
+	pw.WriteVarInt(int32(len(val)))
+	for _, elem := range val {
+		write(pw, elem)
+	}
+}
+
+// ListOf returns a codec for a VarInt-prefixed list, limited only by the
+// length of the packet.
+func ListOf[T any](codec Codec[T]) Codec[[]T] {
+	return LimitedListOf(codec, math.MaxInt32)
+}
+
+// LimitedListOf returns a codec for a VarInt-prefixed list of at most max
+// elements.
+func LimitedListOf[T any](codec Codec[T], max int) Codec[[]T] {
+	return Codec[[]T]{
+		Encode: func(pw *PacketWriter, val []T) {
+			WritePrefixedArray(pw, val, codec.Encode)
+		},
+		Decode: func(pr *PacketReader) ([]T, error) {
+			return ReadPrefixedArray(pr, codec.Decode, max)
+		},
+	}
+}
+
+// MapOf returns a codec for a VarInt-prefixed list of key and value pairs.
+// Maps are written in no particular order, and duplicate keys are refused
+// when reading.
+func MapOf[K comparable, V any](key Codec[K], value Codec[V]) Codec[map[K]V] {
+	return Codec[map[K]V]{
+		Encode: func(pw *PacketWriter, val map[K]V) {
+			pw.WriteVarInt(int32(len(val)))
+			for k, v := range val {
+				key.Encode(pw, k)
+				value.Encode(pw, v)
+			}
+		},
+		Decode: func(pr *PacketReader) (map[K]V, error) {
+			length, err := readLength(pr)
+			if err != nil {
+				return nil, err
+			}
+			val := make(map[K]V, length)
+			for i := range length {
+				k, err := key.Decode(pr)
+				if err != nil {
+					return nil, fmt.Errorf("could not read key %d: %w", i, err)
+				}
+				if _, ok := val[k]; ok {
+					return nil, fmt.Errorf("key %v appears twice", k)
+				}
+				if val[k], err = value.Decode(pr); err != nil {
+					return nil, fmt.Errorf("could not read value %d: %w", i, err)
+				}
+			}
+			return val, nil
+		},
+	}
+}
+
+// Either holds one of two kinds of value, Left if IsLeft is set and Right
+// otherwise.
+type Either[L, R any] struct {
+	IsLeft bool
+	Left   L
+	Right  R
+}
+
+// EitherOf returns a codec for an Either: a boolean, true for the left value
+// and false for the right, followed by that value.
+func EitherOf[L, R any](left Codec[L], right Codec[R]) Codec[Either[L, R]] {
+	return Codec[Either[L, R]]{
+		Encode: func(pw *PacketWriter, val Either[L, R]) {
+			pw.WriteBoolean(val.IsLeft)
+			if val.IsLeft {
+				left.Encode(pw, val.Left)
+			} else {
+				right.Encode(pw, val.Right)
+			}
+		},
+		Decode: func(pr *PacketReader) (Either[L, R], error) {
+			var val Either[L, R]
+			var err error
+			if val.IsLeft, err = pr.ReadBoolean(); err != nil {
+				return val, err
+			}
+			if val.IsLeft {
+				val.Left, err = left.Decode(pr)
+			} else {
+				val.Right, err = right.Decode(pr)
+			}
+			return val, err
+		},
+	}
+}
+
+// Convert returns a codec for B that is written as an A, such as an enum
+// written as a VarInt. from may refuse values that have no B.
+func Convert[A, B any](codec Codec[A], to func(b B) A, from func(a A) (B, error)) Codec[B] {
+	return Codec[B]{
+		Encode: func(pw *PacketWriter, val B) {
+			codec.Encode(pw, to(val))
+		},
+		Decode: func(pr *PacketReader) (B, error) {
+			a, err := codec.Decode(pr)
+			if err != nil {
+				var b B
+				return b, err
+			}
+			return from(a)
+		},
+	}
+}
+
+// RemainingCodec is a codec for the rest of the packet, unprefixed, as some
+// packets end with. It must be the last field.
+var RemainingCodec = Codec[[]byte]{
+	Encode: (*PacketWriter).WriteBytes,
+	Decode: func(pr *PacketReader) ([]byte, error) {
+		return pr.ReadRemainingBytes(), nil
+	},
+}
@@ -0,0 +1,225 @@
+package packetutil
+
+import (
+	"sort"
+	"sync"
+)
+
+// AnyPacket can be passed as the packet ID when registering middleware or a
+// handler to have it run for every packet, regardless of its ID.
+const AnyPacket int32 = -1
+
+// Packet is a single packet passing through a Dispatcher. Data holds the body
+// of the packet, without the length prefix or packet ID. Middleware may modify
+// both fields before the packet reaches the handlers.
+type Packet struct {
+	ID   int32
+	Data []byte
+}
+
+// Reader returns a PacketReader over the body of the packet.
+func (p *Packet) Reader() *PacketReader {
+	return CreatePacketReader(p.Data)
+}
+
+// PacketHandler consumes a packet that made it through the middleware.
+type PacketHandler func(p *Packet) error
+
+// PacketMiddleware runs before the handlers of a packet, and may inspect,
+// rewrite or drop it. Returning false cancels the packet, so no further
+// middleware or handlers see it.
+type PacketMiddleware func(p *Packet) (bool, error)
+
+// Registration identifies middleware or a handler registered with a
+// Dispatcher, so that it can later be removed or replaced.
+type Registration struct {
+	id      uint64
+	handler bool
+}
+
+type middlewareEntry struct {
+	id         uint64
+	packetID   int32
+	priority   int
+	middleware PacketMiddleware
+}
+
+type handlerEntry struct {
+	id       uint64
+	packetID int32
+	priority int
+	handler  PacketHandler
+}
+
+// Dispatcher routes packets to the middleware and handlers registered for
+// their packet ID. Middleware always runs before handlers, and within each
+// group entries with a higher priority run first; entries with the same
+// priority run in the order they were registered.
+//
+// Registrations can be added, removed and replaced while packets are being
+// dispatched. Each change is atomic: a packet sees either every entry as it
+// was before the change or every entry as it is after.
+//
+// A Dispatcher has no notion of direction, so it can sit on either side of a
+// connection: for outbound packets, register a handler that writes the packet
+// to the wire and middleware gets the chance to cancel or mutate it first.
+type Dispatcher struct {
+	lock       sync.RWMutex
+	middleware []middlewareEntry
+	handlers   []handlerEntry
+	lastID     uint64
+}
+
+// CreateDispatcher is a factory function for creating a new Dispatcher.
+func CreateDispatcher() *Dispatcher {
+	return new(Dispatcher)
+}
+
+// Use registers middleware for the given packet ID, or for every packet if
+// packetID is AnyPacket. The returned Registration removes or replaces it.
+func (d *Dispatcher) Use(packetID int32, priority int, middleware PacketMiddleware) Registration {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	// The slice is rebuilt rather than appended to, since Dispatch may be
+	// iterating over the current one without holding the lock.
+	entries := make([]middlewareEntry, len(d.middleware), len(d.middleware)+1)
+	copy(entries, d.middleware)
+	d.lastID++
+	entries = append(entries, middlewareEntry{d.lastID, packetID, priority, middleware})
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].priority > entries[j].priority
+	})
+	d.middleware = entries
+	return Registration{id: d.lastID}
+}
+
+// Handle registers a handler for the given packet ID, or for every packet if
+// packetID is AnyPacket. The returned Registration removes or replaces it.
+func (d *Dispatcher) Handle(packetID int32, priority int, handler PacketHandler) Registration {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	entries := make([]handlerEntry, len(d.handlers), len(d.handlers)+1)
+	copy(entries, d.handlers)
+	d.lastID++
+	entries = append(entries, handlerEntry{d.lastID, packetID, priority, handler})
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].priority > entries[j].priority
+	})
+	d.handlers = entries
+	return Registration{id: d.lastID, handler: true}
+}
+
+// Remove unregisters middleware and handlers at once, returning how many
+// were still registered.
+func (d *Dispatcher) Remove(regs ...Registration) int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	remove := make(map[Registration]bool, len(regs))
+	for _, reg := range regs {
+		remove[reg] = true
+	}
+
+	// As in Use, the slices are rebuilt rather than changed in place.
+	removed := 0
+	middleware := make([]middlewareEntry, 0, len(d.middleware))
+	for _, entry := range d.middleware {
+		if remove[Registration{id: entry.id}] {
+			removed++
+			continue
+		}
+		middleware = append(middleware, entry)
+	}
+	handlers := make([]handlerEntry, 0, len(d.handlers))
+	for _, entry := range d.handlers {
+		if remove[Registration{id: entry.id, handler: true}] {
+			removed++
+			continue
+		}
+		handlers = append(handlers, entry)
+	}
+	d.middleware = middleware
+	d.handlers = handlers
+	return removed
+}
+
+// ReplaceMiddleware swaps the function of registered middleware, keeping its
+// packet ID, priority and place in the order. It reports whether the
+// middleware was still registered.
+func (d *Dispatcher) ReplaceMiddleware(reg Registration, middleware PacketMiddleware) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if reg.handler {
+		return false
+	}
+	for i, entry := range d.middleware {
+		if entry.id == reg.id {
+			entries := append([]middlewareEntry(nil), d.middleware...)
+			entries[i].middleware = middleware
+			d.middleware = entries
+			return true
+		}
+	}
+	return false
+}
+
+// ReplaceHandler swaps the function of a registered handler, keeping its
+// packet ID, priority and place in the order. It reports whether the
+// handler was still registered.
+func (d *Dispatcher) ReplaceHandler(reg Registration, handler PacketHandler) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if !reg.handler {
+		return false
+	}
+	for i, entry := range d.handlers {
+		if entry.id == reg.id {
+			entries := append([]handlerEntry(nil), d.handlers...)
+			entries[i].handler = handler
+			d.handlers = entries
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatch runs the packet through the matching middleware and then the
+// matching handlers. It reports whether the packet reached the handlers, and
+// stops at the first error returned by middleware or a handler.
+//
+// Middleware may change the packet ID; handlers are matched against the ID the
+// packet has once all middleware has run.
+func (d *Dispatcher) Dispatch(p *Packet) (bool, error) {
+	d.lock.RLock()
+	middleware := d.middleware
+	handlers := d.handlers
+	d.lock.RUnlock()
+
+	for _, entry := range middleware {
+		if entry.packetID != AnyPacket && entry.packetID != p.ID {
+			continue
+		}
+		proceed, err := entry.middleware(p)
+		if err != nil {
+			return false, err
+		}
+		if !proceed {
+			return false, nil
+		}
+	}
+
+	for _, entry := range handlers {
+		if entry.packetID != AnyPacket && entry.packetID != p.ID {
+			continue
+		}
+		if err := entry.handler(p); err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,61 @@
+package packetutil
+
+import (
+	"fmt"
+	"math"
+)
+
+// Holder is a registry entry as 1.20.5+ packets refer to sound events, trim
+// materials, dimension effects and the like: by registry ID, or for entries
+// the client's registry doesn't have, by a definition written out in full.
+// Inline is nil for entries sent by ID.
+type Holder[T any] struct {
+	ID     int32
+	Inline *T
+}
+
+// HolderOf returns a codec for a Holder: a VarInt of 0 followed by the
+// definition, written with inline, or the registry ID plus one. known
+// reports whether an ID is in the registry, so IDs the receiver couldn't
+// resolve are refused when reading; passing nil accepts every ID.
+func HolderOf[T any](inline Codec[T], known func(id int32) bool) Codec[Holder[T]] {
+	return Codec[Holder[T]]{
+		Encode: func(pw *PacketWriter, val Holder[T]) {
+			if val.Inline != nil {
+				pw.WriteVarInt(0)
+				inline.Encode(pw, *val.Inline)
+				return
+			}
+			if val.ID < 0 || val.ID == math.MaxInt32 {
+				pw.Fail(fmt.Errorf("registry ID %d is out of range", val.ID))
+				return
+			}
+			pw.WriteVarInt(val.ID + 1)
+		},
+		Decode: func(pr *PacketReader) (Holder[T], error) {
+			var val Holder[T]
+			id, err := pr.ReadVarInt()
+			if err != nil {
+				return val, err
+			}
+			if id == 0 {
+				definition, err := inline.Decode(pr)
+				if err != nil {
+					return val, fmt.Errorf("could not read inline definition: %w", err)
+				}
+				val.Inline = &definition
+				return val, nil
+			}
+			// Checked before subtracting, as id-1 of the lowest VarInt
+			// wraps round to the highest.
+			if id < 0 {
+				return val, fmt.Errorf("holder ID %d is negative", id)
+			}
+			val.ID = id - 1
+			if known != nil && !known(val.ID) {
+				return val, fmt.Errorf("registry ID %d is unknown", val.ID)
+			}
+			return val, nil
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package packetutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultNamespace is the namespace of identifiers written without one.
+const DefaultNamespace = "minecraft"
+
+// Identifier is a resource location, such as minecraft:stone: a namespace
+// and a path within it.
+type Identifier struct {
+	Namespace string
+	Path      string
+}
+
+// ParseIdentifier reads an identifier written as namespace:path, or as just
+// a path in the minecraft namespace. Namespaces may hold lower case letters,
+// digits and the characters _ - and ., and paths also /, as in vanilla.
+func ParseIdentifier(str string) (Identifier, error) {
+	id := Identifier{DefaultNamespace, str}
+	if namespace, path, ok := strings.Cut(str, ":"); ok {
+		if namespace != "" {
+			id.Namespace = namespace
+		}
+		id.Path = path
+	}
+	for i := 0; i < len(id.Namespace); i++ {
+		if !validIdentifierChar(id.Namespace[i], false) {
+			return id, fmt.Errorf("identifier %q has an invalid character in its namespace", str)
+		}
+	}
+	for i := 0; i < len(id.Path); i++ {
+		if !validIdentifierChar(id.Path[i], true) {
+			return id, fmt.Errorf("identifier %q has an invalid character in its path", str)
+		}
+	}
+	return id, nil
+}
+
+func validIdentifierChar(c byte, path bool) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.' || path && c == '/'
+}
+
+func (id Identifier) String() string {
+	return id.Namespace + ":" + id.Path
+}
+
+// ReadIdentifier reads an identifier, refusing malformed ones.
+func (pr *PacketReader) ReadIdentifier() (Identifier, error) {
+	str, err := pr.ReadString()
+	if err != nil {
+		return Identifier{}, err
+	}
+	return ParseIdentifier(str)
+}
+
+func (pw *PacketWriter) WriteIdentifier(id Identifier) {
+	pw.WriteString(id.String())
+}
@@ -0,0 +1,91 @@
+package packetutil
+
+import "sync"
+
+// InternTable deduplicates strings that show up over and over again in packet
+// data, such as identifiers, plugin channel names and player names. Every
+// distinct value is stored once and handed back to all later callers, so a
+// server decoding thousands of chunks or entities does not end up holding
+// millions of identical small strings. An InternTable is safe for concurrent
+// use by multiple goroutines.
+type InternTable struct {
+	lock       sync.RWMutex
+	strings    map[string]string
+	maxLen     int
+	maxEntries int
+}
+
+// CreateInternTable is a factory function for creating a new InternTable.
+// Strings longer than maxLen bytes are returned without being stored, which
+// keeps one-off values such as chat messages out of the table. Once the
+// table holds maxEntries strings, new ones are returned without being stored
+// too, so a client sending endless distinct values can't grow it forever.
+// A limit of zero or less disables it.
+func CreateInternTable(maxLen int, maxEntries int) *InternTable {
+	it := new(InternTable)
+	it.strings = make(map[string]string)
+	it.maxLen = maxLen
+	it.maxEntries = maxEntries
+	return it
+}
+
+// Intern returns the canonical copy of val, storing val as the canonical copy
+// if it has not been seen before.
+func (it *InternTable) Intern(val string) string {
+	if !it.shouldIntern(len(val)) {
+		return val
+	}
+
+	it.lock.RLock()
+	canonical, ok := it.strings[val]
+	it.lock.RUnlock()
+	if ok {
+		return canonical
+	}
+
+	it.lock.Lock()
+	defer it.lock.Unlock()
+	if canonical, ok := it.strings[val]; ok {
+		return canonical
+	}
+	if it.maxEntries > 0 && len(it.strings) >= it.maxEntries {
+		return val
+	}
+	it.strings[val] = val
+	return val
+}
+
+// InternBytes behaves like Intern, but only allocates a new string when val
+// has not been seen before. The byte slice is never retained.
+func (it *InternTable) InternBytes(val []byte) string {
+	if !it.shouldIntern(len(val)) {
+		return string(val)
+	}
+
+	it.lock.RLock()
+	canonical, ok := it.strings[string(val)]
+	it.lock.RUnlock()
+	if ok {
+		return canonical
+	}
+
+	return it.Intern(string(val))
+}
+
+// Len returns the number of distinct strings held by the table.
+func (it *InternTable) Len() int {
+	it.lock.RLock()
+	defer it.lock.RUnlock()
+	return len(it.strings)
+}
+
+// Reset drops every string held by the table.
+func (it *InternTable) Reset() {
+	it.lock.Lock()
+	defer it.lock.Unlock()
+	it.strings = make(map[string]string)
+}
+
+func (it *InternTable) shouldIntern(size int) bool {
+	return it.maxLen <= 0 || size <= it.maxLen
+}
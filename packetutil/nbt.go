@@ -0,0 +1,57 @@
+package packetutil
+
+import "github.com/PurpurProject/elytra/nbtutil"
+
+// MaxNBTBytes is how much NBT ReadNBT and ReadNamedNBT accept in arrays,
+// strings and lists, the same limit vanilla puts on NBT in packets.
+const MaxNBTBytes = 2097152
+
+// ReadNBT reads NBT with an unnamed root, as packets have sent it since
+// 1.20.2. A lone TagEnd, meaning no NBT, returns a nil compound.
+func (pr *PacketReader) ReadNBT() (nbtutil.Compound, error) {
+	nr := nbtutil.CreateReader(pr)
+	nr.MaxBytes = MaxNBTBytes
+	return nr.ReadNetwork()
+}
+
+// ReadNamedNBT reads NBT with a named root, as packets sent it before
+// 1.20.2.
+func (pr *PacketReader) ReadNamedNBT() (string, nbtutil.Compound, error) {
+	nr := nbtutil.CreateReader(pr)
+	nr.MaxBytes = MaxNBTBytes
+	return nr.ReadNamed()
+}
+
+// WriteNBT writes NBT with an unnamed root, as packets have sent it since
+// 1.20.2. A nil compound is written as a lone TagEnd. Nothing is written if
+// the compound holds a value NBT can't represent.
+func (pw *PacketWriter) WriteNBT(val nbtutil.Compound) error {
+	nw := nbtutil.CreateWriter()
+	if err := nw.WriteNetwork(val); err != nil {
+		return err
+	}
+	pw.WriteBytes(nw.Bytes())
+	return nil
+}
+
+// WriteNamedNBT writes NBT with a named root, as packets sent it before
+// 1.20.2.
+func (pw *PacketWriter) WriteNamedNBT(name string, val nbtutil.Compound) error {
+	nw := nbtutil.CreateWriter()
+	if err := nw.WriteNamed(name, val); err != nil {
+		return err
+	}
+	pw.WriteBytes(nw.Bytes())
+	return nil
+}
+
+// NBTCodec is a codec for NBT with an unnamed root. Values NBT can't
+// represent are written as no NBT.
+var NBTCodec = Codec[nbtutil.Compound]{
+	Encode: func(pw *PacketWriter, val nbtutil.Compound) {
+		if err := pw.WriteNBT(val); err != nil {
+			pw.WriteNBT(nil)
+		}
+	},
+	Decode: (*PacketReader).ReadNBT,
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"unicode/utf16"
 )
 
 // PacketReader is a special utility made for Trapdoor, a Minecraft server
@@ -73,6 +74,19 @@ func (pr *PacketReader) checkForEOF() bool {
 	return pr.seek >= pr.end
 }
 
+// checkRemaining checks that a value of size bytes is left to read, returning
+// io.EOF if nothing is and io.ErrUnexpectedEOF if the packet ends part way
+// through it.
+func (pr *PacketReader) checkRemaining(size int64) error {
+	if pr.checkForEOF() {
+		return io.EOF
+	}
+	if pr.end-pr.seek < size {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
 func (pr *PacketReader) seekWithEOF(offset int64, whence int) (int64, error) {
 	offset, err := pr.Seek(offset, whence)
 	if err != nil {
@@ -148,8 +162,8 @@ func (pr *PacketReader) ReadShort() (int16, error) {
 }
 
 func (pr *PacketReader) ReadUnsignedShort() (uint16, error) {
-	if pr.checkForEOF() {
-		return 0, io.EOF
+	if err := pr.checkRemaining(2); err != nil {
+		return 0, err
 	}
 
 	short := binary.BigEndian.Uint16(pr.data[pr.seek : pr.seek+2])
@@ -164,8 +178,8 @@ func (pr *PacketReader) ReadUnsignedShort() (uint16, error) {
 }
 
 func (pr *PacketReader) ReadInt() (int32, error) {
-	if pr.checkForEOF() {
-		return 0, io.EOF
+	if err := pr.checkRemaining(4); err != nil {
+		return 0, err
 	}
 
 	longShort := int32(binary.BigEndian.Uint32(pr.data[pr.seek : pr.seek+4]))
@@ -180,8 +194,8 @@ func (pr *PacketReader) ReadInt() (int32, error) {
 }
 
 func (pr *PacketReader) ReadLong() (int64, error) {
-	if pr.checkForEOF() {
-		return 0, io.EOF
+	if err := pr.checkRemaining(8); err != nil {
+		return 0, err
 	}
 
 	long := int64(binary.BigEndian.Uint64(pr.data[pr.seek : pr.seek+8]))
@@ -223,30 +237,105 @@ func (pr *PacketReader) ReadDouble() (float64, error) {
 	return math.Float64frombits(uint64(doubleBits)), nil
 }
 
+// MaxStringLength is the longest string, in UTF-16 characters, the protocol
+// allows. Fields with a lower limit of their own are read with
+// ReadStringLimited.
+const MaxStringLength = 32767
+
+// ReadString reads a VarInt-prefixed UTF-8 string of up to MaxStringLength
+// characters.
 func (pr *PacketReader) ReadString() (string, error) {
+	return pr.ReadStringLimited(MaxStringLength)
+}
+
+// ReadStringLimited reads a string in the same manner as ReadString, but of
+// at most max characters, such as 16 for a player name. max may not exceed
+// MaxStringLength.
+func (pr *PacketReader) ReadStringLimited(max int32) (string, error) {
+	stringBytes, err := pr.readStringBytes(min(max, MaxStringLength))
+
+	return string(stringBytes), err
+}
+
+// ReadInternedString reads a string in the same manner as ReadString, but
+// returns the canonical copy held by the given InternTable, so repeated values
+// share a single allocation.
+func (pr *PacketReader) ReadInternedString(it *InternTable) (string, error) {
+	stringBytes, err := pr.readStringBytes(MaxStringLength)
+
+	return it.InternBytes(stringBytes), err
+}
+
+// ReadByteArray reads a VarInt-prefixed array of bytes, as plugin messages,
+// Encryption Response and cookies carry. The bytes are copied out of the
+// packet data.
+func (pr *PacketReader) ReadByteArray() ([]byte, error) {
+	length, err := readLength(pr)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, length)
+	copy(data, pr.data[pr.seek:])
+	pr.seek += int64(length)
+
+	return data, nil
+}
+
+// ReadRemainingBytes reads everything left in the packet, as the packets
+// that end in an unprefixed payload need. The bytes are copied out of the
+// packet data.
+func (pr *PacketReader) ReadRemainingBytes() []byte {
+	data := make([]byte, pr.end-pr.seek)
+	copy(data, pr.data[pr.seek:pr.end])
+	pr.seek = pr.end
+
+	return data
+}
+
+// readStringBytes reads a length-prefixed string of at most max characters
+// and returns a slice aliasing the packet data. Callers must copy the bytes
+// before handing them out.
+func (pr *PacketReader) readStringBytes(max int32) ([]byte, error) {
 	if pr.checkForEOF() {
-		return "", io.EOF
+		return nil, io.EOF
 	}
 
 	stringSize, err := pr.ReadVarInt()
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if stringSize < 0 {
-		return "", fmt.Errorf("string size of %d invalid", stringSize)
+	// Like vanilla, limit the bytes first, as a character takes at most
+	// three of them, then count the characters.
+	if stringSize < 0 || int64(stringSize) > int64(max)*3 {
+		return nil, fmt.Errorf("string size of %d invalid", stringSize)
 	}
 
-	stringVal := string(pr.data[pr.seek : pr.seek+int64(stringSize)])
+	if int64(stringSize) > pr.end-pr.seek {
+		return nil, io.ErrUnexpectedEOF
+	}
 
-	_, err = pr.seekWithEOF(int64(stringSize), io.SeekCurrent)
+	stringBytes := pr.data[pr.seek : pr.seek+int64(stringSize)]
+	pr.seek += int64(stringSize)
 
-	if err != nil {
-		return stringVal, err
+	if length := UTF16Length(stringBytes); length > int(max) {
+		return nil, fmt.Errorf("string of %d characters is longer than %d", length, max)
 	}
 
-	return stringVal, nil
+	return stringBytes, nil
+}
+
+// UTF16Length returns the length of a UTF-8 string in the UTF-16 code units
+// Java counts string lengths in.
+func UTF16Length[S string | []byte](str S) int {
+	length := 0
+	for _, r := range string(str) {
+		// An invalid byte decodes as U+FFFD, one unit, as it does in Java.
+		length += utf16.RuneLen(r)
+	}
+	return length
 }
 
 func (pr *PacketReader) ReadVarInt() (int32, error) {
@@ -277,6 +366,19 @@ func (pr *PacketReader) ReadVarInt() (int32, error) {
 	return result, nil
 }
 
+// ReadEnum reads a VarInt that must be one of the values of an enum
+// numbered from 0, max being the highest.
+func (pr *PacketReader) ReadEnum(max int32) (int32, error) {
+	val, err := pr.ReadVarInt()
+	if err != nil {
+		return 0, err
+	}
+	if val < 0 || val > max {
+		return 0, fmt.Errorf("enum value %d is out of range 0 to %d", val, max)
+	}
+	return val, nil
+}
+
 func (pr *PacketReader) ReadVarLong() (int64, error) {
 	if pr.checkForEOF() {
 		return 0, io.EOF
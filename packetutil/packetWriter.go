@@ -5,22 +5,48 @@ import (
 	"math"
 )
 
+// maxPacketSizeLength is the largest number of bytes a VarInt packet length
+// prefix can occupy. PacketWriter reserves this much space at the front of its
+// buffer so that GetPacket can fill in the prefix without copying the packet.
+const maxPacketSizeLength = 5
+
 type PacketWriter struct {
 	data       []byte
 	packetID   int32
 	packetSize int32
+	err        error
 }
 
 func CreatePacketWriter(packetID int32) *PacketWriter {
 	pw := new(PacketWriter)
 	pw.packetID = packetID
-	pw.data = make([]byte, 0)
+	pw.data = make([]byte, maxPacketSizeLength)
 	pw.WriteVarInt(packetID)
 	return pw
 }
 
+// GetPacket returns the length-prefixed packet. The length prefix is written
+// into the space reserved in front of the packet data, so the returned slice
+// shares its memory with the PacketWriter rather than being a fresh copy.
 func (pw *PacketWriter) GetPacket() []byte {
-	return append(pw.getVarLong(int64(pw.packetSize)), pw.data...)
+	prefix := pw.getVarLong(int64(pw.packetSize))
+	start := maxPacketSizeLength - len(prefix)
+	copy(pw.data[start:], prefix)
+	return pw.data[start:]
+}
+
+// Fail records why the packet can't be built, for codecs whose Encode has no
+// error to return. Only the first error is kept.
+func (pw *PacketWriter) Fail(err error) {
+	if pw.err == nil {
+		pw.err = err
+	}
+}
+
+// Err returns the error recorded by Fail, if any. A packet with one is
+// malformed and must not be sent.
+func (pw *PacketWriter) Err() error {
+	return pw.err
 }
 
 func (pw *PacketWriter) appendByteSlice(data []byte) {
@@ -91,8 +117,20 @@ func (pw *PacketWriter) WriteString(val string) {
 	pw.appendByteSlice([]byte(val))
 }
 
+// WriteBytes appends val to the packet as-is, without a length prefix.
+func (pw *PacketWriter) WriteBytes(val []byte) {
+	pw.appendByteSlice(val)
+}
+
+// WriteByteArray appends val to the packet with a VarInt length prefix.
+func (pw *PacketWriter) WriteByteArray(val []byte) {
+	pw.WriteVarInt(int32(len(val)))
+	pw.appendByteSlice(val)
+}
+
 func (pw *PacketWriter) WriteVarInt(val int32) {
-	pw.WriteVarLong(int64(val))
+	// Negative values take five bytes, not the ten a VarLong would.
+	pw.WriteVarLong(int64(uint32(val)))
 }
 
 func (pw *PacketWriter) WriteVarLong(val int64) {
@@ -0,0 +1,71 @@
+package packetutil
+
+// Position is a block position, as packets pack it into a single long: 26
+// bits for X and Z and 12 for Y, each signed.
+type Position struct {
+	X, Y, Z int32
+}
+
+// signExtend treats the low bits of val as a signed number.
+func signExtend(val uint64, bits uint) int32 {
+	shift := 64 - bits
+	return int32(int64(val<<shift) >> shift)
+}
+
+// Pack returns the position packed as 1.14 and later send it, with Y in
+// the low bits.
+func (pos Position) Pack() int64 {
+	return int64(uint64(pos.X)&0x3FFFFFF<<38 | uint64(pos.Z)&0x3FFFFFF<<12 | uint64(pos.Y)&0xFFF)
+}
+
+// PackLegacy returns the position packed as versions before 1.14 send it,
+// with Z in the low bits.
+func (pos Position) PackLegacy() int64 {
+	return int64(uint64(pos.X)&0x3FFFFFF<<38 | uint64(pos.Y)&0xFFF<<26 | uint64(pos.Z)&0x3FFFFFF)
+}
+
+// UnpackPosition reads a position packed as Pack packs it.
+func UnpackPosition(packed int64) Position {
+	val := uint64(packed)
+	return Position{
+		X: signExtend(val>>38, 26),
+		Y: signExtend(val, 12),
+		Z: signExtend(val>>12, 26),
+	}
+}
+
+// UnpackLegacyPosition reads a position packed as PackLegacy packs it.
+func UnpackLegacyPosition(packed int64) Position {
+	val := uint64(packed)
+	return Position{
+		X: signExtend(val>>38, 26),
+		Y: signExtend(val>>26, 12),
+		Z: signExtend(val, 26),
+	}
+}
+
+func (pr *PacketReader) ReadPosition() (Position, error) {
+	packed, err := pr.ReadLong()
+	if err != nil {
+		return Position{}, err
+	}
+	return UnpackPosition(packed), nil
+}
+
+// ReadLegacyPosition reads a position as versions before 1.14 send it.
+func (pr *PacketReader) ReadLegacyPosition() (Position, error) {
+	packed, err := pr.ReadLong()
+	if err != nil {
+		return Position{}, err
+	}
+	return UnpackLegacyPosition(packed), nil
+}
+
+func (pw *PacketWriter) WritePosition(pos Position) {
+	pw.WriteLong(pos.Pack())
+}
+
+// WriteLegacyPosition writes a position as versions before 1.14 send it.
+func (pw *PacketWriter) WriteLegacyPosition(pos Position) {
+	pw.WriteLong(pos.PackLegacy())
+}
@@ -0,0 +1,24 @@
+package packetutil
+
+import "unsafe"
+
+// ReadStringUnsafe reads a string in the same manner as ReadString, but
+// without copying it out of the packet data. The returned string aliases the
+// byte slice the PacketReader was created with, which means:
+//
+//   - the string is only valid for as long as that byte slice is left
+//     untouched; reusing or overwriting the buffer silently changes the string
+//   - the string keeps the whole packet buffer alive while it is referenced
+//
+// It is meant for parsers that inspect a value and discard it straight away,
+// such as sniffers and status crawlers. Anything that stores the value must
+// use ReadString, or copy the result with strings.Clone, instead.
+func (pr *PacketReader) ReadStringUnsafe() (string, error) {
+	stringBytes, err := pr.readStringBytes(MaxStringLength)
+
+	if len(stringBytes) == 0 {
+		return "", err
+	}
+
+	return unsafe.String(&stringBytes[0], len(stringBytes)), err
+}
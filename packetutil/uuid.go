@@ -0,0 +1,75 @@
+package packetutil
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ReadUUID reads a UUID, which the protocol sends as two big-endian longs:
+// the most significant half first.
+func (pr *PacketReader) ReadUUID() ([16]byte, error) {
+	var uuid [16]byte
+
+	most, err := pr.ReadLong()
+	if err != nil {
+		return uuid, err
+	}
+	least, err := pr.ReadLong()
+	if err != nil {
+		return uuid, err
+	}
+
+	binary.BigEndian.PutUint64(uuid[:8], uint64(most))
+	binary.BigEndian.PutUint64(uuid[8:], uint64(least))
+	return uuid, nil
+}
+
+// ReadUUIDString reads a UUID sent as a hyphenated string, as Login Success
+// did before 1.16.
+func (pr *PacketReader) ReadUUIDString() ([16]byte, error) {
+	str, err := pr.ReadString()
+	if err != nil {
+		return [16]byte{}, err
+	}
+	return ParseUUID(str)
+}
+
+func (pw *PacketWriter) WriteUUID(uuid [16]byte) {
+	pw.WriteLong(int64(binary.BigEndian.Uint64(uuid[:8])))
+	pw.WriteLong(int64(binary.BigEndian.Uint64(uuid[8:])))
+}
+
+// WriteUUIDString writes a UUID as a hyphenated string, as Login Success did
+// before 1.16.
+func (pw *PacketWriter) WriteUUIDString(uuid [16]byte) {
+	pw.WriteString(FormatUUID(uuid))
+}
+
+// FormatUUID returns the hyphenated form of a UUID, such as
+// 069a79f4-44e9-4726-a5be-fca90e38aaf5.
+func FormatUUID(uuid [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
+
+// ParseUUID reads a UUID in hyphenated form, or without hyphens as the
+// session server sends it.
+func ParseUUID(str string) ([16]byte, error) {
+	var uuid [16]byte
+
+	undashed := str
+	if len(str) == 36 {
+		if str[8] != '-' || str[13] != '-' || str[18] != '-' || str[23] != '-' {
+			return uuid, fmt.Errorf("malformed UUID %q", str)
+		}
+		undashed = strings.ReplaceAll(str, "-", "")
+	}
+	if len(undashed) != 32 {
+		return uuid, fmt.Errorf("malformed UUID %q", str)
+	}
+	if _, err := hex.Decode(uuid[:], []byte(undashed)); err != nil {
+		return uuid, fmt.Errorf("malformed UUID %q", str)
+	}
+	return uuid, nil
+}
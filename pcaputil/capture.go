@@ -0,0 +1,424 @@
+package pcaputil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Link-layer header types we know how to strip, as assigned by tcpdump.org.
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+	linkTypeIPv6     = 229
+	linkTypeSLL2     = 276
+)
+
+const (
+	pcapMagicMicros      = 0xA1B2C3D4
+	pcapMagicNanos       = 0xA1B23C4D
+	pcapngSectionHeader  = 0x0A0D0D0A
+	pcapngInterfaceDesc  = 0x00000001
+	pcapngSimplePacket   = 0x00000003
+	pcapngEnhancedPacket = 0x00000006
+	pcapngByteOrderMagic = 0x1A2B3C4D
+	pcapngOptionEnd      = 0
+	pcapngOptionTSResol  = 9
+)
+
+// maxBlockSize caps the size of a single capture record, so a corrupt file
+// can't make us allocate gigabytes.
+const maxBlockSize = 16 * 1024 * 1024
+
+// capturedFrame is one link-layer frame read from a capture file.
+type capturedFrame struct {
+	timestamp time.Time
+	linkType  uint16
+	data      []byte
+}
+
+// captureReader reads frames from either a classic pcap or a pcapng file.
+type captureReader interface {
+	next() (*capturedFrame, error)
+}
+
+func createCaptureReader(r io.Reader) (captureReader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("could not read capture header: %w", err)
+	}
+
+	if binary.BigEndian.Uint32(magic) == pcapngSectionHeader {
+		return &pcapngReader{reader: br}, nil
+	}
+	return createPcapReader(br)
+}
+
+type pcapReader struct {
+	reader   io.Reader
+	order    binary.ByteOrder
+	nanos    bool
+	linkType uint16
+}
+
+func createPcapReader(r io.Reader) (*pcapReader, error) {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("could not read pcap header: %w", err)
+	}
+
+	pr := &pcapReader{reader: r}
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		switch order.Uint32(header[0:4]) {
+		case pcapMagicMicros:
+			pr.order = order
+		case pcapMagicNanos:
+			pr.order = order
+			pr.nanos = true
+		}
+	}
+	if pr.order == nil {
+		return nil, fmt.Errorf("not a pcap or pcapng capture")
+	}
+
+	pr.linkType = uint16(pr.order.Uint32(header[20:24]))
+	return pr, nil
+}
+
+func (pr *pcapReader) next() (*capturedFrame, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(pr.reader, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated pcap record: %w", err)
+		}
+		return nil, err
+	}
+
+	seconds := int64(pr.order.Uint32(header[0:4]))
+	fraction := int64(pr.order.Uint32(header[4:8]))
+	if !pr.nanos {
+		fraction *= 1000
+	}
+
+	size := pr.order.Uint32(header[8:12])
+	if size > maxBlockSize {
+		return nil, fmt.Errorf("pcap record of %d bytes exceeds the maximum of %d", size, maxBlockSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(pr.reader, data); err != nil {
+		return nil, fmt.Errorf("truncated pcap record: %w", err)
+	}
+
+	return &capturedFrame{time.Unix(seconds, fraction), pr.linkType, data}, nil
+}
+
+type pcapngInterface struct {
+	linkType uint16
+	// units is the number of timestamp units per second.
+	units uint64
+}
+
+type pcapngReader struct {
+	reader     io.Reader
+	order      binary.ByteOrder
+	interfaces []pcapngInterface
+}
+
+func (pr *pcapngReader) next() (*capturedFrame, error) {
+	for {
+		blockType, body, err := pr.readBlock()
+		if err != nil {
+			return nil, err
+		}
+
+		switch blockType {
+		case pcapngInterfaceDesc:
+			pr.interfaces = append(pr.interfaces, pr.parseInterface(body))
+		case pcapngEnhancedPacket:
+			if len(body) < 20 {
+				return nil, fmt.Errorf("enhanced packet block is too short")
+			}
+			iface, err := pr.lookupInterface(pr.order.Uint32(body[0:4]))
+			if err != nil {
+				return nil, err
+			}
+			units := uint64(pr.order.Uint32(body[4:8]))<<32 | uint64(pr.order.Uint32(body[8:12]))
+			size := pr.order.Uint32(body[12:16])
+			if uint64(size) > uint64(len(body)-20) {
+				return nil, fmt.Errorf("enhanced packet block claims %d bytes of data", size)
+			}
+			return &capturedFrame{unitsToTime(units, iface.units), iface.linkType, body[20 : 20+size]}, nil
+		case pcapngSimplePacket:
+			// Simple packet blocks carry no timestamp and always belong
+			// to the first interface.
+			if len(body) < 4 {
+				return nil, fmt.Errorf("simple packet block is too short")
+			}
+			iface, err := pr.lookupInterface(0)
+			if err != nil {
+				return nil, err
+			}
+			size := pr.order.Uint32(body[0:4])
+			if uint64(size) > uint64(len(body)-4) {
+				size = uint32(len(body) - 4)
+			}
+			return &capturedFrame{time.Time{}, iface.linkType, body[4 : 4+size]}, nil
+		}
+	}
+}
+
+// readBlock reads the next pcapng block and returns its type and body. A
+// section header block resets the byte order and the interface list.
+func (pr *pcapngReader) readBlock() (uint32, []byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(pr.reader, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("truncated pcapng block: %w", err)
+		}
+		return 0, nil, err
+	}
+
+	blockType := binary.BigEndian.Uint32(header[0:4])
+	if blockType == pcapngSectionHeader {
+		magic := make([]byte, 4)
+		if _, err := io.ReadFull(pr.reader, magic); err != nil {
+			return 0, nil, fmt.Errorf("truncated pcapng section header: %w", err)
+		}
+		switch uint32(pcapngByteOrderMagic) {
+		case binary.BigEndian.Uint32(magic):
+			pr.order = binary.BigEndian
+		case binary.LittleEndian.Uint32(magic):
+			pr.order = binary.LittleEndian
+		default:
+			return 0, nil, fmt.Errorf("pcapng section has an invalid byte-order magic")
+		}
+		pr.interfaces = nil
+
+		// The magic has already been consumed, so splice it back in front
+		// of the rest of the body.
+		body, err := pr.readBlockBody(pr.order.Uint32(header[4:8]), 4)
+		if err != nil {
+			return 0, nil, err
+		}
+		return blockType, append(magic, body...), nil
+	}
+
+	if pr.order == nil {
+		return 0, nil, fmt.Errorf("pcapng capture does not start with a section header")
+	}
+	blockType = pr.order.Uint32(header[0:4])
+	body, err := pr.readBlockBody(pr.order.Uint32(header[4:8]), 0)
+	return blockType, body, err
+}
+
+func (pr *pcapngReader) readBlockBody(totalSize uint32, consumed uint32) ([]byte, error) {
+	// The total size covers the type, the size itself, the body and the
+	// trailing copy of the size.
+	if totalSize < 12+consumed || totalSize%4 != 0 {
+		return nil, fmt.Errorf("pcapng block has an invalid size of %d", totalSize)
+	}
+	if totalSize > maxBlockSize {
+		return nil, fmt.Errorf("pcapng block of %d bytes exceeds the maximum of %d", totalSize, maxBlockSize)
+	}
+
+	rest := make([]byte, totalSize-8-consumed)
+	if _, err := io.ReadFull(pr.reader, rest); err != nil {
+		return nil, fmt.Errorf("truncated pcapng block: %w", err)
+	}
+	return rest[:len(rest)-4], nil
+}
+
+func (pr *pcapngReader) parseInterface(body []byte) pcapngInterface {
+	iface := pcapngInterface{units: 1000000}
+	if len(body) < 8 {
+		return iface
+	}
+	iface.linkType = pr.order.Uint16(body[0:2])
+
+	options := body[8:]
+	for len(options) >= 4 {
+		code := pr.order.Uint16(options[0:2])
+		size := int(pr.order.Uint16(options[2:4]))
+		if code == pcapngOptionEnd || 4+size > len(options) {
+			break
+		}
+		if code == pcapngOptionTSResol && size >= 1 {
+			resolution := options[4]
+			if resolution&0x80 == 0 {
+				iface.units = uint64(math.Pow10(int(resolution)))
+			} else {
+				iface.units = 1 << (resolution & 0x7F)
+			}
+		}
+		options = options[4+(size+3)/4*4:]
+	}
+	return iface
+}
+
+func (pr *pcapngReader) lookupInterface(id uint32) (pcapngInterface, error) {
+	if int(id) >= len(pr.interfaces) {
+		return pcapngInterface{}, fmt.Errorf("packet refers to undeclared interface %d", id)
+	}
+	return pr.interfaces[id], nil
+}
+
+func unitsToTime(units uint64, perSecond uint64) time.Time {
+	if perSecond == 0 {
+		return time.Unix(0, 0)
+	}
+	seconds := units / perSecond
+	remainder := units % perSecond
+	return time.Unix(int64(seconds), int64(remainder*1000000000/perSecond))
+}
+
+// tcpSegment is the part of a captured frame we care about.
+type tcpSegment struct {
+	srcAddr, dstAddr []byte
+	srcPort, dstPort uint16
+	seq              uint32
+	flags            byte
+	payload          []byte
+}
+
+const (
+	tcpFlagFIN = 0x01
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+	tcpFlagACK = 0x10
+)
+
+// parseTCP strips the link, network and transport headers off a frame. It
+// returns nil for anything that isn't an unfragmented TCP segment over IPv4
+// or IPv6.
+func parseTCP(frame *capturedFrame) *tcpSegment {
+	data := frame.data
+	var ipData []byte
+
+	switch frame.linkType {
+	case linkTypeNull:
+		if len(data) < 4 {
+			return nil
+		}
+		ipData = data[4:]
+	case linkTypeEthernet:
+		if len(data) < 14 {
+			return nil
+		}
+		etherType := binary.BigEndian.Uint16(data[12:14])
+		data = data[14:]
+		for (etherType == 0x8100 || etherType == 0x88A8) && len(data) >= 4 {
+			etherType = binary.BigEndian.Uint16(data[2:4])
+			data = data[4:]
+		}
+		if etherType != 0x0800 && etherType != 0x86DD {
+			return nil
+		}
+		ipData = data
+	case linkTypeLinuxSLL:
+		if len(data) < 16 {
+			return nil
+		}
+		ipData = data[16:]
+	case linkTypeSLL2:
+		if len(data) < 20 {
+			return nil
+		}
+		ipData = data[20:]
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		ipData = data
+	default:
+		return nil
+	}
+
+	if len(ipData) < 1 {
+		return nil
+	}
+	switch ipData[0] >> 4 {
+	case 4:
+		return parseIPv4(ipData)
+	case 6:
+		return parseIPv6(ipData)
+	}
+	return nil
+}
+
+func parseIPv4(data []byte) *tcpSegment {
+	if len(data) < 20 {
+		return nil
+	}
+	headerSize := int(data[0]&0x0F) * 4
+	totalSize := int(binary.BigEndian.Uint16(data[2:4]))
+	fragment := binary.BigEndian.Uint16(data[6:8])
+	if data[9] != 6 || fragment&0x3FFF != 0 || headerSize < 20 || totalSize < headerSize || totalSize > len(data) {
+		return nil
+	}
+
+	segment := parseTCPHeader(data[headerSize:totalSize])
+	if segment != nil {
+		segment.srcAddr = data[12:16]
+		segment.dstAddr = data[16:20]
+	}
+	return segment
+}
+
+func parseIPv6(data []byte) *tcpSegment {
+	if len(data) < 40 {
+		return nil
+	}
+	payloadSize := int(binary.BigEndian.Uint16(data[4:6]))
+	if 40+payloadSize > len(data) {
+		return nil
+	}
+	nextHeader := data[6]
+	payload := data[40 : 40+payloadSize]
+
+	// Skip hop-by-hop, routing and destination option headers. Fragmented
+	// packets are ignored, the same as with IPv4.
+	for nextHeader == 0 || nextHeader == 43 || nextHeader == 60 {
+		if len(payload) < 8 {
+			return nil
+		}
+		size := (int(payload[1]) + 1) * 8
+		if size > len(payload) {
+			return nil
+		}
+		nextHeader = payload[0]
+		payload = payload[size:]
+	}
+	if nextHeader != 6 {
+		return nil
+	}
+
+	segment := parseTCPHeader(payload)
+	if segment != nil {
+		segment.srcAddr = data[8:24]
+		segment.dstAddr = data[24:40]
+	}
+	return segment
+}
+
+func parseTCPHeader(data []byte) *tcpSegment {
+	if len(data) < 20 {
+		return nil
+	}
+	headerSize := int(data[12]>>4) * 4
+	if headerSize < 20 || headerSize > len(data) {
+		return nil
+	}
+	return &tcpSegment{
+		srcPort: binary.BigEndian.Uint16(data[0:2]),
+		dstPort: binary.BigEndian.Uint16(data[2:4]),
+		seq:     binary.BigEndian.Uint32(data[4:8]),
+		flags:   data[13],
+		payload: bytes.Clone(data[headerSize:]),
+	}
+}
@@ -0,0 +1,221 @@
+package pcaputil
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/PurpurProject/elytra/packetutil"
+	"github.com/PurpurProject/elytra/sessionutil"
+)
+
+// exportSegmentSize is the largest TCP payload written per captured packet,
+// matching a typical Ethernet MSS.
+const exportSegmentSize = 1460
+
+// ExportOptions describes the connection a recorded session is presented as
+// when it is exported. Zero values are replaced with defaults.
+type ExportOptions struct {
+	// Client defaults to 10.0.0.1:50000.
+	Client netip.AddrPort
+	// Server defaults to 10.0.0.2 on DefaultServerPort.
+	Server netip.AddrPort
+	// Start is the capture time of the first packet. It defaults to the
+	// current time.
+	Start time.Time
+}
+
+// WriteSession exports a recorded session to w as a pcapng capture, wrapping
+// each packet in synthesised IP and TCP headers so it can be opened with
+// Wireshark. Recorded wire bytes are used when available, so compression is
+// preserved; other packets are framed uncompressed.
+func WriteSession(w io.Writer, sr *sessionutil.SessionReader, opts ExportOptions) error {
+	if !opts.Client.IsValid() {
+		opts.Client = netip.MustParseAddrPort("10.0.0.1:50000")
+	}
+	if !opts.Server.IsValid() {
+		opts.Server = netip.AddrPortFrom(netip.MustParseAddr("10.0.0.2"), DefaultServerPort)
+	}
+	if opts.Start.IsZero() {
+		opts.Start = time.Now()
+	}
+	if opts.Client.Addr().Is4() != opts.Server.Addr().Is4() {
+		return fmt.Errorf("client and server addresses must be of the same family")
+	}
+
+	pw := &pcapngWriter{writer: bufio.NewWriter(w)}
+	pw.writeHeader()
+
+	ex := &exporter{pcap: pw, opts: opts}
+	ex.seq = [2]uint32{1000, 5000}
+
+	// Open the connection, so Wireshark sees a complete TCP stream.
+	ex.writeSegment(opts.Start, sessionutil.Serverbound, tcpFlagSYN, nil)
+	ex.seq[sessionutil.Serverbound]++
+	ex.writeSegment(opts.Start, sessionutil.Clientbound, tcpFlagSYN|tcpFlagACK, nil)
+	ex.seq[sessionutil.Clientbound]++
+	ex.writeSegment(opts.Start, sessionutil.Serverbound, tcpFlagACK, nil)
+
+	for {
+		record, err := sr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		wire := record.Wire
+		if wire == nil {
+			packet := packetutil.CreatePacketWriter(record.Packet.ID)
+			packet.WriteBytes(record.Packet.Data)
+			wire = packet.GetPacket()
+		}
+
+		timestamp := opts.Start.Add(record.Time)
+		for len(wire) > 0 {
+			size := min(len(wire), exportSegmentSize)
+			ex.writeSegment(timestamp, record.Direction, tcpFlagACK|tcpFlagPSH, wire[:size])
+			ex.seq[record.Direction] += uint32(size)
+			wire = wire[size:]
+		}
+	}
+
+	if pw.err != nil {
+		return pw.err
+	}
+	return pw.writer.Flush()
+}
+
+const tcpFlagPSH = 0x08
+
+type exporter struct {
+	pcap *pcapngWriter
+	opts ExportOptions
+	seq  [2]uint32
+}
+
+func (ex *exporter) writeSegment(timestamp time.Time, direction sessionutil.Direction, flags byte, payload []byte) {
+	src, dst := ex.opts.Client, ex.opts.Server
+	if direction == sessionutil.Clientbound {
+		src, dst = dst, src
+	}
+
+	tcp := make([]byte, 20, 20+len(payload))
+	binary.BigEndian.PutUint16(tcp[0:2], src.Port())
+	binary.BigEndian.PutUint16(tcp[2:4], dst.Port())
+	binary.BigEndian.PutUint32(tcp[4:8], ex.seq[direction])
+	if flags&tcpFlagACK != 0 {
+		binary.BigEndian.PutUint32(tcp[8:12], ex.seq[1-direction])
+	}
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	binary.BigEndian.PutUint16(tcp[14:16], 65535)
+	tcp = append(tcp, payload...)
+
+	srcAddr, dstAddr := src.Addr().AsSlice(), dst.Addr().AsSlice()
+	pseudo := make([]byte, 0, len(srcAddr)*2+8)
+	pseudo = append(pseudo, srcAddr...)
+	pseudo = append(pseudo, dstAddr...)
+	pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(tcp)))
+	pseudo = binary.BigEndian.AppendUint32(pseudo, 6)
+	binary.BigEndian.PutUint16(tcp[16:18], checksum(pseudo, tcp))
+
+	var ip []byte
+	if src.Addr().Is4() {
+		ip = make([]byte, 20, 20+len(tcp))
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(tcp)))
+		ip[6] = 0x40 // don't fragment
+		ip[8] = 64
+		ip[9] = 6
+		copy(ip[12:16], srcAddr)
+		copy(ip[16:20], dstAddr)
+		binary.BigEndian.PutUint16(ip[10:12], checksum(ip))
+	} else {
+		ip = make([]byte, 40, 40+len(tcp))
+		ip[0] = 0x60
+		binary.BigEndian.PutUint16(ip[4:6], uint16(len(tcp)))
+		ip[6] = 6
+		ip[7] = 64
+		copy(ip[8:24], srcAddr)
+		copy(ip[24:40], dstAddr)
+	}
+
+	ex.pcap.writePacket(timestamp, append(ip, tcp...))
+}
+
+// checksum computes the Internet checksum over the concatenation of parts.
+// Every part but the last must have an even length.
+func checksum(parts ...[]byte) uint16 {
+	var sum uint32
+	for _, part := range parts {
+		for i := 0; i+1 < len(part); i += 2 {
+			sum += uint32(binary.BigEndian.Uint16(part[i : i+2]))
+		}
+		if len(part)%2 == 1 {
+			sum += uint32(part[len(part)-1]) << 8
+		}
+	}
+	for sum > 0xFFFF {
+		sum = sum&0xFFFF + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// pcapngWriter writes a single-section, single-interface pcapng capture of
+// raw IP packets with microsecond timestamps.
+type pcapngWriter struct {
+	writer *bufio.Writer
+	err    error
+}
+
+func (pw *pcapngWriter) writeHeader() {
+	section := make([]byte, 0, 28)
+	section = binary.BigEndian.AppendUint32(section, pcapngSectionHeader)
+	section = binary.BigEndian.AppendUint32(section, 28)
+	section = binary.BigEndian.AppendUint32(section, pcapngByteOrderMagic)
+	section = binary.BigEndian.AppendUint16(section, 1)
+	section = binary.BigEndian.AppendUint16(section, 0)
+	// A section length of -1 means the length is not specified.
+	section = binary.BigEndian.AppendUint64(section, 0xFFFFFFFFFFFFFFFF)
+	section = binary.BigEndian.AppendUint32(section, 28)
+	pw.write(section)
+
+	iface := make([]byte, 0, 20)
+	iface = binary.BigEndian.AppendUint32(iface, pcapngInterfaceDesc)
+	iface = binary.BigEndian.AppendUint32(iface, 20)
+	iface = binary.BigEndian.AppendUint16(iface, linkTypeRaw)
+	iface = binary.BigEndian.AppendUint16(iface, 0)
+	iface = binary.BigEndian.AppendUint32(iface, 0)
+	iface = binary.BigEndian.AppendUint32(iface, 20)
+	pw.write(iface)
+}
+
+func (pw *pcapngWriter) writePacket(timestamp time.Time, data []byte) {
+	padded := (len(data) + 3) / 4 * 4
+	total := uint32(32 + padded)
+	micros := uint64(timestamp.UnixMicro())
+
+	block := make([]byte, 0, total)
+	block = binary.BigEndian.AppendUint32(block, pcapngEnhancedPacket)
+	block = binary.BigEndian.AppendUint32(block, total)
+	block = binary.BigEndian.AppendUint32(block, 0)
+	block = binary.BigEndian.AppendUint32(block, uint32(micros>>32))
+	block = binary.BigEndian.AppendUint32(block, uint32(micros))
+	block = binary.BigEndian.AppendUint32(block, uint32(len(data)))
+	block = binary.BigEndian.AppendUint32(block, uint32(len(data)))
+	block = append(block, data...)
+	block = append(block, make([]byte, padded-len(data))...)
+	block = binary.BigEndian.AppendUint32(block, total)
+	pw.write(block)
+}
+
+func (pw *pcapngWriter) write(data []byte) {
+	if pw.err == nil {
+		_, pw.err = pw.writer.Write(data)
+	}
+}
@@ -0,0 +1,386 @@
+package pcaputil
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/PurpurProject/elytra/packetutil"
+	"github.com/PurpurProject/elytra/sessionutil"
+)
+
+// DefaultServerPort is the port ReadSessions treats as a Minecraft server
+// when no ports are configured.
+const DefaultServerPort = 25565
+
+// maxFrameSize is the largest packet length the protocol can express in a
+// three byte VarInt. Anything larger means we have lost track of the stream.
+const maxFrameSize = 2097151
+
+// maxDecompressedSize caps the size of a decompressed packet.
+const maxDecompressedSize = 8 * 1024 * 1024
+
+// maxPendingSegments caps the number of out-of-order TCP segments buffered
+// per direction while waiting for a gap to be filled.
+const maxPendingSegments = 4096
+
+// Options controls how ReadSessions picks Minecraft traffic out of a capture.
+type Options struct {
+	// ServerPorts lists the TCP ports Minecraft servers listen on in the
+	// capture. It defaults to DefaultServerPort.
+	ServerPorts []uint16
+}
+
+// Session is a single Minecraft connection extracted from a capture.
+type Session struct {
+	Client netip.AddrPort
+	Server netip.AddrPort
+	// Records holds the decoded packets of both directions in the order
+	// they were captured, with times relative to the first packet.
+	Records []sessionutil.Record
+	// Encrypted is set when the connection enabled encryption, after which
+	// no further packets could be decoded.
+	Encrypted bool
+	// Err is set when the stream could not be decoded any further, for
+	// example because of a gap in the capture.
+	Err error
+}
+
+// WriteTo writes the records of the session to a recorder.
+func (s *Session) WriteTo(r *sessionutil.Recorder) error {
+	for i := range s.Records {
+		record := &s.Records[i]
+		if err := r.Record(record.Direction, &record.Packet, record.Wire); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadSessions reads a pcap or pcapng capture and returns every Minecraft
+// connection found in it, in the order they first appeared. TCP streams are
+// reassembled, and compression is undone once the Set Compression packet has
+// been seen during login. Connections that were already open when the
+// capture started are decoded from their first captured segment onwards,
+// which only works if that segment starts a packet and the connection is
+// neither compressed nor encrypted.
+func ReadSessions(r io.Reader, opts Options) ([]*Session, error) {
+	capture, err := createCaptureReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := opts.ServerPorts
+	if len(ports) == 0 {
+		ports = []uint16{DefaultServerPort}
+	}
+	isServerPort := func(port uint16) bool {
+		for _, p := range ports {
+			if p == port {
+				return true
+			}
+		}
+		return false
+	}
+
+	var sessions []*Session
+	connections := make(map[[2]netip.AddrPort]*connection)
+
+	for {
+		frame, err := capture.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return sessions, err
+		}
+
+		segment := parseTCP(frame)
+		if segment == nil {
+			continue
+		}
+		src := addrPort(segment.srcAddr, segment.srcPort)
+		dst := addrPort(segment.dstAddr, segment.dstPort)
+
+		var key [2]netip.AddrPort
+		var direction sessionutil.Direction
+		switch {
+		case isServerPort(segment.dstPort):
+			key = [2]netip.AddrPort{src, dst}
+			direction = sessionutil.Serverbound
+		case isServerPort(segment.srcPort):
+			key = [2]netip.AddrPort{dst, src}
+			direction = sessionutil.Clientbound
+		default:
+			continue
+		}
+
+		conn := connections[key]
+		if conn == nil || (segment.flags&tcpFlagSYN != 0 && direction == sessionutil.Serverbound && conn.closed) {
+			conn = createConnection(key[0], key[1], frame.timestamp)
+			connections[key] = conn
+			sessions = append(sessions, conn.session)
+		}
+		conn.handleSegment(direction, segment, frame.timestamp)
+	}
+
+	return sessions, nil
+}
+
+func addrPort(addr []byte, port uint16) netip.AddrPort {
+	ip, _ := netip.AddrFromSlice(addr)
+	return netip.AddrPortFrom(ip.Unmap(), port)
+}
+
+// Connection states, following the handshake's next state field.
+const (
+	stateHandshaking = iota
+	stateStatus
+	stateLogin
+	stateAfterLogin
+)
+
+type connection struct {
+	session    *Session
+	start      time.Time
+	streams    [2]*tcpStream
+	state      int
+	compressed bool
+	closed     bool
+	dead       bool
+}
+
+func createConnection(client, server netip.AddrPort, start time.Time) *connection {
+	conn := new(connection)
+	conn.session = &Session{Client: client, Server: server}
+	conn.start = start
+	conn.streams[sessionutil.Serverbound] = new(tcpStream)
+	conn.streams[sessionutil.Clientbound] = new(tcpStream)
+	return conn
+}
+
+func (c *connection) handleSegment(direction sessionutil.Direction, segment *tcpSegment, timestamp time.Time) {
+	if segment.flags&(tcpFlagFIN|tcpFlagRST) != 0 {
+		c.closed = true
+	}
+	if c.dead {
+		return
+	}
+
+	stream := c.streams[direction]
+	if err := stream.add(segment); err != nil {
+		c.fail(err)
+		return
+	}
+
+	for !c.dead {
+		wire, payload, err := c.nextFrame(stream)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		if wire == nil {
+			return
+		}
+
+		pr := packetutil.CreatePacketReader(payload)
+		packetID, err := pr.ReadVarInt()
+		if err != nil {
+			c.fail(fmt.Errorf("could not read packet ID: %w", err))
+			return
+		}
+		offset, _ := pr.Seek(0, io.SeekCurrent)
+
+		c.session.Records = append(c.session.Records, sessionutil.Record{
+			Time:      timestamp.Sub(c.start),
+			Direction: direction,
+			Packet:    packetutil.Packet{ID: packetID, Data: payload[offset:]},
+			Wire:      wire,
+		})
+		c.track(direction, packetID, pr)
+	}
+}
+
+// nextFrame cuts the next complete packet off the stream. It returns the
+// frame as it appeared on the wire along with the uncompressed packet ID and
+// body, or nil if the stream doesn't hold a complete frame yet.
+func (c *connection) nextFrame(stream *tcpStream) ([]byte, []byte, error) {
+	size, prefixSize, err := decodeVarInt(stream.data)
+	if err != nil || prefixSize == 0 {
+		return nil, nil, err
+	}
+	if size < 0 || size > maxFrameSize {
+		return nil, nil, fmt.Errorf("frame length of %d is out of range", size)
+	}
+	if len(stream.data) < prefixSize+int(size) {
+		return nil, nil, nil
+	}
+
+	wire := bytes.Clone(stream.data[:prefixSize+int(size)])
+	stream.data = stream.data[prefixSize+int(size):]
+	payload := wire[prefixSize:]
+
+	if !c.compressed {
+		return wire, payload, nil
+	}
+
+	dataSize, dataPrefixSize, err := decodeVarInt(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dataPrefixSize == 0 {
+		return nil, nil, fmt.Errorf("compressed frame is missing its data length")
+	}
+	if dataSize == 0 {
+		return wire, payload[dataPrefixSize:], nil
+	}
+	if dataSize < 0 || dataSize > maxDecompressedSize {
+		return nil, nil, fmt.Errorf("decompressed length of %d is out of range", dataSize)
+	}
+
+	inflater, err := zlib.NewReader(bytes.NewReader(payload[dataPrefixSize:]))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not decompress packet: %w", err)
+	}
+	defer inflater.Close()
+
+	decompressed := make([]byte, dataSize)
+	if _, err := io.ReadFull(inflater, decompressed); err != nil {
+		return nil, nil, fmt.Errorf("could not decompress packet: %w", err)
+	}
+	return wire, decompressed, nil
+}
+
+// track follows the handshake and login packets that change how the rest of
+// the stream has to be decoded. The login packet IDs involved have not
+// changed since protocol 47.
+func (c *connection) track(direction sessionutil.Direction, packetID int32, pr *packetutil.PacketReader) {
+	switch c.state {
+	case stateHandshaking:
+		if direction != sessionutil.Serverbound || packetID != 0x00 {
+			return
+		}
+		if _, err := pr.ReadVarInt(); err != nil {
+			return
+		}
+		if _, err := pr.ReadString(); err != nil {
+			return
+		}
+		if _, err := pr.ReadUnsignedShort(); err != nil {
+			return
+		}
+		nextState, err := pr.ReadVarInt()
+		if err != nil {
+			return
+		}
+		switch nextState {
+		case 1:
+			c.state = stateStatus
+		case 2, 3:
+			c.state = stateLogin
+		}
+	case stateLogin:
+		switch {
+		case direction == sessionutil.Serverbound && packetID == 0x01:
+			// Encryption Response: everything after it is encrypted.
+			c.session.Encrypted = true
+			c.dead = true
+		case direction == sessionutil.Clientbound && packetID == 0x02:
+			c.state = stateAfterLogin
+		case direction == sessionutil.Clientbound && packetID == 0x03:
+			threshold, err := pr.ReadVarInt()
+			if err != nil {
+				return
+			}
+			c.compressed = threshold >= 0
+		}
+	}
+}
+
+func (c *connection) fail(err error) {
+	c.session.Err = err
+	c.dead = true
+}
+
+// tcpStream reassembles one direction of a TCP connection.
+type tcpStream struct {
+	started bool
+	nextSeq uint32
+	data    []byte
+	pending map[uint32][]byte
+}
+
+func (s *tcpStream) add(segment *tcpSegment) error {
+	seq := segment.seq
+	if segment.flags&tcpFlagSYN != 0 {
+		s.started = true
+		s.nextSeq = seq + 1
+		s.pending = nil
+		return nil
+	}
+	if len(segment.payload) == 0 {
+		return nil
+	}
+	if !s.started {
+		// We missed the SYN, so pick the stream up from here.
+		s.started = true
+		s.nextSeq = seq
+	}
+
+	if int32(seq-s.nextSeq) > 0 {
+		if s.pending == nil {
+			s.pending = make(map[uint32][]byte)
+		}
+		if len(s.pending) >= maxPendingSegments {
+			return fmt.Errorf("too many out-of-order segments, the capture is missing data")
+		}
+		s.pending[seq] = segment.payload
+		return nil
+	}
+
+	s.append(seq, segment.payload)
+	for len(s.pending) > 0 {
+		progressed := false
+		for pendingSeq, payload := range s.pending {
+			if int32(pendingSeq-s.nextSeq) <= 0 {
+				delete(s.pending, pendingSeq)
+				s.append(pendingSeq, payload)
+				progressed = true
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+	return nil
+}
+
+// append adds a segment that starts at or before nextSeq, skipping any part
+// of it that has already been seen.
+func (s *tcpStream) append(seq uint32, payload []byte) {
+	overlap := s.nextSeq - seq
+	if uint64(overlap) >= uint64(len(payload)) {
+		return
+	}
+	s.data = append(s.data, payload[overlap:]...)
+	s.nextSeq += uint32(len(payload)) - overlap
+}
+
+// decodeVarInt decodes a VarInt from the start of data. It returns a size of
+// zero if data ends before the VarInt does.
+func decodeVarInt(data []byte) (int32, int, error) {
+	var result int32
+	for i := 0; i < 5; i++ {
+		if i >= len(data) {
+			return 0, 0, nil
+		}
+		result |= int32(data[i]&0x7F) << (7 * i)
+		if data[i]&0x80 == 0 {
+			return result, i + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("varint was over five bytes without termination")
+}
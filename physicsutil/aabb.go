@@ -0,0 +1,104 @@
+package physicsutil
+
+import "math"
+
+// collisionEpsilon is the tolerance vanilla uses when deciding whether two
+// boxes touch along an axis.
+const collisionEpsilon = 1.0e-7
+
+// AABB is an axis-aligned bounding box.
+type AABB struct {
+	MinX, MinY, MinZ float64
+	MaxX, MaxY, MaxZ float64
+}
+
+// FullBlock is the collision box of a full cube, in block-local coordinates.
+var FullBlock = AABB{0, 0, 0, 1, 1, 1}
+
+// EntityBox returns the bounding box of an entity standing at x, y, z, which
+// vanilla centres horizontally on the position.
+func EntityBox(x, y, z, width, height float64) AABB {
+	half := width / 2
+	return AABB{x - half, y, z - half, x + half, y + height, z + half}
+}
+
+// Offset returns the box moved by the given amounts.
+func (b AABB) Offset(dx, dy, dz float64) AABB {
+	return AABB{b.MinX + dx, b.MinY + dy, b.MinZ + dz, b.MaxX + dx, b.MaxY + dy, b.MaxZ + dz}
+}
+
+// Stretch returns the box extended in the direction of the given movement,
+// covering every position it passes through.
+func (b AABB) Stretch(dx, dy, dz float64) AABB {
+	if dx < 0 {
+		b.MinX += dx
+	} else {
+		b.MaxX += dx
+	}
+	if dy < 0 {
+		b.MinY += dy
+	} else {
+		b.MaxY += dy
+	}
+	if dz < 0 {
+		b.MinZ += dz
+	} else {
+		b.MaxZ += dz
+	}
+	return b
+}
+
+// Intersects reports whether the two boxes overlap. Boxes that only touch do
+// not intersect.
+func (b AABB) Intersects(other AABB) bool {
+	return b.MinX < other.MaxX && b.MaxX > other.MinX &&
+		b.MinY < other.MaxY && b.MaxY > other.MinY &&
+		b.MinZ < other.MaxZ && b.MaxZ > other.MinZ
+}
+
+// Contains reports whether the point lies inside the box.
+func (b AABB) Contains(x, y, z float64) bool {
+	return x >= b.MinX && x < b.MaxX && y >= b.MinY && y < b.MaxY && z >= b.MinZ && z < b.MaxZ
+}
+
+// ClipX limits a movement of dx along the X axis so that box, moving by it,
+// doesn't enter b.
+func (b AABB) ClipX(box AABB, dx float64) float64 {
+	if box.MaxY <= b.MinY || box.MinY >= b.MaxY || box.MaxZ <= b.MinZ || box.MinZ >= b.MaxZ {
+		return dx
+	}
+	if dx > 0 && box.MaxX <= b.MinX+collisionEpsilon {
+		dx = math.Min(dx, b.MinX-box.MaxX)
+	} else if dx < 0 && box.MinX >= b.MaxX-collisionEpsilon {
+		dx = math.Max(dx, b.MaxX-box.MinX)
+	}
+	return dx
+}
+
+// ClipY limits a movement of dy along the Y axis so that box, moving by it,
+// doesn't enter b.
+func (b AABB) ClipY(box AABB, dy float64) float64 {
+	if box.MaxX <= b.MinX || box.MinX >= b.MaxX || box.MaxZ <= b.MinZ || box.MinZ >= b.MaxZ {
+		return dy
+	}
+	if dy > 0 && box.MaxY <= b.MinY+collisionEpsilon {
+		dy = math.Min(dy, b.MinY-box.MaxY)
+	} else if dy < 0 && box.MinY >= b.MaxY-collisionEpsilon {
+		dy = math.Max(dy, b.MaxY-box.MinY)
+	}
+	return dy
+}
+
+// ClipZ limits a movement of dz along the Z axis so that box, moving by it,
+// doesn't enter b.
+func (b AABB) ClipZ(box AABB, dz float64) float64 {
+	if box.MaxX <= b.MinX || box.MinX >= b.MaxX || box.MaxY <= b.MinY || box.MinY >= b.MaxY {
+		return dz
+	}
+	if dz > 0 && box.MaxZ <= b.MinZ+collisionEpsilon {
+		dz = math.Min(dz, b.MinZ-box.MaxZ)
+	} else if dz < 0 && box.MinZ >= b.MaxZ-collisionEpsilon {
+		dz = math.Max(dz, b.MaxZ-box.MinZ)
+	}
+	return dz
+}
@@ -0,0 +1,310 @@
+package physicsutil
+
+import "math"
+
+// Player dimensions and movement constants, as used by the vanilla client.
+const (
+	PlayerWidth         = 0.6
+	PlayerHeight        = 1.8
+	PlayerSneakHeight   = 1.5
+	PlayerStepHeight    = 0.6
+	PlayerMovementSpeed = 0.1
+
+	Gravity          = 0.08
+	VerticalDrag     = 0.98
+	AirDrag          = 0.91
+	DefaultFriction  = 0.6
+	JumpVelocity     = 0.42
+	SprintJumpBoost  = 0.2
+	SprintMultiplier = 1.3
+	SneakMultiplier  = 0.3
+	InputScale       = 0.98
+
+	airAcceleration       = 0.02
+	sprintAirAcceleration = 0.025999999
+	groundAcceleration    = 0.21600002
+	fluidAcceleration     = 0.02
+	waterDrag             = 0.8
+	sprintWaterDrag       = 0.9
+	lavaDrag              = 0.5
+	fluidJumpVelocity     = 0.04
+	minimumVelocity       = 0.003
+)
+
+// Fluid is the kind of fluid occupying a block.
+type Fluid int
+
+const (
+	NoFluid Fluid = iota
+	Water
+	Lava
+)
+
+// World is what the simulation needs to know about the blocks around a
+// player. Coordinates are block coordinates.
+type World interface {
+	// CollisionBoxes returns the collision shape of the block, in
+	// block-local coordinates where a full cube is FullBlock. Blocks that
+	// can be walked through return nothing.
+	CollisionBoxes(x, y, z int) []AABB
+	// Fluid returns the fluid in the block, if any.
+	Fluid(x, y, z int) Fluid
+	// Friction returns the slipperiness of the block, which is
+	// DefaultFriction for most blocks, 0.8 for slime and 0.98 for ice.
+	Friction(x, y, z int) float64
+}
+
+// PlayerState is the physical state of a player between ticks.
+type PlayerState struct {
+	X, Y, Z             float64
+	VelX, VelY, VelZ    float64
+	Yaw                 float32
+	OnGround            bool
+	HorizontalCollision bool
+	VerticalCollision   bool
+	Sneaking, Sprinting bool
+	MovementSpeed       float64
+	InWater, InLava     bool
+}
+
+// Input is what the player is pressing during a tick. Forward and Strafe are
+// between -1 and 1, with positive values meaning forwards and left.
+type Input struct {
+	Forward, Strafe float64
+	Jump            bool
+	Sneak           bool
+	Sprint          bool
+}
+
+// Box returns the player's current bounding box.
+func (s *PlayerState) Box() AABB {
+	height := PlayerHeight
+	if s.Sneaking {
+		height = PlayerSneakHeight
+	}
+	return EntityBox(s.X, s.Y, s.Z, PlayerWidth, height)
+}
+
+// Step advances the player by one tick, following the order of operations in
+// the vanilla client: jumping, acceleration from input, movement with
+// collision and step-up, and then gravity and drag. Status effects, climbing,
+// elytra flight and sneaking at edges are not simulated.
+func Step(w World, s *PlayerState, in Input) {
+	s.Sneaking = in.Sneak
+	s.Sprinting = in.Sprint && in.Forward > 0 && !in.Sneak
+	speed := s.MovementSpeed
+	if speed == 0 {
+		speed = PlayerMovementSpeed
+	}
+	if s.Sprinting {
+		speed *= SprintMultiplier
+	}
+
+	forward, strafe := in.Forward*InputScale, in.Strafe*InputScale
+	if s.Sneaking {
+		forward *= SneakMultiplier
+		strafe *= SneakMultiplier
+	}
+
+	if math.Abs(s.VelX) < minimumVelocity {
+		s.VelX = 0
+	}
+	if math.Abs(s.VelY) < minimumVelocity {
+		s.VelY = 0
+	}
+	if math.Abs(s.VelZ) < minimumVelocity {
+		s.VelZ = 0
+	}
+
+	s.InWater = touchesFluid(w, s.Box(), Water)
+	s.InLava = !s.InWater && touchesFluid(w, s.Box(), Lava)
+
+	if in.Jump {
+		switch {
+		case s.InWater || s.InLava:
+			s.VelY += fluidJumpVelocity
+		case s.OnGround:
+			s.VelY = JumpVelocity
+			if s.Sprinting {
+				yaw := float64(s.Yaw) * math.Pi / 180
+				s.VelX -= math.Sin(yaw) * SprintJumpBoost
+				s.VelZ += math.Cos(yaw) * SprintJumpBoost
+			}
+		}
+	}
+
+	switch {
+	case s.InWater:
+		drag := waterDrag
+		if s.Sprinting {
+			drag = sprintWaterDrag
+		}
+		falling := s.VelY <= 0
+		s.accelerate(fluidAcceleration, forward, strafe)
+		s.move(w)
+		s.VelX *= drag
+		s.VelY *= waterDrag
+		s.VelZ *= drag
+		if !s.Sprinting {
+			if falling && math.Abs(s.VelY-0.005) >= minimumVelocity && math.Abs(s.VelY-Gravity/16) < minimumVelocity {
+				s.VelY = -minimumVelocity
+			} else {
+				s.VelY -= Gravity / 16
+			}
+		}
+	case s.InLava:
+		s.accelerate(fluidAcceleration, forward, strafe)
+		s.move(w)
+		s.VelX *= lavaDrag
+		s.VelY *= lavaDrag
+		s.VelZ *= lavaDrag
+		s.VelY -= Gravity / 4
+	default:
+		friction := w.Friction(int(math.Floor(s.X)), int(math.Floor(s.Y-0.5000001)), int(math.Floor(s.Z)))
+		drag := AirDrag
+		acceleration := airAcceleration
+		if s.Sprinting {
+			acceleration = sprintAirAcceleration
+		}
+		if s.OnGround {
+			drag = friction * AirDrag
+			acceleration = speed * (groundAcceleration / (friction * friction * friction))
+		}
+		s.accelerate(acceleration, forward, strafe)
+		s.move(w)
+		s.VelY -= Gravity
+		s.VelX *= drag
+		s.VelY *= VerticalDrag
+		s.VelZ *= drag
+	}
+}
+
+// accelerate adds the player's input, rotated to face their yaw, to their
+// velocity.
+func (s *PlayerState) accelerate(acceleration, forward, strafe float64) {
+	length := forward*forward + strafe*strafe
+	if length < 1.0e-7 {
+		return
+	}
+	if length > 1 {
+		length = math.Sqrt(length)
+		forward /= length
+		strafe /= length
+	}
+	forward *= acceleration
+	strafe *= acceleration
+
+	yaw := float64(s.Yaw) * math.Pi / 180
+	sin, cos := math.Sin(yaw), math.Cos(yaw)
+	s.VelX += strafe*cos - forward*sin
+	s.VelZ += forward*cos + strafe*sin
+}
+
+// move applies the player's velocity, stopping at any block in the way and
+// stepping up onto blocks no taller than PlayerStepHeight.
+func (s *PlayerState) move(w World) {
+	box := s.Box()
+	dx, dy, dz := collide(w, box, s.VelX, s.VelY, s.VelZ)
+
+	horizontal := dx != s.VelX || dz != s.VelZ
+	if horizontal && (s.OnGround || (dy != s.VelY && s.VelY < 0)) {
+		// Try the same move again from PlayerStepHeight higher up, and
+		// keep it if that gets the player further.
+		stepX, stepY, stepZ := collide(w, box, s.VelX, PlayerStepHeight, s.VelZ)
+		raised := box.Offset(stepX, stepY, stepZ)
+		_, down, _ := collide(w, raised, 0, -stepY+dy, 0)
+		stepY += down
+		if stepX*stepX+stepZ*stepZ > dx*dx+dz*dz {
+			dx, dy, dz = stepX, stepY, stepZ
+		}
+	}
+
+	s.X += dx
+	s.Y += dy
+	s.Z += dz
+
+	s.HorizontalCollision = dx != s.VelX || dz != s.VelZ
+	s.VerticalCollision = dy != s.VelY
+	s.OnGround = s.VerticalCollision && s.VelY < 0
+
+	if dx != s.VelX {
+		s.VelX = 0
+	}
+	if dz != s.VelZ {
+		s.VelZ = 0
+	}
+	if s.VerticalCollision {
+		s.VelY = 0
+	}
+}
+
+// collide clips a movement against the blocks around box, resolving the Y
+// axis first and then whichever horizontal axis has the larger movement, as
+// vanilla does.
+func collide(w World, box AABB, dx, dy, dz float64) (float64, float64, float64) {
+	boxes := nearbyBoxes(w, box.Stretch(dx, dy, dz))
+
+	for _, b := range boxes {
+		dy = b.ClipY(box, dy)
+	}
+	box = box.Offset(0, dy, 0)
+
+	if math.Abs(dx) < math.Abs(dz) {
+		for _, b := range boxes {
+			dz = b.ClipZ(box, dz)
+		}
+		box = box.Offset(0, 0, dz)
+		for _, b := range boxes {
+			dx = b.ClipX(box, dx)
+		}
+	} else {
+		for _, b := range boxes {
+			dx = b.ClipX(box, dx)
+		}
+		box = box.Offset(dx, 0, 0)
+		for _, b := range boxes {
+			dz = b.ClipZ(box, dz)
+		}
+	}
+	return dx, dy, dz
+}
+
+// nearbyBoxes returns the collision boxes, in world coordinates, of every
+// block overlapping the area.
+func nearbyBoxes(w World, area AABB) []AABB {
+	var boxes []AABB
+	// Reach one block further down, since shapes such as fences and walls
+	// stick up into the block above them.
+	forEachBlock(area, 1, func(x, y, z int) {
+		for _, b := range w.CollisionBoxes(x, y, z) {
+			boxes = append(boxes, b.Offset(float64(x), float64(y), float64(z)))
+		}
+	})
+	return boxes
+}
+
+func touchesFluid(w World, box AABB, fluid Fluid) bool {
+	found := false
+	forEachBlock(box, 0, func(x, y, z int) {
+		if !found && w.Fluid(x, y, z) == fluid {
+			found = true
+		}
+	})
+	return found
+}
+
+// forEachBlock calls fn for every block the area overlaps, plus the given
+// number of extra layers below it.
+func forEachBlock(area AABB, below int, fn func(x, y, z int)) {
+	minX, maxX := int(math.Floor(area.MinX-collisionEpsilon)), int(math.Floor(area.MaxX+collisionEpsilon))
+	minY, maxY := int(math.Floor(area.MinY-collisionEpsilon))-below, int(math.Floor(area.MaxY+collisionEpsilon))
+	minZ, maxZ := int(math.Floor(area.MinZ-collisionEpsilon)), int(math.Floor(area.MaxZ+collisionEpsilon))
+
+	for x := minX; x <= maxX; x++ {
+		for y := minY; y <= maxY; y++ {
+			for z := minZ; z <= maxZ; z++ {
+				fn(x, y, z)
+			}
+		}
+	}
+}
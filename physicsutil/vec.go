@@ -0,0 +1,215 @@
+package physicsutil
+
+import (
+	"fmt"
+	"math"
+)
+
+// Vec3 is a position, movement or velocity in blocks, or blocks per tick.
+type Vec3 struct {
+	X, Y, Z float64
+}
+
+// Vec2 is a horizontal position or movement, on the X and Z axes.
+type Vec2 struct {
+	X, Z float64
+}
+
+// Rotation is which way an entity faces, in degrees. Yaw runs clockwise
+// from south, and pitch from -90 looking straight up to 90 looking down.
+type Rotation struct {
+	Yaw, Pitch float32
+}
+
+func (v Vec3) Add(other Vec3) Vec3 {
+	return Vec3{v.X + other.X, v.Y + other.Y, v.Z + other.Z}
+}
+
+func (v Vec3) Sub(other Vec3) Vec3 {
+	return Vec3{v.X - other.X, v.Y - other.Y, v.Z - other.Z}
+}
+
+func (v Vec3) Scale(factor float64) Vec3 {
+	return Vec3{v.X * factor, v.Y * factor, v.Z * factor}
+}
+
+func (v Vec3) Dot(other Vec3) float64 {
+	return v.X*other.X + v.Y*other.Y + v.Z*other.Z
+}
+
+func (v Vec3) Cross(other Vec3) Vec3 {
+	return Vec3{
+		v.Y*other.Z - v.Z*other.Y,
+		v.Z*other.X - v.X*other.Z,
+		v.X*other.Y - v.Y*other.X,
+	}
+}
+
+func (v Vec3) LengthSquared() float64 {
+	return v.Dot(v)
+}
+
+func (v Vec3) Length() float64 {
+	return math.Sqrt(v.LengthSquared())
+}
+
+// Normalize returns the vector scaled to a length of one, or the zero
+// vector for vectors too short to have a direction, as vanilla does.
+func (v Vec3) Normalize() Vec3 {
+	length := v.Length()
+	if length < 1.0e-4 {
+		return Vec3{}
+	}
+	return v.Scale(1 / length)
+}
+
+func (v Vec3) Distance(other Vec3) float64 {
+	return v.Sub(other).Length()
+}
+
+// Horizontal drops the vertical part of the vector.
+func (v Vec3) Horizontal() Vec2 {
+	return Vec2{v.X, v.Z}
+}
+
+func (v Vec2) Add(other Vec2) Vec2 {
+	return Vec2{v.X + other.X, v.Z + other.Z}
+}
+
+func (v Vec2) Sub(other Vec2) Vec2 {
+	return Vec2{v.X - other.X, v.Z - other.Z}
+}
+
+func (v Vec2) Scale(factor float64) Vec2 {
+	return Vec2{v.X * factor, v.Z * factor}
+}
+
+func (v Vec2) Length() float64 {
+	return math.Hypot(v.X, v.Z)
+}
+
+// velocityScale is how many units a block per tick is in the velocity
+// fields of entity packets, and maxVelocity the fastest they can hold.
+const (
+	velocityScale = 8000
+	maxVelocity   = 3.9
+)
+
+// PacketVelocity returns the velocity as Spawn Entity and Set Entity
+// Velocity send it: in 1/8000ths of a block per tick, clamped to what the
+// fields can hold.
+func (v Vec3) PacketVelocity() (x, y, z int16) {
+	scale := func(val float64) int16 {
+		return int16(max(-maxVelocity, min(val, maxVelocity)) * velocityScale)
+	}
+	return scale(v.X), scale(v.Y), scale(v.Z)
+}
+
+// VelocityFromPacket reads a velocity sent as PacketVelocity writes it.
+func VelocityFromPacket(x, y, z int16) Vec3 {
+	return Vec3{float64(x) / velocityScale, float64(y) / velocityScale, float64(z) / velocityScale}
+}
+
+func toRadians(deg float32) float64 {
+	return float64(deg) * math.Pi / 180
+}
+
+// Direction returns the unit vector the rotation looks along.
+func (r Rotation) Direction() Vec3 {
+	yaw, pitch := toRadians(r.Yaw), toRadians(r.Pitch)
+	return Vec3{
+		-math.Sin(yaw) * math.Cos(pitch),
+		-math.Sin(pitch),
+		math.Cos(yaw) * math.Cos(pitch),
+	}
+}
+
+// Rotation returns the rotation that looks along the vector, as vanilla
+// works out where a mob should look.
+func (v Vec3) Rotation() Rotation {
+	yaw := math.Atan2(-v.X, v.Z) * 180 / math.Pi
+	pitch := math.Atan2(-v.Y, math.Hypot(v.X, v.Z)) * 180 / math.Pi
+	return Rotation{float32(yaw), float32(pitch)}
+}
+
+// Facing returns the horizontal face the rotation looks most towards.
+func (r Rotation) Facing() BlockFace {
+	// South, west, north and east follow each other clockwise from a yaw
+	// of 0.
+	quarter := int(math.Floor(float64(r.Yaw)/90+0.5)) & 3
+	return [4]BlockFace{South, West, North, East}[quarter]
+}
+
+// BlockFace is a face of a block, numbered as packets such as Player Action
+// and Use Item On send it.
+type BlockFace int8
+
+const (
+	Down BlockFace = iota
+	Up
+	North
+	South
+	West
+	East
+)
+
+var faceOffsets = [6][3]int{
+	Down:  {0, -1, 0},
+	Up:    {0, 1, 0},
+	North: {0, 0, -1},
+	South: {0, 0, 1},
+	West:  {-1, 0, 0},
+	East:  {1, 0, 0},
+}
+
+// Valid reports whether the face is one of the six, as a face read from a
+// packet must be checked before use.
+func (f BlockFace) Valid() bool {
+	return f >= Down && f <= East
+}
+
+// Offset returns the step from a block to the one on this face of it.
+func (f BlockFace) Offset() (dx, dy, dz int) {
+	offset := faceOffsets[f]
+	return offset[0], offset[1], offset[2]
+}
+
+// Normal returns the unit vector pointing out of the face.
+func (f BlockFace) Normal() Vec3 {
+	dx, dy, dz := f.Offset()
+	return Vec3{float64(dx), float64(dy), float64(dz)}
+}
+
+// Opposite returns the face on the other side of the block.
+func (f BlockFace) Opposite() BlockFace {
+	return f ^ 1
+}
+
+// FaceOf returns the face whose normal is nearest the direction of v.
+func FaceOf(v Vec3) BlockFace {
+	best, bestDot := Up, math.Inf(-1)
+	for f := Down; f <= East; f++ {
+		if dot := v.Dot(f.Normal()); dot > bestDot {
+			best, bestDot = f, dot
+		}
+	}
+	return best
+}
+
+func (f BlockFace) String() string {
+	switch f {
+	case Down:
+		return "down"
+	case Up:
+		return "up"
+	case North:
+		return "north"
+	case South:
+		return "south"
+	case West:
+		return "west"
+	case East:
+		return "east"
+	}
+	return fmt.Sprintf("BlockFace(%d)", int(f))
+}
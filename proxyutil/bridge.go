@@ -0,0 +1,237 @@
+package proxyutil
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/PurpurProject/elytra/connutil"
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Bridge relays packets between a client and a backend server once both
+// have logged in. Each side keeps its own compression threshold and
+// encryption, set on its Conn during login; packets are decompressed as they
+// are read and re-encoded for the other side.
+//
+// Every packet passes through the dispatcher for its direction before it is
+// forwarded. Middleware may rewrite a packet or cancel it, in which case it
+// is dropped, and handlers observe what is about to be forwarded.
+type Bridge struct {
+	Client *connutil.Conn
+
+	// Serverbound sees packets from the client, Clientbound packets from the
+	// backend.
+	Serverbound *packetutil.Dispatcher
+	Clientbound *packetutil.Dispatcher
+
+	// route is held for reading while a packet is forwarded, so a switch
+	// waits for packets in flight and none reach the wrong side after it.
+	route       sync.RWMutex
+	backend     *connutil.Conn
+	awaitAck    int32
+	acked       chan struct{}
+	transferred bool
+
+	pumps     sync.WaitGroup
+	closeOnce sync.Once
+	done      chan struct{}
+
+	lock sync.Mutex
+	err  error
+}
+
+// CreateBridge is a factory function for creating a new Bridge between two
+// logged in connections.
+func CreateBridge(client, backend *connutil.Conn) *Bridge {
+	b := new(Bridge)
+	b.Client = client
+	b.backend = backend
+	b.awaitAck = -1
+	b.Serverbound = packetutil.CreateDispatcher()
+	b.Clientbound = packetutil.CreateDispatcher()
+	b.done = make(chan struct{})
+	return b
+}
+
+// Backend returns the backend packets are currently relayed to.
+func (b *Bridge) Backend() *connutil.Conn {
+	b.route.RLock()
+	defer b.route.RUnlock()
+	return b.backend
+}
+
+// Run relays packets until either side disconnects or fails, or Close is
+// called, then closes both connections. It returns the error that ended the
+// bridge, or nil if a connection was closed cleanly.
+func (b *Bridge) Run() error {
+	b.pumps.Add(2)
+	go b.pumpServerbound()
+	go b.pumpClientbound(b.Backend(), nil, nil)
+	<-b.done
+	b.pumps.Wait()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.err
+}
+
+func (b *Bridge) pumpServerbound() {
+	defer b.pumps.Done()
+	for {
+		p, err := b.Client.ReadPacket()
+		if err != nil {
+			b.fail(err)
+			return
+		}
+		if err := b.serverbound(p); err != nil {
+			b.fail(err)
+			return
+		}
+	}
+}
+
+func (b *Bridge) serverbound(p *packetutil.Packet) error {
+	b.route.RLock()
+	defer b.route.RUnlock()
+
+	// While the client is being moved into configuration, its packets are
+	// for the old backend and are dropped, as is its acknowledgement. Only
+	// this goroutine reads awaitAck, and switches write it exclusively.
+	if b.transferred {
+		return nil
+	}
+	if b.awaitAck >= 0 {
+		if p.ID == b.awaitAck {
+			b.awaitAck = -1
+			close(b.acked)
+		}
+		return nil
+	}
+
+	forward, err := b.Serverbound.Dispatch(p)
+	if err != nil || !forward {
+		return err
+	}
+	return b.backend.WritePacket(p)
+}
+
+// pumpClientbound relays packets from one backend until it is switched away
+// from. It waits for ready first, if set, and passes the first packet
+// through rejoin, if set.
+func (b *Bridge) pumpClientbound(backend *connutil.Conn, ready <-chan struct{}, rejoin Rejoin) {
+	defer b.pumps.Done()
+	if ready != nil {
+		select {
+		case <-ready:
+		case <-b.done:
+			return
+		}
+	}
+
+	for {
+		p, err := backend.ReadPacket()
+		if err != nil {
+			// A backend that was switched away from was closed on purpose.
+			if !b.retired(backend) {
+				b.fail(err)
+			}
+			return
+		}
+
+		packets := []*packetutil.Packet{p}
+		if rejoin != nil {
+			if packets, err = rejoin(p); err != nil {
+				b.fail(err)
+				return
+			}
+			rejoin = nil
+		}
+
+		current, err := b.clientbound(backend, packets)
+		if err != nil {
+			b.fail(err)
+			return
+		}
+		if !current {
+			return
+		}
+	}
+}
+
+func (b *Bridge) retired(backend *connutil.Conn) bool {
+	b.route.RLock()
+	defer b.route.RUnlock()
+	return b.backend != backend || b.transferred
+}
+
+func (b *Bridge) clientbound(backend *connutil.Conn, packets []*packetutil.Packet) (bool, error) {
+	b.route.RLock()
+	defer b.route.RUnlock()
+
+	if b.backend != backend || b.transferred {
+		return false, nil
+	}
+	for _, p := range packets {
+		forward, err := b.Clientbound.Dispatch(p)
+		if err != nil {
+			return true, err
+		}
+		if !forward {
+			continue
+		}
+		if err := b.Client.WritePacket(p); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// fail records the first error to end the bridge and tears it down. The
+// other pump then fails on its closed connection, which is not recorded.
+func (b *Bridge) fail(err error) {
+	select {
+	case <-b.done:
+		return
+	default:
+	}
+
+	b.lock.Lock()
+	if b.err == nil && !isClosed(err) {
+		b.err = err
+	}
+	b.lock.Unlock()
+	b.Close()
+}
+
+func isClosed(err error) bool {
+	return err == nil || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed)
+}
+
+// Close tears the bridge down, closing both connections. It is safe to call
+// more than once and from any goroutine.
+func (b *Bridge) Close() {
+	b.closeOnce.Do(func() {
+		close(b.done)
+		b.Client.Close()
+		b.Backend().Close()
+	})
+}
+
+// Done is closed once the bridge has been torn down.
+func (b *Bridge) Done() <-chan struct{} {
+	return b.done
+}
+
+// SendToClient writes a packet to the client, skipping the dispatchers. It
+// is safe to call while the bridge runs.
+func (b *Bridge) SendToClient(p *packetutil.Packet) error {
+	return b.Client.WritePacket(p)
+}
+
+// SendToBackend writes a packet to the current backend, skipping the
+// dispatchers. It is safe to call while the bridge runs.
+func (b *Bridge) SendToBackend(p *packetutil.Packet) error {
+	return b.Backend().WritePacket(p)
+}
@@ -0,0 +1,109 @@
+package proxyutil
+
+import (
+	"fmt"
+
+	"github.com/PurpurProject/elytra/connutil"
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Rejoin turns the Join Game packet of a new backend into the packets that
+// move an already joined client into its world. Before 1.20.2 that is
+// usually a Respawn into a different dimension followed by one into the
+// right dimension, since the client ignores a Respawn into the world it is
+// already in. The packet layouts depend on the protocol version, so the
+// proxy supplies the translation.
+type Rejoin func(joinGame *packetutil.Packet) ([]*packetutil.Packet, error)
+
+// ReconfigureIDs are the play state packet IDs needed to move a 1.20.2+
+// client back into the configuration state.
+type ReconfigureIDs struct {
+	// StartConfiguration is clientbound.
+	StartConfiguration int32
+	// AcknowledgeConfiguration is serverbound.
+	AcknowledgeConfiguration int32
+}
+
+// swap detaches the current backend and closes it, once any packet being
+// forwarded to or from it has been written.
+func (b *Bridge) swap(backend *connutil.Conn, set func()) error {
+	b.route.Lock()
+	defer b.route.Unlock()
+
+	select {
+	case <-b.done:
+		return fmt.Errorf("bridge is closed")
+	default:
+	}
+	old := b.backend
+	b.backend = backend
+	if set != nil {
+		set()
+	}
+	// The new pump is counted before the old one can exit, so Run is never
+	// left waiting on nothing.
+	b.pumps.Add(1)
+	old.Close()
+	return nil
+}
+
+// Reconfigure switches to a new backend on 1.20.2+. The proxy must already
+// have logged in to it and sent Login Acknowledged, so it is waiting in the
+// configuration state. The client is sent Start Configuration; once it
+// acknowledges, the new backend's registries and configuration are relayed
+// to it, followed by its Join Game. Packets the client sends before
+// acknowledging were meant for the old backend and are dropped.
+func (b *Bridge) Reconfigure(backend *connutil.Conn, ids ReconfigureIDs) error {
+	acked := make(chan struct{})
+	err := b.swap(backend, func() {
+		b.awaitAck = ids.AcknowledgeConfiguration
+		b.acked = acked
+	})
+	if err != nil {
+		return err
+	}
+	go b.pumpClientbound(backend, acked, nil)
+
+	pw := packetutil.CreatePacketWriter(ids.StartConfiguration)
+	return b.Client.WriteFrame(pw.GetPacket())
+}
+
+// Respawn switches to a new backend before 1.20.2, which has no
+// configuration state. The proxy must already have logged in to it; the
+// first packet it sends is its Join Game, which is passed through rejoin.
+// Everything after is relayed as usual.
+func (b *Bridge) Respawn(backend *connutil.Conn, rejoin Rejoin) error {
+	if err := b.swap(backend, nil); err != nil {
+		return err
+	}
+	go b.pumpClientbound(backend, nil, rejoin)
+	return nil
+}
+
+// TransferPacket builds a Transfer packet, which since 1.20.5 tells the
+// client to disconnect and connect to another server itself. Its ID depends
+// on the protocol version and state, so it is passed in.
+func TransferPacket(packetID int32, host string, port int32) []byte {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteString(host)
+	pw.WriteVarInt(port)
+	return pw.GetPacket()
+}
+
+// Transfer sends the client to another server with a Transfer packet, as an
+// alternative to switching backends behind the proxy. The backend is closed
+// straight away; the bridge ends when the client disconnects.
+func (b *Bridge) Transfer(packetID int32, host string, port int32) error {
+	b.route.Lock()
+	defer b.route.Unlock()
+
+	select {
+	case <-b.done:
+		return fmt.Errorf("bridge is closed")
+	default:
+	}
+	// Whatever the client sends until it goes is dropped.
+	b.transferred = true
+	b.backend.Close()
+	return b.Client.WriteFrame(TransferPacket(packetID, host, port))
+}
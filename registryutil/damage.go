@@ -0,0 +1,116 @@
+package registryutil
+
+import "github.com/PurpurProject/elytra/nbtutil"
+
+// DamageTypeRegistry is the name of the damage type registry, synchronised
+// since 1.19.4.
+const DamageTypeRegistry = "minecraft:damage_type"
+
+// When damage scales with difficulty.
+const (
+	ScaleNever                       = "never"
+	ScaleWhenCausedByLivingNonPlayer = "when_caused_by_living_non_player"
+	ScaleAlways                      = "always"
+)
+
+// DamageType is an entry of the damage type registry.
+type DamageType struct {
+	// MessageID picks the death message, death.attack.<MessageID>.
+	MessageID  string
+	Scaling    string
+	Exhaustion float32
+	// Effects is the sound played when hurt, such as burning, or empty for
+	// the default.
+	Effects string
+	// DeathMessageType is fall_variants or intentional_game_design for the
+	// damage types with special death messages, and empty otherwise.
+	DeathMessageType string
+}
+
+// Compound returns the damage type's registry data.
+func (dt DamageType) Compound() nbtutil.Compound {
+	c := nbtutil.Compound{
+		"message_id": dt.MessageID,
+		"scaling":    dt.Scaling,
+		"exhaustion": dt.Exhaustion,
+	}
+	if dt.Effects != "" {
+		c["effects"] = dt.Effects
+	}
+	if dt.DeathMessageType != "" {
+		c["death_message_type"] = dt.DeathMessageType
+	}
+	return c
+}
+
+func damage(messageID string, exhaustion float32) DamageType {
+	return DamageType{MessageID: messageID, Scaling: ScaleWhenCausedByLivingNonPlayer, Exhaustion: exhaustion}
+}
+
+func (dt DamageType) with(effects string) DamageType {
+	dt.Effects = effects
+	return dt
+}
+
+func (dt DamageType) scaled(scaling string) DamageType {
+	dt.Scaling = scaling
+	return dt
+}
+
+// VanillaDamageTypes holds vanilla's damage types as of 1.21, by ID. The
+// client looks a number of these up by name, so a registry missing any of
+// them is rejected.
+var VanillaDamageTypes = map[string]DamageType{
+	"minecraft:arrow":                 damage("arrow", 0.1),
+	"minecraft:bad_respawn_point":     {MessageID: "badRespawnPoint", Scaling: ScaleAlways, Exhaustion: 0.1, DeathMessageType: "intentional_game_design"},
+	"minecraft:cactus":                damage("cactus", 0.1),
+	"minecraft:cramming":              damage("cramming", 0),
+	"minecraft:dragon_breath":         damage("dragonBreath", 0),
+	"minecraft:drown":                 damage("drown", 0).with("drowning"),
+	"minecraft:dry_out":               damage("dryout", 0.1),
+	"minecraft:explosion":             damage("explosion", 0.1).scaled(ScaleAlways),
+	"minecraft:fall":                  {MessageID: "fall", Scaling: ScaleWhenCausedByLivingNonPlayer, DeathMessageType: "fall_variants"},
+	"minecraft:falling_anvil":         damage("anvil", 0.1),
+	"minecraft:falling_block":         damage("fallingBlock", 0.1),
+	"minecraft:falling_stalactite":    damage("fallingStalactite", 0.1),
+	"minecraft:fireball":              damage("fireball", 0.1).with("burning"),
+	"minecraft:fireworks":             damage("fireworks", 0.1),
+	"minecraft:fly_into_wall":         damage("flyIntoWall", 0),
+	"minecraft:freeze":                damage("freeze", 0).with("freezing"),
+	"minecraft:generic":               damage("generic", 0),
+	"minecraft:generic_kill":          damage("genericKill", 0),
+	"minecraft:hot_floor":             damage("hotFloor", 0.1).with("burning"),
+	"minecraft:in_fire":               damage("inFire", 0.1).with("burning"),
+	"minecraft:in_wall":               damage("inWall", 0),
+	"minecraft:indirect_magic":        damage("indirectMagic", 0),
+	"minecraft:lava":                  damage("lava", 0.1).with("burning"),
+	"minecraft:lightning_bolt":        damage("lightningBolt", 0.1),
+	"minecraft:mace_smash":            damage("mace_smash", 0.1),
+	"minecraft:magic":                 damage("magic", 0),
+	"minecraft:mob_attack":            damage("mob", 0.1),
+	"minecraft:mob_attack_no_aggro":   damage("mob", 0.1),
+	"minecraft:mob_projectile":        damage("mob", 0.1),
+	"minecraft:on_fire":               damage("onFire", 0).with("burning"),
+	"minecraft:out_of_world":          damage("outOfWorld", 0),
+	"minecraft:outside_border":        damage("outsideBorder", 0),
+	"minecraft:player_attack":         damage("player", 0.1),
+	"minecraft:player_explosion":      damage("explosion.player", 0.1).scaled(ScaleAlways),
+	"minecraft:sonic_boom":            damage("sonic_boom", 0).scaled(ScaleAlways),
+	"minecraft:spit":                  damage("mob", 0.1),
+	"minecraft:stalagmite":            damage("stalagmite", 0),
+	"minecraft:starve":                damage("starve", 0),
+	"minecraft:sting":                 damage("sting", 0.1),
+	"minecraft:sweet_berry_bush":      damage("sweetBerryBush", 0.1).with("poking"),
+	"minecraft:thorns":                damage("thorns", 0.1).with("thorns"),
+	"minecraft:thrown":                damage("thrown", 0.1),
+	"minecraft:trident":               damage("trident", 0.1),
+	"minecraft:unattributed_fireball": damage("onFire", 0.1).with("burning"),
+	"minecraft:wind_charge":           damage("mob", 0.1),
+	"minecraft:wither":                damage("wither", 0),
+	"minecraft:wither_skull":          damage("witherSkull", 0.1),
+}
+
+// DamageTypes returns a registry of VanillaDamageTypes, sorted by ID.
+func DamageTypes() *Registry {
+	return sortedRegistry(DamageTypeRegistry, VanillaDamageTypes)
+}
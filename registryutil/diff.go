@@ -0,0 +1,117 @@
+package registryutil
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Snapshot is the full set of registries a server sent, by name, such as
+// what each backend behind a proxy sends during configuration.
+type Snapshot map[string]*Registry
+
+// CreateSnapshot is a factory function for creating a new Snapshot of the
+// given registries.
+func CreateSnapshot(registries ...*Registry) Snapshot {
+	s := make(Snapshot, len(registries))
+	for _, r := range registries {
+		s[r.Name] = r
+	}
+	return s
+}
+
+// Change is how one registry differs between two snapshots.
+type Change struct {
+	Registry string
+	// Added, Removed and Changed list entries by ID. Changed entries are in
+	// both snapshots with different data.
+	Added   []string
+	Removed []string
+	Changed []string
+	// Renumbered is set when entries in both snapshots have different
+	// registry IDs, which changes what every packet referring to them by ID
+	// means even if no data changed.
+	Renumbered bool
+}
+
+// Diff compares two snapshots, returning a Change for every registry that
+// isn't the same in both, sorted by registry name. A registry missing from
+// one snapshot counts as empty there.
+func Diff(from, to Snapshot) []Change {
+	names := make(map[string]bool, len(from)+len(to))
+	for name := range from {
+		names[name] = true
+	}
+	for name := range to {
+		names[name] = true
+	}
+
+	var changes []Change
+	for name := range names {
+		if change, ok := diffRegistry(name, from[name], to[name]); ok {
+			changes = append(changes, change)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Registry < changes[j].Registry
+	})
+	return changes
+}
+
+func diffRegistry(name string, from, to *Registry) (Change, bool) {
+	if from == nil {
+		from = CreateRegistry(name)
+	}
+	if to == nil {
+		to = CreateRegistry(name)
+	}
+
+	change := Change{Registry: name}
+	for num, entry := range to.Entries {
+		old, ok := from.ID(entry.ID)
+		switch {
+		case !ok:
+			change.Added = append(change.Added, entry.ID)
+			continue
+		case !reflect.DeepEqual(from.Entries[old].Data, entry.Data):
+			change.Changed = append(change.Changed, entry.ID)
+		}
+		if old != int32(num) {
+			change.Renumbered = true
+		}
+	}
+	for _, entry := range from.Entries {
+		if _, ok := to.ID(entry.ID); !ok {
+			change.Removed = append(change.Removed, entry.ID)
+		}
+	}
+	ok := change.Renumbered || len(change.Added)+len(change.Removed)+len(change.Changed) > 0
+	return change, ok
+}
+
+// NeedsReconfiguration reports whether a client holding the from snapshot
+// must be sent back to configuration before it can play with the to
+// snapshot, as a proxy moving a player between backends asks. Registries
+// can only be sent during configuration, so any change at all means it
+// must.
+func NeedsReconfiguration(from, to Snapshot) bool {
+	return len(Diff(from, to)) > 0
+}
+
+// ResyncPackets returns the Registry Data packets that bring a client from
+// one snapshot to another during configuration: one for each registry in
+// changes that the to snapshot has, in the order of changes. Registries that
+// didn't change aren't sent again, and ones the to snapshot lacks can't be
+// taken away, so they are left as they are.
+func ResyncPackets(packetID int32, to Snapshot, changes []Change) ([][]byte, error) {
+	var packets [][]byte
+	for _, change := range changes {
+		if r, ok := to[change.Registry]; ok {
+			packet, err := r.Packet(packetID)
+			if err != nil {
+				return nil, err
+			}
+			packets = append(packets, packet)
+		}
+	}
+	return packets, nil
+}
@@ -0,0 +1,129 @@
+// Package registryutil builds the synchronised registries a server sends
+// during configuration, such as damage types and armor trims.
+package registryutil
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/PurpurProject/elytra/nbtutil"
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Entry is one element of a registry. Data is nil for entries the client
+// takes from a data pack it already has, which 1.20.5+ clients allow for
+// the known packs they report.
+type Entry struct {
+	ID   string
+	Data nbtutil.Compound
+}
+
+// Registry is a synchronised registry, such as minecraft:damage_type, as the
+// server sends it during configuration. Entries are numbered in the order
+// they were added, which is how packets refer to them.
+type Registry struct {
+	Name    string
+	Entries []Entry
+
+	ids map[string]int32
+}
+
+// CreateRegistry is a factory function for creating a new, empty Registry.
+func CreateRegistry(name string) *Registry {
+	r := new(Registry)
+	r.Name = name
+	r.ids = make(map[string]int32)
+	return r
+}
+
+// Add adds an entry, returning its ID. Adding an entry that is already there
+// replaces its data and keeps its ID.
+func (r *Registry) Add(id string, data nbtutil.Compound) int32 {
+	if num, ok := r.ids[id]; ok {
+		r.Entries[num].Data = data
+		return num
+	}
+	num := int32(len(r.Entries))
+	r.Entries = append(r.Entries, Entry{ID: id, Data: data})
+	r.ids[id] = num
+	return num
+}
+
+// ID returns the ID of an entry.
+func (r *Registry) ID(id string) (int32, bool) {
+	num, ok := r.ids[id]
+	return num, ok
+}
+
+// Known reports whether an ID belongs to an entry, as packetutil.HolderOf
+// asks.
+func (r *Registry) Known(num int32) bool {
+	return num >= 0 && int(num) < len(r.Entries)
+}
+
+// Packet returns the Registry Data packet for the registry, as sent from
+// 1.20.5. The packet ID depends on the protocol version, so it is passed in.
+func (r *Registry) Packet(packetID int32) ([]byte, error) {
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteString(r.Name)
+	pw.WriteVarInt(int32(len(r.Entries)))
+	for _, entry := range r.Entries {
+		pw.WriteString(entry.ID)
+		pw.WriteBoolean(entry.Data != nil)
+		if entry.Data != nil {
+			nw := nbtutil.CreateWriter()
+			if err := nw.WriteNetwork(entry.Data); err != nil {
+				return nil, fmt.Errorf("encoding %s entry %s: %w", r.Name, entry.ID, err)
+			}
+			pw.WriteBytes(nw.Bytes())
+		}
+	}
+	return pw.GetPacket(), nil
+}
+
+// Compound returns the registry as 1.20.2 to 1.20.4 send it: one compound
+// of every registry, in a single Registry Data packet, holds this under the
+// registry's name. Every entry must have data.
+func (r *Registry) Compound() nbtutil.Compound {
+	value := nbtutil.List{Type: nbtutil.TagCompound}
+	for num, entry := range r.Entries {
+		value.Elements = append(value.Elements, nbtutil.Compound{
+			"name":    entry.ID,
+			"id":      int32(num),
+			"element": entry.Data,
+		})
+	}
+	return nbtutil.Compound{"type": r.Name, "value": value}
+}
+
+// LegacyPacket returns the single Registry Data packet 1.20.2 to 1.20.4
+// expect, holding every registry.
+func LegacyPacket(packetID int32, registries ...*Registry) ([]byte, error) {
+	codec := make(nbtutil.Compound, len(registries))
+	for _, r := range registries {
+		codec[r.Name] = r.Compound()
+	}
+	nw := nbtutil.CreateWriter()
+	if err := nw.WriteNetwork(codec); err != nil {
+		return nil, err
+	}
+	pw := packetutil.CreatePacketWriter(packetID)
+	pw.WriteBytes(nw.Bytes())
+	return pw.GetPacket(), nil
+}
+
+// sortedRegistry builds a registry from entries, with IDs in sorted order so
+// they are the same every time.
+func sortedRegistry[T interface{ Compound() nbtutil.Compound }](name string, entries map[string]T) *Registry {
+	ids := make([]string, 0, len(entries))
+	for id := range entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	r := CreateRegistry(name)
+	for _, id := range ids {
+		r.Add(id, entries[id].Compound())
+	}
+	return r
+}
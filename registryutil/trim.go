@@ -0,0 +1,276 @@
+package registryutil
+
+import (
+	"fmt"
+
+	"github.com/PurpurProject/elytra/nbtutil"
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Names of the registries armor trims and banners are drawn from. The trim
+// registries are synchronised since 1.20 and banner patterns since 1.20.5;
+// clients refuse a configuration that lacks them.
+const (
+	TrimPatternRegistry   = "minecraft:trim_pattern"
+	TrimMaterialRegistry  = "minecraft:trim_material"
+	BannerPatternRegistry = "minecraft:banner_pattern"
+)
+
+// Packets refer to items by their ID in the item registry, which isn't
+// synchronised, so the types below hold an item both by name, for registry
+// data, and by ID, for inline definitions in item components.
+
+// TrimPattern is an entry of the trim pattern registry: the shape a trim
+// puts on armor.
+type TrimPattern struct {
+	AssetID        string
+	TemplateItem   string
+	TemplateItemID int32
+	// Description is the pattern's name as a text component, such as
+	// {"translate": "trim_pattern.minecraft.coast"}.
+	Description nbtutil.Compound
+	// Decal draws the pattern over the armor's leggings layer rather than
+	// tinting it.
+	Decal bool
+}
+
+// Compound returns the trim pattern's registry data.
+func (tp TrimPattern) Compound() nbtutil.Compound {
+	return nbtutil.Compound{
+		"asset_id":      tp.AssetID,
+		"template_item": tp.TemplateItem,
+		"description":   tp.Description,
+		"decal":         boolByte(tp.Decal),
+	}
+}
+
+// TrimMaterial is an entry of the trim material registry: the colour a trim
+// is drawn in.
+type TrimMaterial struct {
+	AssetName    string
+	Ingredient   string
+	IngredientID int32
+	// ItemModelIndex picks the item model override for trimmed armor, from
+	// 0 to 1.
+	ItemModelIndex float32
+	// Overrides holds the asset to use instead of AssetName on armor of the
+	// same material, so gold trims show on gold armor, by armor material.
+	// OverrideIDs holds the same by armor material ID, for the wire.
+	Overrides   map[string]string
+	OverrideIDs map[int32]string
+	// Description is the material's name as a text component, coloured in
+	// the trim's colour.
+	Description nbtutil.Compound
+}
+
+// Compound returns the trim material's registry data.
+func (tm TrimMaterial) Compound() nbtutil.Compound {
+	c := nbtutil.Compound{
+		"asset_name":       tm.AssetName,
+		"ingredient":       tm.Ingredient,
+		"item_model_index": tm.ItemModelIndex,
+		"description":      tm.Description,
+	}
+	if len(tm.Overrides) > 0 {
+		overrides := make(nbtutil.Compound, len(tm.Overrides))
+		for material, asset := range tm.Overrides {
+			overrides[material] = asset
+		}
+		c["override_armor_materials"] = overrides
+	}
+	return c
+}
+
+// BannerPattern is an entry of the banner pattern registry.
+type BannerPattern struct {
+	AssetID        string
+	TranslationKey string
+}
+
+// Compound returns the banner pattern's registry data.
+func (bp BannerPattern) Compound() nbtutil.Compound {
+	return nbtutil.Compound{
+		"asset_id":        bp.AssetID,
+		"translation_key": bp.TranslationKey,
+	}
+}
+
+func boolByte(val bool) int8 {
+	if val {
+		return 1
+	}
+	return 0
+}
+
+func trimPattern(name string) TrimPattern {
+	return TrimPattern{
+		AssetID:      "minecraft:" + name,
+		TemplateItem: "minecraft:" + name + "_armor_trim_smithing_template",
+		Description:  nbtutil.Compound{"translate": "trim_pattern.minecraft." + name},
+	}
+}
+
+func trimMaterial(name, ingredient, color string, index float32) TrimMaterial {
+	return TrimMaterial{
+		AssetName:      name,
+		Ingredient:     "minecraft:" + ingredient,
+		ItemModelIndex: index,
+		Description:    nbtutil.Compound{"translate": "trim_material.minecraft." + name, "color": color},
+	}
+}
+
+func (tm TrimMaterial) darker(material string) TrimMaterial {
+	tm.Overrides = map[string]string{"minecraft:" + material: material + "_darker"}
+	return tm
+}
+
+// Vanilla's trims as of 1.21, by ID. TemplateItemID and IngredientID are
+// left at zero, as they depend on the protocol version.
+var (
+	VanillaTrimPatterns = map[string]TrimPattern{
+		"minecraft:bolt":      trimPattern("bolt"),
+		"minecraft:coast":     trimPattern("coast"),
+		"minecraft:dune":      trimPattern("dune"),
+		"minecraft:eye":       trimPattern("eye"),
+		"minecraft:flow":      trimPattern("flow"),
+		"minecraft:host":      trimPattern("host"),
+		"minecraft:raiser":    trimPattern("raiser"),
+		"minecraft:rib":       trimPattern("rib"),
+		"minecraft:sentry":    trimPattern("sentry"),
+		"minecraft:shaper":    trimPattern("shaper"),
+		"minecraft:silence":   trimPattern("silence"),
+		"minecraft:snout":     trimPattern("snout"),
+		"minecraft:spire":     trimPattern("spire"),
+		"minecraft:tide":      trimPattern("tide"),
+		"minecraft:vex":       trimPattern("vex"),
+		"minecraft:ward":      trimPattern("ward"),
+		"minecraft:wayfinder": trimPattern("wayfinder"),
+		"minecraft:wild":      trimPattern("wild"),
+	}
+	VanillaTrimMaterials = map[string]TrimMaterial{
+		"minecraft:amethyst":  trimMaterial("amethyst", "amethyst_shard", "#9A5CC6", 1.0),
+		"minecraft:copper":    trimMaterial("copper", "copper_ingot", "#B4684D", 0.5),
+		"minecraft:diamond":   trimMaterial("diamond", "diamond", "#6EECD2", 0.8).darker("diamond"),
+		"minecraft:emerald":   trimMaterial("emerald", "emerald", "#11A036", 0.7),
+		"minecraft:gold":      trimMaterial("gold", "gold_ingot", "#DEB12D", 0.6).darker("gold"),
+		"minecraft:iron":      trimMaterial("iron", "iron_ingot", "#ECECEC", 0.2).darker("iron"),
+		"minecraft:lapis":     trimMaterial("lapis", "lapis_lazuli", "#416E97", 0.9),
+		"minecraft:netherite": trimMaterial("netherite", "netherite_ingot", "#625859", 0.3).darker("netherite"),
+		"minecraft:quartz":    trimMaterial("quartz", "quartz", "#E3D4C4", 0.1),
+		"minecraft:redstone":  trimMaterial("redstone", "redstone", "#971607", 0.4),
+	}
+)
+
+// bannerPatterns names vanilla's banner patterns as of 1.21, whose assets
+// and translation keys all follow the name.
+var bannerPatterns = []string{
+	"base", "border", "bricks", "circle", "creeper", "cross", "curly_border",
+	"diagonal_left", "diagonal_right", "diagonal_up_left", "diagonal_up_right",
+	"flow", "flower", "globe", "gradient", "gradient_up", "guster",
+	"half_horizontal", "half_horizontal_bottom", "half_vertical",
+	"half_vertical_right", "mojang", "piglin", "rhombus", "skull",
+	"small_stripes", "square_bottom_left", "square_bottom_right",
+	"square_top_left", "square_top_right", "straight_cross", "stripe_bottom",
+	"stripe_center", "stripe_downleft", "stripe_downright", "stripe_left",
+	"stripe_middle", "stripe_right", "stripe_top", "triangle_bottom",
+	"triangle_top", "triangles_bottom", "triangles_top",
+}
+
+// VanillaBannerPatterns holds vanilla's banner patterns as of 1.21, by ID.
+var VanillaBannerPatterns = func() map[string]BannerPattern {
+	patterns := make(map[string]BannerPattern, len(bannerPatterns))
+	for _, name := range bannerPatterns {
+		patterns["minecraft:"+name] = BannerPattern{
+			AssetID:        "minecraft:" + name,
+			TranslationKey: "block.minecraft.banner." + name,
+		}
+	}
+	return patterns
+}()
+
+// TrimPatterns returns a registry of VanillaTrimPatterns, sorted by ID.
+func TrimPatterns() *Registry {
+	return sortedRegistry(TrimPatternRegistry, VanillaTrimPatterns)
+}
+
+// TrimMaterials returns a registry of VanillaTrimMaterials, sorted by ID.
+func TrimMaterials() *Registry {
+	return sortedRegistry(TrimMaterialRegistry, VanillaTrimMaterials)
+}
+
+// BannerPatterns returns a registry of VanillaBannerPatterns, sorted by ID.
+func BannerPatterns() *Registry {
+	return sortedRegistry(BannerPatternRegistry, VanillaBannerPatterns)
+}
+
+// componentCodec reads and writes a text component as 1.20.3+ packets do,
+// as NBT.
+var componentCodec = packetutil.Codec[nbtutil.Compound]{
+	Encode: func(pw *packetutil.PacketWriter, val nbtutil.Compound) {
+		nw := nbtutil.CreateWriter()
+		if err := nw.WriteNetwork(val); err != nil {
+			pw.Fail(fmt.Errorf("encoding description: %w", err))
+			return
+		}
+		pw.WriteBytes(nw.Bytes())
+	},
+	Decode: func(pr *packetutil.PacketReader) (nbtutil.Compound, error) {
+		return nbtutil.CreateReader(pr).ReadNetwork()
+	},
+}
+
+// Inline definitions of registry entries, as item components carry them
+// from 1.20.5 for entries the client's registry lacks.
+var (
+	TrimPatternCodec = packetutil.StructOf(
+		packetutil.Field(func(tp *TrimPattern) *string { return &tp.AssetID }, packetutil.StringCodec),
+		packetutil.Field(func(tp *TrimPattern) *int32 { return &tp.TemplateItemID }, packetutil.VarIntCodec),
+		packetutil.Field(func(tp *TrimPattern) *nbtutil.Compound { return &tp.Description }, componentCodec),
+		packetutil.Field(func(tp *TrimPattern) *bool { return &tp.Decal }, packetutil.BooleanCodec),
+	)
+	TrimMaterialCodec = packetutil.StructOf(
+		packetutil.Field(func(tm *TrimMaterial) *string { return &tm.AssetName }, packetutil.StringCodec),
+		packetutil.Field(func(tm *TrimMaterial) *int32 { return &tm.IngredientID }, packetutil.VarIntCodec),
+		packetutil.Field(func(tm *TrimMaterial) *float32 { return &tm.ItemModelIndex }, packetutil.FloatCodec),
+		packetutil.Field(func(tm *TrimMaterial) *map[int32]string { return &tm.OverrideIDs }, packetutil.MapOf(packetutil.VarIntCodec, packetutil.StringCodec)),
+		packetutil.Field(func(tm *TrimMaterial) *nbtutil.Compound { return &tm.Description }, componentCodec),
+	)
+	BannerPatternCodec = packetutil.StructOf(
+		packetutil.Field(func(bp *BannerPattern) *string { return &bp.AssetID }, packetutil.StringCodec),
+		packetutil.Field(func(bp *BannerPattern) *string { return &bp.TranslationKey }, packetutil.StringCodec),
+	)
+)
+
+// ArmorTrim is the minecraft:trim item component.
+type ArmorTrim struct {
+	Material      packetutil.Holder[TrimMaterial]
+	Pattern       packetutil.Holder[TrimPattern]
+	ShowInTooltip bool
+}
+
+// ArmorTrimCodec returns a codec for the trim component, with registry IDs
+// checked against the given registries.
+func ArmorTrimCodec(materials, patterns *Registry) packetutil.Codec[ArmorTrim] {
+	return packetutil.StructOf(
+		packetutil.Field(func(at *ArmorTrim) *packetutil.Holder[TrimMaterial] { return &at.Material }, packetutil.HolderOf(TrimMaterialCodec, materials.Known)),
+		packetutil.Field(func(at *ArmorTrim) *packetutil.Holder[TrimPattern] { return &at.Pattern }, packetutil.HolderOf(TrimPatternCodec, patterns.Known)),
+		packetutil.Field(func(at *ArmorTrim) *bool { return &at.ShowInTooltip }, packetutil.BooleanCodec),
+	)
+}
+
+// BannerLayer is one layer of the minecraft:banner_patterns item component.
+type BannerLayer struct {
+	Pattern packetutil.Holder[BannerPattern]
+	// Color is the dye colour's ID, from 0 for white to 15 for black.
+	Color int32
+}
+
+// BannerLayersCodec returns a codec for the banner patterns component, a
+// list of layers from the bottom up, with registry IDs checked against
+// patterns.
+func BannerLayersCodec(patterns *Registry) packetutil.Codec[[]BannerLayer] {
+	return packetutil.ListOf(packetutil.StructOf(
+		packetutil.Field(func(bl *BannerLayer) *packetutil.Holder[BannerPattern] { return &bl.Pattern }, packetutil.HolderOf(BannerPatternCodec, patterns.Known)),
+		packetutil.Field(func(bl *BannerLayer) *int32 { return &bl.Color }, packetutil.VarIntCodec),
+	))
+}
@@ -0,0 +1,232 @@
+package resourcepackutil
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PurpurProject/elytra/jsonutil"
+	"github.com/PurpurProject/elytra/nbtutil"
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// DefaultTokenLifetime is how long a per-player pack URL stays valid. The
+// client starts downloading as soon as it accepts the prompt, so this only
+// needs to cover players who leave the prompt open.
+const DefaultTokenLifetime = 10 * time.Minute
+
+// packFile is the file name pack URLs end in. Some clients check for a .zip
+// extension.
+const packFile = "pack.zip"
+
+// protocol1_20_3 is the protocol version from which Add Resource Pack leads
+// with the pack's UUID and sends the prompt as NBT.
+const protocol1_20_3 = 765
+
+type token struct {
+	player  string
+	expires time.Time
+}
+
+// PackServer serves a resource pack over HTTP. It can be mounted on any
+// http.ServeMux, or run on its own with http.ListenAndServe.
+type PackServer struct {
+	// Path is the pack's zip file. It is read on each request, so it can be
+	// replaced while the server runs.
+	Path string
+	// BaseURL is the URL the server is reachable at from clients, such as
+	// http://play.example.com:8080.
+	BaseURL string
+	// RequireToken restricts downloads to the per-player URLs handed out
+	// by URL, so the pack can't be fetched by anyone who knows the address.
+	RequireToken  bool
+	TokenLifetime time.Duration
+
+	lock        sync.Mutex
+	hash        string
+	hashSize    int64
+	hashModTime time.Time
+	tokens      map[string]token
+}
+
+// CreatePackServer is a factory function for creating a new PackServer.
+func CreatePackServer(path, baseURL string) *PackServer {
+	ps := new(PackServer)
+	ps.Path = path
+	ps.BaseURL = strings.TrimSuffix(baseURL, "/")
+	ps.TokenLifetime = DefaultTokenLifetime
+	ps.tokens = make(map[string]token)
+	return ps
+}
+
+// SHA1 returns the hex SHA-1 of the pack, which clients use to verify the
+// download and to reuse a cached copy. The hash is only recomputed when the
+// file's size or modification time changes.
+func (ps *PackServer) SHA1() (string, error) {
+	info, err := os.Stat(ps.Path)
+	if err != nil {
+		return "", err
+	}
+
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	if ps.hash != "" && ps.hashSize == info.Size() && ps.hashModTime.Equal(info.ModTime()) {
+		return ps.hash, nil
+	}
+
+	file, err := os.Open(ps.Path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	ps.hash = hex.EncodeToString(h.Sum(nil))
+	ps.hashSize, ps.hashModTime = info.Size(), info.ModTime()
+	return ps.hash, nil
+}
+
+// IssueToken creates a download token for a player, dropping any tokens that
+// have expired.
+func (ps *PackServer) IssueToken(player string) string {
+	var random [16]byte
+	rand.Read(random[:])
+	id := hex.EncodeToString(random[:])
+
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	now := time.Now()
+	for existing, t := range ps.tokens {
+		if now.After(t.expires) {
+			delete(ps.tokens, existing)
+		}
+	}
+	ps.tokens[id] = token{player, now.Add(ps.TokenLifetime)}
+	return id
+}
+
+// RevokeTokens invalidates every token issued to a player, such as when they
+// leave.
+func (ps *PackServer) RevokeTokens(player string) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	for id, t := range ps.tokens {
+		if t.player == player {
+			delete(ps.tokens, id)
+		}
+	}
+}
+
+func (ps *PackServer) validToken(id string) bool {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	t, ok := ps.tokens[id]
+	return ok && time.Now().Before(t.expires)
+}
+
+// URL returns the URL to send a player. With RequireToken set this issues a
+// new token for them; otherwise every player gets the same URL.
+func (ps *PackServer) URL(player string) string {
+	if !ps.RequireToken {
+		return ps.BaseURL + "/" + packFile
+	}
+	return ps.BaseURL + "/" + ps.IssueToken(player) + "/" + packFile
+}
+
+// PackID returns the UUID identifying the pack to clients since 1.20.3. Like
+// vanilla, it is derived from the URL, here without any token, so it is the
+// same for every player.
+func (ps *PackServer) PackID() [16]byte {
+	id := md5.Sum([]byte(ps.BaseURL + "/" + packFile))
+	id[6] = id[6]&0x0F | 0x30
+	id[8] = id[8]&0x3F | 0x80
+	return id
+}
+
+func (ps *PackServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	switch {
+	case path == packFile && !ps.RequireToken:
+	case strings.HasSuffix(path, "/"+packFile) && ps.validToken(strings.TrimSuffix(path, "/"+packFile)):
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	hash, err := ps.SHA1()
+	if err != nil {
+		http.Error(w, "resource pack unavailable", http.StatusInternalServerError)
+		return
+	}
+	file, err := os.Open(ps.Path)
+	if err != nil {
+		http.Error(w, "resource pack unavailable", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "resource pack unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("ETag", `"`+hash+`"`)
+	http.ServeContent(w, r, packFile, info.ModTime(), file)
+}
+
+// Packet builds the Add Resource Pack packet, called Resource Pack Send
+// before 1.20.3, telling a player to download the pack. The packet ID
+// depends on the protocol version and state, so it is passed in. From
+// 1.20.3 the packet leads with the pack's UUID and sends the prompt as NBT
+// rather than JSON. prompt may be nil.
+func (ps *PackServer) Packet(packetID int32, player string, forced bool, prompt *jsonutil.ChatObject, protocol int32) ([]byte, error) {
+	hash, err := ps.SHA1()
+	if err != nil {
+		return nil, err
+	}
+
+	pw := packetutil.CreatePacketWriter(packetID)
+	if protocol >= protocol1_20_3 {
+		id := ps.PackID()
+		pw.WriteBytes(id[:])
+	}
+	pw.WriteString(ps.URL(player))
+	pw.WriteString(hash)
+	pw.WriteBoolean(forced)
+	pw.WriteBoolean(prompt != nil)
+	if prompt != nil {
+		if protocol >= protocol1_20_3 {
+			nw := nbtutil.CreateWriter()
+			if err := nw.WriteNetwork(prompt.Compound()); err != nil {
+				return nil, fmt.Errorf("encoding prompt: %w", err)
+			}
+			pw.WriteBytes(nw.Bytes())
+		} else {
+			encoded, err := json.Marshal(prompt)
+			if err != nil {
+				return nil, fmt.Errorf("encoding prompt: %w", err)
+			}
+			pw.WriteString(string(encoded))
+		}
+	}
+	return pw.GetPacket(), nil
+}
@@ -0,0 +1,235 @@
+package scoreboardutil
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/PurpurProject/elytra/jsonutil"
+	"github.com/PurpurProject/elytra/nbtutil"
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Layout selects which version of the scoreboard packets to build.
+type Layout int
+
+const (
+	// LayoutJSON is 1.13 to 1.20.2: text as JSON, no number formats, and
+	// scores reset through Update Score.
+	LayoutJSON Layout = iota
+	// LayoutNBT is 1.20.3 to 1.21.4: text as NBT, number formats, and a
+	// separate Reset Score packet.
+	LayoutNBT
+	// LayoutEnums is 1.21.5 onwards, which sends team name tag visibility
+	// and collision rules as VarInt enums instead of strings.
+	LayoutEnums
+)
+
+// PacketIDs holds the IDs of the scoreboard packets, which change between
+// protocol versions. ResetScore is unused by LayoutJSON.
+type PacketIDs struct {
+	DisplayObjective int32
+	UpdateObjectives int32
+	UpdateScore      int32
+	ResetScore       int32
+	UpdateTeams      int32
+}
+
+// Update Objectives modes.
+const (
+	objectiveCreate int8 = iota
+	objectiveRemove
+	objectiveUpdate
+)
+
+// Update Teams modes.
+const (
+	teamCreate int8 = iota
+	teamRemove
+	teamUpdate
+	teamAddEntities
+	teamRemoveEntities
+)
+
+// visibilityIDs and collisionIDs are the enum values sent for name tag
+// visibility and collision rules by LayoutEnums.
+var (
+	visibilityIDs = map[string]int32{
+		VisibilityAlways: 0, VisibilityNever: 1, VisibilityHideForOtherTeams: 2, VisibilityHideForOwnTeam: 3,
+	}
+	collisionIDs = map[string]int32{
+		CollisionAlways: 0, CollisionNever: 1, CollisionPushOtherTeams: 2, CollisionPushOwnTeam: 3,
+	}
+)
+
+func (sb *Scoreboard) writeText(pw *packetutil.PacketWriter, text jsonutil.ChatObject) error {
+	if sb.layout == LayoutJSON {
+		encoded, err := json.Marshal(text)
+		if err != nil {
+			return fmt.Errorf("encoding scoreboard text: %w", err)
+		}
+		pw.WriteString(string(encoded))
+		return nil
+	}
+	nw := nbtutil.CreateWriter()
+	if err := nw.WriteNetwork(text.Compound()); err != nil {
+		return fmt.Errorf("encoding scoreboard text: %w", err)
+	}
+	pw.WriteBytes(nw.Bytes())
+	return nil
+}
+
+func (sb *Scoreboard) writeNumberFormat(pw *packetutil.PacketWriter, format *NumberFormat) error {
+	pw.WriteBoolean(format != nil)
+	if format == nil {
+		return nil
+	}
+	pw.WriteVarInt(int32(format.Kind))
+	switch format.Kind {
+	case FormatStyled:
+		nw := nbtutil.CreateWriter()
+		style := format.Style
+		if style == nil {
+			style = nbtutil.Compound{}
+		}
+		if err := nw.WriteNetwork(style); err != nil {
+			return fmt.Errorf("encoding number format style: %w", err)
+		}
+		pw.WriteBytes(nw.Bytes())
+	case FormatFixed:
+		return sb.writeText(pw, format.Fixed)
+	}
+	return nil
+}
+
+// objectivePacket creates or updates an objective.
+func (sb *Scoreboard) objectivePacket(obj *Objective, mode int8) ([]byte, error) {
+	pw := packetutil.CreatePacketWriter(sb.ids.UpdateObjectives)
+	pw.WriteString(obj.Name)
+	pw.WriteByte(mode)
+	if err := sb.writeText(pw, obj.DisplayName); err != nil {
+		return nil, err
+	}
+	pw.WriteVarInt(int32(obj.Render))
+	if sb.layout != LayoutJSON {
+		if err := sb.writeNumberFormat(pw, obj.NumberFormat); err != nil {
+			return nil, err
+		}
+	}
+	return pw.GetPacket(), nil
+}
+
+func (sb *Scoreboard) removeObjectivePacket(name string) []byte {
+	pw := packetutil.CreatePacketWriter(sb.ids.UpdateObjectives)
+	pw.WriteString(name)
+	pw.WriteByte(objectiveRemove)
+	return pw.GetPacket()
+}
+
+func (sb *Scoreboard) displayPacket(slot DisplaySlot, objective string) []byte {
+	pw := packetutil.CreatePacketWriter(sb.ids.DisplayObjective)
+	// Positions were a byte before 1.20.2 and a VarInt since; both encode
+	// the slot numbers identically.
+	pw.WriteVarInt(int32(slot))
+	pw.WriteString(objective)
+	return pw.GetPacket()
+}
+
+func (sb *Scoreboard) scorePacket(entity, objective string, score *Score) ([]byte, error) {
+	pw := packetutil.CreatePacketWriter(sb.ids.UpdateScore)
+	pw.WriteString(entity)
+	if sb.layout == LayoutJSON {
+		pw.WriteVarInt(0)
+		pw.WriteString(objective)
+		pw.WriteVarInt(score.Value)
+		return pw.GetPacket(), nil
+	}
+
+	pw.WriteString(objective)
+	pw.WriteVarInt(score.Value)
+	pw.WriteBoolean(score.DisplayName != nil)
+	if score.DisplayName != nil {
+		if err := sb.writeText(pw, *score.DisplayName); err != nil {
+			return nil, err
+		}
+	}
+	if err := sb.writeNumberFormat(pw, score.NumberFormat); err != nil {
+		return nil, err
+	}
+	return pw.GetPacket(), nil
+}
+
+// resetPacket removes an entity's score for an objective, or for every
+// objective if objective is empty.
+func (sb *Scoreboard) resetPacket(entity, objective string) []byte {
+	if sb.layout == LayoutJSON {
+		pw := packetutil.CreatePacketWriter(sb.ids.UpdateScore)
+		pw.WriteString(entity)
+		pw.WriteVarInt(1)
+		pw.WriteString(objective)
+		return pw.GetPacket()
+	}
+
+	pw := packetutil.CreatePacketWriter(sb.ids.ResetScore)
+	pw.WriteString(entity)
+	pw.WriteBoolean(objective != "")
+	if objective != "" {
+		pw.WriteString(objective)
+	}
+	return pw.GetPacket()
+}
+
+// teamPacket creates a team with entities, or updates it.
+func (sb *Scoreboard) teamPacket(team *Team, mode int8, entities []string) ([]byte, error) {
+	pw := packetutil.CreatePacketWriter(sb.ids.UpdateTeams)
+	pw.WriteString(team.Name)
+	pw.WriteByte(mode)
+
+	if err := sb.writeText(pw, team.DisplayName); err != nil {
+		return nil, err
+	}
+	var flags int8
+	if team.FriendlyFire {
+		flags |= 0x01
+	}
+	if team.SeeFriendlyInvisibles {
+		flags |= 0x02
+	}
+	pw.WriteByte(flags)
+	if sb.layout == LayoutEnums {
+		pw.WriteVarInt(visibilityIDs[team.visibility()])
+		pw.WriteVarInt(collisionIDs[team.collision()])
+	} else {
+		pw.WriteString(team.visibility())
+		pw.WriteString(team.collision())
+	}
+	pw.WriteVarInt(team.Color)
+	if err := sb.writeText(pw, team.Prefix); err != nil {
+		return nil, err
+	}
+	if err := sb.writeText(pw, team.Suffix); err != nil {
+		return nil, err
+	}
+
+	if mode == teamCreate {
+		writeEntities(pw, entities)
+	}
+	return pw.GetPacket(), nil
+}
+
+// membersPacket removes a team, or adds or removes some of its entities.
+func (sb *Scoreboard) membersPacket(name string, mode int8, entities []string) []byte {
+	pw := packetutil.CreatePacketWriter(sb.ids.UpdateTeams)
+	pw.WriteString(name)
+	pw.WriteByte(mode)
+	if mode != teamRemove {
+		writeEntities(pw, entities)
+	}
+	return pw.GetPacket()
+}
+
+func writeEntities(pw *packetutil.PacketWriter, entities []string) {
+	pw.WriteVarInt(int32(len(entities)))
+	for _, entity := range entities {
+		pw.WriteString(entity)
+	}
+}
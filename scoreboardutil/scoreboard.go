@@ -0,0 +1,529 @@
+package scoreboardutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/PurpurProject/elytra/jsonutil"
+	"github.com/PurpurProject/elytra/nbtutil"
+)
+
+// RenderType is how the client draws an objective's scores in the player
+// list.
+type RenderType int32
+
+const (
+	RenderInteger RenderType = iota
+	RenderHearts
+)
+
+// NumberFormatKind selects how scores are drawn, since 1.20.3.
+type NumberFormatKind int32
+
+const (
+	// FormatBlank hides the numbers.
+	FormatBlank NumberFormatKind = iota
+	// FormatStyled draws the numbers with the style in NumberFormat.Style.
+	FormatStyled
+	// FormatFixed replaces the numbers with NumberFormat.Fixed.
+	FormatFixed
+)
+
+// NumberFormat overrides how an objective's or a score's number is drawn.
+type NumberFormat struct {
+	Kind  NumberFormatKind
+	Style nbtutil.Compound
+	Fixed jsonutil.ChatObject
+}
+
+// DisplaySlot is where an objective is shown.
+type DisplaySlot int32
+
+const (
+	SlotList DisplaySlot = iota
+	SlotSidebar
+	SlotBelowName
+)
+
+// TeamSidebar returns the sidebar slot shown only to members of teams with
+// the given color, from 0 (black) to 15 (white).
+func TeamSidebar(color int32) DisplaySlot {
+	return DisplaySlot(3 + color)
+}
+
+// Name tag visibility rules.
+const (
+	VisibilityAlways            = "always"
+	VisibilityNever             = "never"
+	VisibilityHideForOtherTeams = "hideForOtherTeams"
+	VisibilityHideForOwnTeam    = "hideForOwnTeam"
+)
+
+// Collision rules.
+const (
+	CollisionAlways         = "always"
+	CollisionNever          = "never"
+	CollisionPushOtherTeams = "pushOtherTeams"
+	CollisionPushOwnTeam    = "pushOwnTeam"
+)
+
+// ColorReset is the team color meaning no color.
+const ColorReset = 21
+
+// Objective is a scoreboard objective.
+type Objective struct {
+	Name         string
+	DisplayName  jsonutil.ChatObject
+	Render       RenderType
+	NumberFormat *NumberFormat
+}
+
+// Score is an entity's score for an objective. DisplayName and NumberFormat
+// are only sent since 1.20.3.
+type Score struct {
+	Value        int32
+	DisplayName  *jsonutil.ChatObject
+	NumberFormat *NumberFormat
+}
+
+// Team is a scoreboard team. Empty rules mean "always".
+type Team struct {
+	Name                  string
+	DisplayName           jsonutil.ChatObject
+	Prefix, Suffix        jsonutil.ChatObject
+	FriendlyFire          bool
+	SeeFriendlyInvisibles bool
+	NameTagVisibility     string
+	CollisionRule         string
+	Color                 int32
+}
+
+func (t *Team) visibility() string {
+	if t.NameTagVisibility == "" {
+		return VisibilityAlways
+	}
+	return t.NameTagVisibility
+}
+
+func (t *Team) collision() string {
+	if t.CollisionRule == "" {
+		return CollisionAlways
+	}
+	return t.CollisionRule
+}
+
+// Scoreboard is a server-side scoreboard. Every change that the client
+// would see queues the packet that makes it, and nothing is queued for a
+// change that doesn't alter anything, so Flush returns the smallest set of
+// packets to bring viewers up to date.
+//
+// Players can share a scoreboard or each have their own. To move a player
+// to another scoreboard, send them the old one's ClearPackets and then the
+// new one's Packets.
+type Scoreboard struct {
+	ids    PacketIDs
+	layout Layout
+
+	lock       sync.Mutex
+	objectives map[string]*Objective
+	// scores holds each objective's scores by entity name.
+	scores   map[string]map[string]*Score
+	display  map[DisplaySlot]string
+	teams    map[string]*Team
+	members  map[string]map[string]bool
+	memberOf map[string]string
+	pending  [][]byte
+}
+
+// CreateScoreboard is a factory function for creating a new, empty
+// Scoreboard that builds packets for a protocol version.
+func CreateScoreboard(ids PacketIDs, layout Layout) *Scoreboard {
+	sb := new(Scoreboard)
+	sb.ids = ids
+	sb.layout = layout
+	sb.objectives = make(map[string]*Objective)
+	sb.scores = make(map[string]map[string]*Score)
+	sb.display = make(map[DisplaySlot]string)
+	sb.teams = make(map[string]*Team)
+	sb.members = make(map[string]map[string]bool)
+	sb.memberOf = make(map[string]string)
+	return sb
+}
+
+// Flush returns the packets queued by changes since the last call.
+func (sb *Scoreboard) Flush() [][]byte {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	pending := sb.pending
+	sb.pending = nil
+	return pending
+}
+
+// SetObjective adds an objective, or updates the one with the same name. It
+// returns an error, changing nothing, if the objective's text can't be
+// encoded.
+func (sb *Scoreboard) SetObjective(obj Objective) error {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	existing, ok := sb.objectives[obj.Name]
+	if ok {
+		if reflect.DeepEqual(*existing, obj) {
+			return nil
+		}
+		packet, err := sb.objectivePacket(&obj, objectiveUpdate)
+		if err != nil {
+			return err
+		}
+		*existing = obj
+		sb.pending = append(sb.pending, packet)
+		return nil
+	}
+	created := obj
+	packet, err := sb.objectivePacket(&created, objectiveCreate)
+	if err != nil {
+		return err
+	}
+	sb.objectives[obj.Name] = &created
+	sb.scores[obj.Name] = make(map[string]*Score)
+	sb.pending = append(sb.pending, packet)
+	return nil
+}
+
+// Objective returns an objective.
+func (sb *Scoreboard) Objective(name string) (Objective, bool) {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	obj, ok := sb.objectives[name]
+	if !ok {
+		return Objective{}, false
+	}
+	return *obj, true
+}
+
+// RemoveObjective removes an objective along with its scores and any slots
+// it is displayed in. The client drops those itself.
+func (sb *Scoreboard) RemoveObjective(name string) bool {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	obj, ok := sb.objectives[name]
+	if !ok {
+		return false
+	}
+	delete(sb.objectives, name)
+	delete(sb.scores, name)
+	for slot, shown := range sb.display {
+		if shown == name {
+			delete(sb.display, slot)
+		}
+	}
+	sb.pending = append(sb.pending, sb.removeObjectivePacket(obj.Name))
+	return true
+}
+
+// SetDisplay shows an objective in a slot, or clears the slot if objective
+// is empty.
+func (sb *Scoreboard) SetDisplay(slot DisplaySlot, objective string) error {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	if objective != "" && sb.objectives[objective] == nil {
+		return fmt.Errorf("unknown objective %q", objective)
+	}
+	if sb.display[slot] == objective {
+		return nil
+	}
+	if objective == "" {
+		delete(sb.display, slot)
+	} else {
+		sb.display[slot] = objective
+	}
+	sb.pending = append(sb.pending, sb.displayPacket(slot, objective))
+	return nil
+}
+
+// Display returns the objective shown in a slot, if any.
+func (sb *Scoreboard) Display(slot DisplaySlot) string {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+	return sb.display[slot]
+}
+
+// SetScore sets an entity's score for an objective, keeping its display name
+// and number format.
+func (sb *Scoreboard) SetScore(entity, objective string, value int32) error {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	scores, ok := sb.scores[objective]
+	if !ok {
+		return fmt.Errorf("unknown objective %q", objective)
+	}
+	score := Score{Value: value}
+	if existing, ok := scores[entity]; ok {
+		score.DisplayName, score.NumberFormat = existing.DisplayName, existing.NumberFormat
+	}
+	return sb.setScore(scores, entity, objective, score)
+}
+
+// SetScoreDetails sets an entity's score for an objective along with how it
+// is drawn. It returns an error, changing nothing, if the score's text
+// can't be encoded.
+func (sb *Scoreboard) SetScoreDetails(entity, objective string, score Score) error {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	scores, ok := sb.scores[objective]
+	if !ok {
+		return fmt.Errorf("unknown objective %q", objective)
+	}
+	return sb.setScore(scores, entity, objective, score)
+}
+
+func (sb *Scoreboard) setScore(scores map[string]*Score, entity, objective string, score Score) error {
+	if existing, ok := scores[entity]; ok && reflect.DeepEqual(*existing, score) {
+		return nil
+	}
+	packet, err := sb.scorePacket(entity, objective, &score)
+	if err != nil {
+		return err
+	}
+	scores[entity] = &score
+	sb.pending = append(sb.pending, packet)
+	return nil
+}
+
+// Score returns an entity's score for an objective.
+func (sb *Scoreboard) Score(entity, objective string) (Score, bool) {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	score, ok := sb.scores[objective][entity]
+	if !ok {
+		return Score{}, false
+	}
+	return *score, true
+}
+
+// ResetScore removes an entity's score for an objective, or for every
+// objective if objective is empty.
+func (sb *Scoreboard) ResetScore(entity, objective string) {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	if objective != "" {
+		if _, ok := sb.scores[objective][entity]; !ok {
+			return
+		}
+		delete(sb.scores[objective], entity)
+		sb.pending = append(sb.pending, sb.resetPacket(entity, objective))
+		return
+	}
+
+	found := false
+	for _, scores := range sb.scores {
+		if _, ok := scores[entity]; ok {
+			delete(scores, entity)
+			found = true
+		}
+	}
+	if found {
+		sb.pending = append(sb.pending, sb.resetPacket(entity, ""))
+	}
+}
+
+// SetTeam adds a team, or updates the one with the same name. Members are
+// managed with AddToTeam and RemoveFromTeam. It returns an error, changing
+// nothing, if the team's text can't be encoded.
+func (sb *Scoreboard) SetTeam(team Team) error {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	existing, ok := sb.teams[team.Name]
+	if ok {
+		if reflect.DeepEqual(*existing, team) {
+			return nil
+		}
+		packet, err := sb.teamPacket(&team, teamUpdate, nil)
+		if err != nil {
+			return err
+		}
+		*existing = team
+		sb.pending = append(sb.pending, packet)
+		return nil
+	}
+	created := team
+	packet, err := sb.teamPacket(&created, teamCreate, nil)
+	if err != nil {
+		return err
+	}
+	sb.teams[team.Name] = &created
+	sb.members[team.Name] = make(map[string]bool)
+	sb.pending = append(sb.pending, packet)
+	return nil
+}
+
+// Team returns a team.
+func (sb *Scoreboard) Team(name string) (Team, bool) {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	team, ok := sb.teams[name]
+	if !ok {
+		return Team{}, false
+	}
+	return *team, true
+}
+
+// RemoveTeam removes a team. Its members are left without a team.
+func (sb *Scoreboard) RemoveTeam(name string) bool {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	team, ok := sb.teams[name]
+	if !ok {
+		return false
+	}
+	for entity := range sb.members[name] {
+		delete(sb.memberOf, entity)
+	}
+	delete(sb.teams, name)
+	delete(sb.members, name)
+	sb.pending = append(sb.pending, sb.membersPacket(team.Name, teamRemove, nil))
+	return true
+}
+
+// AddToTeam adds entities, by player name or entity UUID, to a team. An
+// entity can only be on one team, so it leaves its previous one; the client
+// does the same on its side.
+func (sb *Scoreboard) AddToTeam(name string, entities ...string) error {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	if _, ok := sb.teams[name]; !ok {
+		return fmt.Errorf("unknown team %q", name)
+	}
+	var added []string
+	for _, entity := range entities {
+		if previous, ok := sb.memberOf[entity]; ok {
+			if previous == name {
+				continue
+			}
+			delete(sb.members[previous], entity)
+		}
+		sb.memberOf[entity] = name
+		sb.members[name][entity] = true
+		added = append(added, entity)
+	}
+	if len(added) > 0 {
+		sb.pending = append(sb.pending, sb.membersPacket(name, teamAddEntities, added))
+	}
+	return nil
+}
+
+// RemoveFromTeam takes entities off whatever team they are on.
+func (sb *Scoreboard) RemoveFromTeam(entities ...string) {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	removed := make(map[string][]string)
+	var order []string
+	for _, entity := range entities {
+		name, ok := sb.memberOf[entity]
+		if !ok {
+			continue
+		}
+		delete(sb.memberOf, entity)
+		delete(sb.members[name], entity)
+		if removed[name] == nil {
+			order = append(order, name)
+		}
+		removed[name] = append(removed[name], entity)
+	}
+	for _, name := range order {
+		sb.pending = append(sb.pending, sb.membersPacket(name, teamRemoveEntities, removed[name]))
+	}
+}
+
+// TeamOf returns the name of the team an entity is on, if any.
+func (sb *Scoreboard) TeamOf(entity string) (string, bool) {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	name, ok := sb.memberOf[entity]
+	return name, ok
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Packets returns the packets that send the whole scoreboard to a player who
+// hasn't seen it yet.
+func (sb *Scoreboard) Packets() ([][]byte, error) {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	var packets [][]byte
+	for _, name := range sortedKeys(sb.objectives) {
+		packet, err := sb.objectivePacket(sb.objectives[name], objectiveCreate)
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, packet)
+		scores := sb.scores[name]
+		for _, entity := range sortedKeys(scores) {
+			packet, err := sb.scorePacket(entity, name, scores[entity])
+			if err != nil {
+				return nil, err
+			}
+			packets = append(packets, packet)
+		}
+	}
+
+	slots := make([]DisplaySlot, 0, len(sb.display))
+	for slot := range sb.display {
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i] < slots[j]
+	})
+	for _, slot := range slots {
+		packets = append(packets, sb.displayPacket(slot, sb.display[slot]))
+	}
+
+	for _, name := range sortedKeys(sb.teams) {
+		packet, err := sb.teamPacket(sb.teams[name], teamCreate, sortedKeys(sb.members[name]))
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, packet)
+	}
+	return packets, nil
+}
+
+// ClearPackets returns the packets that remove the whole scoreboard from a
+// player who has seen it.
+func (sb *Scoreboard) ClearPackets() [][]byte {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+
+	var packets [][]byte
+	for _, name := range sortedKeys(sb.objectives) {
+		packets = append(packets, sb.removeObjectivePacket(name))
+	}
+	for _, name := range sortedKeys(sb.teams) {
+		packets = append(packets, sb.membersPacket(name, teamRemove, nil))
+	}
+	return packets
+}
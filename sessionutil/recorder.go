@@ -0,0 +1,155 @@
+package sessionutil
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// fileMagic starts every session recording, followed by a single format
+// version byte.
+var fileMagic = []byte("ELYTRASESSION")
+
+const formatVersion byte = 1
+
+// maxRecordSize caps the size of a single packet body or wire payload read
+// back from a recording, so a corrupt file can't make us allocate gigabytes.
+const maxRecordSize = 32 * 1024 * 1024
+
+const flagHasWire byte = 0x01
+
+// Direction tells which way a recorded packet travelled.
+type Direction byte
+
+const (
+	Serverbound Direction = iota
+	Clientbound
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Serverbound:
+		return "serverbound"
+	case Clientbound:
+		return "clientbound"
+	}
+	return fmt.Sprintf("Direction(%d)", byte(d))
+}
+
+// Record is a single packet captured from a connection.
+type Record struct {
+	// Time is the offset from the moment recording started.
+	Time      time.Duration
+	Direction Direction
+	// Packet holds the packet ID and body after decompression.
+	Packet packetutil.Packet
+	// Wire holds the packet exactly as it was sent over the connection,
+	// including the length prefix and, if enabled, compression. It is nil
+	// when the recorder was not given the wire bytes.
+	Wire []byte
+}
+
+// Recorder writes the packets of a connection to a session recording, which
+// can later be read back with a SessionReader. A Recorder is safe for
+// concurrent use, so both directions of a connection can share one.
+type Recorder struct {
+	lock   sync.Mutex
+	writer *bufio.Writer
+	closer io.Closer
+	start  time.Time
+}
+
+// CreateRecorder is a factory function for creating a new Recorder that
+// writes to w. The recording header is written immediately.
+func CreateRecorder(w io.Writer) (*Recorder, error) {
+	r := new(Recorder)
+	r.writer = bufio.NewWriter(w)
+	r.start = time.Now()
+
+	if _, err := r.writer.Write(fileMagic); err != nil {
+		return nil, err
+	}
+	if err := r.writer.WriteByte(formatVersion); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// CreateFileRecorder creates (or truncates) the file at path and returns a
+// Recorder writing to it. Closing the Recorder closes the file.
+func CreateFileRecorder(path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := CreateRecorder(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	r.closer = file
+	return r, nil
+}
+
+// Record appends a packet to the recording. wire may be nil if the bytes as
+// seen on the connection are not available. A packet that is too large to
+// record is refused before anything is written, so the recording stays
+// readable.
+func (r *Recorder) Record(direction Direction, p *packetutil.Packet, wire []byte) error {
+	for _, data := range [][]byte{p.Data, wire} {
+		if len(data) > maxRecordSize {
+			return fmt.Errorf("record of %d bytes exceeds the maximum of %d", len(data), maxRecordSize)
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var flags byte
+	if wire != nil {
+		flags |= flagHasWire
+	}
+
+	record := make([]byte, 0, 14+4+len(p.Data)+4+len(wire))
+	record = binary.BigEndian.AppendUint64(record, uint64(time.Since(r.start)))
+	record = append(record, byte(direction), flags)
+	record = binary.BigEndian.AppendUint32(record, uint32(p.ID))
+	record = appendChunk(record, p.Data)
+	if wire != nil {
+		record = appendChunk(record, wire)
+	}
+
+	_, err := r.writer.Write(record)
+	return err
+}
+
+func appendChunk(record, data []byte) []byte {
+	record = binary.BigEndian.AppendUint32(record, uint32(len(data)))
+	return append(record, data...)
+}
+
+// Flush writes any buffered records to the underlying writer.
+func (r *Recorder) Flush() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.writer.Flush()
+}
+
+// Close flushes the recording, and closes the underlying file if the
+// Recorder was created with CreateFileRecorder.
+func (r *Recorder) Close() error {
+	if err := r.Flush(); err != nil {
+		return err
+	}
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package sessionutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// SessionReader reads back the records of a session recording.
+type SessionReader struct {
+	reader *bufio.Reader
+	closer io.Closer
+}
+
+// CreateSessionReader is a factory function for creating a new SessionReader.
+// It returns an error if r does not start with a supported recording header.
+func CreateSessionReader(r io.Reader) (*SessionReader, error) {
+	sr := new(SessionReader)
+	sr.reader = bufio.NewReader(r)
+
+	header := make([]byte, len(fileMagic)+1)
+	if _, err := io.ReadFull(sr.reader, header); err != nil {
+		return nil, fmt.Errorf("could not read session header: %w", err)
+	}
+	if !bytes.Equal(header[:len(fileMagic)], fileMagic) {
+		return nil, fmt.Errorf("not a session recording")
+	}
+	if header[len(fileMagic)] != formatVersion {
+		return nil, fmt.Errorf("session format version %d is not supported", header[len(fileMagic)])
+	}
+	return sr, nil
+}
+
+// OpenSessionFile opens the recording at path. Closing the SessionReader
+// closes the file.
+func OpenSessionFile(path string) (*SessionReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sr, err := CreateSessionReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	sr.closer = file
+	return sr, nil
+}
+
+// Next returns the next record in the recording, or io.EOF once every record
+// has been read.
+func (sr *SessionReader) Next() (*Record, error) {
+	header := make([]byte, 14)
+	if _, err := io.ReadFull(sr.reader, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated session record: %w", err)
+		}
+		return nil, err
+	}
+
+	record := new(Record)
+	record.Time = time.Duration(binary.BigEndian.Uint64(header[0:8]))
+	record.Direction = Direction(header[8])
+	flags := header[9]
+	record.Packet.ID = int32(binary.BigEndian.Uint32(header[10:14]))
+
+	var err error
+	if record.Packet.Data, err = sr.readChunk(); err != nil {
+		return nil, err
+	}
+	if flags&flagHasWire != 0 {
+		if record.Wire, err = sr.readChunk(); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+func (sr *SessionReader) readChunk() ([]byte, error) {
+	sizeBytes := make([]byte, 4)
+	if _, err := io.ReadFull(sr.reader, sizeBytes); err != nil {
+		return nil, fmt.Errorf("truncated session record: %w", err)
+	}
+
+	size := binary.BigEndian.Uint32(sizeBytes)
+	if size > maxRecordSize {
+		return nil, fmt.Errorf("record of %d bytes exceeds the maximum of %d", size, maxRecordSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(sr.reader, data); err != nil {
+		return nil, fmt.Errorf("truncated session record: %w", err)
+	}
+	return data, nil
+}
+
+// Close closes the underlying file if the SessionReader was created with
+// OpenSessionFile.
+func (sr *SessionReader) Close() error {
+	if sr.closer != nil {
+		return sr.closer.Close()
+	}
+	return nil
+}
+
+// ReplayOptions controls which records are replayed and how fast.
+type ReplayOptions struct {
+	// Direction selects the records to replay; the others are skipped.
+	Direction Direction
+	// Paced waits between records so they are replayed with the same timing
+	// they were recorded with, rather than as fast as possible.
+	Paced bool
+}
+
+// ReplayToDispatcher feeds the recorded packets into a Dispatcher, as if they
+// had just been received.
+func ReplayToDispatcher(sr *SessionReader, d *packetutil.Dispatcher, opts ReplayOptions) error {
+	return replay(sr, opts, func(record *Record) error {
+		_, err := d.Dispatch(&record.Packet)
+		return err
+	})
+}
+
+// ReplayToWriter writes the recorded packets to w, which is usually a live
+// connection. The recorded wire bytes are written when present, so a
+// compressed session replays byte for byte; otherwise the packet is framed
+// uncompressed.
+func ReplayToWriter(sr *SessionReader, w io.Writer, opts ReplayOptions) error {
+	return replay(sr, opts, func(record *Record) error {
+		wire := record.Wire
+		if wire == nil {
+			pw := packetutil.CreatePacketWriter(record.Packet.ID)
+			pw.WriteBytes(record.Packet.Data)
+			wire = pw.GetPacket()
+		}
+		_, err := w.Write(wire)
+		return err
+	})
+}
+
+func replay(sr *SessionReader, opts ReplayOptions, emit func(*Record) error) error {
+	start := time.Now()
+	for {
+		record, err := sr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if record.Direction != opts.Direction {
+			continue
+		}
+		if opts.Paced {
+			if wait := record.Time - time.Since(start); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		if err := emit(record); err != nil {
+			return err
+		}
+	}
+}
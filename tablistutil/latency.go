@@ -0,0 +1,49 @@
+package tablistutil
+
+import "time"
+
+// LatencyStats describes a player's connection as measured by keep-alive
+// round trips.
+type LatencyStats struct {
+	// Last is the most recent round trip.
+	Last time.Duration
+	// Smoothed is the rolling round trip estimate, weighting the old value
+	// three to one as vanilla does. It is what the entry's Latency is sent
+	// as.
+	Smoothed time.Duration
+	// Jitter is the rolling mean of how much each round trip differed from
+	// the one before, weighted as RTP measures it, fifteen to one.
+	Jitter time.Duration
+	// Samples is how many round trips have been measured.
+	Samples int
+	// Pending is how many keep-alives are waiting for a reply.
+	Pending int
+}
+
+// observe folds a round trip into the stats.
+func (ls *LatencyStats) observe(rtt time.Duration) {
+	if ls.Samples > 0 {
+		diff := rtt - ls.Last
+		if diff < 0 {
+			diff = -diff
+		}
+		ls.Jitter += (diff - ls.Jitter) / 16
+	}
+	ls.Smoothed = (ls.Smoothed*3 + rtt) / 4
+	ls.Last = rtt
+	ls.Samples++
+}
+
+// LatencyStats returns the latency measured for a player.
+func (tl *TabList) LatencyStats(uuid [16]byte) (LatencyStats, bool) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	e, ok := tl.entries[uuid]
+	if !ok {
+		return LatencyStats{}, false
+	}
+	stats := e.stats
+	stats.Pending = len(e.pings)
+	return stats, true
+}
@@ -0,0 +1,201 @@
+package tablistutil
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/PurpurProject/elytra/connutil"
+)
+
+// Errors Join returns for a player who can't join.
+var (
+	ErrServerFull    = errors.New("server is full")
+	ErrAlreadyOnline = errors.New("player is already online")
+)
+
+// DuplicatePolicy decides what happens when a player logs in while a player
+// with the same UUID is online.
+type DuplicatePolicy int
+
+const (
+	// ReplaceExisting lets the new login in and hands back the player it
+	// replaces to be disconnected, as vanilla does.
+	ReplaceExisting DuplicatePolicy = iota
+	// RefuseNew turns the new login away.
+	RefuseNew
+)
+
+// Player is a player in a PlayerList, with the queue packets are sent to
+// them on.
+type Player struct {
+	UUID  [16]byte
+	Name  string
+	Queue *connutil.SendQueue
+}
+
+// PlayerList keeps track of the players online. Each has an entry in a tab
+// list, whose changes Flush sends to everyone, so game modes and latency are
+// set on the TabList and every player sees them. It is safe for concurrent
+// use.
+type PlayerList struct {
+	tab        *TabList
+	maxPlayers int
+	policy     DuplicatePolicy
+
+	lock    sync.Mutex
+	players map[[16]byte]*Player
+}
+
+// CreatePlayerList is a factory function for creating a new, empty
+// PlayerList whose players are shown in tab. maxPlayers of 0 or less allows
+// any number.
+func CreatePlayerList(tab *TabList, maxPlayers int, policy DuplicatePolicy) *PlayerList {
+	pl := new(PlayerList)
+	pl.tab = tab
+	pl.maxPlayers = maxPlayers
+	pl.policy = policy
+	pl.players = make(map[[16]byte]*Player)
+	return pl
+}
+
+// TabList returns the tab list the players are shown in.
+func (pl *PlayerList) TabList() *TabList {
+	return pl.tab
+}
+
+// MaxPlayers returns the most players allowed online, or 0 or less for no
+// limit.
+func (pl *PlayerList) MaxPlayers() int {
+	return pl.maxPlayers
+}
+
+// Join adds a player, sending the whole tab list to them and their entry to
+// everyone else. If a player with the same UUID is online and the policy
+// replaces them, they are taken off the list and returned so the caller can
+// disconnect them. Joining fails with ErrServerFull, ErrAlreadyOnline or
+// the entry's encoding error, leaving the list as it was.
+func (pl *PlayerList) Join(e Entry, queue *connutil.SendQueue) (player, replaced *Player, err error) {
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+
+	existing, online := pl.players[e.UUID]
+	if online && pl.policy == RefuseNew {
+		return nil, nil, ErrAlreadyOnline
+	}
+	if !online && pl.maxPlayers > 0 && len(pl.players) >= pl.maxPlayers {
+		return nil, nil, ErrServerFull
+	}
+
+	// Changes made before the player joined go only to those already on.
+	pl.flush()
+	// Add replaces the entry of a player with the same UUID.
+	if err := pl.tab.Add(e); err != nil {
+		return nil, nil, err
+	}
+	if online {
+		delete(pl.players, e.UUID)
+		replaced = existing
+	}
+	pl.flush()
+
+	player = &Player{UUID: e.UUID, Name: e.Name, Queue: queue}
+	pl.players[e.UUID] = player
+	for _, packet := range pl.tab.Packets() {
+		queue.SendFrame(connutil.PriorityChat, packet)
+	}
+	return player, replaced, nil
+}
+
+// Leave takes a player off the list and tells everyone else. It does nothing
+// if they have already been replaced by a newer login.
+func (pl *PlayerList) Leave(player *Player) {
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+
+	if pl.players[player.UUID] != player {
+		return
+	}
+	delete(pl.players, player.UUID)
+	pl.tab.Remove(player.UUID)
+	pl.flush()
+}
+
+// Flush sends the tab list changes queued since the last call to every
+// player.
+func (pl *PlayerList) Flush() {
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+	pl.flush()
+}
+
+func (pl *PlayerList) flush() {
+	for _, packet := range pl.tab.Flush() {
+		pl.broadcast(connutil.PriorityChat, packet, nil)
+	}
+}
+
+// Broadcast sends a packet built with PacketWriter to every player but
+// those in except. Players whose queue is closed are skipped, as they are
+// on their way out.
+func (pl *PlayerList) Broadcast(priority connutil.Priority, frame []byte, except ...[16]byte) {
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+	pl.broadcast(priority, frame, except)
+}
+
+func (pl *PlayerList) broadcast(priority connutil.Priority, frame []byte, except [][16]byte) {
+outer:
+	for uuid, player := range pl.players {
+		for _, skip := range except {
+			if uuid == skip {
+				continue outer
+			}
+		}
+		player.Queue.SendFrame(priority, frame)
+	}
+}
+
+// Count returns the number of players online.
+func (pl *PlayerList) Count() int {
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+	return len(pl.players)
+}
+
+// Player returns the player with a UUID.
+func (pl *PlayerList) Player(uuid [16]byte) (*Player, bool) {
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+	player, ok := pl.players[uuid]
+	return player, ok
+}
+
+// PlayerByName returns the player with a name, ignoring case as vanilla
+// does.
+func (pl *PlayerList) PlayerByName(name string) (*Player, bool) {
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+	for _, player := range pl.players {
+		if strings.EqualFold(player.Name, name) {
+			return player, true
+		}
+	}
+	return nil, false
+}
+
+// Players returns the players online, sorted by name.
+func (pl *PlayerList) Players() []*Player {
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+
+	players := make([]*Player, 0, len(pl.players))
+	for _, player := range pl.players {
+		players = append(players, player)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].Name < players[j].Name
+	})
+	return players
+}
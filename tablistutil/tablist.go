@@ -0,0 +1,492 @@
+package tablistutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PurpurProject/elytra/jsonutil"
+	"github.com/PurpurProject/elytra/nbtutil"
+	"github.com/PurpurProject/elytra/packetutil"
+)
+
+// Layout selects which version of the Player Info Update packet to build.
+type Layout int
+
+const (
+	// LayoutJSON is 1.19.3 to 1.20.2, with display names as JSON.
+	LayoutJSON Layout = iota
+	// LayoutNBT is 1.20.3 to 1.21.1, with display names as NBT.
+	LayoutNBT
+	// LayoutListOrder is 1.21.2 onwards, which adds the list order.
+	LayoutListOrder
+)
+
+// PacketIDs holds the IDs of the tab list packets, which change between
+// protocol versions.
+type PacketIDs struct {
+	PlayerInfoUpdate int32
+	PlayerInfoRemove int32
+	HeaderFooter     int32
+}
+
+// Player Info Update actions. Initialize Chat (0x02) is left to the chat
+// session code.
+const (
+	actionAddPlayer   byte = 0x01
+	actionGameMode    byte = 0x04
+	actionListed      byte = 0x08
+	actionLatency     byte = 0x10
+	actionDisplayName byte = 0x20
+	actionListOrder   byte = 0x40
+)
+
+// Property is a game profile property, such as the skin textures.
+type Property struct {
+	Name      string
+	Value     string
+	Signature string
+}
+
+// Entry is a player shown in the tab list.
+type Entry struct {
+	UUID       [16]byte
+	Name       string
+	Properties []Property
+	GameMode   int32
+	Listed     bool
+	// Latency is the smoothed round trip time in milliseconds, which the
+	// client draws as signal bars.
+	Latency int32
+	// DisplayName replaces Name in the list when set, before the Format
+	// hook is applied.
+	DisplayName *jsonutil.ChatObject
+	// ListOrder sorts the list, highest first, since 1.21.2.
+	ListOrder int32
+}
+
+type entry struct {
+	Entry
+	// shown is the display name last sent, after formatting, and
+	// shownText is it encoded.
+	shown          *jsonutil.ChatObject
+	shownText      []byte
+	latencyChanged bool
+	pings          map[int64]time.Time
+	stats          LatencyStats
+}
+
+// TabList keeps the entries and header and footer of a tab list and queues
+// the packets that keep clients in step with it, sending only what changed.
+// Latency comes from keep-alive round trips, which LatencyStats reports in
+// more detail, and is sent in batches by FlushLatency, which vanilla does
+// every 30 seconds.
+type TabList struct {
+	// Format, if set, decides each entry's displayed name, such as adding a
+	// rank prefix. It is given the entry and returns the name to show, or
+	// nil for the plain name. Call Reformat after changing what it depends
+	// on. It runs with the list locked, so it must not call back into it.
+	Format func(e Entry) *jsonutil.ChatObject
+
+	ids    PacketIDs
+	layout Layout
+
+	lock       sync.Mutex
+	entries    map[[16]byte]*entry
+	header     jsonutil.ChatObject
+	footer     jsonutil.ChatObject
+	headerText []byte
+	footerText []byte
+	pending    [][]byte
+}
+
+// CreateTabList is a factory function for creating a new, empty TabList
+// that builds packets for a protocol version.
+func CreateTabList(ids PacketIDs, layout Layout) *TabList {
+	tl := new(TabList)
+	tl.ids = ids
+	tl.layout = layout
+	tl.entries = make(map[[16]byte]*entry)
+	// An empty component always encodes.
+	tl.headerText, _ = tl.encodeText(&tl.header)
+	tl.footerText = tl.headerText
+	return tl
+}
+
+// Flush returns the packets queued by changes since the last call.
+func (tl *TabList) Flush() [][]byte {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	pending := tl.pending
+	tl.pending = nil
+	return pending
+}
+
+func (tl *TabList) format(e *entry) *jsonutil.ChatObject {
+	if tl.Format != nil {
+		return tl.Format(e.Entry)
+	}
+	return e.DisplayName
+}
+
+// Add adds a player to the list, or replaces the entry with the same UUID.
+// It returns an error, leaving the list as it was, if the displayed name
+// can't be encoded.
+func (tl *TabList) Add(e Entry) error {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	added := &entry{Entry: e, pings: make(map[int64]time.Time)}
+	if existing, ok := tl.entries[e.UUID]; ok {
+		added.pings = existing.pings
+		added.stats = existing.stats
+	}
+	shown := tl.format(added)
+	shownText, err := tl.encodeText(shown)
+	if err != nil {
+		return err
+	}
+	added.shown, added.shownText = shown, shownText
+	tl.entries[e.UUID] = added
+	tl.pending = append(tl.pending, tl.updatePacket(tl.addActions(), []*entry{added}))
+	return nil
+}
+
+func (tl *TabList) addActions() byte {
+	actions := actionAddPlayer | actionGameMode | actionListed | actionLatency | actionDisplayName
+	if tl.layout >= LayoutListOrder {
+		actions |= actionListOrder
+	}
+	return actions
+}
+
+// Remove takes players off the list.
+func (tl *TabList) Remove(uuids ...[16]byte) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	var removed [][16]byte
+	for _, uuid := range uuids {
+		if _, ok := tl.entries[uuid]; ok {
+			delete(tl.entries, uuid)
+			removed = append(removed, uuid)
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+	pw := packetutil.CreatePacketWriter(tl.ids.PlayerInfoRemove)
+	pw.WriteVarInt(int32(len(removed)))
+	for _, uuid := range removed {
+		pw.WriteBytes(uuid[:])
+	}
+	tl.pending = append(tl.pending, pw.GetPacket())
+}
+
+// Entry returns a player's entry.
+func (tl *TabList) Entry(uuid [16]byte) (Entry, bool) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	e, ok := tl.entries[uuid]
+	if !ok {
+		return Entry{}, false
+	}
+	return e.Entry, true
+}
+
+// update applies a change to an entry and queues it if it made one.
+func (tl *TabList) update(uuid [16]byte, action byte, change func(e *entry) bool) bool {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	e, ok := tl.entries[uuid]
+	if !ok || !change(e) {
+		return false
+	}
+	tl.pending = append(tl.pending, tl.updatePacket(action, []*entry{e}))
+	return true
+}
+
+// SetGameMode changes a player's game mode, which also moves spectators to
+// the bottom of the list.
+func (tl *TabList) SetGameMode(uuid [16]byte, gameMode int32) bool {
+	return tl.update(uuid, actionGameMode, func(e *entry) bool {
+		if e.GameMode == gameMode {
+			return false
+		}
+		e.GameMode = gameMode
+		return true
+	})
+}
+
+// SetListed shows or hides a player in the list.
+func (tl *TabList) SetListed(uuid [16]byte, listed bool) bool {
+	return tl.update(uuid, actionListed, func(e *entry) bool {
+		if e.Listed == listed {
+			return false
+		}
+		e.Listed = listed
+		return true
+	})
+}
+
+// SetListOrder changes where a player sorts in the list. It does nothing
+// before LayoutListOrder.
+func (tl *TabList) SetListOrder(uuid [16]byte, order int32) bool {
+	if tl.layout < LayoutListOrder {
+		return false
+	}
+	return tl.update(uuid, actionListOrder, func(e *entry) bool {
+		if e.ListOrder == order {
+			return false
+		}
+		e.ListOrder = order
+		return true
+	})
+}
+
+// SetDisplayName changes a player's display name, which is passed through
+// the Format hook. It returns an error, keeping the old name, if the
+// displayed name can't be encoded.
+func (tl *TabList) SetDisplayName(uuid [16]byte, name *jsonutil.ChatObject) (bool, error) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	e, ok := tl.entries[uuid]
+	if !ok {
+		return false, nil
+	}
+	old := e.DisplayName
+	e.DisplayName = name
+	shown := tl.format(e)
+	if reflect.DeepEqual(shown, e.shown) {
+		return false, nil
+	}
+	shownText, err := tl.encodeText(shown)
+	if err != nil {
+		e.DisplayName = old
+		return false, err
+	}
+	e.shown, e.shownText = shown, shownText
+	tl.pending = append(tl.pending, tl.updatePacket(actionDisplayName, []*entry{e}))
+	return true, nil
+}
+
+// Reformat runs the Format hook over every entry again, queueing one update
+// for the names that changed. Entries whose new name can't be encoded keep
+// their old one, and the first such error is returned.
+func (tl *TabList) Reformat() error {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	var changed []*entry
+	var firstErr error
+	for _, e := range tl.sorted() {
+		shown := tl.format(e)
+		if reflect.DeepEqual(shown, e.shown) {
+			continue
+		}
+		shownText, err := tl.encodeText(shown)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		e.shown, e.shownText = shown, shownText
+		changed = append(changed, e)
+	}
+	if len(changed) > 0 {
+		tl.pending = append(tl.pending, tl.updatePacket(actionDisplayName, changed))
+	}
+	return firstErr
+}
+
+// KeepAliveSent records that a keep-alive was sent to a player.
+func (tl *TabList) KeepAliveSent(uuid [16]byte, id int64, at time.Time) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	if e, ok := tl.entries[uuid]; ok {
+		e.pings[id] = at
+	}
+}
+
+// KeepAliveReceived records a player's keep-alive reply and folds the round
+// trip into their LatencyStats, updating their latency from the smoothed
+// round trip. It returns false for an ID that wasn't sent.
+func (tl *TabList) KeepAliveReceived(uuid [16]byte, id int64, at time.Time) bool {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	e, ok := tl.entries[uuid]
+	if !ok {
+		return false
+	}
+	sent, ok := e.pings[id]
+	if !ok {
+		return false
+	}
+	// Drop any older keep-alives the client skipped.
+	for pending, pendingAt := range e.pings {
+		if !pendingAt.After(sent) {
+			delete(e.pings, pending)
+		}
+	}
+
+	if e.stats.Samples == 0 {
+		e.stats.Smoothed = time.Duration(e.Latency) * time.Millisecond
+	}
+	e.stats.observe(at.Sub(sent))
+	latency := int32(e.stats.Smoothed.Milliseconds())
+	if latency != e.Latency {
+		e.Latency = latency
+		e.latencyChanged = true
+	}
+	return true
+}
+
+// FlushLatency queues a single update carrying every latency that changed
+// since the last call.
+func (tl *TabList) FlushLatency() {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	var changed []*entry
+	for _, e := range tl.sorted() {
+		if e.latencyChanged {
+			e.latencyChanged = false
+			changed = append(changed, e)
+		}
+	}
+	if len(changed) > 0 {
+		tl.pending = append(tl.pending, tl.updatePacket(actionLatency, changed))
+	}
+}
+
+// SetHeaderFooter changes the text above and below the list, or returns an
+// error if either can't be encoded.
+func (tl *TabList) SetHeaderFooter(header, footer jsonutil.ChatObject) error {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	if reflect.DeepEqual(tl.header, header) && reflect.DeepEqual(tl.footer, footer) {
+		return nil
+	}
+	headerText, err := tl.encodeText(&header)
+	if err != nil {
+		return err
+	}
+	footerText, err := tl.encodeText(&footer)
+	if err != nil {
+		return err
+	}
+	tl.header, tl.footer = header, footer
+	tl.headerText, tl.footerText = headerText, footerText
+	tl.pending = append(tl.pending, tl.headerFooterPacket())
+	return nil
+}
+
+// Packets returns the packets that send the whole list to a player who
+// hasn't seen it yet.
+func (tl *TabList) Packets() [][]byte {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	var packets [][]byte
+	if len(tl.entries) > 0 {
+		packets = append(packets, tl.updatePacket(tl.addActions(), tl.sorted()))
+	}
+	return append(packets, tl.headerFooterPacket())
+}
+
+func (tl *TabList) sorted() []*entry {
+	entries := make([]*entry, 0, len(tl.entries))
+	for _, e := range tl.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return string(entries[i].UUID[:]) < string(entries[j].UUID[:])
+	})
+	return entries
+}
+
+// encodeText encodes text as the layout sends it, or returns nil for no
+// text. Text is encoded when it is set so that building a packet can't fail.
+func (tl *TabList) encodeText(text *jsonutil.ChatObject) ([]byte, error) {
+	if text == nil {
+		return nil, nil
+	}
+	if tl.layout == LayoutJSON {
+		encoded, err := json.Marshal(text)
+		if err != nil {
+			return nil, fmt.Errorf("encoding tab list text: %w", err)
+		}
+		return encoded, nil
+	}
+	nw := nbtutil.CreateWriter()
+	if err := nw.WriteNetwork(text.Compound()); err != nil {
+		return nil, fmt.Errorf("encoding tab list text: %w", err)
+	}
+	return nw.Bytes(), nil
+}
+
+func (tl *TabList) writeText(pw *packetutil.PacketWriter, encoded []byte) {
+	if tl.layout == LayoutJSON {
+		pw.WriteString(string(encoded))
+		return
+	}
+	pw.WriteBytes(encoded)
+}
+
+func (tl *TabList) updatePacket(actions byte, entries []*entry) []byte {
+	pw := packetutil.CreatePacketWriter(tl.ids.PlayerInfoUpdate)
+	pw.WriteUnsignedByte(actions)
+	pw.WriteVarInt(int32(len(entries)))
+	for _, e := range entries {
+		pw.WriteBytes(e.UUID[:])
+		if actions&actionAddPlayer != 0 {
+			pw.WriteString(e.Name)
+			pw.WriteVarInt(int32(len(e.Properties)))
+			for _, property := range e.Properties {
+				pw.WriteString(property.Name)
+				pw.WriteString(property.Value)
+				pw.WriteBoolean(property.Signature != "")
+				if property.Signature != "" {
+					pw.WriteString(property.Signature)
+				}
+			}
+		}
+		if actions&actionGameMode != 0 {
+			pw.WriteVarInt(e.GameMode)
+		}
+		if actions&actionListed != 0 {
+			pw.WriteBoolean(e.Listed)
+		}
+		if actions&actionLatency != 0 {
+			pw.WriteVarInt(e.Latency)
+		}
+		if actions&actionDisplayName != 0 {
+			pw.WriteBoolean(e.shown != nil)
+			if e.shown != nil {
+				tl.writeText(pw, e.shownText)
+			}
+		}
+		if actions&actionListOrder != 0 {
+			pw.WriteVarInt(e.ListOrder)
+		}
+	}
+	return pw.GetPacket()
+}
+
+func (tl *TabList) headerFooterPacket() []byte {
+	pw := packetutil.CreatePacketWriter(tl.ids.HeaderFooter)
+	tl.writeText(pw, tl.headerText)
+	tl.writeText(pw, tl.footerText)
+	return pw.GetPacket()
+}
@@ -0,0 +1,326 @@
+package tickutil
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTPS is the tick rate of a vanilla server.
+const DefaultTPS = 20
+
+// DefaultMaxCatchUpTicks is how many ticks a Scheduler will run back to back
+// to catch up after falling behind, before giving up and skipping the rest.
+// It matches vanilla, which skips ahead once it is more than two seconds
+// behind.
+const DefaultMaxCatchUpTicks = 2 * DefaultTPS
+
+// sampleSize is the number of ticks TPS and MSPT are averaged over.
+const sampleSize = 100
+
+// Task is a function scheduled to run on a Scheduler.
+type Task struct {
+	fn        func()
+	owner     any
+	due       uint64
+	period    uint64
+	sequence  uint64
+	cancelled atomic.Bool
+}
+
+// Cancel stops the task from running again. A task that is currently running
+// finishes normally.
+func (t *Task) Cancel() {
+	t.cancelled.Store(true)
+}
+
+// Cancelled reports whether Cancel has been called on the task.
+func (t *Task) Cancelled() bool {
+	return t.cancelled.Load()
+}
+
+// Scheduler drives a fixed-rate game tick and runs scheduled tasks on it.
+// Tasks may be scheduled from any goroutine, but always run on the goroutine
+// calling Tick or Run, in the order they became due.
+type Scheduler struct {
+	lock     sync.Mutex
+	tick     uint64
+	sequence uint64
+	queue    taskQueue
+	// owners indexes the tasks that haven't finished by owner, including
+	// one that is running and so not in the queue.
+	owners map[any]map[*Task]struct{}
+
+	tickDuration time.Duration
+	// MaxCatchUpTicks is how many ticks Run may execute back to back when
+	// it falls behind. Beyond that the missed ticks are skipped.
+	MaxCatchUpTicks int
+	// OnTick, if set, is called at the start of every tick before any tasks
+	// run, with the number of the tick.
+	OnTick func(tick uint64)
+
+	statsLock    sync.Mutex
+	tickStarts   [sampleSize]time.Time
+	tickLengths  [sampleSize]time.Duration
+	samples      int
+	skippedTicks uint64
+}
+
+// CreateScheduler is a factory function for creating a new Scheduler running
+// at tps ticks per second, or DefaultTPS if tps is zero or less.
+func CreateScheduler(tps int) *Scheduler {
+	if tps <= 0 {
+		tps = DefaultTPS
+	}
+	s := new(Scheduler)
+	s.tickDuration = time.Second / time.Duration(tps)
+	s.MaxCatchUpTicks = DefaultMaxCatchUpTicks
+	s.owners = make(map[any]map[*Task]struct{})
+	return s
+}
+
+// RunLater schedules fn to run once, delay ticks from now. A delay of zero
+// runs it on the next tick.
+func (s *Scheduler) RunLater(delay uint64, fn func()) *Task {
+	return s.ScheduleFor(nil, delay, 0, fn)
+}
+
+// RunRepeating schedules fn to run delay ticks from now, and then every
+// period ticks until it is cancelled.
+func (s *Scheduler) RunRepeating(delay, period uint64, fn func()) *Task {
+	return s.ScheduleFor(nil, delay, period, fn)
+}
+
+// ScheduleFor schedules fn on behalf of owner, such as an entity or a
+// player, so that all of its tasks can be cancelled together with
+// CancelOwner. owner must be comparable, such as a pointer, or nil for a
+// task with no owner. A period of zero runs the task once.
+func (s *Scheduler) ScheduleFor(owner any, delay, period uint64, fn func()) *Task {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	task := new(Task)
+	task.fn = fn
+	task.owner = owner
+	task.due = s.tick + max(delay, 1)
+	task.period = period
+	task.sequence = s.sequence
+	s.sequence++
+
+	if owner != nil {
+		tasks, ok := s.owners[owner]
+		if !ok {
+			tasks = make(map[*Task]struct{})
+			s.owners[owner] = tasks
+		}
+		tasks[task] = struct{}{}
+	}
+	heap.Push(&s.queue, task)
+	return task
+}
+
+// forget drops a finished task from the owner index. s.lock must be held.
+func (s *Scheduler) forget(task *Task) {
+	if task.owner == nil {
+		return
+	}
+	tasks := s.owners[task.owner]
+	delete(tasks, task)
+	if len(tasks) == 0 {
+		delete(s.owners, task.owner)
+	}
+}
+
+// CancelOwner cancels every task scheduled for owner, including a repeating
+// one that is running now, which finishes but doesn't run again. It returns
+// how many were cancelled. Tasks without an owner aren't affected: a nil
+// owner cancels nothing.
+func (s *Scheduler) CancelOwner(owner any) int {
+	if owner == nil {
+		return 0
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	cancelled := 0
+	for task := range s.owners[owner] {
+		if !task.Cancelled() {
+			task.Cancel()
+			cancelled++
+		}
+	}
+	delete(s.owners, owner)
+	return cancelled
+}
+
+// CurrentTick returns the number of ticks run so far.
+func (s *Scheduler) CurrentTick() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.tick
+}
+
+// Tick advances the scheduler by a single tick and runs every task that has
+// become due. It is called by Run, but may also be called directly to drive
+// the scheduler from an existing loop or from tests.
+func (s *Scheduler) Tick() {
+	start := time.Now()
+
+	s.lock.Lock()
+	s.tick++
+	tick := s.tick
+	s.lock.Unlock()
+
+	if s.OnTick != nil {
+		s.OnTick(tick)
+	}
+
+	for {
+		task := s.popDue(tick)
+		if task == nil {
+			break
+		}
+
+		task.fn()
+
+		s.lock.Lock()
+		if task.period > 0 && !task.Cancelled() {
+			task.due = tick + task.period
+			heap.Push(&s.queue, task)
+		} else {
+			s.forget(task)
+		}
+		s.lock.Unlock()
+	}
+
+	s.recordTick(start, time.Since(start))
+}
+
+func (s *Scheduler) popDue(tick uint64) *Task {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for len(s.queue) > 0 && s.queue[0].due <= tick {
+		task := heap.Pop(&s.queue).(*Task)
+		if !task.Cancelled() {
+			return task
+		}
+		s.forget(task)
+	}
+	return nil
+}
+
+// Run ticks the scheduler at its configured rate until ctx is done. When a
+// tick overruns, the following ticks run back to back to catch up, up to
+// MaxCatchUpTicks; if the scheduler falls further behind than that, the
+// missed ticks are skipped and counted by SkippedTicks.
+func (s *Scheduler) Run(ctx context.Context) error {
+	next := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		s.Tick()
+		next = next.Add(s.tickDuration)
+
+		behind := time.Since(next)
+		if limit := time.Duration(s.MaxCatchUpTicks) * s.tickDuration; behind > limit {
+			skipped := uint64(behind / s.tickDuration)
+			s.statsLock.Lock()
+			s.skippedTicks += skipped
+			s.statsLock.Unlock()
+			next = next.Add(time.Duration(skipped) * s.tickDuration)
+		}
+
+		timer.Reset(time.Until(next))
+	}
+}
+
+func (s *Scheduler) recordTick(start time.Time, length time.Duration) {
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+
+	index := s.samples % sampleSize
+	s.tickStarts[index] = start
+	s.tickLengths[index] = length
+	s.samples++
+}
+
+// TPS returns the measured ticks per second over the last 100 ticks. It never
+// reports more than the configured rate.
+func (s *Scheduler) TPS() float64 {
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+
+	count := min(s.samples, sampleSize)
+	if count < 2 {
+		return 0
+	}
+	newest := s.tickStarts[(s.samples-1)%sampleSize]
+	oldest := s.tickStarts[(s.samples-count)%sampleSize]
+	elapsed := newest.Sub(oldest)
+	if elapsed <= 0 {
+		return 0
+	}
+
+	tps := float64(count-1) / elapsed.Seconds()
+	return min(tps, float64(time.Second)/float64(s.tickDuration))
+}
+
+// MSPT returns the mean time spent running a tick, over the last 100 ticks.
+func (s *Scheduler) MSPT() time.Duration {
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+
+	count := min(s.samples, sampleSize)
+	if count == 0 {
+		return 0
+	}
+	var total time.Duration
+	for i := 0; i < count; i++ {
+		total += s.tickLengths[i]
+	}
+	return total / time.Duration(count)
+}
+
+// SkippedTicks returns how many ticks Run has skipped because it fell too far
+// behind to catch up.
+func (s *Scheduler) SkippedTicks() uint64 {
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+	return s.skippedTicks
+}
+
+// taskQueue is a min-heap of tasks ordered by due tick, and then by the order
+// they were scheduled in.
+type taskQueue []*Task
+
+func (q taskQueue) Len() int { return len(q) }
+
+func (q taskQueue) Less(i, j int) bool {
+	if q[i].due != q[j].due {
+		return q[i].due < q[j].due
+	}
+	return q[i].sequence < q[j].sequence
+}
+
+func (q taskQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *taskQueue) Push(x any) { *q = append(*q, x.(*Task)) }
+
+func (q *taskQueue) Pop() any {
+	old := *q
+	task := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return task
+}
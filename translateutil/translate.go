@@ -0,0 +1,181 @@
+// Package translateutil rewrites packets between adjacent protocol versions,
+// so a server targeting one version can accept clients a version either
+// side, for packets whose differences are mechanical: remapped IDs, fields
+// added with a default, and the like.
+//
+// Each Step holds the rules between two versions, and a Chain strings steps
+// together between a client's version and the server's. States are named
+// as in conformanceutil: handshaking, status, login, configuration and play.
+package translateutil
+
+import (
+	"fmt"
+
+	"github.com/PurpurProject/elytra/packetutil"
+	"github.com/PurpurProject/elytra/sessionutil"
+)
+
+// Transformer rewrites a packet into the other version's form. It returns
+// no packets to drop it, or several to split it. A Transformer may modify
+// the packet it is given.
+type Transformer func(p *packetutil.Packet) ([]*packetutil.Packet, error)
+
+type ruleKey struct {
+	state     string
+	direction sessionutil.Direction
+	packetID  int32
+}
+
+type rule struct {
+	packetID  int32
+	transform Transformer
+}
+
+// Step translates packets between two protocol versions, Old and New. Rules
+// are looked up by the packet's ID in the version it is coming from;
+// packets without a rule pass through untouched.
+type Step struct {
+	Old, New int32
+
+	upgrade   map[ruleKey]rule
+	downgrade map[ruleKey]rule
+}
+
+// CreateStep is a factory function for creating a new Step with no rules.
+func CreateStep(oldVersion, newVersion int32) *Step {
+	s := new(Step)
+	s.Old = oldVersion
+	s.New = newVersion
+	s.upgrade = make(map[ruleKey]rule)
+	s.downgrade = make(map[ruleKey]rule)
+	return s
+}
+
+// Upgrade registers how a packet in the old version becomes newID in the
+// new version. transform may be nil if only the ID changes.
+func (s *Step) Upgrade(state string, direction sessionutil.Direction, oldID, newID int32, transform Transformer) {
+	s.upgrade[ruleKey{state, direction, oldID}] = rule{newID, transform}
+}
+
+// Downgrade registers how a packet in the new version becomes oldID in the
+// old version. transform may be nil if only the ID changes.
+func (s *Step) Downgrade(state string, direction sessionutil.Direction, newID, oldID int32, transform Transformer) {
+	s.downgrade[ruleKey{state, direction, newID}] = rule{oldID, transform}
+}
+
+// Remap registers a packet that is identical in both versions apart from its
+// ID.
+func (s *Step) Remap(state string, direction sessionutil.Direction, oldID, newID int32) {
+	s.Upgrade(state, direction, oldID, newID, nil)
+	s.Downgrade(state, direction, newID, oldID, nil)
+}
+
+// AddTrailingField registers a packet that gained a field at its end in the
+// new version. Upgrading appends def, the encoded default; downgrading cuts
+// off as many bytes, so the field must have a fixed size.
+func (s *Step) AddTrailingField(state string, direction sessionutil.Direction, oldID, newID int32, def []byte) {
+	s.Upgrade(state, direction, oldID, newID, func(p *packetutil.Packet) ([]*packetutil.Packet, error) {
+		p.Data = append(p.Data[:len(p.Data):len(p.Data)], def...)
+		return []*packetutil.Packet{p}, nil
+	})
+	s.Downgrade(state, direction, newID, oldID, func(p *packetutil.Packet) ([]*packetutil.Packet, error) {
+		if len(p.Data) < len(def) {
+			return nil, fmt.Errorf("packet 0x%02X is too short for its %d byte trailing field", p.ID, len(def))
+		}
+		p.Data = p.Data[:len(p.Data)-len(def)]
+		return []*packetutil.Packet{p}, nil
+	})
+}
+
+// apply runs one packet through a rule set.
+func apply(rules map[ruleKey]rule, state string, direction sessionutil.Direction, p *packetutil.Packet) ([]*packetutil.Packet, error) {
+	r, ok := rules[ruleKey{state, direction, p.ID}]
+	if !ok {
+		return []*packetutil.Packet{p}, nil
+	}
+	p.ID = r.packetID
+	if r.transform == nil {
+		return []*packetutil.Packet{p}, nil
+	}
+	return r.transform(p)
+}
+
+// Pipeline is the set of steps a server knows, from which a Chain can be
+// built for any client version they connect.
+type Pipeline struct {
+	steps map[int32]*Step
+}
+
+// CreatePipeline is a factory function for creating a new, empty Pipeline.
+func CreatePipeline() *Pipeline {
+	pl := new(Pipeline)
+	pl.steps = make(map[int32]*Step)
+	return pl
+}
+
+// Register adds a step, replacing any other step from the same old version.
+func (pl *Pipeline) Register(s *Step) {
+	pl.steps[s.Old] = s
+}
+
+// Chain strings together the steps between a client's protocol version and
+// the server's. It fails if some version in between has no step.
+func (pl *Pipeline) Chain(client, server int32) (*Chain, error) {
+	c := new(Chain)
+	c.upgradeClient = client < server
+
+	from, to := min(client, server), max(client, server)
+	for version := from; version != to; {
+		s, ok := pl.steps[version]
+		if !ok || s.New > to {
+			return nil, fmt.Errorf("no translation from protocol %d towards %d", version, to)
+		}
+		c.steps = append(c.steps, s)
+		version = s.New
+	}
+	return c, nil
+}
+
+// Chain translates the packets of one connection. A Chain between equal
+// versions passes everything through.
+type Chain struct {
+	// steps run from the older version to the newer.
+	steps []*Step
+	// upgradeClient is set when the client is the older end.
+	upgradeClient bool
+}
+
+// Serverbound translates a packet from the client into the server's
+// version.
+func (c *Chain) Serverbound(state string, p *packetutil.Packet) ([]*packetutil.Packet, error) {
+	return c.translate(state, sessionutil.Serverbound, c.upgradeClient, p)
+}
+
+// Clientbound translates a packet from the server into the client's
+// version.
+func (c *Chain) Clientbound(state string, p *packetutil.Packet) ([]*packetutil.Packet, error) {
+	return c.translate(state, sessionutil.Clientbound, !c.upgradeClient, p)
+}
+
+func (c *Chain) translate(state string, direction sessionutil.Direction, upgrade bool, p *packetutil.Packet) ([]*packetutil.Packet, error) {
+	packets := []*packetutil.Packet{p}
+	for i := range c.steps {
+		s := c.steps[i]
+		rules := s.upgrade
+		if !upgrade {
+			s = c.steps[len(c.steps)-1-i]
+			rules = s.downgrade
+		}
+
+		var next []*packetutil.Packet
+		for _, p := range packets {
+			out, err := apply(rules, state, direction, p)
+			if err != nil {
+				return nil, fmt.Errorf("translating between protocols %d and %d: %w", s.Old, s.New, err)
+			}
+			next = append(next, out...)
+		}
+		packets = next
+	}
+	return packets, nil
+}
@@ -0,0 +1,73 @@
+package worldgenutil
+
+// ChunkWriter receives the output of a ChunkGenerator. It is implemented by
+// whatever holds chunk data, so generators don't depend on a particular chunk
+// representation. Coordinates are local to the chunk horizontally, from 0 to
+// 15, and absolute vertically; blocks that are never set are air.
+type ChunkWriter interface {
+	SetBlock(x, y, z int, block string)
+	// SetBiome sets the biome of a 4×4×4 cell. Cell coordinates are block
+	// coordinates divided by four, so cellX and cellZ run from 0 to 3.
+	SetBiome(cellX, cellY, cellZ int, biome string)
+}
+
+// ChunkGenerator fills in newly created chunks. Implementations must be
+// deterministic, producing the same chunk for the same coordinates every time,
+// and safe to call from multiple goroutines at once.
+type ChunkGenerator interface {
+	GenerateChunk(chunkX, chunkZ int32, w ChunkWriter)
+}
+
+// FlatGenerator generates a superflat world from a preset, with the bottom
+// layer at MinY.
+type FlatGenerator struct {
+	Preset *FlatPreset
+	MinY   int
+	// Height is the height of the dimension, used to fill every biome cell
+	// and to cut off layers that don't fit.
+	Height int
+}
+
+// CreateFlatGenerator is a factory function for creating a new FlatGenerator
+// for an overworld-sized dimension, which starts at y=-64 and is 384 blocks
+// high.
+func CreateFlatGenerator(preset *FlatPreset) *FlatGenerator {
+	fg := new(FlatGenerator)
+	fg.Preset = preset
+	fg.MinY = -64
+	fg.Height = 384
+	return fg
+}
+
+func (fg *FlatGenerator) GenerateChunk(chunkX, chunkZ int32, w ChunkWriter) {
+	column := fg.Preset.Column()
+	// Layers above the top of the world are cut off, as vanilla does.
+	if len(column) > fg.Height {
+		column = column[:max(fg.Height, 0)]
+	}
+	for x := 0; x < 16; x++ {
+		for z := 0; z < 16; z++ {
+			for y, block := range column {
+				if block != "minecraft:air" {
+					w.SetBlock(x, fg.MinY+y, z, block)
+				}
+			}
+		}
+	}
+	fillBiome(w, fg.MinY, fg.Height, func(cellX, cellZ int) string {
+		return fg.Preset.Biome
+	})
+}
+
+// fillBiome sets the biome of every cell in the chunk, with the biome picked
+// per column of cells.
+func fillBiome(w ChunkWriter, minY, height int, biomeAt func(cellX, cellZ int) string) {
+	for cellX := 0; cellX < 4; cellX++ {
+		for cellZ := 0; cellZ < 4; cellZ++ {
+			biome := biomeAt(cellX, cellZ)
+			for cellY := minY >> 2; cellY < (minY+height)>>2; cellY++ {
+				w.SetBiome(cellX, cellY, cellZ, biome)
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package worldgenutil
+
+import (
+	"math"
+	"math/rand"
+)
+
+// PerlinNoise is Ken Perlin's improved gradient noise, with the permutation
+// table shuffled by a seed.
+type PerlinNoise struct {
+	perm [512]uint8
+	// The offsets break up the lattice alignment between octaves.
+	offsetX, offsetY, offsetZ float64
+}
+
+// CreatePerlinNoise is a factory function for creating a new PerlinNoise.
+func CreatePerlinNoise(seed int64) *PerlinNoise {
+	random := rand.New(rand.NewSource(seed))
+
+	pn := new(PerlinNoise)
+	pn.offsetX = random.Float64() * 256
+	pn.offsetY = random.Float64() * 256
+	pn.offsetZ = random.Float64() * 256
+	for i := 0; i < 256; i++ {
+		pn.perm[i] = uint8(i)
+	}
+	random.Shuffle(256, func(i, j int) {
+		pn.perm[i], pn.perm[j] = pn.perm[j], pn.perm[i]
+	})
+	copy(pn.perm[256:], pn.perm[:256])
+	return pn
+}
+
+// Noise3 returns the noise value at a point, roughly between -1 and 1.
+func (pn *PerlinNoise) Noise3(x, y, z float64) float64 {
+	x += pn.offsetX
+	y += pn.offsetY
+	z += pn.offsetZ
+
+	floorX, floorY, floorZ := math.Floor(x), math.Floor(y), math.Floor(z)
+	xi, yi, zi := int(floorX)&255, int(floorY)&255, int(floorZ)&255
+	x, y, z = x-floorX, y-floorY, z-floorZ
+	u, v, w := fade(x), fade(y), fade(z)
+
+	p := &pn.perm
+	a := int(p[xi]) + yi
+	aa, ab := int(p[a])+zi, int(p[a+1])+zi
+	b := int(p[xi+1]) + yi
+	ba, bb := int(p[b])+zi, int(p[b+1])+zi
+
+	return lerp(w,
+		lerp(v,
+			lerp(u, grad(p[aa], x, y, z), grad(p[ba], x-1, y, z)),
+			lerp(u, grad(p[ab], x, y-1, z), grad(p[bb], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, grad(p[aa+1], x, y, z-1), grad(p[ba+1], x-1, y, z-1)),
+			lerp(u, grad(p[ab+1], x, y-1, z-1), grad(p[bb+1], x-1, y-1, z-1))))
+}
+
+// Noise2 returns the noise value at a point on the plane.
+func (pn *PerlinNoise) Noise2(x, z float64) float64 {
+	return pn.Noise3(x, 0, z)
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+func grad(hash uint8, x, y, z float64) float64 {
+	h := hash & 15
+	u := y
+	if h < 8 {
+		u = x
+	}
+	v := z
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
+
+// OctaveNoise sums several layers of PerlinNoise, each at twice the frequency
+// and half the amplitude of the one before, and normalises the result back to
+// roughly between -1 and 1.
+type OctaveNoise struct {
+	octaves []*PerlinNoise
+}
+
+// CreateOctaveNoise is a factory function for creating a new OctaveNoise with
+// the given number of octaves.
+func CreateOctaveNoise(seed int64, octaves int) *OctaveNoise {
+	random := rand.New(rand.NewSource(seed))
+
+	on := new(OctaveNoise)
+	for i := 0; i < octaves; i++ {
+		on.octaves = append(on.octaves, CreatePerlinNoise(random.Int63()))
+	}
+	return on
+}
+
+// Noise2 returns the summed noise at a point on the plane.
+func (on *OctaveNoise) Noise2(x, z float64) float64 {
+	total, amplitude, frequency, norm := 0.0, 1.0, 1.0, 0.0
+	for _, octave := range on.octaves {
+		total += octave.Noise2(x*frequency, z*frequency) * amplitude
+		norm += amplitude
+		amplitude /= 2
+		frequency *= 2
+	}
+	if norm == 0 {
+		return 0
+	}
+	return total / norm
+}
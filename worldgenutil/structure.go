@@ -0,0 +1,523 @@
+package worldgenutil
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/PurpurProject/elytra/nbtutil"
+)
+
+// Rotation is a clockwise rotation around the vertical axis, in quarter
+// turns.
+type Rotation int
+
+const (
+	NoRotation Rotation = iota
+	Clockwise90
+	Clockwise180
+	CounterClockwise90
+)
+
+// Mirror flips a structure along one horizontal axis before it is rotated.
+// The names follow vanilla: left-right flips the z axis and front-back flips
+// the x axis.
+type Mirror int
+
+const (
+	NoMirror Mirror = iota
+	MirrorLeftRight
+	MirrorFrontBack
+)
+
+// BlockState is a block name with its state properties.
+type BlockState struct {
+	Name       string
+	Properties map[string]string
+}
+
+// String returns the block state in the form used by commands, such as
+// minecraft:oak_stairs[facing=east,half=bottom], with the properties sorted.
+func (bs BlockState) String() string {
+	if len(bs.Properties) == 0 {
+		return bs.Name
+	}
+
+	keys := make([]string, 0, len(bs.Properties))
+	for key := range bs.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(bs.Name)
+	sb.WriteByte('[')
+	for i, key := range keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(key)
+		sb.WriteByte('=')
+		sb.WriteString(bs.Properties[key])
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+var horizontalDirections = [4]string{"north", "east", "south", "west"}
+
+func rotateDirection(dir string, r Rotation) string {
+	for i, horizontal := range horizontalDirections {
+		if dir == horizontal {
+			return horizontalDirections[(i+int(r))&3]
+		}
+	}
+	return dir
+}
+
+func mirrorDirection(dir string, m Mirror) string {
+	switch {
+	case m == MirrorLeftRight && dir == "north":
+		return "south"
+	case m == MirrorLeftRight && dir == "south":
+		return "north"
+	case m == MirrorFrontBack && dir == "east":
+		return "west"
+	case m == MirrorFrontBack && dir == "west":
+		return "east"
+	}
+	return dir
+}
+
+// mapDirectionWords rewrites each direction in an underscore-separated value
+// such as north_up or ascending_east.
+func mapDirectionWords(val string, fn func(string) string) string {
+	words := strings.Split(val, "_")
+	for i, word := range words {
+		words[i] = fn(word)
+	}
+	return strings.Join(words, "_")
+}
+
+// canonicalRailShape puts a rail shape back into the order vanilla names it
+// in after its directions have been rewritten, so east_south becomes
+// south_east.
+func canonicalRailShape(shape string) string {
+	first, second, ok := strings.Cut(shape, "_")
+	if !ok || first == "ascending" || strings.Contains(second, "_") {
+		return shape
+	}
+	switch {
+	case (first == "south" && second == "north") || (first == "west" && second == "east"):
+		return second + "_" + first
+	case (first == "east" || first == "west") && (second == "north" || second == "south"):
+		return second + "_" + first
+	}
+	return shape
+}
+
+func (bs BlockState) transform(fn func(string) string, sides map[string]string, sixteenths func(int) int) BlockState {
+	if len(bs.Properties) == 0 {
+		return bs
+	}
+
+	transformed := BlockState{bs.Name, make(map[string]string, len(bs.Properties))}
+	for key, val := range bs.Properties {
+		switch key {
+		case "facing", "orientation":
+			val = mapDirectionWords(val, fn)
+		case "shape":
+			val = canonicalRailShape(mapDirectionWords(val, fn))
+		case "rotation":
+			var rotation int
+			if _, err := fmt.Sscan(val, &rotation); err == nil {
+				val = fmt.Sprint(sixteenths(rotation) & 15)
+			}
+		}
+		if side, ok := sides[key]; ok {
+			key = side
+		}
+		transformed.Properties[key] = val
+	}
+	return transformed
+}
+
+// Rotate returns the block state turned by r. It handles the properties
+// shared by most directional blocks: facing, orientation, axis, rail shapes,
+// the 0-15 rotation of signs, banners and heads, and the per-side properties
+// of fences, walls, panes and redstone.
+func (bs BlockState) Rotate(r Rotation) BlockState {
+	r &= 3
+	if r == NoRotation {
+		return bs
+	}
+
+	sides := make(map[string]string, 4)
+	for _, side := range horizontalDirections {
+		sides[side] = rotateDirection(side, r)
+	}
+	transformed := bs.transform(func(dir string) string {
+		return rotateDirection(dir, r)
+	}, sides, func(rotation int) int {
+		return rotation + int(r)*4
+	})
+
+	if axis := transformed.Properties["axis"]; r != Clockwise180 && (axis == "x" || axis == "z") {
+		transformed.Properties["axis"] = map[string]string{"x": "z", "z": "x"}[axis]
+	}
+	return transformed
+}
+
+// Mirror returns the block state flipped by m. On top of the properties
+// Rotate handles, left and right swap for door hinges, chest halves and
+// stair corners.
+func (bs BlockState) Mirror(m Mirror) BlockState {
+	if m == NoMirror {
+		return bs
+	}
+
+	sides := make(map[string]string, 4)
+	for _, side := range horizontalDirections {
+		sides[side] = mirrorDirection(side, m)
+	}
+	transformed := bs.transform(func(dir string) string {
+		return mirrorDirection(dir, m)
+	}, sides, func(rotation int) int {
+		if m == MirrorLeftRight {
+			return 8 - rotation + 16
+		}
+		return 16 - rotation
+	})
+
+	for _, key := range [3]string{"hinge", "type", "shape"} {
+		val, ok := transformed.Properties[key]
+		if !ok {
+			continue
+		}
+		switch {
+		case val == "left":
+			val = "right"
+		case val == "right":
+			val = "left"
+		case strings.HasSuffix(val, "_left"):
+			val = strings.TrimSuffix(val, "_left") + "_right"
+		case strings.HasSuffix(val, "_right"):
+			val = strings.TrimSuffix(val, "_right") + "_left"
+		}
+		transformed.Properties[key] = val
+	}
+	return transformed
+}
+
+// StructureBlock is a block in a StructureTemplate, positioned relative to
+// the template's origin corner.
+type StructureBlock struct {
+	X, Y, Z int
+	// State indexes into the template's palette.
+	State int
+	// NBT holds the block entity data, if the block has any.
+	NBT nbtutil.Compound
+}
+
+// StructureEntity is an entity in a StructureTemplate.
+type StructureEntity struct {
+	X, Y, Z                float64
+	BlockX, BlockY, BlockZ int
+	NBT                    nbtutil.Compound
+}
+
+// StructureTemplate is a vanilla structure file, as saved by structure blocks
+// and shipped in data packs.
+type StructureTemplate struct {
+	DataVersion         int32
+	SizeX, SizeY, SizeZ int
+	// Palettes holds the block states the blocks index into. Most templates
+	// have one; those with several, such as shipwrecks, pick one per
+	// placement.
+	Palettes [][]BlockState
+	Blocks   []StructureBlock
+	Entities []StructureEntity
+}
+
+// LoadStructureTemplate reads a structure template from a .nbt file, which is
+// normally gzip compressed.
+func LoadStructureTemplate(r io.Reader) (*StructureTemplate, error) {
+	_, root, err := nbtutil.ReadFile(r)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, fmt.Errorf("structure file is empty")
+	}
+	return ParseStructureTemplate(root)
+}
+
+func intTriple(c nbtutil.Compound, name string) ([3]int, bool) {
+	var triple [3]int
+	list, ok := c.List(name)
+	if !ok || list.Type != nbtutil.TagInt || len(list.Elements) != 3 {
+		return triple, false
+	}
+	for i, element := range list.Elements {
+		triple[i] = int(element.(int32))
+	}
+	return triple, true
+}
+
+func parsePalette(list nbtutil.List) ([]BlockState, error) {
+	if list.Type != nbtutil.TagCompound && len(list.Elements) > 0 {
+		return nil, fmt.Errorf("palette holds tags of type %d", list.Type)
+	}
+
+	palette := make([]BlockState, len(list.Elements))
+	for i, element := range list.Elements {
+		entry := element.(nbtutil.Compound)
+		name, ok := entry.String("Name")
+		if !ok {
+			return nil, fmt.Errorf("palette entry %d has no name", i)
+		}
+		palette[i].Name = name
+
+		if properties, ok := entry.Compound("Properties"); ok {
+			palette[i].Properties = make(map[string]string, len(properties))
+			for key, val := range properties {
+				if s, ok := val.(string); ok {
+					palette[i].Properties[key] = s
+				}
+			}
+		}
+	}
+	return palette, nil
+}
+
+// ParseStructureTemplate builds a StructureTemplate from the root compound of
+// a structure file.
+func ParseStructureTemplate(root nbtutil.Compound) (*StructureTemplate, error) {
+	st := new(StructureTemplate)
+	st.DataVersion, _ = root.Int("DataVersion")
+
+	size, ok := intTriple(root, "size")
+	if !ok {
+		return nil, fmt.Errorf("structure has no size")
+	}
+	st.SizeX, st.SizeY, st.SizeZ = size[0], size[1], size[2]
+
+	if list, ok := root.List("palette"); ok {
+		palette, err := parsePalette(list)
+		if err != nil {
+			return nil, err
+		}
+		st.Palettes = append(st.Palettes, palette)
+	} else if list, ok := root.List("palettes"); ok {
+		for _, element := range list.Elements {
+			inner, ok := element.(nbtutil.List)
+			if !ok {
+				return nil, fmt.Errorf("palettes holds a %T", element)
+			}
+			palette, err := parsePalette(inner)
+			if err != nil {
+				return nil, err
+			}
+			st.Palettes = append(st.Palettes, palette)
+		}
+	}
+	if len(st.Palettes) == 0 {
+		return nil, fmt.Errorf("structure has no palette")
+	}
+
+	blocks, _ := root.Compounds("blocks")
+	for i, entry := range blocks {
+		pos, ok := intTriple(entry, "pos")
+		if !ok {
+			return nil, fmt.Errorf("block %d has no position", i)
+		}
+		state, _ := entry.Int("state")
+		if state < 0 || int(state) >= len(st.Palettes[0]) {
+			return nil, fmt.Errorf("block %d has state %d, outside the palette", i, state)
+		}
+		nbt, _ := entry.Compound("nbt")
+		st.Blocks = append(st.Blocks, StructureBlock{pos[0], pos[1], pos[2], int(state), nbt})
+	}
+
+	entities, _ := root.Compounds("entities")
+	for i, entry := range entities {
+		pos, ok := entry.List("pos")
+		if !ok || pos.Type != nbtutil.TagDouble || len(pos.Elements) != 3 {
+			return nil, fmt.Errorf("entity %d has no position", i)
+		}
+		blockPos, _ := intTriple(entry, "blockPos")
+		nbt, _ := entry.Compound("nbt")
+		st.Entities = append(st.Entities, StructureEntity{
+			pos.Elements[0].(float64), pos.Elements[1].(float64), pos.Elements[2].(float64),
+			blockPos[0], blockPos[1], blockPos[2], nbt,
+		})
+	}
+	return st, nil
+}
+
+// PlaceSettings controls how a StructureTemplate is placed.
+type PlaceSettings struct {
+	Rotation Rotation
+	Mirror   Mirror
+	// PivotX and PivotZ are the point, relative to the template's origin,
+	// that it is rotated around.
+	PivotX, PivotZ int
+	// Palette picks between the palettes of templates that have several.
+	Palette int
+	// SkipAir leaves the world untouched where the template has air, rather
+	// than clearing it.
+	SkipAir bool
+}
+
+// transformPos applies the mirror and rotation to a position relative to the
+// template origin, the same way vanilla does.
+func (ps PlaceSettings) transformPos(x, y, z int) (int, int, int) {
+	switch ps.Mirror {
+	case MirrorLeftRight:
+		z = -z
+	case MirrorFrontBack:
+		x = -x
+	}
+
+	px, pz := ps.PivotX, ps.PivotZ
+	switch ps.Rotation & 3 {
+	case Clockwise90:
+		return px + pz - z, y, pz - px + x
+	case Clockwise180:
+		return px + px - x, y, pz + pz - z
+	case CounterClockwise90:
+		return px - pz + z, y, px + pz - x
+	}
+	return x, y, z
+}
+
+// transformVec is transformPos for entity positions, which are offset by a
+// block so an entity keeps its place inside the block it stands in.
+func (ps PlaceSettings) transformVec(x, y, z float64) (float64, float64, float64) {
+	switch ps.Mirror {
+	case MirrorLeftRight:
+		z = 1 - z
+	case MirrorFrontBack:
+		x = 1 - x
+	}
+
+	px, pz := float64(ps.PivotX), float64(ps.PivotZ)
+	switch ps.Rotation & 3 {
+	case Clockwise90:
+		return px + pz + 1 - z, y, pz - px + x
+	case Clockwise180:
+		return px + px + 1 - x, y, pz + pz + 1 - z
+	case CounterClockwise90:
+		return px - pz + z, y, px + pz + 1 - x
+	}
+	return x, y, z
+}
+
+// transformYaw turns an entity's yaw to match the placement.
+func (ps PlaceSettings) transformYaw(yaw float32) float32 {
+	switch ps.Mirror {
+	case MirrorLeftRight:
+		yaw = 180 - yaw
+	case MirrorFrontBack:
+		yaw = -yaw
+	}
+	yaw += float32(ps.Rotation&3) * 90
+	return float32(math.Mod(float64(yaw)+540, 360) - 180)
+}
+
+// PlacedBlock is a block of a placed StructureTemplate, in world coordinates.
+type PlacedBlock struct {
+	X, Y, Z int
+	State   BlockState
+	NBT     nbtutil.Compound
+}
+
+// PlacedEntity is an entity of a placed StructureTemplate, in world
+// coordinates. Its NBT is a copy with Pos and Rotation updated and the UUID
+// removed, so each placement spawns fresh entities.
+type PlacedEntity struct {
+	X, Y, Z float64
+	NBT     nbtutil.Compound
+}
+
+// Place returns the template's blocks transformed and moved to the given
+// world origin. Block entity data is copied with its x, y and z updated.
+// Structure voids are never placed.
+func (st *StructureTemplate) Place(originX, originY, originZ int, settings PlaceSettings) ([]PlacedBlock, error) {
+	if settings.Palette < 0 || settings.Palette >= len(st.Palettes) {
+		return nil, fmt.Errorf("palette %d is out of range, the template has %d", settings.Palette, len(st.Palettes))
+	}
+
+	palette := st.Palettes[settings.Palette]
+	states := make([]BlockState, len(palette))
+	for i, state := range palette {
+		states[i] = state.Mirror(settings.Mirror).Rotate(settings.Rotation)
+	}
+
+	placed := make([]PlacedBlock, 0, len(st.Blocks))
+	for _, block := range st.Blocks {
+		if block.State >= len(states) {
+			return nil, fmt.Errorf("block state %d is outside palette %d", block.State, settings.Palette)
+		}
+		state := states[block.State]
+		if state.Name == "minecraft:structure_void" || (settings.SkipAir && state.Name == "minecraft:air") {
+			continue
+		}
+
+		x, y, z := settings.transformPos(block.X, block.Y, block.Z)
+		x, y, z = x+originX, y+originY, z+originZ
+
+		var nbt nbtutil.Compound
+		if block.NBT != nil {
+			nbt = block.NBT.Clone()
+			nbt["x"], nbt["y"], nbt["z"] = int32(x), int32(y), int32(z)
+		}
+		placed = append(placed, PlacedBlock{x, y, z, state, nbt})
+	}
+	return placed, nil
+}
+
+// PlaceEntities returns the template's entities transformed and moved to the
+// given world origin.
+func (st *StructureTemplate) PlaceEntities(originX, originY, originZ int, settings PlaceSettings) []PlacedEntity {
+	placed := make([]PlacedEntity, 0, len(st.Entities))
+	for _, entity := range st.Entities {
+		x, y, z := settings.transformVec(entity.X, entity.Y, entity.Z)
+		x, y, z = x+float64(originX), y+float64(originY), z+float64(originZ)
+
+		var nbt nbtutil.Compound
+		if entity.NBT != nil {
+			nbt = entity.NBT.Clone()
+		} else {
+			nbt = make(nbtutil.Compound)
+		}
+		delete(nbt, "UUID")
+		nbt["Pos"] = nbtutil.CreateList(x, y, z)
+		if rotation, ok := nbt.List("Rotation"); ok && rotation.Type == nbtutil.TagFloat && len(rotation.Elements) == 2 {
+			nbt["Rotation"] = nbtutil.CreateList(settings.transformYaw(rotation.Elements[0].(float32)), rotation.Elements[1])
+		}
+		placed = append(placed, PlacedEntity{x, y, z, nbt})
+	}
+	return placed
+}
+
+// PlaceInChunk writes the part of the placed template that falls inside one
+// chunk, so a ChunkGenerator can stamp structures that cross chunk borders
+// one chunk at a time. Block entity data can't be passed through a
+// ChunkWriter; callers that need it should use Place.
+func (st *StructureTemplate) PlaceInChunk(originX, originY, originZ int, settings PlaceSettings, chunkX, chunkZ int32, w ChunkWriter) error {
+	blocks, err := st.Place(originX, originY, originZ, settings)
+	if err != nil {
+		return err
+	}
+
+	for _, block := range blocks {
+		if int32(block.X>>4) != chunkX || int32(block.Z>>4) != chunkZ {
+			continue
+		}
+		w.SetBlock(block.X&15, block.Y, block.Z&15, block.State.String())
+	}
+	return nil
+}
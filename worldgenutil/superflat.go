@@ -0,0 +1,159 @@
+package worldgenutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultFlatPreset is the preset vanilla uses for a new superflat world.
+const DefaultFlatPreset = "minecraft:bedrock,2*minecraft:dirt,minecraft:grass_block;minecraft:plains"
+
+// maxFlatHeight is the tallest stack of layers vanilla accepts, which is the
+// largest height a dimension can have.
+const maxFlatHeight = 4064
+
+// FlatLayer is a run of identical blocks in a superflat preset.
+type FlatLayer struct {
+	Block  string
+	Height int
+}
+
+// FlatPreset is a parsed superflat preset string, with the layers listed
+// from the bottom of the world up.
+type FlatPreset struct {
+	Layers []FlatLayer
+	Biome  string
+	// Structures lists the structure options of presets from before 1.16,
+	// such as village or stronghold, which newer versions ignore.
+	Structures []string
+}
+
+// ParseFlatPreset parses a superflat preset string in the form shown by the
+// vanilla customisation screen, such as
+//
+//	minecraft:bedrock,2*minecraft:dirt,minecraft:grass_block;minecraft:plains
+//
+// The older form with a leading version number, "3;" followed by the layers,
+// the biome and then the structures, is accepted too. Block and biome names
+// without a namespace default to minecraft, and the biome defaults to plains.
+// Numeric block and biome IDs from before 1.13 are not translated.
+func ParseFlatPreset(preset string) (*FlatPreset, error) {
+	parts := strings.Split(strings.TrimSpace(preset), ";")
+	if len(parts) > 1 {
+		if _, err := strconv.Atoi(parts[0]); err == nil {
+			parts = parts[1:]
+		}
+	}
+
+	fp := new(FlatPreset)
+	fp.Biome = "minecraft:plains"
+
+	total := 0
+	for _, entry := range strings.Split(parts[0], ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		layer, err := parseFlatLayer(entry)
+		if err != nil {
+			return nil, err
+		}
+		total += layer.Height
+		if total > maxFlatHeight {
+			return nil, fmt.Errorf("superflat layers are %d blocks high, the limit is %d", total, maxFlatHeight)
+		}
+		fp.Layers = append(fp.Layers, layer)
+	}
+
+	if len(parts) > 1 && strings.TrimSpace(parts[1]) != "" {
+		fp.Biome = withDefaultNamespace(strings.TrimSpace(parts[1]))
+	}
+	if len(parts) > 2 {
+		for _, structure := range strings.Split(parts[2], ",") {
+			if structure = strings.TrimSpace(structure); structure != "" {
+				fp.Structures = append(fp.Structures, structure)
+			}
+		}
+	}
+
+	return fp, nil
+}
+
+func parseFlatLayer(entry string) (FlatLayer, error) {
+	layer := FlatLayer{Block: entry, Height: 1}
+
+	// Older presets write the count as 2x, newer ones as 2*.
+	if i := strings.IndexAny(entry, "*x"); i > 0 {
+		if count, err := strconv.Atoi(entry[:i]); err == nil {
+			if count <= 0 {
+				return layer, fmt.Errorf("layer %q has a height of %d", entry, count)
+			}
+			layer.Height = count
+			layer.Block = strings.TrimSpace(entry[i+1:])
+		}
+	}
+
+	if layer.Block == "" {
+		return layer, fmt.Errorf("layer %q has no block", entry)
+	}
+	layer.Block = withDefaultNamespace(layer.Block)
+	return layer, nil
+}
+
+func withDefaultNamespace(name string) string {
+	if strings.Contains(name, ":") {
+		return name
+	}
+	return "minecraft:" + name
+}
+
+// String formats the preset in the modern form, which vanilla accepts back.
+func (fp *FlatPreset) String() string {
+	layers := make([]string, len(fp.Layers))
+	for i, layer := range fp.Layers {
+		if layer.Height == 1 {
+			layers[i] = layer.Block
+		} else {
+			layers[i] = fmt.Sprintf("%d*%s", layer.Height, layer.Block)
+		}
+	}
+	return strings.Join(layers, ",") + ";" + fp.Biome
+}
+
+// Height returns the combined height of every layer.
+func (fp *FlatPreset) Height() int {
+	total := 0
+	for _, layer := range fp.Layers {
+		total += layer.Height
+	}
+	return total
+}
+
+// BlockAt returns the block y blocks above the bottom of the world, or false
+// if y is above the top layer.
+func (fp *FlatPreset) BlockAt(y int) (string, bool) {
+	if y < 0 {
+		return "", false
+	}
+	for _, layer := range fp.Layers {
+		if y < layer.Height {
+			return layer.Block, true
+		}
+		y -= layer.Height
+	}
+	return "", false
+}
+
+// Column returns the block at every height of a column, from the bottom of
+// the world up to the top layer. Every column of a superflat world is the
+// same, so this is all a chunk generator needs to fill a chunk.
+func (fp *FlatPreset) Column() []string {
+	column := make([]string, 0, fp.Height())
+	for _, layer := range fp.Layers {
+		for i := 0; i < layer.Height; i++ {
+			column = append(column, layer.Block)
+		}
+	}
+	return column
+}
@@ -0,0 +1,116 @@
+package worldgenutil
+
+import "math"
+
+// TerrainGenerator is a reference noise-based ChunkGenerator. It builds a
+// heightmap from octave noise, floods everything below sea level, and picks a
+// handful of basic biomes from temperature and humidity noise. It makes no
+// attempt to match vanilla terrain, but gives demo servers something other
+// than a flat world.
+type TerrainGenerator struct {
+	MinY     int
+	Height   int
+	SeaLevel int
+	// BaseHeight is the average height of the land surface, and Amplitude
+	// how far it strays from that.
+	BaseHeight int
+	Amplitude  float64
+	// Scale is the horizontal size of terrain features, in blocks.
+	Scale float64
+
+	height      *OctaveNoise
+	temperature *OctaveNoise
+	humidity    *OctaveNoise
+}
+
+// CreateTerrainGenerator is a factory function for creating a new
+// TerrainGenerator for an overworld-sized dimension.
+func CreateTerrainGenerator(seed int64) *TerrainGenerator {
+	tg := new(TerrainGenerator)
+	tg.MinY = -64
+	tg.Height = 384
+	tg.SeaLevel = 63
+	tg.BaseHeight = 66
+	tg.Amplitude = 64
+	tg.Scale = 256
+	tg.height = CreateOctaveNoise(seed, 6)
+	tg.temperature = CreateOctaveNoise(seed+1, 2)
+	tg.humidity = CreateOctaveNoise(seed+2, 2)
+	return tg
+}
+
+// SurfaceHeight returns the y of the topmost solid block at the given world
+// column.
+func (tg *TerrainGenerator) SurfaceHeight(x, z int) int {
+	n := tg.height.Noise2(float64(x)/tg.Scale, float64(z)/tg.Scale)
+	surface := tg.BaseHeight + int(math.Round(n*tg.Amplitude))
+	return min(max(surface, tg.MinY+1), tg.MinY+tg.Height-1)
+}
+
+// Biome returns the biome at the given world column.
+func (tg *TerrainGenerator) Biome(x, z int) string {
+	surface := tg.SurfaceHeight(x, z)
+	switch {
+	case surface < tg.SeaLevel-1:
+		return "minecraft:ocean"
+	case surface <= tg.SeaLevel+1:
+		return "minecraft:beach"
+	}
+
+	climateScale := tg.Scale * 4
+	temperature := tg.temperature.Noise2(float64(x)/climateScale, float64(z)/climateScale)
+	humidity := tg.humidity.Noise2(float64(x)/climateScale, float64(z)/climateScale)
+	switch {
+	case temperature < -0.25:
+		return "minecraft:snowy_plains"
+	case temperature > 0.25 && humidity < 0:
+		return "minecraft:desert"
+	case humidity > 0.15:
+		return "minecraft:forest"
+	}
+	return "minecraft:plains"
+}
+
+// surfaceBlocks returns the top block and the filler below it for a biome.
+func surfaceBlocks(biome string, underwater bool) (string, string) {
+	switch {
+	case biome == "minecraft:desert" || biome == "minecraft:beach":
+		return "minecraft:sand", "minecraft:sandstone"
+	case underwater || biome == "minecraft:ocean":
+		return "minecraft:gravel", "minecraft:dirt"
+	case biome == "minecraft:snowy_plains":
+		return "minecraft:snow_block", "minecraft:dirt"
+	}
+	return "minecraft:grass_block", "minecraft:dirt"
+}
+
+func (tg *TerrainGenerator) GenerateChunk(chunkX, chunkZ int32, w ChunkWriter) {
+	baseX, baseZ := int(chunkX)*16, int(chunkZ)*16
+
+	for x := 0; x < 16; x++ {
+		for z := 0; z < 16; z++ {
+			worldX, worldZ := baseX+x, baseZ+z
+			surface := tg.SurfaceHeight(worldX, worldZ)
+			top, filler := surfaceBlocks(tg.Biome(worldX, worldZ), surface < tg.SeaLevel)
+
+			w.SetBlock(x, tg.MinY, z, "minecraft:bedrock")
+			for y := tg.MinY + 1; y <= surface; y++ {
+				switch {
+				case y == surface:
+					w.SetBlock(x, y, z, top)
+				case y > surface-4:
+					w.SetBlock(x, y, z, filler)
+				default:
+					w.SetBlock(x, y, z, "minecraft:stone")
+				}
+			}
+			for y := surface + 1; y <= tg.SeaLevel; y++ {
+				w.SetBlock(x, y, z, "minecraft:water")
+			}
+		}
+	}
+
+	fillBiome(w, tg.MinY, tg.Height, func(cellX, cellZ int) string {
+		return tg.Biome(baseX+cellX*4+2, baseZ+cellZ*4+2)
+	})
+}